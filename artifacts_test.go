@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// The violations the tests find are saved to the working directory by
+// default, named for the round and the time of day. With -out-dir, each
+// run saves them to a directory of its own there, named for -run-name or
+// the time the run started, with a directory per test, and
+// -keep-violations keeps only the newest violations of all the runs in it:
+//
+//	go test -run 'TestSyncMap$' -out-dir=violations -keep-violations=20
+var (
+	outDirFlag  = flag.String("out-dir", "", "directory of the per-run directories the violations and their traces are saved to, the working directory if empty")
+	runNameFlag = flag.String("run-name", "", "name of the directory of this run in -out-dir, the time the run started if empty")
+	keepFlag    = flag.Int("keep-violations", 0, "violations kept in -out-dir across runs, the newest ones, 0 for all")
+)
+
+// Only a violation is visualized by default. To see what the histories of
+// a workload look like when they pass, -sample=<n> saves the visualization
+// of a random one in n of its rounds that pass as well, chosen by their
+// seeds, next to where a violation would be:
+//
+//	go test -run 'TestSyncMap$' -rounds=10000 -sample=1000
+var sampleFlag = flag.Int("sample", 0, "save the visualization of one in this many passing workload rounds, 0 for none")
+
+// runStart names the directory of the run without -run-name.
+var runStart = time.Now()
+
+// runName returns the name of the directory of this run in -out-dir.
+func runName() string {
+	if *runNameFlag != "" {
+		return *runNameFlag
+	}
+	return runStart.Format("20060102-150405")
+}
+
+// artifact returns the path the test t saves the file name to, in the
+// directory of t in that of the run with -out-dir, which it creates.
+func artifact(t *testing.T, name string) string {
+	if *outDirFlag == "" {
+		return name
+	}
+	dir := filepath.Join(*outDirFlag, runName(), strings.ReplaceAll(t.Name(), "/", "_"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, name)
+}
+
+// saveSample writes the visualization of the passing round s of impl to
+// a file.
+func saveSample(t *testing.T, impl string, s *workload.Sample) {
+	filename := artifact(t, fmt.Sprintf("%s_sample_%d_%s.html", workload.FilePrefix(impl), s.Round, time.Now().Format("150405")))
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Errorf("Round %d (seed %d): %v", s.Round, s.Seed, err)
+		return
+	}
+	defer file.Close()
+	if err := s.Visualize(file); err != nil {
+		t.Errorf("Round %d (seed %d): %s: %v", s.Round, s.Seed, filename, err)
+		return
+	}
+	t.Logf("Round %d (seed %d): sample saved to %s", s.Round, s.Seed, filename)
+}
+
+// violationFiles are the suffixes of the files saved for one violation,
+// after the name of its visualization without .html.
+var violationFiles = []string{".html", ".json", ".svg", ".png", ".trace", "_traced.html", "_traced.json"}
+
+// pruneViolations removes all but the newest -keep-violations violations
+// from -out-dir, each with the files saved next to it, and the directories
+// that leaves empty. It never touches the working directory.
+func pruneViolations(t *testing.T) {
+	if *outDirFlag == "" || *keepFlag <= 0 {
+		return
+	}
+	type saved struct {
+		base string
+		mod  time.Time
+	}
+	var all []saved
+	err := filepath.WalkDir(*outDirFlag, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if !strings.Contains(name, "_violation_") || !strings.HasSuffix(name, ".html") || strings.HasSuffix(name, "_traced.html") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		all = append(all, saved{strings.TrimSuffix(path, ".html"), info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		t.Errorf("-keep-violations: %v", err)
+		return
+	}
+	// Newest first, by name at equal times.
+	slices.SortFunc(all, func(a, b saved) int {
+		if c := b.mod.Compare(a.mod); c != 0 {
+			return c
+		}
+		return strings.Compare(b.base, a.base)
+	})
+	for _, s := range all[min(*keepFlag, len(all)):] {
+		for _, suffix := range violationFiles {
+			if err := os.Remove(s.base + suffix); err != nil && !os.IsNotExist(err) {
+				t.Errorf("-keep-violations: %v", err)
+			}
+		}
+		// The directories of the test and of the run, if empty now.
+		for dir := filepath.Dir(s.base); dir != filepath.Clean(*outDirFlag) && dir != "."; dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+}
+
+func TestPruneViolations(t *testing.T) {
+	dir := t.TempDir()
+	defer func(out string, keep int) { *outDirFlag, *keepFlag = out, keep }(*outDirFlag, *keepFlag)
+	*outDirFlag, *keepFlag = dir, 2
+
+	// Three violations of two runs, oldest first, each with its history and trace.
+	bases := []string{
+		filepath.Join(dir, "run1", "TestSyncMap", "syncmap_violation_3_150405"),
+		filepath.Join(dir, "run2", "TestSyncMap", "syncmap_violation_1_160000"),
+		filepath.Join(dir, "run2", "TestSyncMapOf", "syncmapof_violation_2_160001"),
+	}
+	start := time.Now().Add(-time.Hour)
+	for i, base := range bases {
+		if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, suffix := range []string{".html", ".json", ".trace"} {
+			file := base + suffix
+			if err := os.WriteFile(file, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mod := start.Add(time.Duration(i) * time.Minute)
+			if err := os.Chtimes(file, mod, mod); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	pruneViolations(t)
+
+	if _, err := os.Stat(filepath.Join(dir, "run1")); !os.IsNotExist(err) {
+		t.Errorf("the directory of the run of the oldest violation is left: %v", err)
+	}
+	for _, base := range bases[1:] {
+		if _, err := os.Stat(base + ".json"); err != nil {
+			t.Errorf("newer violation removed: %v", err)
+		}
+	}
+	if name := artifact(t, "x.html"); name != filepath.Join(dir, runName(), "TestPruneViolations", "x.html") {
+		t.Errorf("artifact = %s", name)
+	}
+}
@@ -0,0 +1,212 @@
+// Package bruteforce is a linearizability checker short enough to be
+// trusted on sight, to check porcupine and the models of this module
+// with: it tries the operations of a history in every order their calls
+// and returns allow, one after another, without porcupine's caches or
+// its pruning. That is exponential in the operations of a history, so it
+// is only for small ones, up to MaxOperations, and CrossCheck compares its
+// verdict with porcupine's on histories of Random.
+package bruteforce
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// MaxOperations is the length of the longest history Check takes, past
+// which trying every order takes too long.
+const MaxOperations = 15
+
+// Check reports whether history is linearizable with m: whether, for
+// every partition of m, some order of its operations in which none goes
+// before an operation that returned before its call is accepted by m,
+// step by step. As in porcupine, an operation called at the time another
+// returns may go before it.
+func Check(m porcupine.Model, history []porcupine.Operation) (bool, error) {
+	if len(history) > MaxOperations {
+		return false, fmt.Errorf("bruteforce: %d operations, at most %d", len(history), MaxOperations)
+	}
+	parts := [][]porcupine.Operation{history}
+	if m.Partition != nil {
+		parts = m.Partition(history)
+	}
+	for _, part := range parts {
+		if !search(m, part, 0, m.Init()) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// search reports whether the operations of ops not in done can follow
+// them in state.
+func search(m porcupine.Model, ops []porcupine.Operation, done uint32, state any) bool {
+	if done == 1<<len(ops)-1 {
+		return true
+	}
+	// The first return of those left bounds the calls that can go next.
+	end := int64(math.MaxInt64)
+	for i, op := range ops {
+		if done&(1<<i) == 0 {
+			end = min(end, op.Return)
+		}
+	}
+	for i, op := range ops {
+		if done&(1<<i) != 0 || op.Call > end {
+			continue
+		}
+		if ok, next := m.Step(state, op.Input, op.Output); ok && search(m, ops, done|1<<i, next) {
+			return true
+		}
+	}
+	return false
+}
+
+// A Mismatch is a history on which porcupine and Check disagree.
+type Mismatch struct {
+	History      []porcupine.Operation
+	Porcupine    porcupine.CheckResult
+	Linearizable bool
+}
+
+func (e *Mismatch) Error() string {
+	verdict := "not linearizable"
+	if e.Linearizable {
+		verdict = "linearizable"
+	}
+	return fmt.Sprintf("bruteforce: porcupine finds a history of %d operations %s, the brute force %s", len(e.History), e.Porcupine, verdict)
+}
+
+// CrossCheck checks history with porcupine and with Check, and returns a
+// *Mismatch if they disagree. A check of porcupine that times out, after
+// timeout unless it is 0, agrees with either.
+func CrossCheck(m porcupine.Model, history []porcupine.Operation, timeout time.Duration) error {
+	linearizable, err := Check(m, history)
+	if err != nil {
+		return err
+	}
+	result := porcupine.CheckOperationsTimeout(m, history, timeout)
+	if result == porcupine.Unknown || (result == porcupine.Ok) == linearizable {
+		return nil
+	}
+	return &Mismatch{History: history, Porcupine: result, Linearizable: linearizable}
+}
+
+// Random returns a history of n operations of model.Model by clients on
+// keys, with values that collide, as a sync.Map runs them: the operations
+// take effect, one after another on a sync.Map, at points in increasing
+// order, and every client calls each of its operations after the previous
+// one returned, at random before its point, and returns at random after
+// it. Such a history is linearizable. Half of them then have the output
+// of one operation changed, which makes most of those not linearizable,
+// and some operations are left pending, by a client of their own.
+func Random(rng *rand.Rand, n, clients, keys int) []porcupine.Operation {
+	const spacing = 10
+	var (
+		m       sync.Map
+		ops     = make([]porcupine.Operation, n)
+		owners  = make([]int, n)
+		all     = model.Ops()
+		pending = clients
+	)
+	for i := range ops {
+		in := model.Input{Op: all[rng.IntN(len(all))], Key: fmt.Sprintf("k%d", rng.IntN(keys)), Val: rng.IntN(4)}
+		switch in.Op {
+		case model.Range:
+			in.Key, in.Val = "", 0
+		case model.CompareAndSwap:
+			in.Old = rng.IntN(4)
+		}
+		owners[i] = rng.IntN(clients)
+		ops[i] = porcupine.Operation{ClientId: owners[i], Input: in, Output: apply(&m, in)}
+	}
+
+	// The point of operation i is spacing*(i+1), its call after the return
+	// of the previous operation of its client and its return before the
+	// point of the next one.
+	last := make([]int64, clients)
+	for i := range ops {
+		point := int64(spacing * (i + 1))
+		next := int64(spacing * (n + 1))
+		for j := i + 1; j < n; j++ {
+			if owners[j] == owners[i] {
+				next = int64(spacing * (j + 1))
+				break
+			}
+		}
+		ops[i].Call = point - rng.Int64N(point-last[owners[i]])
+		ops[i].Return = point + rng.Int64N(next-point)
+		last[owners[i]] = ops[i].Return
+	}
+	end := int64(spacing * (n + 2))
+	for i := range ops {
+		if rng.IntN(8) == 0 {
+			// What it returned is unknown, and its client may have called
+			// the next operation before it returned, so another client
+			// made it.
+			ops[i].ClientId, ops[i].Output, ops[i].Return = pending, model.Output{Pending: true}, end
+			pending++
+		}
+	}
+	if n > 0 && rng.IntN(2) == 0 {
+		i := rng.IntN(n)
+		ops[i].Output = corrupt(rng, ops[i].Input.(model.Input), ops[i].Output.(model.Output))
+	}
+	return ops
+}
+
+// apply runs in on m and returns its output.
+func apply(m *sync.Map, in model.Input) model.Output {
+	var (
+		out model.Output
+		v   any
+	)
+	switch in.Op {
+	case model.Load:
+		v, out.Found = m.Load(in.Key)
+	case model.Store:
+		m.Store(in.Key, in.Val)
+	case model.LoadOrStore:
+		v, out.Found = m.LoadOrStore(in.Key, in.Val)
+	case model.LoadAndDelete:
+		v, out.Found = m.LoadAndDelete(in.Key)
+	case model.Swap:
+		v, out.Found = m.Swap(in.Key, in.Val)
+	case model.CompareAndSwap:
+		out.Found = m.CompareAndSwap(in.Key, in.Old, in.Val)
+	case model.Range:
+		out.Entries = make(map[string]int)
+		m.Range(func(k, v any) bool {
+			out.Entries[k.(string)] = v.(int)
+			return true
+		})
+	}
+	if out.Found && in.Op != model.CompareAndSwap {
+		out.Val = v.(int)
+	}
+	return out
+}
+
+// corrupt returns an output of in other than out: found flipped, another
+// value loaded or, for a Range, another value of any key it visited or
+// one it did not.
+func corrupt(rng *rand.Rand, in model.Input, out model.Output) model.Output {
+	switch {
+	case out.Pending:
+		return out
+	case in.Op == model.Range:
+		entries := maps.Clone(out.Entries)
+		entries[fmt.Sprintf("k%d", rng.IntN(2))] = 4
+		return model.Output{Entries: entries}
+	case out.Found && in.Op != model.CompareAndSwap && rng.IntN(2) == 0:
+		return model.Output{Found: true, Val: (out.Val + 1 + rng.IntN(3)) % 4}
+	}
+	return model.Output{Found: !out.Found, Val: rng.IntN(4)}
+}
@@ -0,0 +1,109 @@
+package bruteforce
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestCheck(t *testing.T) {
+	store := porcupine.Operation{ClientId: 0, Input: model.Input{Op: model.Store, Key: "k", Val: 1}, Call: 0, Output: model.Output{}, Return: 10}
+	load := func(call, ret int64, found bool) porcupine.Operation {
+		out := model.Output{}
+		if found {
+			out = model.Output{Found: true, Val: 1}
+		}
+		return porcupine.Operation{ClientId: 1, Input: model.Input{Op: model.Load, Key: "k"}, Call: call, Output: out, Return: ret}
+	}
+	for _, tc := range []struct {
+		name    string
+		history []porcupine.Operation
+		ok      bool
+	}{
+		{"load after store", []porcupine.Operation{store, load(20, 30, true)}, true},
+		{"stale load", []porcupine.Operation{store, load(20, 30, false)}, false},
+		{"overlapping load", []porcupine.Operation{store, load(5, 30, false)}, true},
+		// Called as the Store returns, the Load may still go first.
+		{"load at the return", []porcupine.Operation{store, load(10, 30, false)}, true},
+		{"load before store", []porcupine.Operation{load(-20, -10, true), store}, false},
+	} {
+		ok, err := Check(model.Model, tc.history)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tc.ok {
+			t.Errorf("%s: Check = %v, want %v", tc.name, ok, tc.ok)
+		}
+		if want := porcupine.CheckOperations(model.Model, tc.history); ok != want {
+			t.Errorf("%s: Check = %v, porcupine %v", tc.name, ok, want)
+		}
+	}
+	if _, err := Check(model.Model, make([]porcupine.Operation, MaxOperations+1)); err == nil {
+		t.Error("Check took a history longer than MaxOperations")
+	}
+}
+
+func TestRandom(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var linearizable, changed int
+	for range 200 {
+		h := Random(rng, 10, 3, 2)
+		if len(h) != 10 {
+			t.Fatalf("%d operations, want 10", len(h))
+		}
+		ok, err := Check(model.Model, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			linearizable++
+		} else {
+			changed++
+		}
+	}
+	// Half have an output changed, and most of those are not linearizable.
+	if linearizable < 100 || changed < 50 {
+		t.Errorf("%d linearizable and %d not of 200 histories", linearizable, changed)
+	}
+}
+
+// Porcupine agrees with the brute force on small random histories, with
+// either model.
+func TestCrossCheck(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	for name, m := range map[string]porcupine.Model{"map": model.Model, "snapshot": model.Snapshot} {
+		for range 300 {
+			h := Random(rng, 1+rng.IntN(12), 1+rng.IntN(4), 1+rng.IntN(3))
+			if err := CrossCheck(m, h, 0); err != nil {
+				var mm *Mismatch
+				if errors.As(err, &mm) {
+					t.Fatalf("%s: %v:\n%v", name, err, mm.History)
+				}
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// A model whose Equal is wrong misleads the cache of porcupine's search,
+// which the brute force does without.
+func TestCrossCheckMismatch(t *testing.T) {
+	m := model.Snapshot
+	m.Equal = func(a, b any) bool { return true }
+	rng := rand.New(rand.NewPCG(5, 6))
+	for range 2000 {
+		var mm *Mismatch
+		if err := CrossCheck(m, Random(rng, 8, 3, 2), 0); errors.As(err, &mm) {
+			if mm.Porcupine != porcupine.Illegal || !mm.Linearizable || !strings.Contains(err.Error(), "Illegal, the brute force linearizable") {
+				t.Errorf("mismatch %v", err)
+			}
+			return
+		}
+	}
+	t.Error("no mismatch with a model whose states are all equal")
+}
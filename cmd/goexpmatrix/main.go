@@ -0,0 +1,127 @@
+// Command goexpmatrix runs the litmus cases and linearizability tests of
+// this module under several GOEXPERIMENT and GODEBUG variants, by default
+// the HashTrieMap sync.Map of Go 1.24 and newer against the one it
+// replaced, and prints them side by side:
+//
+//	goexpmatrix -cases SB:Map.Load,MP:Map -budget 5s -tests 'TestSyncMap$' -test-args -rounds=1000
+//	goexpmatrix -variant default -variant 'noasync=GODEBUG=asyncpreemptoff=1' -json
+//	goexpmatrix -godebug scheduler -cases SB:Map.Load -tests 'TestSyncMap$'
+//
+// -godebug adds a variant per combination of its GODEBUG settings, with
+// scheduler for asynchronous preemption off, stop-the-world collections
+// and a scheduler trace, see goexp.SchedulerSettings.
+//
+// Run it from the module root. Exit status is 0 if every variant passed, 1
+// if one observed a forbidden outcome or failed a test, and 2 for usage
+// errors or variants that could not be built or run.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/crossrun"
+	"github.com/jmasters-git/porcupine-syncmap/goexp"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("goexpmatrix", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var variants []goexp.Variant
+	fs.Func("variant", "a variant, name or name=KEY=value,KEY=value, repeatable; default and nosynchashtriemap if none", func(s string) error {
+		name, env, _ := strings.Cut(s, "=")
+		if name == "" {
+			return fmt.Errorf("invalid variant %q, want name=KEY=value,...", s)
+		}
+		v := goexp.Variant{Name: name}
+		if env != "" {
+			for _, kv := range strings.Split(env, ",") {
+				if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+					return fmt.Errorf("invalid variable %q in variant %s, want KEY=value", kv, name)
+				}
+				v.Env = append(v.Env, kv)
+			}
+		}
+		variants = append(variants, v)
+		return nil
+	})
+	fs.Func("godebug", "comma-separated GODEBUG settings to add a variant per combination of, or scheduler for "+strings.Join(goexp.SchedulerSettings(), ","), func(s string) error {
+		settings := goexp.SchedulerSettings()
+		if s != "scheduler" {
+			settings = strings.Split(s, ",")
+		}
+		for _, setting := range settings {
+			if k, _, ok := strings.Cut(setting, "="); !ok || k == "" {
+				return fmt.Errorf("invalid GODEBUG setting %q, want key=value", setting)
+			}
+		}
+		variants = append(variants, goexp.Combinations(settings...)...)
+		return nil
+	})
+	var (
+		cases    = fs.String("cases", "SB:Map.Load,SB:Map.Store,MP:Map,IRIW:Map", "comma-separated preset:primitive litmus pairs, empty for none")
+		budget   = fs.Duration("budget", 5*time.Second, "wall-clock budget per litmus case and variant")
+		tests    = fs.String("tests", "^TestSyncMap$|^TestPromotion$", "-run pattern of the go tests run per variant, empty for none")
+		testArgs = fs.String("test-args", "", "space-separated flags of the test binary, e.g. -rounds=1000")
+		asJSON   = fs.Bool("json", false, "write a JSON report instead of text")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if len(variants) == 0 {
+		variants = goexp.SyncMapVariants()
+	}
+
+	opts := goexp.Options{
+		Variants:   variants,
+		LitmusArgs: []string{"-iters", "0", "-budget", budget.String()},
+		Tests:      *tests,
+	}
+	if *testArgs != "" {
+		opts.TestArgs = append([]string{"-args"}, strings.Fields(*testArgs)...)
+	}
+	if *cases != "" {
+		for _, c := range strings.Split(*cases, ",") {
+			preset, prim, ok := strings.Cut(c, ":")
+			if !ok || preset == "" || prim == "" {
+				fmt.Fprintf(stderr, "goexpmatrix: invalid case %q, want preset:primitive\n", c)
+				return 2
+			}
+			opts.Cases = append(opts.Cases, crossrun.Case{Preset: preset, Prim: prim})
+		}
+	}
+
+	rep, err := goexp.Run(context.Background(), opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		fmt.Fprint(stdout, rep.Summary())
+	}
+
+	switch {
+	case rep.Unavailable():
+		return 2
+	case rep.Forbidden() || rep.Failed():
+		return 1
+	}
+	return 0
+}
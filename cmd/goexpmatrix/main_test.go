@@ -0,0 +1,15 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{{"-nope"}, {"-cases", "SB"}, {"-variant", "=GODEBUG=x=1"}, {"-variant", "v=GODEBUG"}, {"-godebug", "asyncpreemptoff"}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+	}
+}
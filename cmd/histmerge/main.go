@@ -0,0 +1,187 @@
+// Command histmerge merges the histories recorded by several processes,
+// each with its own clients and clock, into one history to check, and
+// writes it as JSON:
+//
+//	histmerge -o merged.json a.json b.json,offset=1.2ms,uncertainty=50us
+//	histmerge -check map a.hist,round=3 b.hist,round=3,clients=100
+//
+// Each part is a history file, saved as JSON or CSV or recorded with
+// -record, then of the round it names, followed by comma-separated
+// options: the offset of its clock ahead of that of the first part, the
+// uncertainty of the offset, by which its operations are widened, and the
+// first id of its clients, after those of the parts before it by default.
+// -skew widens every operation of the merged history by that much more,
+// for clocks only known to agree within it, see workload.Widen. -check
+// checks the merged history with a model of model.Names. -format csv
+// writes its operations as CSV instead, one row each, see
+// model.WriteOperationsCSV, and -format knossos for Knossos, see
+// workload.WriteKnossos, every key or the one of -key:
+//
+//	histmerge -format knossos -key k3 -o history.edn a.hist,round=3
+//
+// Exit status is 0 if the history was merged and, with -check, is
+// linearizable, 1 if it is not, and 2 for usage and file errors.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("histmerge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		out     = fs.String("o", "", "file to write the merged history to, standard output if empty")
+		check   = fs.String("check", "", "model to check the merged history with: "+strings.Join(model.Names(), ", "))
+		timeout = fs.Duration("timeout", 0, "checker timeout, 0 for none")
+		format  = fs.String("format", "json", "format of the merged history: json, csv or knossos")
+		key     = fs.String("key", "", "with -format knossos, the only key to write, every key if empty")
+		skew    = fs.Duration("skew", 0, "skew of the clocks of all the parts, by which every operation is widened")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "histmerge: no parts given")
+		return 2
+	}
+	if *format != "json" && *format != "csv" && *format != "knossos" {
+		fmt.Fprintf(stderr, "histmerge: unknown format %q, want json, csv or knossos\n", *format)
+		return 2
+	}
+	if *skew < 0 {
+		fmt.Fprintln(stderr, "histmerge: -skew must not be negative")
+		return 2
+	}
+	var m porcupine.Model
+	if *check != "" {
+		var err error
+		if m, err = model.Lookup(*check); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	var parts []workload.Part
+	next := 0
+	for _, arg := range fs.Args() {
+		p, err := parsePart(arg, next)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		next = max(next, p.ClientBase+workload.NextClient(p.History))
+		parts = append(parts, p)
+	}
+	h, err := workload.Merge(parts)
+	if err == nil {
+		h, err = workload.Widen(h, *skew)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := write(w, h, *format, *key); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *check == "" {
+		return 0
+	}
+	res := workload.Record{History: h}.Recheck(m, *timeout)
+	fmt.Fprintf(stderr, "%d operations of %d parts: %s in %v\n", res.Ops, len(parts), res.Result, res.Checking.Round(time.Millisecond))
+	if res.Result == porcupine.Illegal {
+		return 1
+	}
+	return 0
+}
+
+func write(w io.Writer, h *workload.History, format, key string) error {
+	switch format {
+	case "csv":
+		return h.WriteCSV(w)
+	case "knossos":
+		return workload.WriteKnossos(w, h, key)
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// parsePart parses a part, path[,round=N][,offset=D][,uncertainty=D][,clients=N],
+// whose clients start at next unless it says otherwise.
+func parsePart(arg string, next int) (workload.Part, error) {
+	path, opts, _ := strings.Cut(arg, ",")
+	p := workload.Part{ClientBase: next}
+	round := -1
+	for opt := range strings.SplitSeq(opts, ",") {
+		if opt == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(opt, "=")
+		var err error
+		switch {
+		case !ok:
+			err = errors.New("want key=value")
+		case k == "round":
+			round, err = strconv.Atoi(v)
+		case k == "offset":
+			p.Offset, err = time.ParseDuration(v)
+		case k == "uncertainty":
+			p.Uncertainty, err = time.ParseDuration(v)
+		case k == "clients":
+			p.ClientBase, err = strconv.Atoi(v)
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return p, fmt.Errorf("histmerge: part %s: option %q: %v", path, opt, err)
+		}
+	}
+
+	records, err := workload.LoadRecords(path)
+	if err != nil {
+		return p, err
+	}
+	for _, r := range records {
+		if round < 0 && len(records) == 1 || r.Round == round {
+			p.History = r.History
+			return p, nil
+		}
+	}
+	if round < 0 {
+		return p, fmt.Errorf("histmerge: part %s: %d rounds, pick one with round=N", path, len(records))
+	}
+	return p, fmt.Errorf("histmerge: part %s: no round %d", path, round)
+}
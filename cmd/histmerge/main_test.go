@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, ops ...porcupine.Operation) string {
+		b, err := json.Marshal(&workload.History{Operations: ops})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a.json",
+		porcupine.Operation{Input: model.Input{Op: model.Store, Key: "k", Val: 1}, Call: 0, Output: model.Output{}, Return: 10},
+		porcupine.Operation{Input: model.Input{Op: model.Load, Key: "k"}, Call: 50, Output: model.Output{Found: true, Val: 2}, Return: 60},
+	)
+	b := write("b.json",
+		porcupine.Operation{Input: model.Input{Op: model.Store, Key: "k", Val: 2}, Call: 1020, Output: model.Output{}, Return: 1030},
+	)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-check", "map", a, b + ",offset=1us"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run = %d: %s", code, &stderr)
+	}
+	var h workload.History
+	if err := json.Unmarshal(stdout.Bytes(), &h); err != nil || len(h.Operations) != 3 || h.Operations[2].ClientId != 1 {
+		t.Fatalf("merged %s: %v", &stdout, err)
+	}
+	if code := run([]string{"-check", "map", a, b}, &stdout, &stderr); code != 1 {
+		t.Errorf("run without the offset = %d, want 1", code)
+	}
+	if code := run([]string{"-check", "map", "-skew", "1us", a, b}, &stdout, &stderr); code != 0 {
+		t.Errorf("run with a skew covering the offset = %d, want 0", code)
+	}
+	out := filepath.Join(dir, "merged.json")
+	if code := run([]string{"-o", out, a, b + ",clients=5"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run -o = %d: %s", code, &stderr)
+	}
+	if merged, err := workload.LoadHistory(out); err != nil || merged.Operations[2].ClientId != 5 {
+		t.Errorf("LoadHistory of the merged history: %v", err)
+	}
+	csv := filepath.Join(dir, "merged.csv")
+	if code := run([]string{"-format", "csv", "-o", csv, a, b + ",offset=1us"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run -format csv = %d: %s", code, &stderr)
+	}
+	if code := run([]string{"-check", "map", csv}, &stdout, &stderr); code != 0 {
+		t.Errorf("run -check of the CSV = %d: %s", code, &stderr)
+	}
+	edn := filepath.Join(dir, "merged.edn")
+	if code := run([]string{"-format", "knossos", "-o", edn, a, b + ",offset=1us"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run -format knossos = %d: %s", code, &stderr)
+	}
+	if merged, err := workload.LoadJepsen(edn); err != nil || len(merged.Operations) != 3 || !porcupine.CheckOperations(model.Model, merged.Operations) {
+		t.Errorf("LoadJepsen of the merged history: %v", err)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{{}, {"-nope"}, {"-check", "nope", "a.json"}, {"missing.json"}, {"a.json,offset"}, {"a.json,offset=soon"}, {"a.json,color=red"}, {"-format", "xml", "a.json"}, {"-skew", "-1us", "a.json"}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Command litmus runs the litmus suite outside of go test, so other projects
+// can embed memory model sanity checks for their target platforms in CI.
+//
+//	litmus run -preset SB -prim atomic.Store -json
+//	litmus run -preset MP -prim Map -format litmus7
+//	litmus run -preset SB,MP -prim all -budget 10s
+//	litmus list
+//
+// -preset and -prim each take a comma-separated list, or all, and run
+// every preset of the list that can be built around every primitive of
+// the other, one after the other, each with its own -iters and -budget.
+// The results are written one per case: a line of text each, a litmus7
+// log each or, with -json, a JSON report each, in a stream. list prints
+// the presets with the primitives each supports, -json for JSON.
+//
+// Build a static binary with CGO_ENABLED=0 go build ./cmd/litmus.
+//
+// Exit status is 0 if no case observed the outcome it looks for where
+// litmus.Expected forbids it on this architecture, 1 if one did, and 2 for
+// usage errors. The condition of a litmus7 file is always forbidden.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/cpuload"
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+// report is the stable JSON document written by -json. Fields are only ever
+// added, never renamed or removed.
+type report struct {
+	Version int            `json:"version"`
+	GOOS    string         `json:"goos"`
+	GOARCH  string         `json:"goarch"`
+	Go      string         `json:"go"`
+	Preset  string         `json:"preset"`
+	File    string         `json:"file,omitempty"`
+	Prim    string         `json:"prim"`
+	Impl    string         `json:"impl"`
+	Expect  string         `json:"expect"`
+	CPULoad int            `json:"cpu_load,omitempty"`
+	Result  *litmus.Result `json:"result"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			return runCases(args[1:], stdout, stderr)
+		case "list":
+			return list(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintln(stderr, "usage: litmus run|list [flags]")
+	return 2
+}
+
+// list prints the presets with the primitives each can be built around,
+// as text or as JSON.
+func list(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("litmus list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "write the list as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	type entry struct {
+		Preset string   `json:"preset"`
+		Prims  []string `json:"prims"`
+	}
+	var entries []entry
+	for _, name := range litmus.PresetNames() {
+		ps, _ := litmus.LookupPreset(name)
+		e := entry{Preset: name}
+		for _, prim := range litmus.PrimNames() {
+			if p, _ := litmus.LookupPrim(prim); ps.Supports(p) {
+				e.Prims = append(e.Prims, prim)
+			}
+		}
+		entries = append(entries, e)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		return 0
+	}
+	for _, e := range entries {
+		fmt.Fprintf(stdout, "%-6s %s\n", e.Preset, strings.Join(e.Prims, " "))
+	}
+	return 0
+}
+
+// A litmusCase is a test to run, with the preset or file and the
+// primitive it was built from.
+type litmusCase struct {
+	preset string
+	prim   litmus.Prim
+	test   litmus.Test
+}
+
+// expect returns whether the outcome c looks for is forbidden on this
+// architecture. That of a litmus7 file is, as the file asks for it.
+func (c litmusCase) expect() litmus.Expect {
+	if c.preset == "" {
+		return litmus.ExpectForbidden
+	}
+	return litmus.Expected(c.preset, c.prim.Name, runtime.GOARCH)
+}
+
+func runCases(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("litmus run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		preset   = fs.String("preset", "SB", "comma-separated litmus shapes, or all: "+strings.Join(litmus.PresetNames(), ", "))
+		file     = fs.String("file", "", "run the X86 litmus7 test in this .litmus file instead of -preset, accesses go through -prim")
+		prim     = fs.String("prim", "Map.Load", "comma-separated operations under test, or all: "+strings.Join(litmus.PrimNames(), ", "))
+		implName = fs.String("impl", "sync.Map", "map implementation used by Map.* primitives")
+		iters    = fs.Int("iters", 1_000_000, "maximum number of iterations of each test, 0 for no limit")
+		budget   = fs.Duration("budget", 0, "maximum wall-clock time of each test, 0 for no limit")
+		stop     = fs.Int("stop-after", 0, "stop once the forbidden outcome has been observed this many times, 0 to never stop early")
+		evict    = fs.String("evict", "none", "evict cache lines between iterations: none, thrash or clflush")
+		padded   = fs.Bool("padded", false, "place every variable on its own cache line")
+		fence    = fs.String("fence", "none", "fence before and after every primitive access: none, full, store or load")
+		affinity = fs.String("affinity", "none", "pin threads: none, distinct, siblings or same (linux only)")
+		recCPU   = fs.Bool("record-cpu", false, "record the CPUs the threads of forbidden-outcome iterations ran on (linux only)")
+		load     = fs.Int("cpu-load", 0, "goroutines keeping a CPU busy each during the run")
+		duty     = fs.Int("cpu-duty", 0, "percentage of every millisecond the -cpu-load goroutines are busy, 0 for all of it")
+		progress = fs.Duration("progress", 0, "interval of progress lines on stderr, 0 for none")
+		format   = fs.String("format", "text", "output format: text, json or litmus7")
+		asJSON   = fs.Bool("json", false, "shorthand for -format json")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *asJSON {
+		*format = "json"
+	}
+	if *format != "text" && *format != "json" && *format != "litmus7" {
+		fmt.Fprintf(stderr, "litmus: unknown format %q\n", *format)
+		return 2
+	}
+	if *iters <= 0 && *budget <= 0 {
+		fmt.Fprintln(stderr, "litmus: one of -iters and -budget must be set")
+		return 2
+	}
+	f, err := litmus.ParseFence(*fence)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	cases, err := selectCases(*preset, *file, *prim, f)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	impl, ok := mapimpl.Lookup(*implName)
+	if !ok {
+		fmt.Fprintf(stderr, "litmus: unknown implementation %q\n", *implName)
+		return 2
+	}
+	ev, err := litmus.ParseEvict(*evict)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	if err := (cpuload.Load{Cores: *load, Duty: *duty}).Validate(); err != nil {
+		fmt.Fprintf(stderr, "litmus: %v\n", err)
+		return 2
+	}
+
+	aff, err := litmus.ParseAffinity(*affinity)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	// Place every case before running any, so a placement the machine
+	// cannot provide fails the command at once.
+	placed := make([][]int, len(cases))
+	for i, c := range cases {
+		if placed[i], err = litmus.Place(aff, len(c.test.Threads)); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	code := 0
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	for i, c := range cases {
+		res := litmus.Run(c.test, litmus.Options{
+			Iterations: *iters,
+			Budget:     *budget,
+			StopAfter:  *stop,
+			NewMap:     impl.New,
+			Evict:      ev,
+			CPUs:       placed[i],
+			Padded:     *padded,
+			RecordCPU:  *recCPU,
+			CPULoad:    *load,
+			CPUDuty:    *duty,
+			Progress:   *progress,
+			Logf:       func(format string, args ...any) { fmt.Fprintf(stderr, format+"\n", args...) },
+		})
+
+		switch *format {
+		case "json":
+			// One report per case, one after the other.
+			err := enc.Encode(report{
+				Version: 1,
+				GOOS:    runtime.GOOS,
+				GOARCH:  runtime.GOARCH,
+				Go:      runtime.Version(),
+				Preset:  c.preset,
+				File:    *file,
+				Prim:    c.prim.Name,
+				Impl:    impl.Name,
+				Expect:  c.expect().String(),
+				CPULoad: *load,
+				Result:  res,
+			})
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+		case "litmus7":
+			fmt.Fprint(stdout, res.Litmus7())
+		default:
+			fmt.Fprintf(stdout, "%s/%s %s\n", runtime.GOOS, runtime.GOARCH, res.Histogram())
+		}
+
+		if res.Forbidden > 0 && c.expect() == litmus.ExpectForbidden {
+			code = 1
+		}
+	}
+	return code
+}
+
+// selectCases builds the tests of the presets, or of the litmus7 file, and
+// primitives given, each a comma-separated list or all. A preset that
+// cannot be built around a primitive is skipped if either list has several
+// entries, and an error otherwise.
+func selectCases(presets, file, prims string, f litmus.Fence) ([]litmusCase, error) {
+	primNames := strings.Split(prims, ",")
+	if prims == "all" {
+		primNames = litmus.PrimNames()
+	}
+	var presetNames []string
+	switch {
+	case file != "":
+	case presets == "all":
+		presetNames = litmus.PresetNames()
+	default:
+		presetNames = strings.Split(presets, ",")
+	}
+	var fileTest []byte
+	if file != "" {
+		var err error
+		if fileTest, err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range primNames {
+		if _, ok := litmus.LookupPrim(name); !ok {
+			return nil, fmt.Errorf("litmus: unknown primitive %q", name)
+		}
+	}
+	several := len(primNames) > 1 || len(presetNames) > 1
+	var cases []litmusCase
+	if file != "" {
+		for _, name := range primNames {
+			p, _ := litmus.LookupPrim(name)
+			p = litmus.Fenced(p, f)
+			test, err := litmus.Parse(bytes.NewReader(fileTest), p)
+			if err != nil && several {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			cases = append(cases, litmusCase{prim: p, test: test})
+		}
+	}
+	for _, preset := range presetNames {
+		ps, ok := litmus.LookupPreset(preset)
+		if !ok {
+			return nil, fmt.Errorf("litmus: unknown preset %q", preset)
+		}
+		for _, name := range primNames {
+			// Every case has a fresh instance of its primitive.
+			p, _ := litmus.LookupPrim(name)
+			if !ps.Supports(p) && several {
+				continue
+			} else if !ps.Supports(p) {
+				return nil, fmt.Errorf("litmus: preset %s cannot be built around primitive %s", ps.Name, p.Name)
+			}
+			p = litmus.Fenced(p, f)
+			cases = append(cases, litmusCase{preset: ps.Name, prim: p, test: ps.Build(p)})
+		}
+	}
+	if len(cases) == 0 {
+		return nil, errors.New("litmus: no preset can be built around the primitives given")
+	}
+	return cases, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", "-preset", "SB", "-prim", "atomic.Store", "-iters", "100", "-json"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+
+	var rep struct {
+		Version int    `json:"version"`
+		Prim    string `json:"prim"`
+		Result  struct {
+			Test       string `json:"test"`
+			Iterations int    `json:"iterations"`
+			Outcomes   []struct {
+				Regs  []int64 `json:"regs"`
+				Count int     `json:"count"`
+			} `json:"outcomes"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &rep); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	total := 0
+	for _, oc := range rep.Result.Outcomes {
+		total += oc.Count
+	}
+	if rep.Version != 1 || rep.Prim != "atomic.Store" || rep.Result.Test != "SB+atomic.Store" || total != 100 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
+
+func TestRunLitmus7(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", "-preset", "MP", "-prim", "atomic", "-iters", "100", "-format", "litmus7"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "Test MP+atomic Allowed\n") || !strings.Contains(out, "Observation MP+atomic Never 0 100\n") {
+		t.Fatalf("unexpected litmus7 log:\n%s", out)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", "-file", "../../litmus/testdata/MP.litmus", "-prim", "Map", "-iters", "100", "-format", "litmus7"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	if out := stdout.String(); !strings.HasPrefix(out, "Test MP+Map Allowed\n") || !strings.Contains(out, "1:EAX=") {
+		t.Fatalf("unexpected litmus7 log:\n%s", out)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{nil, {"walk"}, {"run", "-prim", "nope"}, {"run", "-preset", "nope"}, {"run", "-format", "yaml"}, {"run", "-file", "nope.litmus"}, {"run", "-file", "../../litmus/testdata/MP.litmus", "-prim", "none"}, {"run", "-cpu-load", "1", "-cpu-duty", "101"}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", "-preset", "SB", "-prim", "atomic.Store", "-iters", "0", "-budget", "50ms", "-progress", "10ms"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.HasPrefix(stderr.String(), "progress: SB+atomic.Store, ") || !strings.Contains(stderr.String(), "ETA") {
+		t.Fatalf("stderr: %s", stderr.String())
+	}
+}
+
+func TestRunSuite(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", "-preset", "SB,MP", "-prim", "atomic,atomic.Store", "-iters", "50", "-json"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	// SB needs an operation and MP accesses, each is built around one. SB
+	// may reorder around a plain Load, MP not with sequentially consistent
+	// atomics.
+	var tests []string
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var rep struct {
+			Preset string `json:"preset"`
+			Expect string `json:"expect"`
+			Result struct {
+				Test string `json:"test"`
+			} `json:"result"`
+		}
+		if err := dec.Decode(&rep); err != nil {
+			t.Fatal(err)
+		}
+		tests = append(tests, rep.Preset+":"+rep.Result.Test+":"+rep.Expect)
+	}
+	if got, want := strings.Join(tests, " "), "SB:SB+atomic.Store:allowed MP:MP+atomic:forbidden"; got != want {
+		t.Errorf("cases %s, want %s", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"run", "-preset", "all", "-prim", "atomic", "-iters", "10"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	if lines := strings.Count(stdout.String(), "\n"); lines < 6 {
+		t.Errorf("%d lines for all presets:\n%s", lines, stdout.String())
+	}
+	if code := run([]string{"run", "-preset", "MP,LB", "-prim", "atomic.Store,none", "-iters", "10"}, &stdout, &stderr); code != 2 {
+		t.Errorf("run without a case = %d, want 2", code)
+	}
+}
+
+func TestList(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	if out := stdout.String(); !strings.Contains(out, "MP     ") || strings.Contains(strings.SplitAfter(out[strings.Index(out, "MP "):], "\n")[0], "atomic.Store") {
+		t.Errorf("list:\n%s", out)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"list", "-json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d, stderr: %s", code, stderr.String())
+	}
+	var entries []struct {
+		Preset string   `json:"preset"`
+		Prims  []string `json:"prims"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil || len(entries) == 0 || entries[0].Preset != "SB" || len(entries[0].Prims) == 0 {
+		t.Errorf("list -json: %v\n%s", err, stdout.String())
+	}
+}
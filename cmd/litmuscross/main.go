@@ -0,0 +1,140 @@
+// Command litmuscross runs litmus cases on other architectures, under
+// qemu-user or on remote hosts over ssh, and prints one report:
+//
+//	litmuscross -arch arm64,riscv64,ppc64le -cases SB:Map.Load,MP:Map -budget 10s
+//	litmuscross -arch arm64 -remote arm64=pi@raspberrypi -json
+//	litmuscross -host linux/arm64=me@graviton -host darwin/arm64=me@mac -host amd64=me@box -litmus-flags "-affinity distinct"
+//
+// -remote runs an architecture of -arch on a host instead of qemu-user;
+// -host adds a host of its own, with the operating system and
+// architecture of its binary, and several hosts may share one. With -host
+// and without -arch, only the hosts run. -litmus-flags are passed to
+// every run of cmd/litmus, the same on every target.
+//
+// Run it from the module root. Exit status is 0 if no case observed an
+// outcome that litmus.Expected forbids on its architecture, 1 if one did,
+// and 2 for usage errors or failed runs.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/crossrun"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("litmuscross", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		arches  = fs.String("arch", "arm64,riscv64,ppc64le", "comma-separated GOARCH list")
+		remotes = fs.String("remote", "", "comma-separated arch=ssh-destination pairs, run those arches remotely instead of under qemu-user")
+		qemus   = fs.String("qemu", "", "comma-separated arch=qemu-binary pairs overriding the qemu-user binary")
+		cases   = fs.String("cases", "SB:Map.Load,SB:Map.Store,MP:Map,IRIW:Map", "comma-separated preset:primitive pairs")
+		budget  = fs.Duration("budget", 10*time.Second, "wall-clock budget per case and architecture")
+		flags   = fs.String("litmus-flags", "", "space-separated flags passed to every litmus run, e.g. \"-affinity distinct -evict thrash\"")
+		asJSON  = fs.Bool("json", false, "write a JSON report instead of text")
+		hosts   []crossrun.Target
+	)
+	fs.Func("host", "[goos/]goarch=ssh-destination of a host to run on besides the -arch list, linux if goos is omitted, repeated for several", func(s string) error {
+		t, err := host(s)
+		if err == nil {
+			hosts = append(hosts, t)
+		}
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	archSet := false
+	fs.Visit(func(f *flag.Flag) { archSet = archSet || f.Name == "arch" })
+
+	remote, err := pairs(*remotes)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	qemu, err := pairs(*qemus)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts := crossrun.Options{Args: append([]string{"-iters", "0", "-budget", budget.String()}, strings.Fields(*flags)...)}
+	if len(hosts) == 0 || archSet {
+		for _, a := range strings.Split(*arches, ",") {
+			opts.Targets = append(opts.Targets, crossrun.Target{GOARCH: a, Remote: remote[a], QEMU: qemu[a]})
+		}
+	}
+	opts.Targets = append(opts.Targets, hosts...)
+	for _, c := range strings.Split(*cases, ",") {
+		preset, prim, ok := strings.Cut(c, ":")
+		if !ok || preset == "" || prim == "" {
+			fmt.Fprintf(stderr, "litmuscross: invalid case %q, want preset:primitive\n", c)
+			return 2
+		}
+		opts.Cases = append(opts.Cases, crossrun.Case{Preset: preset, Prim: prim})
+	}
+
+	rep, err := crossrun.Run(context.Background(), opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		fmt.Fprint(stdout, rep.Summary())
+	}
+
+	switch {
+	case rep.Failed():
+		return 2
+	case rep.Forbidden():
+		return 1
+	}
+	return 0
+}
+
+// host parses a -host value, [goos/]goarch=destination.
+func host(s string) (crossrun.Target, error) {
+	platform, dest, ok := strings.Cut(s, "=")
+	goos, goarch, hasOS := strings.Cut(platform, "/")
+	if !hasOS {
+		goos, goarch = "linux", platform
+	}
+	if !ok || goos == "" || goarch == "" || dest == "" {
+		return crossrun.Target{}, fmt.Errorf("invalid host %q, want [goos/]goarch=ssh-destination", s)
+	}
+	return crossrun.Target{GOOS: goos, GOARCH: goarch, Remote: dest}, nil
+}
+
+// pairs parses comma-separated key=value pairs.
+func pairs(s string) (map[string]string, error) {
+	m := map[string]string{}
+	if s == "" {
+		return m, nil
+	}
+	for _, p := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("litmuscross: invalid pair %q", p)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
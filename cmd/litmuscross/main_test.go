@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/crossrun"
+)
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{{"-nope"}, {"-cases", "SB"}, {"-cases", ":Map"}, {"-remote", "arm64"}, {"-qemu", "=qemu"}, {"-host", "arm64"}, {"-host", "darwin/=me@mac"}, {"-host", "=me@box"}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	for s, want := range map[string]crossrun.Target{
+		"arm64=me@graviton":    {GOOS: "linux", GOARCH: "arm64", Remote: "me@graviton"},
+		"darwin/arm64=me@mac":  {GOOS: "darwin", GOARCH: "arm64", Remote: "me@mac"},
+		"linux/amd64=box:2222": {GOOS: "linux", GOARCH: "amd64", Remote: "box:2222"},
+	} {
+		if got, err := host(s); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("host(%q) = %+v, %v, want %+v", s, got, err, want)
+		}
+	}
+}
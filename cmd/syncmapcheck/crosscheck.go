@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/bruteforce"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// crosscheck checks random small histories with porcupine and the brute
+// force of package bruteforce, and saves those they disagree on.
+func crosscheck(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("syncmapcheck crosscheck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		modelName = fs.String("model", "map", "model to check the histories with: "+strings.Join(model.Names(), ", "))
+		histories = fs.Int("histories", 10000, "number of histories to check")
+		ops       = fs.Int("ops", 10, fmt.Sprintf("most operations of a history, at most %d", bruteforce.MaxOperations))
+		clients   = fs.Int("clients", 3, "most clients of a history")
+		keys      = fs.Int("keys", 2, "most keys of a history")
+		seed      = fs.Uint64("seed", 0, "seed of the histories, 0 for a random one")
+		outDir    = fs.String("out-dir", ".", "directory the histories they disagree on are written to")
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	switch {
+	case *ops < 1 || *ops > bruteforce.MaxOperations:
+		fmt.Fprintf(stderr, "syncmapcheck: -ops must be between 1 and %d\n", bruteforce.MaxOperations)
+		return exitError
+	case *histories < 1 || *clients < 1 || *keys < 1:
+		fmt.Fprintln(stderr, "syncmapcheck: -histories, -clients and -keys must be positive")
+		return exitError
+	}
+	m, err := model.Lookup(*modelName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+
+	var (
+		start      = time.Now()
+		mismatches int
+	)
+	for i := range *histories {
+		// Every history has a generator of its own, to generate it again
+		// from the seed and its index.
+		rng := rand.New(rand.NewPCG(*seed, uint64(i)))
+		h := bruteforce.Random(rng, 1+rng.IntN(*ops), 1+rng.IntN(*clients), 1+rng.IntN(*keys))
+		err := bruteforce.CrossCheck(m, h, 0)
+		if err == nil {
+			continue
+		}
+		mismatches++
+		path := filepath.Join(*outDir, fmt.Sprintf("crosscheck_%s_mismatch_%d_%d.json", *modelName, *seed, i))
+		if werr := writeHistory(&workload.History{Operations: h}, path); werr != nil {
+			fmt.Fprintln(stderr, werr)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "history %d (seed %d): %v, saved to %s\n", i, *seed, err, path)
+	}
+	fmt.Fprintf(stderr, "%d histories of up to %d operations checked with the %s model in %v (seed %d), %d mismatches\n", *histories, *ops, *modelName, time.Since(start).Round(time.Millisecond), *seed, mismatches)
+	if mismatches > 0 {
+		return exitViolation
+	}
+	return exitPass
+}
+
+// writeHistory writes h to path as JSON, for recheck and visualize.
+func writeHistory(h *workload.History, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
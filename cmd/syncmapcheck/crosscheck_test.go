@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCrosscheck(t *testing.T) {
+	dir := t.TempDir()
+	for _, m := range []string{"map", "snapshot"} {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"crosscheck", "-model", m, "-histories", "200", "-ops", "12", "-clients", "4", "-seed", "7", "-out-dir", dir}, &stdout, &stderr); code != exitPass {
+			t.Fatalf("crosscheck -model %s = %d: %s%s", m, code, &stdout, &stderr)
+		}
+		if want := "200 histories of up to 12 operations checked with the " + m + " model in "; !strings.Contains(stderr.String(), want) || !strings.Contains(stderr.String(), "(seed 7), 0 mismatches") {
+			t.Errorf("output %s", &stderr)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("files %v written without a mismatch", files)
+	}
+
+	for _, args := range [][]string{
+		{"crosscheck", "-ops", "16"},
+		{"crosscheck", "-ops", "0"},
+		{"crosscheck", "-clients", "0"},
+		{"crosscheck", "-model", "serial"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("%v = %d, want %d", args, code, exitError)
+		}
+	}
+}
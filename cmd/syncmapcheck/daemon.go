@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/metrics"
+	"github.com/jmasters-git/porcupine-syncmap/report"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// daemon runs a workload and litmus cases in turn until it is interrupted,
+// or for -for, and serves their progress on -listen: a status page, its
+// JSON, the Prometheus metrics and the files of the violations.
+func daemon(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("syncmapcheck daemon", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		listen   = fs.String("listen", ":8080", "address to serve the status page on")
+		spec     = fs.String("workload", "default", "workload profile ("+strings.Join(workload.ProfileNames(), ", ")+") or JSON spec file")
+		impl     = fs.String("impl", "", "implementation under test, the spec's if empty: "+strings.Join(implNames(), ", "))
+		ops      = fs.Int("ops", 0, "operations per worker and round, 0 for the spec's")
+		workers  = fs.Int("workers", 0, "concurrent workers per round, 0 for the spec's")
+		timeout  = fs.Duration("check-timeout", 0, "porcupine checker timeout per round, 0 for the spec's")
+		inFlight = fs.Int("check-inflight", 4, "histories checked in the background while the next rounds run, 0 to check each round before the next")
+		batch    = fs.Duration("batch", 30*time.Second, "how long the workload runs, with a seed of its own, between two litmus cases")
+		cases    = fs.String("litmus", "SB:Map.Load,MP:Map", "comma-separated preset:primitive litmus cases run in turn between the batches, empty for none")
+		budget   = fs.Duration("litmus-budget", 10*time.Second, "wall-clock budget of a litmus case")
+		recent   = fs.Int("recent", 50, "checked rounds the status page lists")
+		forTime  = fs.Duration("for", 0, "stop after this long, 0 to run until interrupted")
+		outDir   = fs.String("out-dir", ".", "directory the files of the violations are written to and served from")
+		debug    = fs.Bool("pprof", false, "serve the profiles of net/http/pprof on -listen too, under /debug/pprof/")
+		prof     = profileFlags(fs)
+		logs     = logFlags(fs)
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "syncmapcheck: unexpected arguments %q\n", fs.Args())
+		return exitError
+	}
+	if *batch <= 0 || *budget <= 0 {
+		fmt.Fprintln(stderr, "syncmapcheck: -batch and -litmus-budget must be positive")
+		return exitError
+	}
+	s, ok := workload.Profile(*spec)
+	if !ok {
+		var err error
+		if s, err = workload.Load(*spec); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if *impl != "" {
+		s.Impl = *impl
+	}
+	if *ops > 0 {
+		s.Ops = *ops
+	}
+	if *workers > 0 && len(s.Roles) == 0 {
+		s.Workers = *workers
+	}
+	if *timeout > 0 {
+		s.Checker.Timeout = workload.Duration(*timeout)
+	}
+	if err := s.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	mapImpl, ok := mapimpl.Lookup(s.Impl)
+	if !ok {
+		fmt.Fprintf(stderr, "syncmapcheck: unknown implementation %q\n", s.Impl)
+		return exitError
+	}
+	var lcs []litmusCase
+	if *cases != "" {
+		var err error
+		if lcs, err = litmusCases(*cases); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *forTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *forTime)
+		defer cancel()
+	}
+	log, err := logs.logger(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	log = log.With("impl", s.Impl)
+	t := newTracker(s.String(), *recent)
+	m := metrics.New()
+	mux := http.NewServeMux()
+	mux.Handle("/", t)
+	mux.Handle("/metrics", m)
+	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(*outDir))))
+	if *debug {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Close()
+	log.Info(fmt.Sprintf("serving the status of %s on %s", s.Impl, ln.Addr()), "addr", ln.Addr().String())
+
+	code := prof.run(stderr, func() int {
+		code := exitPass
+		for i := 0; ctx.Err() == nil; i++ {
+			run := s
+			run.Seed = rand.Uint64()
+			var summary workload.Stats
+			err := run.Run(workload.RunOptions{
+				AfterRound: func(_ int, _ mapimpl.MapUnderTest, history []porcupine.Operation) error {
+					m.AddOps(s.Name, s.Impl, history)
+					return nil
+				},
+				Logger:   log.With("batch", i),
+				Duration: *batch,
+				InFlight: *inFlight,
+				Summary:  &summary,
+				Stop:     ctx.Done(),
+				Checked: func(c workload.Checked) {
+					m.AddChecked(s.Name, s.Impl, c)
+					t.checked(c)
+				},
+			})
+			if errors.Is(err, workload.ErrStopped) {
+				err = nil
+			}
+			file := ""
+			var v *workload.Violation
+			if errors.As(err, &v) {
+				base := filepath.Join(*outDir, workload.ViolationName(s.Impl, v.Round))
+				if werr := v.WriteFiles(base); werr != nil {
+					log.Error(werr.Error(), "batch", i)
+				} else {
+					file = filepath.Base(base)
+				}
+				log.Error(fmt.Sprintf("%v, saved to %s.html", v, base), "batch", i, "round", v.Round, "seed", v.Seed, "file", base+".html")
+			} else if err != nil {
+				log.Error(err.Error(), "batch", i, "error", err)
+			}
+			t.batch(summary, err, v, file)
+			code = worse(code, status(err, summary))
+
+			if len(lcs) == 0 || ctx.Err() != nil {
+				continue
+			}
+			lc := lcs[i%len(lcs)]
+			// Every run has a fresh instance of its primitive.
+			p, _ := litmus.LookupPrim(lc.prim)
+			ps, _ := litmus.LookupPreset(lc.preset)
+			res := litmus.Run(ps.Build(p), litmus.Options{Budget: *budget, NewMap: mapImpl.New})
+			expect := litmus.Expected(lc.preset, lc.prim, runtime.GOARCH)
+			m.AddLitmus(lc.String(), res)
+			t.litmus(lc.String(), expect, res)
+			log.Info(fmt.Sprintf("%s: %d iterations, %d %s", lc, res.Iterations, res.Forbidden, expect), "litmus", lc.String(), "iterations", res.Iterations, "observed", res.Forbidden, "expect", expect.String())
+		}
+		return code
+	})
+	sum := t.status()
+	fmt.Fprintf(stdout, "%s: %d batches, %s, %d violations\n", s.Impl, sum.Batches, sum.Total, len(sum.Violations))
+	return code
+}
+
+// A litmusCase is a litmus test of the daemon, by its preset and
+// primitive.
+type litmusCase struct {
+	preset, prim string
+}
+
+func (c litmusCase) String() string { return c.preset + "+" + c.prim }
+
+// litmusCases parses a comma-separated list of preset:primitive cases.
+func litmusCases(list string) ([]litmusCase, error) {
+	var cs []litmusCase
+	for c := range strings.SplitSeq(list, ",") {
+		preset, prim, _ := strings.Cut(c, ":")
+		ps, ok := litmus.LookupPreset(preset)
+		if !ok {
+			return nil, fmt.Errorf("syncmapcheck: -litmus %s: unknown preset %q, want preset:primitive", c, preset)
+		}
+		p, ok := litmus.LookupPrim(prim)
+		if !ok {
+			return nil, fmt.Errorf("syncmapcheck: -litmus %s: unknown primitive %q", c, prim)
+		}
+		if !ps.Supports(p) {
+			return nil, fmt.Errorf("syncmapcheck: -litmus %s: preset %s cannot be built around primitive %s", c, ps.Name, p.Name)
+		}
+		cs = append(cs, litmusCase{preset: preset, prim: prim})
+	}
+	return cs, nil
+}
+
+// daemonStatus is what the daemon ran so far, as its status page and
+// /status.json show it.
+type daemonStatus struct {
+	Start  time.Time  `json:"start"`
+	Config string     `json:"config"`
+	Env    report.Env `json:"env"`
+	// Rounds counts the rounds checked by porcupine result, or hung, and
+	// Batches and Total are those of the workload runs that returned.
+	Rounds  map[string]int `json:"rounds"`
+	Batches int            `json:"batches"`
+	Total   workload.Stats `json:"total"`
+	// Recent are the last rounds checked, the latest batch and, in it, the
+	// latest round first.
+	Recent     []recentCheck `json:"recent"`
+	Litmus     []litmusRate  `json:"litmus"`
+	Violations []artifact    `json:"violations"`
+	// Errors are the last errors other than violations a batch stopped at.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// A recentCheck is a round checked in a batch, counted from 0 like its
+// rounds.
+type recentCheck struct {
+	Batch int `json:"batch"`
+	workload.Checked
+}
+
+// after reports whether r ran after o.
+func (r recentCheck) after(o recentCheck) bool {
+	return r.Batch > o.Batch || (r.Batch == o.Batch && r.Round > o.Round)
+}
+
+// A litmusRate is how often a litmus case observed the outcome it looks
+// for, and whether that is allowed or forbidden on this architecture.
+type litmusRate struct {
+	Case       string    `json:"case"`
+	Expect     string    `json:"expect"`
+	Runs       int       `json:"runs"`
+	Iterations int       `json:"iterations"`
+	Observed   int       `json:"observed"`
+	Elapsed    float64   `json:"elapsed_seconds"`
+	Last       time.Time `json:"last"`
+}
+
+// Rate is the percentage of the iterations with the outcome.
+func (r litmusRate) Rate() float64 {
+	if r.Iterations == 0 {
+		return 0
+	}
+	return 100 * float64(r.Observed) / float64(r.Iterations)
+}
+
+// Failed reports whether the outcome was observed although forbidden.
+func (r litmusRate) Failed() bool {
+	return r.Observed > 0 && r.Expect == litmus.ExpectForbidden.String()
+}
+
+// An artifact is a violation the daemon saved, File without the extension
+// in the -out-dir.
+type artifact struct {
+	Time  time.Time `json:"time"`
+	Round int       `json:"round"`
+	Seed  uint64    `json:"seed"`
+	Key   string    `json:"key,omitempty"`
+	File  string    `json:"file,omitempty"`
+}
+
+// A tracker collects the status of the daemon, and serves it.
+type tracker struct {
+	mu     sync.Mutex
+	st     daemonStatus
+	recent int
+	// rounds counts the rounds of the running batch, which batch adds to
+	// st.Rounds along with its stats to st.Total.
+	rounds map[string]int
+}
+
+func newTracker(config string, recent int) *tracker {
+	return &tracker{recent: recent, rounds: make(map[string]int), st: daemonStatus{Start: time.Now(), Config: config, Env: report.Environment(), Rounds: make(map[string]int)}}
+}
+
+func (t *tracker) checked(c workload.Checked) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := string(c.Result)
+	if c.Hung != "" {
+		result = "hung"
+	}
+	t.rounds[result]++
+	// The batches run one after the other, but with checks in flight the
+	// rounds of one finish checking out of order.
+	rc := recentCheck{Batch: t.st.Batches, Checked: c}
+	i := 0
+	for i < len(t.st.Recent) && t.st.Recent[i].after(rc) {
+		i++
+	}
+	t.st.Recent = slices.Insert(t.st.Recent, i, rc)
+	t.st.Recent = t.st.Recent[:min(len(t.st.Recent), max(t.recent, 1))]
+}
+
+func (t *tracker) batch(summary workload.Stats, err error, v *workload.Violation, file string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.st.Batches++
+	t.st.Total = t.st.Total.Add(summary)
+	for r, n := range t.rounds {
+		t.st.Rounds[r] += n
+	}
+	clear(t.rounds)
+	switch {
+	case v != nil:
+		t.st.Violations = append(t.st.Violations, artifact{Time: time.Now(), Round: v.Round, Seed: v.Seed, Key: v.Key, File: file})
+	case err != nil:
+		t.st.Errors = append(t.st.Errors, time.Now().Format("15:04:05")+" "+err.Error())
+		t.st.Errors = t.st.Errors[max(len(t.st.Errors)-10, 0):]
+	}
+}
+
+func (t *tracker) litmus(name string, expect litmus.Expect, res *litmus.Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := 0
+	for i < len(t.st.Litmus) && t.st.Litmus[i].Case != name {
+		i++
+	}
+	if i == len(t.st.Litmus) {
+		t.st.Litmus = append(t.st.Litmus, litmusRate{Case: name, Expect: expect.String()})
+	}
+	r := &t.st.Litmus[i]
+	r.Runs++
+	r.Iterations += res.Iterations
+	r.Observed += res.Forbidden
+	r.Elapsed += res.Elapsed.Seconds()
+	r.Last = time.Now()
+}
+
+// status returns a copy of the status.
+func (t *tracker) status() daemonStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.st
+	st.Rounds = make(map[string]int, len(t.st.Rounds))
+	for r, n := range t.st.Rounds {
+		st.Rounds[r] = n
+	}
+	st.Recent = append([]recentCheck(nil), t.st.Recent...)
+	st.Litmus = append([]litmusRate(nil), t.st.Litmus...)
+	st.Violations = append([]artifact(nil), t.st.Violations...)
+	st.Errors = append([]string(nil), t.st.Errors...)
+	return st
+}
+
+// ServeHTTP serves the status page on /, and its JSON on /status.json.
+func (t *tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st := t.status()
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		statusTemplate.Execute(w, st)
+	case "/status.json":
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(st)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"since":    func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+	"time":     func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"duration": func(d workload.Duration) string { return time.Duration(d).Round(time.Microsecond).String() },
+	"percent":  func(f float64) string { return fmt.Sprintf("%.4f%%", f) },
+	"persec": func(n int, secs float64) string {
+		if secs <= 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.0f", float64(n)/secs)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>syncmapcheck daemon</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+.fail { color: #b00; font-weight: bold; }
+.pass { color: #070; }
+</style>
+</head>
+<body>
+<h1>syncmapcheck daemon</h1>
+<p>Up {{since .Start}}, since {{time .Start}}, on {{if .Env.Host}}{{.Env.Host}}, {{end}}{{.Env.GOOS}}/{{.Env.GOARCH}}{{if .Env.CPU}}, {{.Env.CPU}}{{end}}, {{.Env.NumCPU}} CPUs, {{.Env.Go}}.</p>
+<p><code>{{.Config}}</code></p>
+
+<h2>Rounds</h2>
+<table>
+<tr><th>Ok</th><th>Illegal</th><th>Unknown</th><th>Hung</th><th>Batches</th><th>Ops</th></tr>
+<tr><td class="n pass">{{index .Rounds "Ok"}}</td><td class="n{{if index .Rounds "Illegal"}} fail{{end}}">{{index .Rounds "Illegal"}}</td>
+<td class="n">{{index .Rounds "Unknown"}}</td><td class="n">{{index .Rounds "hung"}}</td><td class="n">{{.Batches}}</td><td class="n">{{.Total.Ops}}</td></tr>
+</table>
+
+{{if .Violations}}<h2 class="fail">Violations</h2>
+<table>
+<tr><th>Time</th><th>Round</th><th>Seed</th><th>Key</th><th>Files</th></tr>
+{{range .Violations}}<tr><td>{{time .Time}}</td><td class="n">{{.Round}}</td><td class="n">{{.Seed}}</td><td>{{.Key}}</td>
+<td>{{if .File}}<a href="files/{{.File}}.html">visualization</a> <a href="files/{{.File}}.json">history</a>{{else}}not saved{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .Errors}}<h2>Errors</h2>
+<ul>{{range .Errors}}<li>{{.}}</li>{{end}}</ul>{{end}}
+
+{{if .Litmus}}<h2>Litmus outcomes</h2>
+<table>
+<tr><th>Case</th><th>Runs</th><th>Iterations</th><th>Per second</th><th>Observed</th><th>Rate</th><th>Last run</th></tr>
+{{range .Litmus}}<tr><td>{{.Case}}</td><td class="n">{{.Runs}}</td><td class="n">{{.Iterations}}</td><td class="n">{{persec .Iterations .Elapsed}}</td>
+<td class="n{{if .Failed}} fail{{end}}">{{.Observed}} {{.Expect}}</td><td class="n">{{percent .Rate}}</td><td>{{time .Last}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .Recent}}<h2>Recent checks</h2>
+<table>
+<tr><th>Batch</th><th>Round</th><th>Seed</th><th>Ops</th><th>Result</th><th>Checking</th></tr>
+{{range .Recent}}<tr><td class="n">{{.Batch}}</td><td class="n">{{.Round}}</td><td class="n">{{.Seed}}</td><td class="n">{{.Ops}}</td>
+<td{{if eq (print .Result) "Illegal"}} class="fail"{{end}}>{{.Result}}{{if .Hung}} (hung in {{.Hung}}){{end}}{{if .Key}}, key {{.Key}}{{end}}</td><td class="n">{{duration .Checking}}</td></tr>
+{{end}}</table>{{end}}
+
+<p><a href="status.json">JSON</a> · <a href="metrics">metrics</a></p>
+</body>
+</html>
+`))
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+)
+
+func TestDaemon(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var stdout, stderr bytes.Buffer
+	done := make(chan int)
+	go func() {
+		done <- run([]string{"daemon", "-listen", addr, "-impl", "MutexMap", "-ops", "10", "-batch", "200ms",
+			"-litmus", "SB:atomic.Store", "-litmus-budget", "50ms", "-recent", "3", "-pprof", "-for", "2s", "-out-dir", t.TempDir()}, &stdout, &stderr)
+	}()
+
+	var st daemonStatus
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if resp, err := http.Get("http://" + addr + "/status.json"); err == nil {
+			err = json.NewDecoder(resp.Body).Decode(&st)
+			resp.Body.Close()
+			if err == nil && st.Rounds["Ok"] > 0 && len(st.Litmus) > 0 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no rounds and litmus runs in the status: %+v", st)
+		}
+	}
+	if len(st.Recent) != 3 || !st.Recent[0].after(st.Recent[1]) || !st.Recent[1].after(st.Recent[2]) || st.Litmus[0].Case != "SB+atomic.Store" || st.Litmus[0].Expect != "allowed" || st.Litmus[0].Iterations == 0 {
+		t.Errorf("status %+v", st)
+	}
+	if n := st.Rounds["Ok"] + st.Rounds["Illegal"] + st.Rounds["Unknown"] + st.Rounds["hung"]; n != st.Total.Rounds {
+		t.Errorf("%d rounds by result, %d in all", n, st.Total.Rounds)
+	}
+	for path, want := range map[string]string{"/": "<h2>Litmus outcomes</h2>", "/metrics": "syncmap_rounds_total", "/debug/pprof/": "goroutine"} {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(b), want) {
+			t.Errorf("%s without %q:\n%s", path, want, b)
+		}
+	}
+	if code := <-done; code != exitPass {
+		t.Fatalf("daemon = %d: %s", code, &stderr)
+	}
+	if !strings.HasPrefix(stdout.String(), "MutexMap: ") {
+		t.Errorf("stdout %q", &stdout)
+	}
+
+	for _, args := range [][]string{
+		{"daemon", "-litmus", "SB:atomic"},
+		{"daemon", "-litmus", "XX:Map"},
+		{"daemon", "-batch", "0"},
+		{"daemon", "-impl", "nosuch"},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("run %q = %d, want %d", args, code, exitError)
+		}
+	}
+}
+
+func TestDaemonLitmusExpect(t *testing.T) {
+	tr := newTracker("test", 1)
+	tr.litmus("SB+Map.Load", litmus.ExpectAllowed, &litmus.Result{Iterations: 10, Forbidden: 3})
+	tr.litmus("MP+Map", litmus.ExpectForbidden, &litmus.Result{Iterations: 10, Forbidden: 1})
+	var b bytes.Buffer
+	if err := statusTemplate.Execute(&b, tr.status()); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	if !strings.Contains(page, `<td class="n">3 allowed</td>`) || !strings.Contains(page, `<td class="n fail">1 forbidden</td>`) {
+		t.Errorf("litmus outcomes without their expectations:\n%s", page)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/fleet"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// coordinate hands out the rounds of a workload in batches to agents on
+// other machines, and prints what each of them ran once every batch is
+// in.
+func coordinate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("syncmapcheck coordinate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		listen  = fs.String("listen", ":8700", "address to serve the agents on")
+		spec    = fs.String("workload", "default", "workload profile ("+strings.Join(workload.ProfileNames(), ", ")+") or JSON spec file")
+		impl    = fs.String("impl", "", "implementation under test, the spec's if empty: "+strings.Join(implNames(), ", "))
+		rounds  = fs.Int("rounds", 0, "rounds to run over all the agents, 0 for the spec's")
+		ops     = fs.Int("ops", 0, "operations per worker and round, 0 for the spec's")
+		workers = fs.Int("workers", 0, "concurrent workers per round, 0 for the spec's")
+		seed    = fs.Uint64("seed", 0, "seed of the first round's operations, 0 for the spec's or a random one")
+		timeout = fs.Duration("check-timeout", 0, "porcupine checker timeout per round, 0 for the spec's")
+		size    = fs.Int("batch", 100, "rounds of a batch an agent leases")
+		lease   = fs.Duration("lease-timeout", 10*time.Minute, "how long an agent has to report a batch before another one gets it")
+		linger  = fs.Duration("linger", 5*time.Second, "how long to keep answering after the last batch, for the agents waiting for one to learn there are none left")
+		outDir  = fs.String("out-dir", ".", "directory the files of the violations are written to")
+		logs    = logFlags(fs)
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "syncmapcheck: unexpected arguments %q\n", fs.Args())
+		return exitError
+	}
+	s, ok := workload.Profile(*spec)
+	if !ok {
+		var err error
+		if s, err = workload.Load(*spec); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if *impl != "" {
+		s.Impl = *impl
+	}
+	if *rounds > 0 {
+		s.Rounds = *rounds
+	}
+	if *ops > 0 {
+		s.Ops = *ops
+	}
+	if *workers > 0 && len(s.Roles) == 0 {
+		s.Workers = *workers
+	}
+	if *seed != 0 {
+		s.Seed = *seed
+	}
+	if *timeout > 0 {
+		s.Checker.Timeout = workload.Duration(*timeout)
+	}
+	log, err := logs.logger(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	c, err := fleet.NewCoordinator(s, *size)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	c.Dir, c.LeaseTimeout, c.Logger = *outDir, *lease, log.With("impl", s.Impl)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	srv := &http.Server{Handler: c}
+	go srv.Serve(ln)
+	fmt.Fprintf(stderr, "%s: %d rounds in batches of %d, serving on %s\n", s.Impl, s.Rounds, *size, ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	interrupted := false
+	select {
+	case <-c.Done():
+		select {
+		case <-time.After(*linger):
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+		interrupted = true
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.Shutdown(shutdown)
+
+	sum := c.Summary()
+	if err := writeAgents(stdout, sum); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "%s: %d of %d batches, %s\n", s.Impl, sum.Done, sum.Batches, sum.Total)
+	code := exitPass
+	switch {
+	case len(sum.Findings) > 0:
+		code = exitViolation
+	case interrupted || sum.Done < sum.Batches:
+		code = exitError
+	}
+	for _, a := range sum.Agents {
+		if len(a.Errors) > 0 {
+			code = worse(code, exitError)
+		}
+		for _, e := range a.Errors {
+			fmt.Fprintf(stderr, "%s: %s\n", a.Agent, e)
+		}
+	}
+	if sum.Total.Hung > 0 {
+		code = worse(code, exitHung)
+	}
+	if sum.Total.Unknown > 0 {
+		code = worse(code, exitTimeout)
+	}
+	return code
+}
+
+// writeAgents writes a line for every agent of s, and one for every
+// violation they found.
+func writeAgents(w io.Writer, s fleet.Summary) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "AGENT\tGOOS/GOARCH\tCPUS\tCPU\tBATCHES\tROUNDS\tOPS\tUNKNOWN\tVIOLATIONS\tELAPSED")
+	for _, a := range s.Agents {
+		cpu, st := a.Env.CPU, a.Stats
+		if cpu == "" {
+			cpu = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s/%s\t%d\t%s\t%d\t%d\t%d\t%d\t%d\t%v\n", a.Agent, a.Env.GOOS, a.Env.GOARCH, a.Env.NumCPU, cpu, a.Batches,
+			st.Rounds, st.Ops, st.Unknown, st.Violations, time.Duration(st.Elapsed).Round(time.Millisecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, f := range s.Findings {
+		if _, err := fmt.Fprintf(w, "Round %d (seed %d): violation on %s (%s/%s), saved to %s\n", f.Round, f.Seed, f.Agent, f.Env.GOOS, f.Env.GOARCH, f.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// agent runs the batches a coordinator hands out until it has none left.
+func agent(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("syncmapcheck agent", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		url      = fs.String("coordinator", "", "URL of the coordinator, like http://host:8700")
+		name     = fs.String("name", "", "name of this agent, the host name if empty")
+		inFlight = fs.Int("check-inflight", 4, "histories checked in the background while the next rounds run, 0 to check each round before the next")
+		logs     = logFlags(fs)
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "syncmapcheck: unexpected arguments %q\n", fs.Args())
+		return exitError
+	}
+	if *url == "" {
+		fmt.Fprintln(stderr, "syncmapcheck: agent needs -coordinator")
+		return exitError
+	}
+	log, err := logs.logger(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	a := &fleet.Agent{URL: *url, Name: *name, InFlight: *inFlight, Logger: log}
+	if err := a.Run(ctx); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			fmt.Fprintln(stderr, err)
+		}
+		return exitError
+	}
+	return exitPass
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoordinate(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var (
+		wg             sync.WaitGroup
+		code           int
+		stdout, stderr bytes.Buffer
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		code = run([]string{"coordinate", "-listen", addr, "-impl", "MutexMap", "-rounds", "6", "-ops", "10", "-batch", "2",
+			"-linger", "500ms", "-out-dir", t.TempDir()}, &stdout, &stderr)
+	}()
+	for i := 0; ; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			c.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var agentOut, agentErr bytes.Buffer
+	if c := run([]string{"agent", "-coordinator", "http://" + addr, "-name", "local", "-check-inflight", "0"}, &agentOut, &agentErr); c != exitPass {
+		t.Errorf("agent = %d: %s", c, &agentErr)
+	}
+	wg.Wait()
+	if code != exitPass {
+		t.Fatalf("coordinate = %d: %s", code, &stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "local ") || !strings.Contains(lines[1], " 3  ") {
+		t.Errorf("agents:\n%s", &stdout)
+	}
+
+	for _, args := range [][]string{
+		{"agent"},
+		{"coordinate", "-batch", "0"},
+		{"coordinate", "-impl", "nosuch"},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("run %q = %d, want %d", args, code, exitError)
+		}
+	}
+}
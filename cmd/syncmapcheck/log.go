@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// logging is the configuration of the -log-level and -log-format flags.
+type logging struct {
+	level  slog.Level
+	format string
+}
+
+// logFlags adds the logging flags to fs.
+func logFlags(fs *flag.FlagSet) *logging {
+	l := new(logging)
+	fs.TextVar(&l.level, "log-level", slog.LevelInfo, "lowest level logged: debug, which has the seed of every round, info, warn or error")
+	fs.StringVar(&l.format, "log-format", "plain", "format of the logs: plain, the messages after the time, or text or json, slog records with attributes such as impl, round and seed")
+	return l
+}
+
+// logger returns the logger writing to stderr in the format of l.
+func (l *logging) logger(stderr io.Writer) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: l.level}
+	switch l.format {
+	case "plain":
+		return slog.New(workload.LogfHandler{Logf: func(format string, args ...any) {
+			fmt.Fprintf(stderr, "%s "+format+"\n", append([]any{time.Now().Format("15:04:05")}, args...)...)
+		}, Level: l.level}), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(stderr, opts)), nil
+	}
+	return nil, fmt.Errorf("syncmapcheck: unknown log format %q, want plain, text or json", l.format)
+}
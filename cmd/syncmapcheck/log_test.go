@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestLogFormat(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-impl", "MutexMap", "-rounds", "3", "-ops", "20", "-workers", "2", "-seed", "7", "-out-dir", t.TempDir(),
+		"-log-format", "json", "-log-level", "debug"}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("run = %d: %s", code, &stderr)
+	}
+	rounds := 0
+	for line := range bytes.Lines(stderr.Bytes()) {
+		if !bytes.HasPrefix(line, []byte("{")) {
+			continue
+		}
+		var rec struct {
+			Impl  string
+			Round *int
+			Seed  uint64
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if rec.Impl != "MutexMap" {
+			t.Errorf("%s: impl %q, want MutexMap", line, rec.Impl)
+		}
+		if rec.Round != nil && rec.Seed != 0 {
+			rounds++
+		}
+	}
+	if rounds != 3 {
+		t.Errorf("%d rounds logged with their seeds, want 3:\n%s", rounds, &stderr)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-log-format", "xml"}, &stdout, &stderr); code != exitError {
+		t.Errorf("run -log-format xml = %d, want %d: %s", code, exitError, &stderr)
+	}
+}
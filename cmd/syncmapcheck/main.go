@@ -0,0 +1,359 @@
+// Command syncmapcheck runs a linearizability workload outside of go test,
+// for a soak on a machine without the module's tests or a CI job that only
+// has the binary:
+//
+//	syncmapcheck -workload promotion -rounds 5000
+//	syncmapcheck -workload spec.json -impl MutexMap -duration 12h -checkpoint soak.json
+//
+// -workload is a profile of workload.ProfileNames or a JSON spec file, and
+// the other flags override its implementation, rounds, operations, workers,
+// seed and checker timeout, as the flags of go test do. -duration soaks it
+// for that long instead, logging its progress every -progress and writing
+// it to -checkpoint, from which a soak that was stopped resumes. An
+// interrupt or SIGTERM stops it after the round running, with the rounds
+// in flight checked and the checkpoint written, one of its own in -out-dir
+// without -checkpoint, and -resume goes on with it, without any of the
+// flags of the workload again:
+//
+//	syncmapcheck -resume mutexmap_checkpoint_123456.json
+//
+// The visualization and history of a violation are written to -out-dir
+// as <impl>_violation_<round>_<time>.html and .json, the files the tests
+// write, and the violation is rendered to standard output. The invariant
+// probes of the tests are not run: only the histories are checked.
+//
+// -cpuprofile, -memprofile and -mutexprofile write the profiles of a run,
+// a matrix or a daemon, whose CPU samples are labeled with the phase of
+// workload.Spec.Run they were taken in, for go tool pprof -tagfocus to
+// tell running the rounds from checking them; daemon -pprof serves
+// net/http/pprof under /debug/pprof/ of -listen.
+//
+// -log-format plain writes the logs of any of them as the messages after
+// the time, and text or json as log/slog records, with the
+// implementation, round and seed, the cell of a matrix or the batch and
+// agent of a daemon or fleet as attributes; -log-level debug adds the
+// seed of every round, and warn keeps the rounds that hung and the
+// violations that a matrix, daemon or fleet goes on past.
+//
+// recheck checks the rounds of saved histories again without running
+// anything, with a model of model.Names and a timeout of its own, and
+// prints the verdict of every round, to try a model on histories that
+// are known to pass or fail:
+//
+//	syncmapcheck recheck -model snapshot -timeout 1m soak/TestSyncMap.hist
+//	syncmapcheck recheck -round 12 -skew 2ms a.json b.hist store/history.edn
+//
+// Each file is a stream recorded with -record, or a history saved as
+// JSON, CSV or a Jepsen EDN file, see workload.LoadRecords. -round checks
+// only that round of each, and a round that is not linearizable is
+// written to -out-dir, as recheck_<model>_violation_<round>_<time>.html
+// and .json.
+//
+// visualize writes porcupine's visualization of a round of a saved
+// history again, for a run that only kept the histories, or of the part of
+// it that the window of -from and -to, -clients and -keys keep, see
+// workload.Restrict. -format svg draws where the search of a round that
+// is not linearizable got stuck instead, and -format tla writes it as a
+// TLA+ trace of the map model, with a TLC config next to it, for the
+// MapTrace spec of tla/ to check, see workload.WriteTLA; -o names the
+// file, the history's with the extension of the format by default:
+//
+//	syncmapcheck visualize -round 12 -keys k3 soak/TestSyncMap.hist
+//	syncmapcheck visualize -from 1ms -to 3ms -clients 0,4 -format svg round.json
+//
+// crosscheck checks random histories of up to -ops operations, at most
+// bruteforce.MaxOperations, with porcupine and with the brute force of
+// package bruteforce, which tries every order of their operations, to
+// catch a model or a use of porcupine that gets a history wrong. A
+// history they disagree on is written to -out-dir as
+// crosscheck_<model>_mismatch_<seed>_<index>.json, and the status is 1:
+//
+//	syncmapcheck crosscheck -model snapshot -histories 100000 -ops 12 -clients 4
+//
+// matrix runs the workload once for every combination of the lists of
+// -impls, -workers, -gomaxprocs and of the -mix flags, with the same seed,
+// and prints a table of them, or a Markdown summary with -markdown. A
+// violation is saved to -out-dir and the rest of the matrix still runs;
+// -report and -results write the HTML report and JSON results of all the
+// combinations, whose latencies the report compares by implementation:
+//
+//	syncmapcheck matrix -impls all -workers 2,8 -gomaxprocs 1,4,numcpu -mix Load=8,Store=1 -mix LoadOrStore=2,LoadAndDelete=1 -report matrix.html
+//
+// coordinate runs the rounds of a workload on several machines, to try
+// other CPUs and architectures: it splits them into batches of -batch
+// rounds, serves them on -listen to the agents, which run them with the
+// seeds they have in the whole run, and saves the violations they report
+// to -out-dir, as <agent>_violation_<round>_<time>.html and .json. A batch
+// whose agent does not report within -lease-timeout goes to another one.
+// Once every batch is in, it prints a line per agent:
+//
+//	syncmapcheck coordinate -workload promotion -rounds 100000 -batch 500
+//	syncmapcheck agent -coordinator http://coordinator:8700 -name arm64
+//
+// daemon runs the workload for a torture box: in batches of -batch, each
+// with a random seed, with a litmus case of -litmus after each batch, in
+// turn, until it is interrupted or -for elapses. It serves a status page
+// on -listen with the rounds checked by result, the last checks and their
+// durations, the rates of the forbidden outcomes of the litmus cases and
+// links to the violations, which it saves to -out-dir and serves from
+// /files/, and /status.json and /metrics next to it. A violation does not
+// stop it; an interrupt or SIGTERM waits for the round or litmus case
+// running:
+//
+//	syncmapcheck daemon -listen :8080 -workload promotion -litmus SB:Map.Load,MP:Map,IRIW:Map -out-dir /var/lib/syncmapcheck
+//
+// Exit status, of all of them, is 0 if every round is linearizable, 1 if
+// one is not, 2 for usage and other errors, 3 if the checker timed out
+// on a round and none was found not to be linearizable, 4 if a round of
+// a workload hung past the watchdog of the spec, and 5 if it was stopped
+// before its end; that of a matrix is the worst of its combinations, a
+// violation first, and that of coordinate the worst of its batches, as
+// is that of daemon. An agent exits with 0 once the coordinator has no
+// batch left, whatever the batches found.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// The exit statuses.
+const (
+	exitPass      = 0
+	exitViolation = 1
+	exitError     = 2
+	exitTimeout   = 3
+	exitHung      = 4
+	exitStopped   = 5
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// options are those of a run besides its spec.
+type options struct {
+	workload.RunOptions
+	outDir string
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "recheck":
+			return recheck(args[1:], stdout, stderr)
+		case "visualize":
+			return visualize(args[1:], stdout, stderr)
+		case "crosscheck":
+			return crosscheck(args[1:], stdout, stderr)
+		case "matrix":
+			return matrix(args[1:], stdout, stderr)
+		case "coordinate":
+			return coordinate(args[1:], stdout, stderr)
+		case "agent":
+			return agent(args[1:], stdout, stderr)
+		case "daemon":
+			return daemon(args[1:], stdout, stderr)
+		}
+	}
+	fs := flag.NewFlagSet("syncmapcheck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		spec       = fs.String("workload", "default", "workload profile ("+strings.Join(workload.ProfileNames(), ", ")+") or JSON spec file")
+		impl       = fs.String("impl", "", "implementation under test, the spec's if empty: "+strings.Join(implNames(), ", "))
+		rounds     = fs.Int("rounds", 0, "rounds to run, 0 for the spec's")
+		ops        = fs.Int("ops", 0, "operations per worker and round, 0 for the spec's")
+		workers    = fs.Int("workers", 0, "concurrent workers per round, 0 for the spec's")
+		seed       = fs.Uint64("seed", 0, "seed of the first round's operations, 0 for the spec's or a random one")
+		timeout    = fs.Duration("check-timeout", 0, "porcupine checker timeout per round, 0 for the spec's")
+		inFlight   = fs.Int("check-inflight", 4, "histories checked in the background while the next rounds run, 0 to check each round before the next")
+		duration   = fs.Duration("duration", 0, "soak for this long instead of a number of rounds")
+		progress   = fs.Duration("progress", 0, "interval of the progress logs and checkpoints, 0 for a minute in a soak")
+		checkpoint = fs.String("checkpoint", "", "file the soak is checkpointed to and resumes from")
+		outDir     = fs.String("out-dir", ".", "directory the files of a violation are written to")
+		verbose    = fs.Bool("v", false, "log the seed of every round")
+		resume     = fs.String("resume", "", "checkpoint of a stopped run to go on with, which has its workload, overrides and duration")
+		prof       = profileFlags(fs)
+		logs       = logFlags(fs)
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "syncmapcheck: unexpected arguments %q\n", fs.Args())
+		return exitError
+	}
+	var s workload.Spec
+	if *resume != "" {
+		var conflict []string
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "workload", "impl", "rounds", "ops", "workers", "seed", "check-timeout", "duration", "checkpoint":
+				conflict = append(conflict, "-"+f.Name)
+			}
+		})
+		if len(conflict) > 0 {
+			fmt.Fprintf(stderr, "syncmapcheck: -resume goes on with the run of its checkpoint, without %s\n", strings.Join(conflict, ", "))
+			return exitError
+		}
+		var err error
+		if s, *duration, err = workload.LoadCheckpoint(*resume); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		*checkpoint = *resume
+	} else {
+		var ok bool
+		if s, ok = workload.Profile(*spec); !ok {
+			var err error
+			if s, err = workload.Load(*spec); err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+		}
+		if *impl != "" {
+			s.Impl = *impl
+		}
+		if *rounds > 0 {
+			s.Rounds = *rounds
+		}
+		if *ops > 0 {
+			s.Ops = *ops
+		}
+		// Roles set their own workers.
+		if *workers > 0 && len(s.Roles) == 0 {
+			s.Workers = *workers
+		}
+		if *seed != 0 {
+			s.Seed = *seed
+		}
+		if *timeout > 0 {
+			s.Checker.Timeout = workload.Duration(*timeout)
+		}
+	}
+
+	log, err := logs.logger(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	opts := options{outDir: *outDir}
+	opts.Logger, opts.Verbose, opts.InFlight = log, *verbose, *inFlight
+	opts.Duration, opts.Progress, opts.Checkpoint = *duration, *progress, *checkpoint
+	if opts.Duration > 0 {
+		// The progress logs replace the seed of every round.
+		opts.Verbose = false
+		if opts.Progress == 0 {
+			opts.Progress = time.Minute
+		}
+	}
+	return prof.run(stderr, func() int { return check(&s, opts, stdout, stderr) })
+}
+
+// check runs s and returns the exit status. An interrupt or SIGTERM stops
+// it after the round running, with a checkpoint to resume from, of
+// opts.Checkpoint or one of its own in opts.outDir; a second one quits at
+// once.
+func check(s *workload.Spec, opts options, stdout, stderr io.Writer) int {
+	sigs, stopped, finished := make(chan os.Signal, 1), make(chan struct{}), make(chan struct{})
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	defer close(finished)
+	go func() {
+		select {
+		case <-sigs:
+			signal.Stop(sigs)
+			fmt.Fprintln(stderr, "stopping after the round running, interrupt again to quit at once")
+			close(stopped)
+		case <-finished:
+		}
+	}()
+	opts.Stop = stopped
+	own := opts.Checkpoint == ""
+	if own {
+		if err := os.MkdirAll(opts.outDir, 0o755); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		// A fresh name, for the run not to resume from another one.
+		f, err := os.CreateTemp(opts.outDir, workload.FilePrefix(s.Impl)+"_checkpoint_*.json")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		f.Close()
+		os.Remove(f.Name())
+		opts.Checkpoint = f.Name()
+	}
+
+	var summary workload.Stats
+	opts.Summary = &summary
+	err := s.Run(opts.RunOptions)
+	fmt.Fprintf(stderr, "%s: %s\n", s.Impl, summary)
+	if errors.Is(err, workload.ErrStopped) {
+		fmt.Fprintf(stderr, "stopped, go on with: syncmapcheck -resume %s\n", opts.Checkpoint)
+	} else if own {
+		os.Remove(opts.Checkpoint)
+	}
+
+	var v *workload.Violation
+	if errors.As(err, &v) {
+		if v.Key != "" {
+			fmt.Fprintf(stdout, "Round %d (seed %d), key %s: %s violation\n", v.Round, v.Seed, v.Key, s.Impl)
+		} else {
+			fmt.Fprintf(stdout, "Round %d (seed %d): %s violation\n", v.Round, v.Seed, s.Impl)
+		}
+		if err := v.Render(stdout, false); err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+		base := filepath.Join(opts.outDir, workload.ViolationName(s.Impl, v.Round))
+		if err := v.WriteFiles(base); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "saved to %s.html and %s.json\n", base, base)
+	}
+	return status(err, summary)
+}
+
+// status is the exit status of a run that returned err with summary.
+func status(err error, summary workload.Stats) int {
+	var (
+		v *workload.Violation
+		h *workload.Hung
+	)
+	switch {
+	case errors.As(err, &v):
+		return exitViolation
+	case errors.As(err, &h):
+		return exitHung
+	case errors.Is(err, workload.ErrStopped):
+		return exitStopped
+	case err != nil:
+		return exitError
+	case summary.Hung > 0:
+		return exitHung
+	case summary.Unknown > 0:
+		return exitTimeout
+	}
+	return exitPass
+}
+
+func implNames() []string {
+	var names []string
+	for _, impl := range mapimpl.All() {
+		names = append(names, impl.Name)
+	}
+	return names
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// forgetful drops every Store.
+type forgetful struct{ sync.Map }
+
+func (*forgetful) Store(key, value any) {}
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-impl", "MutexMap", "-rounds", "20", "-ops", "20", "-workers", "2", "-seed", "7"}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("run = %d: %s", code, &stderr)
+	}
+	if !strings.Contains(stderr.String(), "impl=MutexMap rounds=20 ops=20 workers=2") || !strings.Contains(stderr.String(), "MutexMap: 20 rounds") {
+		t.Errorf("log %s", &stderr)
+	}
+
+	for _, args := range [][]string{
+		{"-workload", "nosuch.json"},
+		{"-impl", "nosuch"},
+		{"-rounds", "1", "extra"},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("run %q = %d, want %d", args, code, exitError)
+		}
+	}
+}
+
+func TestCheckViolation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	s, err := workload.New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(1).Rounds(3).Ops(20).Seed(1).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := check(&s, options{outDir: dir}, &stdout, &stderr); code != exitViolation {
+		t.Fatalf("check = %d, want %d: %s", code, exitViolation, &stderr)
+	}
+	if !strings.Contains(stdout.String(), "Round 0 (seed 1): forgetful violation") || !strings.Contains(stdout.String(), "saved to ") {
+		t.Errorf("output %s", &stdout)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "forgetful_violation_0_*"))
+	if len(files) != 2 {
+		t.Fatalf("files %v, want the html and json", files)
+	}
+	for _, f := range files {
+		if fi, err := os.Stat(f); err != nil || fi.Size() == 0 {
+			t.Errorf("%s: %v", f, err)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	dir := t.TempDir()
+	cp := filepath.Join(dir, "soak.json")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-impl", "MutexMap", "-rounds", "20", "-ops", "10", "-checkpoint", cp}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("run = %d: %s", code, &stderr)
+	}
+	stderr.Reset()
+	if code := run([]string{"-resume", cp, "-out-dir", dir}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("run -resume = %d: %s", code, &stderr)
+	}
+	if !strings.Contains(stderr.String(), "resuming at round 20") || !strings.Contains(stderr.String(), "impl=MutexMap rounds=20 ops=10") {
+		t.Errorf("log %s", &stderr)
+	}
+
+	// A run without -checkpoint keeps one of its own only if it is stopped.
+	if code := run([]string{"-impl", "MutexMap", "-rounds", "5", "-ops", "10", "-out-dir", dir}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("run = %d: %s", code, &stderr)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*_checkpoint_*")); len(files) > 0 {
+		t.Errorf("checkpoints left: %v", files)
+	}
+
+	for _, args := range [][]string{
+		{"-resume", cp, "-rounds", "5"},
+		{"-resume", filepath.Join(dir, "none.json")},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("run %q = %d, want %d", args, code, exitError)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	for _, tt := range []struct {
+		err     error
+		summary workload.Stats
+		want    int
+	}{
+		{nil, workload.Stats{Rounds: 10}, exitPass},
+		{nil, workload.Stats{Rounds: 10, Unknown: 1}, exitTimeout},
+		{nil, workload.Stats{Rounds: 10, Unknown: 1, Hung: 1}, exitHung},
+		{&workload.Violation{}, workload.Stats{Unknown: 1}, exitViolation},
+		{&workload.Hung{Stage: "round"}, workload.Stats{}, exitHung},
+		{errors.New("probe failed"), workload.Stats{}, exitError},
+		{workload.ErrStopped, workload.Stats{Unknown: 1}, exitStopped},
+	} {
+		if got := status(tt.err, tt.summary); got != tt.want {
+			t.Errorf("status(%v, %+v) = %d, want %d", tt.err, tt.summary, got, tt.want)
+		}
+	}
+}
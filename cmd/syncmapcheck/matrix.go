@@ -0,0 +1,342 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/report"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// A cell is a combination of the matrix.
+type cell struct {
+	impl           string
+	workers, procs int
+	mix            string
+}
+
+func (c cell) String() string {
+	str := fmt.Sprintf("%s/workers=%d/procs=%d", c.impl, c.workers, c.procs)
+	if c.mix != "" {
+		str += "/mix=" + c.mix
+	}
+	return str
+}
+
+// matrix runs a workload once for every combination of implementations,
+// workers, mixes and GOMAXPROCS settings, and writes one report of them
+// all.
+func matrix(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("syncmapcheck matrix", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		spec     = fs.String("workload", "default", "workload profile ("+strings.Join(workload.ProfileNames(), ", ")+") or JSON spec file")
+		impls    = fs.String("impls", "all", "comma-separated implementations under test, or all: "+strings.Join(implNames(), ", "))
+		workers  = fs.String("workers", "", "comma-separated worker counts, the spec's if empty")
+		procs    = fs.String("gomaxprocs", "", "comma-separated GOMAXPROCS settings, positive numbers or numcpu, the current one if empty")
+		rounds   = fs.Int("rounds", 0, "rounds of every combination, 0 for the spec's")
+		ops      = fs.Int("ops", 0, "operations per worker and round, 0 for the spec's")
+		seed     = fs.Uint64("seed", 0, "seed of the first round's operations of every combination, 0 for the spec's or a random one")
+		timeout  = fs.Duration("check-timeout", 0, "porcupine checker timeout per round, 0 for the spec's")
+		inFlight = fs.Int("check-inflight", 4, "histories checked in the background while the next rounds run, 0 to check each round before the next")
+		outDir   = fs.String("out-dir", ".", "directory the files of violations are written to")
+		html     = fs.String("report", "", "file to write the HTML report of the matrix to")
+		results  = fs.String("results", "", "file to write the JSON results of the matrix to")
+		markdown = fs.Bool("markdown", false, "print a Markdown summary instead of the table")
+		prof     = profileFlags(fs)
+		logs     = logFlags(fs)
+		mixes    []string
+	)
+	fs.Func("mix", "operation mix, as weights by operation like Load=8,Store=1, repeated for several, the spec's if not given", func(s string) error {
+		mixes = append(mixes, s)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "syncmapcheck: unexpected arguments %q\n", fs.Args())
+		return exitError
+	}
+	base, ok := workload.Profile(*spec)
+	if !ok {
+		var err error
+		if base, err = workload.Load(*spec); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if *rounds > 0 {
+		base.Rounds = *rounds
+	}
+	if *ops > 0 {
+		base.Ops = *ops
+	}
+	if *seed != 0 {
+		base.Seed = *seed
+	}
+	if *timeout > 0 {
+		base.Checker.Timeout = workload.Duration(*timeout)
+	}
+	if (len(mixes) > 0 || *workers != "") && len(base.Roles) > 0 {
+		fmt.Fprintf(stderr, "syncmapcheck: %s has roles with workers and mixes of their own, -workers and -mix do not apply\n", *spec)
+		return exitError
+	}
+	if base.Seed == 0 {
+		// Every combination runs the same operations, as far as they are
+		// the same.
+		base.Seed = rand.Uint64()
+	}
+	cs, err := cells(*impls, *workers, *procs, mixes)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	rep := report.New("syncmapcheck matrix")
+	fs.Visit(func(f *flag.Flag) {
+		rep.Flags = append(rep.Flags, f.Name+"="+f.Value.String())
+	})
+	log, err := logs.logger(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	// Every spec is valid before any runs, so a typo in the last mix does
+	// not wait for the rest of the matrix.
+	specs := make([]workload.Spec, len(cs))
+	for i, c := range cs {
+		s := base
+		s.Impl, s.Name = c.impl, c.String()
+		if c.workers > 0 {
+			s.Workers = c.workers
+		}
+		if c.mix != "" {
+			s.Mix, _ = parseMix(c.mix)
+		}
+		if err := s.Validate(); err != nil {
+			fmt.Fprintf(stderr, "syncmapcheck: %s: %v\n", c, err)
+			return exitError
+		}
+		specs[i] = s
+	}
+	var rows []row
+	code := prof.run(stderr, func() int {
+		code := exitPass
+		for i, c := range cs {
+			runtime.GOMAXPROCS(c.procs)
+			fmt.Fprintf(stderr, "=== %s\n", c)
+			r := runCell(rep, &specs[i], *inFlight, *outDir, log.With("cell", c.String()), stderr)
+			r.cell = c
+			rows = append(rows, r)
+			code = worse(code, r.code)
+		}
+		return code
+	})
+	rep.End = time.Now()
+
+	if *markdown {
+		err = rep.WriteMarkdown(stdout)
+	} else {
+		err = writeTable(stdout, rows)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	for _, out := range []struct {
+		file  string
+		write func(io.Writer) error
+	}{
+		{*html, func(w io.Writer) error { return rep.WriteHTML(w, filepath.Dir(*html)) }},
+		{*results, rep.WriteJSON},
+	} {
+		if out.file == "" {
+			continue
+		}
+		f, err := os.Create(out.file)
+		if err == nil {
+			err = out.write(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "syncmapcheck: %s: %v\n", out.file, err)
+			return exitError
+		}
+	}
+	return code
+}
+
+// A row is the outcome of a cell.
+type row struct {
+	cell
+	stats workload.Stats
+	code  int
+}
+
+// runCell runs s, adds it to rep and returns its outcome. A violation is
+// saved to outDir and does not stop the matrix.
+func runCell(rep *report.Report, s *workload.Spec, inFlight int, outDir string, log *slog.Logger, stderr io.Writer) row {
+	var (
+		wl      = rep.Workload(s.Name, s.Impl, s.String())
+		summary workload.Stats
+	)
+	err := s.Run(workload.RunOptions{
+		AfterRound: func(_ int, _ mapimpl.MapUnderTest, history []porcupine.Operation) error {
+			wl.AddRound((&workload.History{Operations: history}).Stats())
+			return nil
+		},
+		Logger:   log,
+		InFlight: inFlight,
+		Summary:  &summary,
+		Checked:  wl.AddChecked,
+	})
+	var (
+		v    *workload.Violation
+		file string
+	)
+	if errors.As(err, &v) {
+		base := filepath.Join(outDir, workload.ViolationName(s.Impl, v.Round))
+		if werr := v.WriteFiles(base); werr != nil {
+			log.Error(werr.Error())
+		} else {
+			file = base + ".html"
+		}
+		log.Error(fmt.Sprintf("%s: %v, saved to %s", s.Name, v, file), "round", v.Round, "seed", v.Seed, "file", file)
+	} else if err != nil {
+		log.Error(fmt.Sprintf("%s: %v", s.Name, err), "error", err)
+	}
+	code := status(err, summary)
+	wl.Done(summary, code == exitViolation || code == exitError || code == exitHung, err, file)
+	return row{stats: summary, code: code}
+}
+
+// worse returns the exit status that matters most of a and b: a
+// violation, then an error, a round that hung, a stop and a checker
+// timeout.
+func worse(a, b int) int {
+	rank := map[int]int{exitPass: 0, exitTimeout: 1, exitStopped: 2, exitHung: 3, exitError: 4, exitViolation: 5}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// cells returns the combinations of the lists of implementations, worker
+// counts, GOMAXPROCS settings and mixes, the last varying fastest.
+func cells(impls, workers, procs string, mixes []string) ([]cell, error) {
+	var implList []string
+	if impls == "all" {
+		implList = implNames()
+	} else {
+		implList = strings.Split(impls, ",")
+	}
+	for _, name := range implList {
+		if _, ok := mapimpl.Lookup(name); !ok {
+			return nil, fmt.Errorf("syncmapcheck: unknown implementation %q", name)
+		}
+	}
+	workerList, err := numbers("-workers", workers, []int{0}, false)
+	if err != nil {
+		return nil, err
+	}
+	procList, err := numbers("-gomaxprocs", procs, []int{runtime.GOMAXPROCS(0)}, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(mixes) == 0 {
+		mixes = []string{""}
+	}
+	for _, m := range mixes {
+		if m == "" {
+			continue
+		}
+		if _, err := parseMix(m); err != nil {
+			return nil, err
+		}
+	}
+	var cs []cell
+	for _, impl := range implList {
+		for _, w := range workerList {
+			for _, p := range procList {
+				for _, m := range mixes {
+					cs = append(cs, cell{impl: impl, workers: w, procs: p, mix: m})
+				}
+			}
+		}
+	}
+	return cs, nil
+}
+
+// numbers parses a comma-separated list of positive numbers, and of
+// numcpu if allowed, def if it is empty.
+func numbers(name, list string, def []int, numcpu bool) ([]int, error) {
+	if list == "" {
+		return def, nil
+	}
+	var ns []int
+	for f := range strings.SplitSeq(list, ",") {
+		f = strings.TrimSpace(f)
+		if numcpu && f == "numcpu" {
+			ns = append(ns, runtime.NumCPU())
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("syncmapcheck: %s: invalid value %q, want a positive number", name, f)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+// parseMix parses a mix of the form of Spec.String, Load=8,Store=1.
+func parseMix(s string) (map[string]int, error) {
+	mix := make(map[string]int)
+	for f := range strings.SplitSeq(s, ",") {
+		op, w, ok := strings.Cut(strings.TrimSpace(f), "=")
+		n, err := strconv.Atoi(w)
+		if !ok || err != nil || n < 0 {
+			return nil, fmt.Errorf("syncmapcheck: -mix %s: want weights by operation like Load=8,Store=1", s)
+		}
+		mix[op] = n
+	}
+	return mix, nil
+}
+
+// writeTable writes a line for every row.
+func writeTable(w io.Writer, rows []row) error {
+	results := map[int]string{exitPass: "ok", exitViolation: "VIOLATION", exitError: "FAIL", exitTimeout: "unknown", exitHung: "HUNG"}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMPL\tWORKERS\tGOMAXPROCS\tMIX\tRESULT\tROUNDS\tOPS\tUNKNOWN\tELAPSED\tCHECKING")
+	for _, r := range rows {
+		mix, workers := r.mix, strconv.Itoa(r.workers)
+		if mix == "" {
+			mix = "-"
+		}
+		if r.workers == 0 {
+			workers = "-"
+		}
+		st := r.stats
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%d\t%d\t%d\t%v\t%v\n", r.impl, workers, r.procs, mix, results[r.code], st.Rounds, st.Ops, st.Unknown,
+			time.Duration(st.Elapsed).Round(time.Millisecond), time.Duration(st.Checking).Round(time.Millisecond))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatrix(t *testing.T) {
+	dir := t.TempDir()
+	results := filepath.Join(dir, "results.json")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"matrix", "-impls", "sync.Map,MutexMap", "-workers", "1,2", "-gomaxprocs", "1,2",
+		"-mix", "Load=1,Store=1", "-mix", "LoadOrStore=2,LoadAndDelete=1", "-rounds", "5", "-ops", "10",
+		"-results", results, "-report", filepath.Join(dir, "report.html")}, &stdout, &stderr)
+	if code != exitPass {
+		t.Fatalf("matrix = %d: %s", code, &stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 1+16 || !strings.HasPrefix(lines[1], "sync.Map  1        1           Load=1,Store=1") {
+		t.Errorf("table:\n%s", &stdout)
+	}
+
+	b, err := os.ReadFile(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Workloads []struct {
+			Name  string `json:"name"`
+			Procs int    `json:"gomaxprocs"`
+		} `json:"workloads"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil || len(doc.Workloads) != 16 {
+		t.Fatalf("results %s: %v", b, err)
+	}
+	if w := doc.Workloads[15]; w.Name != "MutexMap/workers=2/procs=2/mix=LoadOrStore=2,LoadAndDelete=1" || w.Procs != 2 {
+		t.Errorf("last workload %+v", w)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "report.html")); err != nil {
+		t.Error(err)
+	}
+
+	for _, args := range [][]string{
+		{"matrix", "-impls", "nosuch"},
+		{"matrix", "-workers", "0"},
+		{"matrix", "-gomaxprocs", "two"},
+		{"matrix", "-mix", "Load"},
+		{"matrix", "-mix", "Load=1,Delete=1"},
+		{"matrix", "-workload", "../../workload/testdata/roles.json", "-mix", "Load=1"},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("run %q = %d, want %d", args, code, exitError)
+		}
+	}
+}
+
+func TestWorse(t *testing.T) {
+	code := exitPass
+	for _, c := range []int{exitTimeout, exitPass, exitHung, exitError, exitTimeout, exitViolation, exitError} {
+		code = worse(code, c)
+	}
+	if code != exitViolation {
+		t.Errorf("worse = %d, want %d", code, exitViolation)
+	}
+}
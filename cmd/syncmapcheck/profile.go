@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profiles are the files of the -cpuprofile, -memprofile and -mutexprofile
+// flags.
+type profiles struct {
+	cpu, mem, mutex string
+}
+
+// profileFlags adds the profile flags to fs.
+func profileFlags(fs *flag.FlagSet) *profiles {
+	p := new(profiles)
+	fs.StringVar(&p.cpu, "cpuprofile", "", "write a CPU profile to this file, its samples labeled with the phase, run, record or check, to tell apart with go tool pprof -tagfocus")
+	fs.StringVar(&p.mem, "memprofile", "", "write a heap profile to this file at the end")
+	fs.StringVar(&p.mutex, "mutexprofile", "", "write a profile of the mutex contention to this file at the end")
+	return p
+}
+
+// run runs f with the profiles, and returns its exit status, or that of
+// an error if a profile could not be written.
+func (p *profiles) run(stderr io.Writer, f func() int) int {
+	stop, err := p.start()
+	if err != nil {
+		fmt.Fprintf(stderr, "syncmapcheck: %v\n", err)
+		return exitError
+	}
+	code := f()
+	if err := stop(); err != nil {
+		fmt.Fprintf(stderr, "syncmapcheck: %v\n", err)
+		return worse(code, exitError)
+	}
+	return code
+}
+
+// start starts the CPU profile and the sampling of mutex contention, and
+// returns a function writing the profiles.
+func (p *profiles) start() (stop func() error, err error) {
+	var cpu *os.File
+	if p.cpu != "" {
+		if cpu, err = os.Create(p.cpu); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, err
+		}
+	}
+	if p.mutex != "" {
+		runtime.SetMutexProfileFraction(1)
+	}
+	return func() error {
+		var errs []error
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			errs = append(errs, cpu.Close())
+		}
+		if p.mem != "" {
+			// The heap profile is that of the last collection.
+			runtime.GC()
+			errs = append(errs, writeProfile(p.mem, "heap"))
+		}
+		if p.mutex != "" {
+			errs = append(errs, writeProfile(p.mutex, "mutex"))
+			runtime.SetMutexProfileFraction(0)
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+// writeProfile writes the named profile to file.
+func writeProfile(file, name string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = pprof.Lookup(name).WriteTo(f, 0)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	cpu, mem, mutex := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof"), filepath.Join(dir, "mutex.pprof")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-impl", "MutexMap", "-duration", "500ms", "-ops", "50", "-workers", "2", "-out-dir", dir,
+		"-cpuprofile", cpu, "-memprofile", mem, "-mutexprofile", mutex}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("run = %d: %s", code, &stderr)
+	}
+	for _, f := range []string{cpu, mem, mutex} {
+		if fi, err := os.Stat(f); err != nil || fi.Size() == 0 {
+			t.Errorf("%s: %v", f, err)
+		}
+	}
+	// The labels are in the string table of the profile.
+	f, err := os.Open(cpu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("phase")) || !bytes.Contains(b, []byte("check")) {
+		t.Error("CPU profile without the phase labels")
+	}
+
+	if code := run([]string{"-rounds", "1", "-cpuprofile", filepath.Join(dir, "none", "cpu.pprof")}, &stdout, &stderr); code != exitError {
+		t.Errorf("run with an unwritable profile = %d, want %d", code, exitError)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// recheck checks the rounds of saved histories again and prints the
+// verdict of each, see workload.Record.Recheck.
+func recheck(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("syncmapcheck recheck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		modelName = fs.String("model", "map", "model to check the histories with: "+strings.Join(model.Names(), ", "))
+		timeout   = fs.Duration("timeout", 0, "checker timeout per round, 0 for none")
+		skew      = fs.Duration("skew", 0, "skew of the clocks that timed the histories, by which every operation is widened")
+		round     = fs.Int("round", -1, "only check this round of each file, every round if negative")
+		outDir    = fs.String("out-dir", ".", "directory the files of a violation are written to")
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "syncmapcheck: no history files given")
+		return exitError
+	}
+	if *skew < 0 {
+		fmt.Fprintln(stderr, "syncmapcheck: -skew must not be negative")
+		return exitError
+	}
+	m, err := model.Lookup(*modelName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	var (
+		checked, illegal, unknown int
+		checking                  time.Duration
+	)
+	for _, path := range fs.Args() {
+		records, err := workload.LoadRecords(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		for _, r := range records {
+			if *round >= 0 && r.Round != *round {
+				continue
+			}
+			if r.History, err = workload.Widen(r.History, *skew); err != nil {
+				fmt.Fprintf(stderr, "%s: round %d: %v\n", path, r.Round, err)
+				return exitError
+			}
+			res := r.Recheck(m, *timeout)
+			checked++
+			checking += res.Checking
+			fmt.Fprintf(stdout, "%s: round %d (seed %d): %d operations, %s in %v\n", path, res.Round, res.Seed, res.Ops, res.Result, res.Checking.Round(time.Millisecond))
+			switch res.Result {
+			case porcupine.Unknown:
+				unknown++
+			case porcupine.Illegal:
+				illegal++
+				base := filepath.Join(*outDir, workload.ViolationName("recheck_"+*modelName, res.Round))
+				if err := res.Violation.WriteFiles(base); err != nil {
+					fmt.Fprintln(stderr, err)
+					return exitError
+				}
+				fmt.Fprintf(stdout, "saved to %s.html and %s.json\n", base, base)
+			}
+		}
+	}
+	if checked == 0 {
+		fmt.Fprintf(stderr, "syncmapcheck: no round %d in the files given\n", *round)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "%d rounds checked with the %s model in %v, %d not linearizable, %d timed out\n", checked, *modelName, checking.Round(time.Millisecond), illegal, unknown)
+	switch {
+	case illegal > 0:
+		return exitViolation
+	case unknown > 0:
+		return exitTimeout
+	}
+	return exitPass
+}
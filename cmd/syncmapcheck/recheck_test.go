@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+func TestRecheck(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, ops ...porcupine.Operation) string {
+		b, err := json.Marshal(&workload.History{Operations: ops})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	store := porcupine.Operation{Input: model.Input{Op: model.Store, Key: "k", Val: 1}, Call: 0, Output: model.Output{}, Return: 10}
+	ok := write("ok.json", store,
+		porcupine.Operation{ClientId: 1, Input: model.Input{Op: model.Load, Key: "k"}, Call: 20, Output: model.Output{Found: true, Val: 1}, Return: 30})
+	// The Load returns after the Store and misses its value.
+	stale := write("stale.json", store,
+		porcupine.Operation{ClientId: 1, Input: model.Input{Op: model.Load, Key: "k"}, Call: 20, Output: model.Output{}, Return: 30})
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"recheck", ok}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("recheck = %d: %s", code, &stderr)
+	}
+	if !strings.Contains(stdout.String(), "ok.json: round 0 (seed 0): 2 operations, Ok in ") {
+		t.Errorf("output %s", &stdout)
+	}
+
+	stdout.Reset()
+	out := filepath.Join(dir, "out")
+	if code := run([]string{"recheck", "-out-dir", out, ok, stale}, &stdout, &stderr); code != exitViolation {
+		t.Fatalf("recheck = %d, want %d: %s", code, exitViolation, &stderr)
+	}
+	if !strings.Contains(stdout.String(), "stale.json: round 0 (seed 0): 2 operations, Illegal in ") {
+		t.Errorf("output %s", &stdout)
+	}
+	if files, _ := filepath.Glob(filepath.Join(out, "recheck_map_violation_0_*")); len(files) != 2 {
+		t.Errorf("files %v, want the html and json", files)
+	}
+	// Widened by the skew, the operations overlap and the Load can go first.
+	if code := run([]string{"recheck", "-skew", "10ns", "-out-dir", out, stale}, &stdout, &stderr); code != exitPass {
+		t.Errorf("recheck with a skew = %d, want %d", code, exitPass)
+	}
+
+	for _, args := range [][]string{
+		{"recheck"},
+		{"recheck", "-model", "nosuch", ok},
+		{"recheck", filepath.Join(dir, "nosuch.json")},
+		{"recheck", "-round", "3", ok},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("run %q = %d, want %d", args, code, exitError)
+		}
+	}
+}
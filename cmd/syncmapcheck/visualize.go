@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// visualize writes the visualization of a round of a saved history, or
+// of the part of it a workload.Filter keeps.
+func visualize(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("syncmapcheck visualize", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		modelName = fs.String("model", "map", "model to check the history with: "+strings.Join(model.Names(), ", "))
+		timeout   = fs.Duration("timeout", 0, "checker timeout, 0 for none")
+		round     = fs.Int("round", -1, "round of the file to visualize, needed if it has several")
+		format    = fs.String("format", "html", "format of the visualization: html, svg for a history that is not linearizable, or tla for a TLA+ trace with its TLC config, of the map model")
+		out       = fs.String("o", "", "file to write the visualization to, the history file with the extension of -format if empty")
+		from      = fs.Duration("from", 0, "start of the window to keep, after the first call of the history")
+		to        = fs.Duration("to", 0, "end of the window to keep, after the first call of the history, 0 for the end of the history")
+		clients   = fs.String("clients", "", "comma-separated clients to keep, all if empty")
+		keys      = fs.String("keys", "", "comma-separated keys to keep, all if empty")
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "syncmapcheck: visualize takes one history file")
+		return exitError
+	}
+	if *format != "html" && *format != "svg" && *format != "tla" {
+		fmt.Fprintf(stderr, "syncmapcheck: unknown format %q, want html, svg or tla\n", *format)
+		return exitError
+	}
+	if *format == "tla" && *modelName != "map" {
+		fmt.Fprintln(stderr, "syncmapcheck: a TLA+ trace is of the map model")
+		return exitError
+	}
+	m, err := model.Lookup(*modelName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	f := workload.Filter{From: *from, To: *to}
+	if *keys != "" {
+		f.Keys = strings.Split(*keys, ",")
+	}
+	for c := range strings.SplitSeq(*clients, ",") {
+		if c == "" {
+			continue
+		}
+		id, err := strconv.Atoi(c)
+		if err != nil {
+			fmt.Fprintf(stderr, "syncmapcheck: -clients: %v\n", err)
+			return exitError
+		}
+		f.Clients = append(f.Clients, id)
+	}
+
+	path := fs.Arg(0)
+	r, err := loadRound(path, *round)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if r.History, err = workload.Restrict(r.History, f); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if len(r.History.Operations) == 0 {
+		fmt.Fprintln(stderr, "syncmapcheck: the filter keeps no operation")
+		return exitError
+	}
+
+	// A Violation knows where the search got stuck, which the SVG draws;
+	// the HTML shows a linearization, or how far one got.
+	var (
+		res   = workload.Rechecked{Round: r.Round, Seed: r.Seed, Ops: len(r.History.Operations)}
+		write func(io.Writer) error
+	)
+	name := *out
+	if name == "" {
+		name = strings.TrimSuffix(path, filepath.Ext(path)) + "." + *format
+	}
+	switch *format {
+	case "svg":
+		res = r.Recheck(m, *timeout)
+		if res.Violation == nil {
+			fmt.Fprintf(stderr, "syncmapcheck: round %d is %s, an SVG only shows a violation\n", r.Round, res.Result)
+			return exitError
+		}
+		write = res.Violation.WriteSVG
+	case "tla":
+		// TLC finds a module by its file, and the config next to it.
+		module := tlaModule(name)
+		name = filepath.Join(filepath.Dir(name), module+filepath.Ext(name))
+		start := time.Now()
+		var info porcupine.LinearizationInfo
+		res.Result, info = porcupine.CheckOperationsVerbose(model.Model, r.History.Operations, *timeout)
+		res.Checking = time.Since(start)
+		write = func(w io.Writer) error { return workload.WriteTLA(w, r.History, module) }
+		if res.Result == porcupine.Illegal {
+			v := &workload.Violation{Round: r.Round, Seed: r.Seed, Info: info, History: r.History}
+			write = func(w io.Writer) error { return v.WriteTLA(w, module) }
+		}
+		cfg := strings.TrimSuffix(name, filepath.Ext(name)) + ".cfg"
+		if err := os.WriteFile(cfg, []byte(workload.TLAConfig), 0o644); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	default:
+		start := time.Now()
+		var info porcupine.LinearizationInfo
+		res.Result, info = porcupine.CheckOperationsVerbose(m, r.History.Operations, *timeout)
+		res.Checking = time.Since(start)
+		info.AddAnnotations(r.History.Annotations)
+		write = func(w io.Writer) error { return porcupine.Visualize(m, info, w) }
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	err = write(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "syncmapcheck: failed to write %s: %v\n", name, err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "%s: round %d (seed %d): %d operations, %s in %v, written to %s\n", path, res.Round, res.Seed, res.Ops, res.Result, res.Checking.Round(time.Millisecond), name)
+	switch res.Result {
+	case porcupine.Illegal:
+		return exitViolation
+	case porcupine.Unknown:
+		return exitTimeout
+	}
+	return exitPass
+}
+
+// tlaModule returns the name of the TLA+ module of the file name, its
+// base name without the extension, with any other character than a
+// letter, digit or underscore replaced by an underscore.
+func tlaModule(name string) string {
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	module := strings.Map(func(r rune) rune {
+		if r == '_' || r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, base)
+	if !strings.ContainsFunc(module, unicode.IsLetter) {
+		module = "round_" + module
+	}
+	return module
+}
+
+// loadRound returns the round of the history file, its only one if round
+// is negative.
+func loadRound(path string, round int) (workload.Record, error) {
+	records, err := workload.LoadRecords(path)
+	if err != nil {
+		return workload.Record{}, err
+	}
+	for _, r := range records {
+		if round < 0 && len(records) == 1 || r.Round == round {
+			return r, nil
+		}
+	}
+	if round < 0 {
+		return workload.Record{}, fmt.Errorf("syncmapcheck: %s: %d rounds, pick one with -round", path, len(records))
+	}
+	return workload.Record{}, fmt.Errorf("syncmapcheck: %s: no round %d", path, round)
+}
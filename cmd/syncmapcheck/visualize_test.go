@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+func TestVisualize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "round.json")
+	b, err := json.Marshal(&workload.History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a", Val: 1}, Output: model.Output{}, Call: 0, Return: 10},
+		// The Load of b misses the Store before it.
+		{ClientId: 1, Input: model.Input{Op: model.Store, Key: "b", Val: 2}, Output: model.Output{}, Call: 0, Return: 10},
+		{ClientId: 2, Input: model.Input{Op: model.Load, Key: "b"}, Output: model.Output{}, Call: 20, Return: 30},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"visualize", path}, &stdout, &stderr); code != exitViolation {
+		t.Fatalf("visualize = %d, want %d: %s", code, exitViolation, &stderr)
+	}
+	if html, err := os.ReadFile(filepath.Join(dir, "round.html")); err != nil || !bytes.Contains(html, []byte("<html")) {
+		t.Errorf("round.html: %v", err)
+	}
+	svg := filepath.Join(dir, "b.svg")
+	if code := run([]string{"visualize", "-format", "svg", "-o", svg, path}, &stdout, &stderr); code != exitViolation {
+		t.Fatalf("visualize -format svg = %d, want %d: %s", code, exitViolation, &stderr)
+	}
+	if b, err := os.ReadFile(svg); err != nil || !bytes.HasPrefix(b, []byte("<svg")) {
+		t.Errorf("%s: %v", svg, err)
+	}
+
+	// The module is named after its file, with the config next to it.
+	if code := run([]string{"visualize", "-format", "tla", "-o", filepath.Join(dir, "b-trace.tla"), path}, &stdout, &stderr); code != exitViolation {
+		t.Fatalf("visualize -format tla = %d, want %d: %s", code, exitViolation, &stderr)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "b_trace.tla")); err != nil || !bytes.HasPrefix(b, []byte("---- MODULE b_trace ----")) || !bytes.Contains(b, []byte(`[key |-> "b", event |-> `)) {
+		t.Errorf("b_trace.tla: %v\n%s", err, b)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "b_trace.cfg")); err != nil || string(b) != workload.TLAConfig {
+		t.Errorf("b_trace.cfg: %v\n%s", err, b)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"visualize", "-keys", "a", "-o", filepath.Join(dir, "a.html"), path}, &stdout, &stderr); code != exitPass {
+		t.Fatalf("visualize -keys a = %d, want %d: %s", code, exitPass, &stderr)
+	}
+	if !strings.Contains(stdout.String(), "1 operations, Ok in ") {
+		t.Errorf("output %s", &stdout)
+	}
+
+	for _, args := range [][]string{
+		{"visualize"},
+		{"visualize", "-format", "png", path},
+		{"visualize", "-clients", "x", path},
+		{"visualize", "-keys", "c", path},
+		{"visualize", "-round", "1", path},
+		{"visualize", "-keys", "a", "-format", "svg", path},
+		{"visualize", "-format", "tla", "-model", "snapshot", path},
+	} {
+		if code := run(args, &stdout, &stderr); code != exitError {
+			t.Errorf("run %q = %d, want %d", args, code, exitError)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// The comparison runs one workload, the default or the one given with
+// -workload, with the same seeds against every implementation registered
+// in mapimpl and logs a table of their results:
+//
+//	go test -run TestCompare -v -compare -rounds=1000
+var compareFlag = flag.Bool("compare", false, "run TestCompare, the workload against every registered implementation")
+
+func TestCompare(t *testing.T) {
+	if !*compareFlag {
+		t.Skip("no -compare given")
+	}
+	s := workload.Default()
+	if *workloadFile != "" {
+		s = loadWorkload(t)
+	}
+	applyHarness(&s)
+	fence := fence(t)
+	opts := workload.RunOptions{
+		Fence: fence.Do,
+		AfterRound: func(_ int, m mapimpl.MapUnderTest, history []porcupine.Operation) error {
+			if s.Checker.SkipProbes {
+				return nil
+			}
+			return runProbes(m, history)
+		},
+		Logf:     t.Logf,
+		InFlight: *checkInFlight,
+	}
+	results, err := workload.Compare(s, mapimpl.All(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("comparison:\n%s", workload.CompareTable(results))
+	for _, c := range results {
+		s := s
+		s.Impl = c.Impl
+		var filename string
+		if v := c.Violation; v != nil {
+			filename = saveViolation(t, c.Impl, v)
+			traceViolation(t, &s, v, fence.Do, filename)
+			notifyEvent(t, violationEvent(c.Impl, v.Round, v.Seed, filename))
+			t.Errorf("%s: %d violations, round %d (seed %d) saved to %s", c.Impl, c.Stats.Violations, v.Round, v.Seed, filename)
+		}
+		if c.Err != nil {
+			t.Errorf("%s: %d anomalies, first: %v", c.Impl, c.Timestamps+c.Probes, c.Err)
+		}
+		if runReport != nil {
+			w := runReport.Workload(t.Name()+"/"+c.Impl, c.Impl, s.String())
+			w.AddHistograms(c.Histograms)
+			w.Done(c.Stats, !c.Passed(), c.Err, filename)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// Harness parameters of the linearizability tests, zero keeps each test's
+// own default. Every flag of the test binary, including the litmus ones, can
+// also be set from the environment as SYNCMAP_<NAME> with dashes as
+// underscores, an explicit flag wins:
+//
+//	go test -run TestSyncMap$ -rounds=100000 -workers=8
+//	SYNCMAP_ROUNDS=100 SYNCMAP_LITMUS_BUDGET=10s go test
+var (
+	roundsFlag    = flag.Int("rounds", 0, "rounds per linearizability test, 0 for the test's default")
+	opsFlag       = flag.Int("ops", 0, "operations per worker and round, 0 for the test's default")
+	workersFlag   = flag.Int("workers", 0, "concurrent workers per round, 0 for GOMAXPROCS")
+	seedFlag      = flag.Uint64("seed", 0, "seed of the first round's operations, 0 for a random one")
+	barrierFlag   = flag.Bool("barrier", false, "release the workers of a workload round at once")
+	jitterFlag    = flag.Duration("start-jitter", 0, "random start delay of up to this per worker of a workload round")
+	valueSize     = flag.Int("value-size", 0, "size of the payload stored as each value of a workload round, 0 for the spec's")
+	valueKind     = flag.String("value-kind", "", "payload of -value-size: string, bytes or struct")
+	gcAlloc       = flag.Int("gc-alloc", 0, "size of the objects a background goroutine keeps allocating during workload rounds, 0 for the spec's")
+	gcInterval    = flag.Duration("gc-interval", 0, "cadence of runtime.GC calls during workload rounds, 0 for the spec's")
+	checkTimeout  = flag.Duration("check-timeout", 0, "porcupine checker timeout per round, 0 for the test's default (5s)")
+	pendingFlag   = flag.Int("pending", 0, "percentage of workload operations left outstanding as if their clients crashed, 0 for the spec's")
+	clockFlag     = flag.String("clock", "", "clock timestamping workload operations: time, nanotime or tsc, empty for the spec's")
+	epsilonFlag   = flag.Duration("epsilon", 0, "tolerate workload timestamps out of order by up to this much and widen the operations involved, 0 for the spec's")
+	checkInFlight = flag.Int("check-inflight", 4, "histories checked in the background while the next rounds run, 0 to check each round before the next")
+	streamFlag    = flag.Int("stream", 0, "write the operations of each workload round to temporary files in batches of this many per worker, 0 for the spec's")
+	shardsFlag    = flag.Int("shards", 0, "check each workload history key by key, this many keys at a time, 0 for the spec's")
+)
+
+// The yield points put a runtime.Gosched or, for -yield-sleep percent of
+// them, a sleep before and after -yields percent of the operations of every
+// workload round, as its seed says, see workload.Yields. The history saved
+// of a violation records them, and -yield-replay yields exactly there again
+// while the seed repeats the operations:
+//
+//	go test -run 'TestSyncMap$' -yields=20 -yield-sleep=10 -yield-priorities
+//	go test -run 'TestSyncMap$' -seed=<its seed> -rounds=1 -yield-replay=<impl>_violation_<round>_<time>.json
+var (
+	yieldsFlag    = flag.Int("yields", 0, "percentage of the yield points around workload operations that yield, 0 for the spec's")
+	yieldSleep    = flag.Int("yield-sleep", 0, "percentage of the -yields that sleep instead of calling runtime.Gosched")
+	yieldPriority = flag.Bool("yield-priorities", false, "give the workers of each round priorities that set how often they yield")
+	yieldReplay   = flag.String("yield-replay", "", "history of a round, as saved of a violation, whose yields to replay in every workload round")
+	yieldSchedule *workload.YieldSchedule
+)
+
+// loadYieldReplay reads the yield schedule of -yield-replay.
+func loadYieldReplay() error {
+	if *yieldReplay == "" {
+		return nil
+	}
+	h, err := workload.LoadHistory(*yieldReplay)
+	if err != nil {
+		return err
+	}
+	yieldSchedule, err = workload.YieldsOf(h)
+	return err
+}
+
+// The antagonist keeps CPUs busy during the litmus runs and workload rounds
+// alike, to see how sharing the processors changes outcome frequencies and
+// histories:
+//
+//	go test -cpu-load=4 -cpu-duty=50
+var (
+	cpuLoadFlag = flag.Int("cpu-load", 0, "goroutines keeping a CPU busy each during litmus runs and workload rounds, 0 for the spec's")
+	cpuDutyFlag = flag.Int("cpu-duty", 0, "percentage of every millisecond the -cpu-load goroutines are busy, 0 for all of it")
+)
+
+// A soak runs every workload test until -duration elapses instead of for
+// its rounds, logging its progress as it goes. With -checkpoint, each test
+// keeps its state in <dir>/<test>.json and an interrupted soak resumes
+// from there when run again. With -record, each test appends the history
+// of every round to <dir>/<test>.hist, see workload.HistoryWriter. With
+// -progress, workload tests and litmus runs log their progress and how
+// long they have left at that interval, soak or not. An interrupt or
+// SIGTERM stops a soak after the rounds running, with the checkpoints
+// written, and skips the rest of it:
+//
+//	go test -run 'TestSyncMap$' -v -timeout=0 -duration=8h -checkpoint=soak -record=soak
+//	go test -v -rounds=100000 -litmus-budget=10m -progress=30s
+var (
+	durationFlag   = flag.Duration("duration", 0, "soak every workload test for this long instead of a number of rounds")
+	progressFlag   = flag.Duration("progress", 0, "interval of the progress logs of workload tests and litmus runs and of checkpoints, 0 for a minute in a soak")
+	checkpointFlag = flag.String("checkpoint", "", "directory of the per-test checkpoints a soak resumes from")
+	recordFlag     = flag.String("record", "", "directory of the per-test files the history of every workload round is appended to, in binary")
+	watchdogFlag   = flag.Duration("watchdog", 0, "deadline of running and of checking each workload round, 0 for the spec's")
+	watchdogSkip   = flag.Bool("watchdog-skip", false, "skip workload rounds past the -watchdog deadline instead of failing the test")
+	statsFlag      = flag.Bool("stats", false, "log the throughput, overlap and latencies of every workload round")
+	logFormat      = flag.String("log-format", "", "write the logs of workload tests to standard error as text or json slog records, with the test, impl, round and seed, instead of the test log")
+	logLevel       = flag.String("log-level", "info", "lowest level of the -log-format records: debug, which has the seed of every round, info, warn or error")
+)
+
+// testLogger returns the logger of -log-format for the test t, or nil to
+// log to t.
+func testLogger(t *testing.T) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		t.Fatalf("-log-level: %v", err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch *logFormat {
+	case "":
+		return nil
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		t.Fatalf("-log-format %q, want text or json", *logFormat)
+	}
+	return slog.New(h).With("test", t.Name())
+}
+
+// soakStop is closed on the first interrupt or SIGTERM of a soak.
+var soakStop = make(chan struct{})
+
+// stopSoakOnSignal stops the soak on the first interrupt or SIGTERM; a
+// second one has its default effect.
+func stopSoakOnSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		signal.Stop(sigs)
+		fmt.Fprintln(os.Stderr, "stopping the soak after the rounds running, interrupt again to quit at once")
+		close(soakStop)
+	}()
+}
+
+// soakOptions sets the soak flags in opts for the test t.
+func soakOptions(t *testing.T, opts *workload.RunOptions) {
+	opts.Duration = *durationFlag
+	opts.Stop = soakStop
+	opts.Progress = *progressFlag
+	opts.Stats = *statsFlag
+	opts.Logger = testLogger(t)
+	if opts.Duration > 0 {
+		// The progress logs replace the seed of every round.
+		opts.Verbose = false
+		if opts.Progress == 0 {
+			opts.Progress = time.Minute
+		}
+	}
+	if *checkpointFlag != "" {
+		if err := os.MkdirAll(*checkpointFlag, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		opts.Checkpoint = filepath.Join(*checkpointFlag, strings.ReplaceAll(t.Name(), "/", "_")+".json")
+	}
+	if *recordFlag != "" {
+		if err := os.MkdirAll(*recordFlag, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(filepath.Join(*recordFlag, strings.ReplaceAll(t.Name(), "/", "_")+".hist"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		opts.Record = workload.NewHistoryWriter(f)
+	}
+}
+
+// The sweep repeats every test of the package, the linearizability and
+// litmus suites alike, once per GOMAXPROCS setting and reports each:
+//
+//	go test -gomaxprocs-sweep=1,2,4,numcpu
+var sweepFlag = flag.String("gomaxprocs-sweep", "", "comma-separated GOMAXPROCS settings to repeat all tests with, numcpu for runtime.NumCPU()")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if err := flagsFromEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var settings []int
+	if *sweepFlag != "" {
+		var err error
+		if settings, err = parseSweep(*sweepFlag); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if err := loadYieldReplay(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := startReport(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := startMetrics(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *durationFlag > 0 {
+		stopSoakOnSignal()
+	}
+	var code int
+	if settings == nil {
+		code = m.Run()
+	} else {
+		code = sweep(m, settings)
+	}
+	if err := writeReport(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		code = max(code, 1)
+	}
+	os.Exit(code)
+}
+
+// parseSweep parses the settings of -gomaxprocs-sweep.
+func parseSweep(s string) ([]int, error) {
+	var settings []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "numcpu" {
+			settings = append(settings, runtime.NumCPU())
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("-gomaxprocs-sweep: invalid setting %q, want a positive number or numcpu", f)
+		}
+		settings = append(settings, n)
+	}
+	return settings, nil
+}
+
+// sweep runs the tests once per GOMAXPROCS setting, prints a summary and
+// returns the exit code of the first failure.
+func sweep(m *testing.M, settings []int) int {
+	type run struct {
+		procs, code int
+		elapsed     time.Duration
+	}
+	var (
+		runs []run
+		code int
+	)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, p := range settings {
+		fmt.Printf("=== GOMAXPROCS=%d\n", p)
+		runtime.GOMAXPROCS(p)
+		start := time.Now()
+		c := m.Run()
+		runs = append(runs, run{procs: p, code: c, elapsed: time.Since(start)})
+		if code == 0 {
+			code = c
+		}
+	}
+	fmt.Println("GOMAXPROCS sweep:")
+	for _, r := range runs {
+		status := "ok"
+		if r.code != 0 {
+			status = "FAIL"
+		}
+		fmt.Printf("  %-8d %-5s %v\n", r.procs, status, r.elapsed.Round(time.Millisecond))
+	}
+	return code
+}
+
+// flagsFromEnv sets every flag not given on the command line from its
+// SYNCMAP_ environment variable, if present.
+func flagsFromEnv() error {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || err != nil {
+			return
+		}
+		name := flagEnv(f.Name)
+		if v, ok := os.LookupEnv(name); ok {
+			if e := f.Value.Set(v); e != nil {
+				err = fmt.Errorf("%s=%q: %v", name, v, e)
+			}
+		}
+	})
+	return err
+}
+
+// flagEnv returns the SYNCMAP_ environment variable of the flag name.
+func flagEnv(name string) string {
+	return "SYNCMAP_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+type harness struct {
+	rounds, ops, workers int
+	checkTimeout         time.Duration
+}
+
+// harnessConfig returns the parameters of a linearizability test whose own
+// defaults are rounds and ops.
+func harnessConfig(rounds, ops int) harness {
+	h := harness{rounds: rounds, ops: ops, workers: runtime.GOMAXPROCS(0), checkTimeout: 5 * time.Second}
+	if *roundsFlag > 0 {
+		h.rounds = *roundsFlag
+	}
+	if *opsFlag > 0 {
+		h.ops = *opsFlag
+	}
+	if *workersFlag > 0 {
+		h.workers = *workersFlag
+	}
+	if *checkTimeout > 0 {
+		h.checkTimeout = *checkTimeout
+	}
+	return h
+}
+
+// applyHarness overrides the parameters of s that were given as flags.
+func applyHarness(s *workload.Spec) {
+	if *roundsFlag > 0 {
+		s.Rounds = *roundsFlag
+	}
+	if *opsFlag > 0 {
+		s.Ops = *opsFlag
+	}
+	// Roles set their own workers.
+	if *workersFlag > 0 && len(s.Roles) == 0 {
+		s.Workers = *workersFlag
+	}
+	if *checkTimeout > 0 {
+		s.Checker.Timeout = workload.Duration(*checkTimeout)
+	}
+	if *seedFlag != 0 {
+		s.Seed = *seedFlag
+	}
+	if *barrierFlag {
+		s.Start.Barrier = true
+	}
+	if *jitterFlag > 0 {
+		s.Start.Jitter = workload.Duration(*jitterFlag)
+	}
+	if *valueSize > 0 {
+		s.ValueSize = *valueSize
+	}
+	if *valueKind != "" {
+		s.ValueKind = *valueKind
+	}
+	if *gcAlloc > 0 {
+		s.GC.AllocSize = *gcAlloc
+	}
+	if *gcInterval > 0 {
+		s.GC.Interval = workload.Duration(*gcInterval)
+	}
+	if *epsilonFlag > 0 {
+		s.Checker.Epsilon = workload.Duration(*epsilonFlag)
+	}
+	if *cpuLoadFlag > 0 {
+		s.CPULoad = workload.CPULoad{Cores: *cpuLoadFlag, Duty: *cpuDutyFlag}
+	}
+	if *pendingFlag > 0 {
+		s.Pending.Percent = *pendingFlag
+	}
+	if *watchdogFlag > 0 {
+		s.Watchdog = workload.Watchdog{Deadline: workload.Duration(*watchdogFlag), Skip: *watchdogSkip}
+	}
+	if *clockFlag != "" {
+		s.Clock = *clockFlag
+	}
+	if *streamFlag > 0 {
+		s.Stream.Batch = *streamFlag
+	}
+	if *shardsFlag > 0 {
+		s.Checker.Shards = *shardsFlag
+	}
+	if *yieldsFlag > 0 {
+		s.Yields = workload.Yields{Percent: *yieldsFlag, SleepPercent: *yieldSleep, MaxSleep: s.Yields.MaxSleep, Priorities: *yieldPriority}
+	}
+	if yieldSchedule != nil {
+		s.Yields.Replay = yieldSchedule
+	}
+}
@@ -0,0 +1,338 @@
+// Package crossrun runs the litmus suite on other architectures: it
+// cross-compiles cmd/litmus, executes it under qemu-user or on a remote
+// host over ssh, and aggregates the outcome histograms per architecture
+// into one report. A remote host may run another operating system, so
+// that one run covers, say, a linux/arm64 server, a darwin/arm64 laptop
+// and a linux/amd64 box.
+package crossrun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+)
+
+// A Target is an architecture to run on.
+type Target struct {
+	GOARCH string
+	// GOOS is the operating system of the target, linux if empty. Others
+	// only run natively or on a Remote, as qemu-user runs linux binaries.
+	GOOS string
+	// Remote, if set, is an ssh destination the binary is copied to and run
+	// on, instead of qemu-user.
+	Remote string
+	// QEMU overrides the qemu-user binary, see QEMUBinary.
+	QEMU string
+	// Env are extra KEY=value variables for building and running, e.g.
+	// GOEXPERIMENT settings for the build and GODEBUG ones for the run.
+	Env []string
+}
+
+// String describes where the target runs, and in which environment.
+func (t Target) String() string {
+	s := t.GOARCH
+	if t.goos() != "linux" {
+		s = t.goos() + "/" + t.GOARCH
+	}
+	switch {
+	case t.Remote != "":
+		s += "@" + t.Remote
+	case t.native():
+		s += " (native)"
+	default:
+		s += " (" + t.qemu() + ")"
+	}
+	if len(t.Env) > 0 {
+		s += " " + strings.Join(t.Env, " ")
+	}
+	return s
+}
+
+func (t Target) goos() string {
+	if t.GOOS != "" {
+		return t.GOOS
+	}
+	return "linux"
+}
+
+func (t Target) native() bool {
+	return t.Remote == "" && t.QEMU == "" && t.GOARCH == runtime.GOARCH && t.goos() == runtime.GOOS
+}
+
+func (t Target) qemu() string {
+	if t.QEMU != "" {
+		return t.QEMU
+	}
+	return QEMUBinary(t.GOARCH)
+}
+
+// QEMUBinary returns the name of the qemu-user binary for goarch, e.g.
+// qemu-aarch64 for arm64.
+func QEMUBinary(goarch string) string {
+	names := map[string]string{"amd64": "x86_64", "386": "i386", "arm64": "aarch64", "arm": "arm", "loong64": "loongarch64", "mips64le": "mips64el"}
+	if n, ok := names[goarch]; ok {
+		return "qemu-" + n
+	}
+	return "qemu-" + goarch
+}
+
+// A Case is one litmus run, by preset and primitive names of cmd/litmus.
+type Case struct {
+	Preset, Prim string
+}
+
+func (c Case) String() string { return c.Preset + "+" + c.Prim }
+
+// Options configure Run.
+type Options struct {
+	Targets []Target
+	Cases   []Case
+	// Args are passed to every litmus run, e.g. -budget 10s -iters 0.
+	Args []string
+	// ModuleDir is the root of this module, the current directory by
+	// default.
+	ModuleDir string
+	// WorkDir holds the binaries, a temporary directory by default.
+	WorkDir string
+}
+
+// LitmusReport is the JSON report of one cmd/litmus run.
+type LitmusReport struct {
+	Version int    `json:"version"`
+	GOOS    string `json:"goos"`
+	GOARCH  string `json:"goarch"`
+	Go      string `json:"go"`
+	Preset  string `json:"preset"`
+	Prim    string `json:"prim"`
+	Impl    string `json:"impl"`
+	Result  struct {
+		Test           string  `json:"test"`
+		Iterations     int     `json:"iterations"`
+		ElapsedSeconds float64 `json:"elapsed_seconds"`
+		Forbidden      int     `json:"forbidden"`
+		FirstForbidden int     `json:"first_forbidden"`
+		Outcomes       []struct {
+			Regs      []int64 `json:"regs"`
+			Count     int     `json:"count"`
+			Forbidden bool    `json:"forbidden"`
+		} `json:"outcomes"`
+	} `json:"result"`
+}
+
+// An Entry is the result of one case on one target. Err is set instead of
+// Report if the run failed.
+type Entry struct {
+	Target string        `json:"target"`
+	GOOS   string        `json:"goos"`
+	GOARCH string        `json:"goarch"`
+	Case   string        `json:"case"`
+	Report *LitmusReport `json:"report,omitempty"`
+	Err    string        `json:"error,omitempty"`
+}
+
+// Report aggregates all entries of a Run, by target and then case.
+type Report struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Expect looks up whether the outcome the entry's case looks for is
+// forbidden on the entry's GOARCH, see litmus.Expected.
+func (e *Entry) Expect() litmus.Expect {
+	if e.Report == nil {
+		return litmus.ExpectAllowed
+	}
+	return litmus.Expected(e.Report.Preset, e.Report.Prim, e.Report.GOARCH)
+}
+
+// Forbidden reports whether any entry observed the outcome its case looks
+// for where the expectations forbid it. Allowed outcomes are only counted.
+func (r *Report) Forbidden() bool {
+	for i := range r.Entries {
+		e := &r.Entries[i]
+		if e.Report != nil && e.Report.Result.Forbidden > 0 && e.Expect() == litmus.ExpectForbidden {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed reports whether any entry failed to run.
+func (r *Report) Failed() bool {
+	for _, e := range r.Entries {
+		if e.Err != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary formats one line per entry: case, target, iterations, how often
+// the looked-for outcome was observed and whether it is allowed there.
+func (r *Report) Summary() string {
+	var b strings.Builder
+	for i := range r.Entries {
+		e := &r.Entries[i]
+		fmt.Fprintf(&b, "%-28s %-28s ", e.Case, e.Target)
+		if e.Err != "" {
+			fmt.Fprintf(&b, "error: %s\n", e.Err)
+			continue
+		}
+		res := e.Report.Result
+		freq := 0.0
+		if res.Iterations > 0 {
+			freq = 100 * float64(res.Forbidden) / float64(res.Iterations)
+		}
+		fmt.Fprintf(&b, "%12d iterations %10d observed (%.4f%%), %s\n", res.Iterations, res.Forbidden, freq, e.Expect())
+	}
+	return b.String()
+}
+
+// Run builds cmd/litmus once per target and runs every case on it. The
+// binary of a remote target is copied to a temporary directory of the
+// host, which is removed once its cases ran, and the JSON reports come
+// back on the standard output of ssh. A target that cannot be built or
+// run, e.g. because qemu-user is missing, only fails its own entries.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	dir := opts.WorkDir
+	if dir == "" {
+		var err error
+		if dir, err = os.MkdirTemp("", "crossrun"); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	rep := &Report{}
+	for _, t := range opts.Targets {
+		bin, err := build(ctx, opts.ModuleDir, dir, t)
+		cleanup := func() {}
+		switch {
+		case err != nil:
+		case t.Remote != "":
+			bin, cleanup, err = copyRemote(ctx, t.Remote, bin)
+		case !t.native() && t.goos() != "linux":
+			err = fmt.Errorf("%s: qemu-user only runs linux binaries, run %s on a remote host", t, t.goos())
+		}
+		for _, c := range opts.Cases {
+			e := Entry{Target: t.String(), GOOS: t.goos(), GOARCH: t.GOARCH, Case: c.String()}
+			if err != nil {
+				e.Err = err.Error()
+			} else if r, runErr := runCase(ctx, t, bin, c, opts.Args); runErr != nil {
+				e.Err = runErr.Error()
+			} else {
+				e.Report = r
+			}
+			rep.Entries = append(rep.Entries, e)
+		}
+		cleanup()
+	}
+	return rep, nil
+}
+
+// build cross-compiles a static cmd/litmus for t.GOOS/t.GOARCH into dir,
+// in the environment of t.
+func build(ctx context.Context, moduleDir, dir string, t Target) (string, error) {
+	// Targets of one architecture in different environments get
+	// directories of their own.
+	bin, err := os.MkdirTemp(dir, "litmus")
+	if err == nil {
+		bin, err = filepath.Abs(filepath.Join(bin, "litmus-"+t.GOARCH))
+	}
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", bin, "./cmd/litmus")
+	cmd.Dir = moduleDir
+	cmd.Env = append(os.Environ(), "GOOS="+t.goos(), "GOARCH="+t.GOARCH, "CGO_ENABLED=0")
+	buildEnv, _ := SplitEnv(t.Env)
+	cmd.Env = append(cmd.Env, buildEnv...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("building for %s: %v: %s", t, err, bytes.TrimSpace(out))
+	}
+	return bin, nil
+}
+
+// runtimeVars are the variables the runtime of every Go program reads, the
+// go command, the compiler and vet as much as the binaries they build.
+var runtimeVars = []string{"GODEBUG", "GOGC", "GOMEMLIMIT", "GOMAXPROCS", "GOTRACEBACK"}
+
+// SplitEnv splits the KEY=value variables of env into those for building,
+// such as GOEXPERIMENT, and those of runtimeVars, such as GODEBUG, for
+// running only: given to the go command, they would change the toolchain
+// too, and what it prints, vet's output included, would be cached.
+func SplitEnv(env []string) (build, run []string) {
+	for _, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(runtimeVars, k) {
+			run = append(run, kv)
+		} else {
+			build = append(build, kv)
+		}
+	}
+	return build, run
+}
+
+// copyRemote copies bin to a temporary directory on host, and returns its
+// path there and a function removing the directory.
+func copyRemote(ctx context.Context, host, bin string) (string, func(), error) {
+	out, err := exec.CommandContext(ctx, "ssh", host, "mktemp", "-d", "/tmp/crossrun.XXXXXX").Output()
+	dir := string(bytes.TrimSpace(out))
+	if err != nil || dir == "" {
+		return "", nil, fmt.Errorf("creating a directory on %s: %v", host, err)
+	}
+	cleanup := func() {
+		// The binary is gone with the host's next reboot anyway.
+		exec.Command("ssh", host, "rm", "-rf", dir).Run()
+	}
+	remote := dir + "/" + filepath.Base(bin)
+	if out, err := exec.CommandContext(ctx, "scp", "-q", bin, host+":"+remote).CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("copying to %s: %v: %s", host, err, bytes.TrimSpace(out))
+	}
+	return remote, cleanup, nil
+}
+
+// Command returns the command line running case c of bin on t.
+func (t Target) Command(bin string, c Case, args []string) []string {
+	litmus := append([]string{bin, "run", "-preset", c.Preset, "-prim", c.Prim, "-format", "json"}, args...)
+	switch {
+	case t.Remote != "" && len(t.Env) > 0:
+		return append(append([]string{"ssh", t.Remote, "env"}, t.Env...), litmus...)
+	case t.Remote != "":
+		return append([]string{"ssh", t.Remote}, litmus...)
+	case t.native():
+		return litmus
+	}
+	return append([]string{t.qemu()}, litmus...)
+}
+
+func runCase(ctx context.Context, t Target, bin string, c Case, args []string) (*LitmusReport, error) {
+	argv := t.Command(bin, c, args)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), t.Env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	// Exit status 1 only means a forbidden outcome was observed.
+	if exit := (*exec.ExitError)(nil); err != nil && (!errors.As(err, &exit) || exit.ExitCode() != 1) {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	var rep LitmusReport
+	if err := json.Unmarshal(stdout.Bytes(), &rep); err != nil {
+		return nil, fmt.Errorf("invalid report: %v", err)
+	}
+	return &rep, nil
+}
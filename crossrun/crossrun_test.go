@@ -0,0 +1,151 @@
+package crossrun
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	c := Case{Preset: "SB", Prim: "Map.Load"}
+	args := []string{"-iters", "10"}
+	litmus := []string{"bin", "run", "-preset", "SB", "-prim", "Map.Load", "-format", "json", "-iters", "10"}
+	for _, tc := range []struct {
+		target Target
+		want   []string
+	}{
+		{Target{GOARCH: "riscv64"}, append([]string{"qemu-riscv64"}, litmus...)},
+		{Target{GOARCH: "arm64"}, append([]string{"qemu-aarch64"}, litmus...)},
+		{Target{GOARCH: "arm64", QEMU: "/opt/qemu-aarch64-static"}, append([]string{"/opt/qemu-aarch64-static"}, litmus...)},
+		{Target{GOARCH: "ppc64le", Remote: "pi@power9"}, append([]string{"ssh", "pi@power9"}, litmus...)},
+		{Target{GOARCH: "ppc64le", Remote: "pi@power9", Env: []string{"GODEBUG=x=1"}}, append([]string{"ssh", "pi@power9", "env", "GODEBUG=x=1"}, litmus...)},
+	} {
+		if got := tc.target.Command("bin", c, args); !slices.Equal(got, tc.want) {
+			t.Errorf("%v: Command = %q, want %q", tc.target, got, tc.want)
+		}
+	}
+}
+
+func TestSplitEnv(t *testing.T) {
+	build, run := SplitEnv([]string{"GOEXPERIMENT=nosynchashtriemap", "GODEBUG=schedtrace=1000", "GOFLAGS=-race", "GOMAXPROCS=2"})
+	if !slices.Equal(build, []string{"GOEXPERIMENT=nosynchashtriemap", "GOFLAGS=-race"}) || !slices.Equal(run, []string{"GODEBUG=schedtrace=1000", "GOMAXPROCS=2"}) {
+		t.Errorf("SplitEnv = %q, %q", build, run)
+	}
+}
+
+func TestRunNative(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cmd/litmus is built for linux")
+	}
+	rep, err := Run(context.Background(), Options{
+		Targets:   []Target{{GOARCH: runtime.GOARCH}, {GOARCH: runtime.GOARCH, QEMU: "qemu-does-not-exist"}},
+		Cases:     []Case{{Preset: "SB", Prim: "atomic.Store"}, {Preset: "MP", Prim: "Map"}},
+		Args:      []string{"-iters", "100"},
+		ModuleDir: "..",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Entries) != 4 {
+		t.Fatalf("%d entries:\n%s", len(rep.Entries), rep.Summary())
+	}
+	for _, e := range rep.Entries[:2] {
+		if e.Err != "" || e.Report.GOARCH != runtime.GOARCH || e.Report.Result.Iterations != 100 {
+			t.Fatalf("native entry %+v", e)
+		}
+	}
+	for _, e := range rep.Entries[2:] {
+		if e.Err == "" {
+			t.Fatalf("missing qemu did not fail: %+v", e)
+		}
+	}
+	if !rep.Failed() || !strings.Contains(rep.Summary(), "MP+Map") {
+		t.Fatalf("summary:\n%s", rep.Summary())
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, tc := range []struct {
+		target Target
+		want   string
+	}{
+		{Target{GOARCH: "arm64", Remote: "me@graviton"}, "arm64@me@graviton"},
+		{Target{GOOS: "darwin", GOARCH: "arm64", Remote: "me@mac"}, "darwin/arm64@me@mac"},
+		{Target{GOARCH: "riscv64"}, "riscv64 (qemu-riscv64)"},
+	} {
+		if got := tc.target.String(); got != tc.want {
+			t.Errorf("String() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	entry := func(preset, prim, goarch string, n int) Entry {
+		rep := &LitmusReport{GOARCH: goarch, Preset: preset, Prim: prim}
+		rep.Result.Iterations, rep.Result.Forbidden = 100, n
+		return Entry{Case: preset + "+" + prim, Target: goarch, GOARCH: goarch, Report: rep}
+	}
+	// SB reorders around a Load anywhere, and IRIW around Map Stores on
+	// POWER, so observing them is no failure.
+	allowed := &Report{Entries: []Entry{entry("SB", "Map.Load", "amd64", 7), entry("IRIW", "Map.Store", "ppc64le", 1)}}
+	if allowed.Forbidden() {
+		t.Errorf("allowed outcomes counted as forbidden:\n%s", allowed.Summary())
+	}
+	if sum := allowed.Summary(); !strings.Contains(sum, "7 observed (7.0000%), allowed") {
+		t.Errorf("summary:\n%s", sum)
+	}
+	forbidden := &Report{Entries: append(allowed.Entries, entry("SB", "Map.Store", "arm64", 0), entry("MP", "Map", "arm64", 2))}
+	if !forbidden.Forbidden() {
+		t.Errorf("MP+Map observed on arm64 not forbidden:\n%s", forbidden.Summary())
+	}
+	if sum := forbidden.Summary(); !strings.Contains(sum, "2 observed (2.0000%), forbidden") {
+		t.Errorf("summary:\n%s", sum)
+	}
+}
+
+// TestRunRemote runs a remote target through an ssh and scp that run
+// their commands on this machine.
+func TestRunRemote(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cmd/litmus is built for linux")
+	}
+	bin := t.TempDir()
+	for name, script := range map[string]string{
+		"ssh": "#!/bin/sh\nshift\nexec sh -c \"$*\"\n",
+		"scp": "#!/bin/sh\nexec cp \"$2\" \"${3#*:}\"\n",
+	} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	rep, err := Run(context.Background(), Options{
+		Targets: []Target{
+			{GOARCH: runtime.GOARCH, Remote: "me@box", Env: []string{"GODEBUG=asyncpreemptoff=1"}},
+			{GOOS: "darwin", GOARCH: "arm64"},
+		},
+		Cases:     []Case{{Preset: "SB", Prim: "atomic.Store"}},
+		Args:      []string{"-iters", "100"},
+		ModuleDir: "..",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Entries) != 2 {
+		t.Fatalf("%d entries:\n%s", len(rep.Entries), rep.Summary())
+	}
+	if e := rep.Entries[0]; e.Err != "" || e.Report.Result.Iterations != 100 || e.Target != runtime.GOARCH+"@me@box GODEBUG=asyncpreemptoff=1" {
+		t.Errorf("remote entry %+v", e)
+	}
+	if e := rep.Entries[1]; !strings.Contains(e.Err, "qemu-user only runs linux") || e.GOOS != "darwin" {
+		t.Errorf("darwin entry without a host %+v", e)
+	}
+	if left, _ := filepath.Glob("/tmp/crossrun.*/litmus-" + runtime.GOARCH); len(left) > 0 {
+		t.Errorf("remote binaries left behind: %q", left)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+)
+
+// Cache-miss injection for the litmus tests, used to test the hypothesis in
+// TestLoad that cold caches make the store buffer reordering less likely.
+//
+//	go test -run TestLoad$ -evict=clflush
+//	go test -run TestLoad$ -evict=thrash -evict-size=1048576
+var (
+	evictMode = flag.String("evict", "none", "evict cache lines between litmus iterations: none, thrash or clflush (amd64 only)")
+	evictSize = flag.Int("evict-size", litmus.DefaultEvictSize, "size in bytes of the buffer walked by -evict=thrash")
+)
+
+// evictOptions validates the -evict flags and applies them to opts.
+func evictOptions(t *testing.T, opts *litmus.Options) {
+	ev, err := litmus.ParseEvict(*evictMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev == litmus.EvictNone {
+		return
+	}
+	if ev == litmus.EvictThrash && *evictSize <= 0 {
+		t.Fatalf("-evict-size must be positive, got %d", *evictSize)
+	}
+	opts.Evict, opts.EvictSize = ev, *evictSize
+	t.Logf("evict=%s evict-size=%d", ev, *evictSize)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+)
+
+// Fence injection, to measure the effect of hardware fences on results: in
+// the porcupine tests the fence runs between each timestamp and its
+// operation, like the commented-out asm.MemoryBarrier calls; in the litmus
+// tests before and after every access or operation of the primitive.
+//
+//	go test -run TestSyncMap$ -fence=full
+//	go test -run TestLoad$ -fence=store
+var fenceMode = flag.String("fence", "none", "inject fences around timestamps and litmus operations: none, full, store or load")
+
+// fence returns the fence selected by -fence.
+func fence(t *testing.T) litmus.Fence {
+	f, err := litmus.ParseFence(*fenceMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != litmus.FenceNone {
+		t.Logf("fence=%s", f)
+	}
+	return f
+}
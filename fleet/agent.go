@@ -0,0 +1,168 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/report"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// An Agent runs the batches of a Coordinator on this machine.
+type Agent struct {
+	// URL is the address of the coordinator, like http://host:8700.
+	URL string
+	// Name tells the agent apart from the others, the host name if empty.
+	Name string
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// InFlight is workload.RunOptions.InFlight for every batch.
+	InFlight int
+	// Logf, if set, receives the batches and the logs of their runs.
+	Logf func(format string, args ...any)
+	// Logger, if set, receives them in place of Logf as slog records with
+	// the attributes agent and batch, and those of workload.RunOptions.
+	Logger *slog.Logger
+}
+
+// Run leases batches and runs them until the coordinator has none left,
+// or ctx is done. A batch that stops at a violation or another error is
+// reported as such, and Run goes on with the next one.
+func (a *Agent) Run(ctx context.Context) error {
+	hello := Hello{Agent: a.Name, Env: report.Environment()}
+	if hello.Agent == "" {
+		hello.Agent = hello.Env.Host
+	}
+	log := logger(a.Logger, a.Logf).With("agent", hello.Agent)
+	for {
+		l, wait, err := a.lease(ctx, hello)
+		switch {
+		case err != nil:
+			return err
+		case wait > 0:
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case l == nil:
+			log.Info("no batches left")
+			return nil
+		}
+		log := log.With("batch", l.ID)
+		log.Info(fmt.Sprintf("batch %d: rounds %d to %d", l.ID, l.First, l.First+l.Count-1))
+		res, err := a.run(l, log)
+		if err != nil {
+			return err
+		}
+		res.Agent, res.Env = hello.Agent, hello.Env
+		if err := a.post(ctx, "/result", res, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// run runs the batch of l.
+func (a *Agent) run(l *Lease, log *slog.Logger) (Result, error) {
+	s, err := workload.Parse(bytes.NewReader(l.Spec))
+	if err != nil {
+		return Result{}, fmt.Errorf("fleet: lease %d: %v", l.ID, err)
+	}
+	res := Result{Lease: l.ID}
+	s.Rounds = l.First + l.Count
+	err = s.Run(workload.RunOptions{
+		Logger:     log,
+		InFlight:   a.InFlight,
+		FirstRound: l.First,
+		Summary:    &res.Stats,
+	})
+	var v *workload.Violation
+	switch {
+	case errors.As(err, &v):
+		var html strings.Builder
+		if err := v.Visualize(&html); err != nil {
+			return Result{}, err
+		}
+		res.Violation = &Violation{Round: v.Round, Seed: v.Seed, Key: v.Key, History: v.History, HTML: html.String()}
+		log.Error(v.Error(), "round", v.Round, "seed", v.Seed)
+	case err != nil:
+		res.Err = err.Error()
+		log.Error(fmt.Sprintf("batch %d: %v", l.ID, err), "error", err)
+	}
+	return res, nil
+}
+
+// lease asks for a batch, and returns nil if there is none left, or how
+// long to wait before asking again.
+func (a *Agent) lease(ctx context.Context, hello Hello) (*Lease, time.Duration, error) {
+	var l Lease
+	err := a.post(ctx, "/lease", hello, &l)
+	var busy *busyError
+	switch {
+	case errors.As(err, &busy):
+		return nil, busy.wait, nil
+	case errors.Is(err, errNoContent):
+		return nil, 0, nil
+	case err != nil:
+		return nil, 0, err
+	}
+	return &l, 0, nil
+}
+
+var errNoContent = errors.New("fleet: no content")
+
+// busyError is the answer of a coordinator whose batches are all leased.
+type busyError struct{ wait time.Duration }
+
+func (e *busyError) Error() string { return "fleet: every batch is leased" }
+
+// post posts v as JSON to the path of the coordinator and decodes the
+// answer into out, if set.
+func (a *Agent) post(ctx context.Context, path string, v, out any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("fleet: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("fleet: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fleet: %v", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return errNoContent
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			secs = 1
+		}
+		return &busyError{time.Duration(secs) * time.Second}
+	case resp.StatusCode/100 != 2:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("fleet: %s: %s: %s", req.URL, resp.Status, bytes.TrimSpace(msg))
+	case out != nil:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("fleet: %s: %v", req.URL, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+// Package fleet runs the rounds of a workload on several machines at once.
+// Memory ordering bugs show on some processors and not others, so the
+// same rounds are worth running on every CPU and architecture at hand: a
+// Coordinator splits the rounds of a spec into batches, which Agents on
+// the machines lease over HTTP, run with the seeds they have in the whole
+// run and report back, with their stats, the environment they ran in and
+// the history of a violation, which the Coordinator saves. A batch whose
+// agent goes quiet is leased again once its lease expires. The wire format
+// is JSON, so that an agent needs nothing but this module and the address
+// of the coordinator.
+package fleet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/report"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// A Lease is a batch of rounds an agent runs: the rounds from First to
+// First+Count of Spec, which is the JSON of a workload.Spec.
+type Lease struct {
+	ID    int             `json:"id"`
+	Spec  json.RawMessage `json:"spec"`
+	First int             `json:"first"`
+	Count int             `json:"count"`
+}
+
+// A Hello is what an agent asks for a lease with.
+type Hello struct {
+	Agent string     `json:"agent"`
+	Env   report.Env `json:"env"`
+}
+
+// A Result is the outcome of a lease.
+type Result struct {
+	Lease int            `json:"lease"`
+	Agent string         `json:"agent"`
+	Env   report.Env     `json:"env"`
+	Stats workload.Stats `json:"stats"`
+	// Err is the error the batch stopped at, other than a violation.
+	Err       string     `json:"error,omitempty"`
+	Violation *Violation `json:"violation,omitempty"`
+}
+
+// A Violation is a round of a batch whose history is not linearizable,
+// with porcupine's visualization of it as the agent wrote it.
+type Violation struct {
+	Round   int               `json:"round"`
+	Seed    uint64            `json:"seed"`
+	Key     string            `json:"key,omitempty"`
+	History *workload.History `json:"history"`
+	HTML    string            `json:"html"`
+}
+
+// A Finding is a violation an agent found, as the Coordinator saved it.
+type Finding struct {
+	Agent string
+	Env   report.Env
+	Round int
+	Seed  uint64
+	// File is the visualization of the violation, next to the history as
+	// JSON, or empty if it could not be saved.
+	File string
+}
+
+// An AgentSummary is what an agent ran.
+type AgentSummary struct {
+	Agent   string
+	Env     report.Env
+	Batches int
+	Stats   workload.Stats
+	// Errors are those of the batches that stopped at one.
+	Errors []string
+}
+
+// A Summary is the outcome of a distributed run so far.
+type Summary struct {
+	// Total is the sum of the stats of every batch, Elapsed among them.
+	Total    workload.Stats
+	Batches  int
+	Done     int
+	Agents   []AgentSummary
+	Findings []Finding
+}
+
+// A Coordinator hands out the batches of a spec to agents and collects
+// their results. It is an http.Handler serving /lease and /result.
+type Coordinator struct {
+	// Dir is the directory the violations are saved to.
+	Dir string
+	// LeaseTimeout is how long an agent has to report a batch before it is
+	// leased to another one, 10 minutes if 0. It has to be longer than a
+	// batch takes on the slowest agent.
+	LeaseTimeout time.Duration
+	// Logf, if set, receives the leases and results.
+	Logf func(format string, args ...any)
+	// Logger, if set, receives them in place of Logf as slog records with
+	// the attributes batch, agent, goos and goarch, and round and seed of
+	// a violation.
+	Logger *slog.Logger
+
+	spec    []byte
+	mu      sync.Mutex
+	batches []batch
+	done    chan struct{}
+	left    int
+	agents  map[string]*AgentSummary
+	found   []Finding
+}
+
+// A batch is the state of a lease.
+type batch struct {
+	first, count int
+	agent        string
+	expires      time.Time
+	done         bool
+}
+
+// NewCoordinator returns a Coordinator of the rounds of s in batches of
+// size rounds. If s has no seed, it gets a random one, for every batch to
+// run its rounds with the seeds they have in the whole run.
+func NewCoordinator(s workload.Spec, size int) (*Coordinator, error) {
+	if size < 1 {
+		return nil, errors.New("fleet: batches must have at least one round")
+	}
+	if s.NewMap != nil {
+		return nil, errors.New("fleet: the agents only run the registered implementations")
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if s.Seed == 0 {
+		s.Seed = rand.Uint64()
+	}
+	spec, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("fleet: %v", err)
+	}
+	c := &Coordinator{Dir: ".", spec: spec, done: make(chan struct{}), agents: make(map[string]*AgentSummary)}
+	for first := 0; first < s.Rounds; first += size {
+		c.batches = append(c.batches, batch{first: first, count: min(size, s.Rounds-first)})
+	}
+	c.left = len(c.batches)
+	if c.left == 0 {
+		close(c.done)
+	}
+	return c, nil
+}
+
+// Done is closed once every batch was reported.
+func (c *Coordinator) Done() <-chan struct{} {
+	return c.done
+}
+
+// log returns Logger, or a logger passing the messages to Logf.
+func (c *Coordinator) log() *slog.Logger {
+	return logger(c.Logger, c.Logf)
+}
+
+// logger returns l, or one passing the records to logf with the lines that
+// logged them, or one that discards them.
+func logger(l *slog.Logger, logf func(string, ...any)) *slog.Logger {
+	switch {
+	case l != nil:
+		return l
+	case logf != nil:
+		return slog.New(workload.LogfHandler{Logf: logf, AddSource: true})
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/lease":
+		var h Hello
+		if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.lease(w, h)
+	case "/result":
+		var res Result
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.result(res); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// lease answers h with the next batch not leased, or whose lease expired,
+// with 204 No Content if every batch is done, and with 503 and a
+// Retry-After if the others are leased.
+func (c *Coordinator) lease(w http.ResponseWriter, h Hello) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.agent(h.Agent, h.Env)
+	if c.left == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	now := time.Now()
+	for i := range c.batches {
+		b := &c.batches[i]
+		if b.done || b.agent != "" && now.Before(b.expires) {
+			continue
+		}
+		if b.agent != "" {
+			c.log().Warn(fmt.Sprintf("lease of batch %d to %s expired", i, b.agent), "batch", i, "agent", b.agent)
+		}
+		timeout := c.LeaseTimeout
+		if timeout <= 0 {
+			timeout = 10 * time.Minute
+		}
+		b.agent, b.expires = h.Agent, now.Add(timeout)
+		c.log().Info(fmt.Sprintf("batch %d (rounds %d to %d) leased to %s (%s/%s)", i, b.first, b.first+b.count-1, h.Agent, h.Env.GOOS, h.Env.GOARCH),
+			"batch", i, "agent", h.Agent, "goos", h.Env.GOOS, "goarch", h.Env.GOARCH)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Lease{ID: i, Spec: c.spec, First: b.first, Count: b.count})
+		return
+	}
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "every batch is leased", http.StatusServiceUnavailable)
+}
+
+// result records res. The first result of a batch counts, that of an
+// agent whose lease expired in the meantime too.
+func (c *Coordinator) result(res Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if res.Lease < 0 || res.Lease >= len(c.batches) {
+		return fmt.Errorf("fleet: no lease %d", res.Lease)
+	}
+	b := &c.batches[res.Lease]
+	if b.done {
+		c.log().Info(fmt.Sprintf("batch %d reported again by %s, ignored", res.Lease, res.Agent), "batch", res.Lease, "agent", res.Agent)
+		return nil
+	}
+	b.done = true
+	c.left--
+	a := c.agent(res.Agent, res.Env)
+	a.Batches++
+	a.Stats = a.Stats.Add(res.Stats)
+	if res.Err != "" {
+		a.Errors = append(a.Errors, fmt.Sprintf("batch %d: %s", res.Lease, res.Err))
+	}
+	log := c.log().With("batch", res.Lease, "agent", res.Agent, "goos", res.Env.GOOS, "goarch", res.Env.GOARCH)
+	log.Info(fmt.Sprintf("batch %d from %s: %s", res.Lease, res.Agent, res.Stats), "stats", res.Stats)
+	if res.Err != "" {
+		log.Error(fmt.Sprintf("batch %d from %s: %s", res.Lease, res.Agent, res.Err), "error", res.Err)
+	}
+	if v := res.Violation; v != nil {
+		f := Finding{Agent: res.Agent, Env: res.Env, Round: v.Round, Seed: v.Seed}
+		log := log.With("round", v.Round, "seed", v.Seed)
+		var err error
+		if f.File, err = c.save(res.Agent, v); err != nil {
+			log.Error(fmt.Sprintf("round %d from %s: %v", v.Round, res.Agent, err), "error", err)
+		}
+		log.Error(fmt.Sprintf("round %d (seed %d) is not linearizable on %s (%s/%s), saved to %s", v.Round, v.Seed, res.Agent, res.Env.GOOS, res.Env.GOARCH, f.File), "file", f.File)
+		c.found = append(c.found, f)
+	}
+	if c.left == 0 {
+		close(c.done)
+	}
+	return nil
+}
+
+// agent returns the summary of the agent name, c.mu must be held.
+func (c *Coordinator) agent(name string, env report.Env) *AgentSummary {
+	a, ok := c.agents[name]
+	if !ok {
+		a = &AgentSummary{Agent: name}
+		c.agents[name] = a
+	}
+	a.Env = env
+	return a
+}
+
+// save writes the visualization and history of v to c.Dir, and returns
+// the name of the visualization.
+func (c *Coordinator) save(agent string, v *Violation) (string, error) {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return "", err
+	}
+	name := strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(agent)
+	base := filepath.Join(c.Dir, fmt.Sprintf("%s_violation_%d_%s", name, v.Round, time.Now().Format("150405")))
+	history, err := json.Marshal(v.History)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(base+".json", history, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(base+".html", []byte(v.HTML), 0o644); err != nil {
+		return "", err
+	}
+	return base + ".html", nil
+}
+
+// Summary returns the outcome of the run so far, the agents by name.
+func (c *Coordinator) Summary() Summary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Summary{Batches: len(c.batches), Done: len(c.batches) - c.left, Findings: append([]Finding(nil), c.found...)}
+	for _, a := range c.agents {
+		s.Total = s.Total.Add(a.Stats)
+		a := *a
+		a.Errors = append([]string(nil), a.Errors...)
+		s.Agents = append(s.Agents, a)
+	}
+	slices.SortFunc(s.Agents, func(a, b AgentSummary) int { return strings.Compare(a.Agent, b.Agent) })
+	return s
+}
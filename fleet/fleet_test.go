@@ -0,0 +1,136 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+func spec(rounds int) workload.Spec {
+	s := workload.Default()
+	s.Impl, s.Rounds, s.Ops, s.Workers = "MutexMap", rounds, 10, 2
+	return s
+}
+
+func TestRun(t *testing.T) {
+	c, err := NewCoordinator(spec(10), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := (&Agent{URL: srv.URL, Name: name}).Run(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("not done after the agents returned")
+	}
+	s := c.Summary()
+	if s.Batches != 4 || s.Done != 4 || s.Total.Rounds != 10 || s.Total.Ops != 10*2*10 || len(s.Findings) != 0 {
+		t.Errorf("summary %+v", s)
+	}
+	batches := 0
+	for _, a := range s.Agents {
+		batches += a.Batches
+		if a.Env.GOARCH == "" {
+			t.Errorf("agent %s without its environment", a.Agent)
+		}
+	}
+	if len(s.Agents) != 2 || batches != 4 {
+		t.Errorf("agents %+v", s.Agents)
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	c, err := NewCoordinator(spec(4), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.LeaseTimeout = time.Millisecond
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	// An agent that leases the first batch and is never heard from again.
+	gone := &Agent{URL: srv.URL, Name: "gone"}
+	if l, _, err := gone.lease(context.Background(), Hello{Agent: "gone"}); err != nil || l == nil || l.First != 0 {
+		t.Fatalf("lease %+v: %v", l, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := (&Agent{URL: srv.URL, Name: "b"}).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s := c.Summary()
+	if s.Done != 2 || s.Total.Rounds != 4 {
+		t.Errorf("summary %+v", s)
+	}
+	// A late result of an expired lease does not count twice.
+	post(t, srv.URL+"/result", Result{Lease: 0, Agent: "gone", Stats: workload.Stats{Rounds: 2}}, http.StatusOK)
+	if s := c.Summary(); s.Total.Rounds != 4 {
+		t.Errorf("late result counted: %+v", s.Total)
+	}
+	post(t, srv.URL+"/result", Result{Lease: 7}, http.StatusBadRequest)
+}
+
+func TestViolation(t *testing.T) {
+	c, err := NewCoordinator(spec(2), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Dir = filepath.Join(t.TempDir(), "found")
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	post(t, srv.URL+"/lease", Hello{Agent: "arm/1"}, http.StatusOK)
+	h := &workload.History{Operations: []porcupine.Operation{
+		{Input: model.Input{Op: model.Load, Key: "k"}, Output: model.Output{Found: true, Val: 1}, Call: 0, Return: 1},
+	}}
+	post(t, srv.URL+"/result", Result{Lease: 0, Agent: "arm/1", Stats: workload.Stats{Rounds: 2, Violations: 1},
+		Violation: &Violation{Round: 1, Seed: 9, History: h, HTML: "<html>"}}, http.StatusOK)
+	s := c.Summary()
+	if len(s.Findings) != 1 || s.Findings[0].Round != 1 || s.Findings[0].Agent != "arm/1" {
+		t.Fatalf("findings %+v", s.Findings)
+	}
+	if _, err := workload.LoadHistory(s.Findings[0].File[:len(s.Findings[0].File)-len(".html")] + ".json"); err != nil {
+		t.Error(err)
+	}
+	if b, err := os.ReadFile(s.Findings[0].File); err != nil || string(b) != "<html>" {
+		t.Errorf("%s: %q, %v", s.Findings[0].File, b, err)
+	}
+	post(t, srv.URL+"/lease", Hello{Agent: "b"}, http.StatusNoContent)
+}
+
+func post(t *testing.T, url string, v any, want int) {
+	t.Helper()
+	b, _ := json.Marshal(v)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != want {
+		t.Errorf("POST %s: %s, want %d", url, resp.Status, want)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// FuzzSyncMap decodes its input as a workload.Schedule, runs it against
+// the map and checks the history, for go test -fuzz to search the
+// schedules of a few clients by the code they reach, which steers it to
+// interleavings the random rounds of TestSyncMap rarely hit. The fuzzer
+// does not instrument package sync, so for sync.Map that is the code of
+// the model and the checker, the outcomes of the operations.
+// Without -fuzz it runs the seeds below and the corpus in testdata/fuzz:
+//
+//	go test -run '^$' -fuzz FuzzSyncMap -fuzztime 10m
+//	go test -run '^$' -fuzz FuzzSyncMap -fuzz-impl SyncMapOf
+var fuzzImpl = flag.String("fuzz-impl", "sync.Map", "registered implementation FuzzSyncMap runs its schedules against")
+
+func FuzzSyncMap(f *testing.F) {
+	impl, ok := mapimpl.Lookup(*fuzzImpl)
+	if !ok {
+		f.Fatalf("-fuzz-impl: unknown implementation %q", *fuzzImpl)
+	}
+	// Two clients on one key: a Store and a Swap against a Load and a
+	// CompareAndSwap expecting the swapped value, then a Range.
+	f.Add([]byte{1, 0,
+		0, 1, 0, 0x01, 0,
+		1, 0, 0, 0x00, 3,
+		0, 4, 0, 0x02, 0,
+		1, 5, 0, 0x23, 200,
+		0, 6, 0, 0x00, 0})
+	// Four clients on two keys, LoadOrStore and LoadAndDelete racing.
+	f.Add([]byte{3, 1,
+		0, 2, 0, 0x01, 0,
+		1, 2, 0, 0x02, 0,
+		2, 3, 0, 0x00, 1,
+		3, 2, 1, 0x03, 0,
+		0, 3, 1, 0x00, 130,
+		1, 6, 0, 0x00, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sc := workload.DecodeSchedule(data)
+		m := impl.New()
+		h := sc.Run(m)
+		if err := runProbes(m, h.Operations); err != nil {
+			t.Fatalf("%s: %v\n%s", impl.Name, err, sc)
+		}
+		r := workload.Check(h, nil, 10*time.Second)
+		if r.Check == porcupine.Illegal {
+			filename := saveViolation(t, impl.Name, &workload.Violation{Info: r.Info, History: h})
+			t.Fatalf("%s violation saved to %s of the schedule\n%s", impl.Name, filename, sc)
+		}
+	})
+}
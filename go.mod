@@ -2,4 +2,8 @@ module github.com/jmasters-git/porcupine-syncmap
 
 go 1.24
 
-require github.com/anishathalye/porcupine v1.0.3
+require (
+	github.com/anishathalye/porcupine v1.0.3
+	github.com/puzpuzpuz/xsync/v3 v3.5.1
+	pgregory.net/rapid v1.3.0
+)
@@ -0,0 +1,327 @@
+// Package goexp runs the suite of this module under several build and
+// runtime environments, GOEXPERIMENT and GODEBUG settings, and compares
+// them side by side: the litmus outcome frequencies of cmd/litmus and the
+// results of the linearizability tests.
+//
+// Go 1.24 replaced the read-only and dirty maps of sync.Map with a
+// HashTrieMap, with GOEXPERIMENT=nosynchashtriemap to build the previous
+// implementation: SyncMapVariants compares the two. A toolchain without
+// the experiment, before 1.24 or after it was removed, fails to build that
+// variant, which the report shows as an error. The preemption policy of
+// the scheduler changes the interleavings as much, Combinations of the
+// SchedulerSettings compare those.
+package goexp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/crossrun"
+)
+
+// A Variant is an environment to build and run the suite in.
+type Variant struct {
+	Name string
+	// Env are KEY=value variables, e.g. GOEXPERIMENT=nosynchashtriemap.
+	// Those of the runtime, such as GODEBUG, are only set for the binaries
+	// the suite runs, see crossrun.SplitEnv.
+	Env []string
+}
+
+// SyncMapVariants returns the two implementations of sync.Map: the
+// toolchain's default, a HashTrieMap since Go 1.24, and the read-only and
+// dirty maps of earlier releases.
+func SyncMapVariants() []Variant {
+	return []Variant{
+		{Name: "default"},
+		{Name: "nosynchashtriemap", Env: []string{"GOEXPERIMENT=nosynchashtriemap"}},
+	}
+}
+
+// SchedulerSettings are the GODEBUG settings of the scheduler and the
+// collector that change the interleavings the suite observes: no
+// asynchronous preemption, so goroutines give up their processor only at
+// calls and blocking operations, every collection stopping the world, and
+// a scheduler trace every second, collected in Result.SchedTrace.
+func SchedulerSettings() []string {
+	return []string{"asyncpreemptoff=1", "gcstoptheworld=1", "schedtrace=1000"}
+}
+
+// Combinations returns a variant per combination of the GODEBUG settings,
+// from none of them, named default, to all of them, named by the settings
+// joined with +.
+func Combinations(settings ...string) []Variant {
+	var variants []Variant
+	for set := range 1 << len(settings) {
+		var on []string
+		for i, setting := range settings {
+			if set&(1<<i) != 0 {
+				on = append(on, setting)
+			}
+		}
+		if len(on) == 0 {
+			variants = append(variants, Variant{Name: "default"})
+			continue
+		}
+		variants = append(variants, Variant{
+			Name: strings.Join(on, "+"),
+			Env:  []string{"GODEBUG=" + strings.Join(on, ",")},
+		})
+	}
+	return variants
+}
+
+// Options configure Run.
+type Options struct {
+	Variants []Variant
+	// Cases are the litmus cases run under every variant, by
+	// crossrun.Run on the native architecture.
+	Cases      []crossrun.Case
+	LitmusArgs []string
+	// Tests, if set, is the -run pattern of the go tests of the module
+	// root run under every variant, with TestArgs.
+	Tests    string
+	TestArgs []string
+	// ModuleDir is the root of this module, the current directory by
+	// default.
+	ModuleDir string
+}
+
+// A TestResult is the result of one top-level test.
+type TestResult struct {
+	Name           string  `json:"name"`
+	Passed         bool    `json:"passed"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	// Output is the output of a failed test.
+	Output string `json:"output,omitempty"`
+}
+
+// A Result is the outcome of the suite under one variant. TestErr is set
+// if the tests could not be run at all, e.g. because the variant does not
+// build.
+type Result struct {
+	Variant string           `json:"variant"`
+	Env     []string         `json:"env,omitempty"`
+	Litmus  []crossrun.Entry `json:"litmus,omitempty"`
+	Tests   []TestResult     `json:"tests,omitempty"`
+	TestErr string           `json:"test_error,omitempty"`
+	// SchedTrace are the lines GODEBUG=schedtrace printed during the
+	// tests, if it was set.
+	SchedTrace []string `json:"schedtrace,omitempty"`
+}
+
+// Report holds the results of every variant, in order.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Run runs the litmus cases and tests of opts under every variant. A
+// variant that fails to build or run only fails its own results.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	rep := &Report{}
+	for _, v := range opts.Variants {
+		res := Result{Variant: v.Name, Env: v.Env}
+		if len(opts.Cases) > 0 {
+			lit, err := crossrun.Run(ctx, crossrun.Options{
+				Targets:   []crossrun.Target{{GOARCH: runtime.GOARCH, Env: v.Env}},
+				Cases:     opts.Cases,
+				Args:      opts.LitmusArgs,
+				ModuleDir: opts.ModuleDir,
+			})
+			if err != nil {
+				return nil, err
+			}
+			res.Litmus = lit.Entries
+		}
+		if opts.Tests != "" {
+			res.Tests, res.SchedTrace, res.TestErr = runTests(ctx, opts, v)
+		}
+		rep.Results = append(rep.Results, res)
+	}
+	return rep, nil
+}
+
+// testEvent is the part of a go test -json event runTests needs.
+type testEvent struct {
+	Action  string
+	Test    string
+	Output  string
+	Elapsed float64
+}
+
+// runTests runs the tests of opts under v and returns their results and
+// scheduler trace, or the error that kept them from running.
+func runTests(ctx context.Context, opts Options, v Variant) ([]TestResult, []string, string) {
+	build, run := crossrun.SplitEnv(v.Env)
+	args := []string{"test", "-count=1", "-json", "-run", opts.Tests}
+	if len(run) > 0 {
+		// Only the test binary runs under GODEBUG and the like, by env, not
+		// the go command, the compiler and vet.
+		args = append(args, "-exec", "env "+strings.Join(run, " "))
+	}
+	args = append(args, opts.TestArgs...)
+	cmd := exec.CommandContext(ctx, "go", append(args, ".")...)
+	cmd.Dir = opts.ModuleDir
+	cmd.Env = append(os.Environ(), build...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+
+	var (
+		results []TestResult
+		sched   []string
+		output  = make(map[string]*strings.Builder)
+		other   strings.Builder
+	)
+	sc := bufio.NewScanner(&stdout)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var ev testEvent
+		if json.Unmarshal(sc.Bytes(), &ev) != nil {
+			continue
+		}
+		// The scheduler trace goes to the test binary's stderr and ends up
+		// in the output of whichever test runs.
+		if ev.Action == "output" && strings.HasPrefix(ev.Output, "SCHED ") {
+			sched = append(sched, strings.TrimSuffix(ev.Output, "\n"))
+			continue
+		}
+		// Only top-level tests, their subtests are part of them.
+		if ev.Test == "" || strings.Contains(ev.Test, "/") {
+			if ev.Action == "output" || ev.Action == "build-output" {
+				other.WriteString(ev.Output)
+			}
+			continue
+		}
+		switch ev.Action {
+		case "output":
+			if output[ev.Test] == nil {
+				output[ev.Test] = new(strings.Builder)
+			}
+			output[ev.Test].WriteString(ev.Output)
+		case "pass", "fail":
+			r := TestResult{Name: ev.Test, Passed: ev.Action == "pass", ElapsedSeconds: ev.Elapsed}
+			if !r.Passed && output[ev.Test] != nil {
+				r.Output = output[ev.Test].String()
+			}
+			results = append(results, r)
+		}
+	}
+	if err != nil && len(results) == 0 {
+		msg := strings.TrimSpace(stderr.String() + other.String())
+		return nil, nil, fmt.Sprintf("%v: %s", err, msg)
+	}
+	return results, sched, ""
+}
+
+// Unavailable reports whether any variant could not be built or run.
+func (r *Report) Unavailable() bool {
+	for _, res := range r.Results {
+		if res.TestErr != "" || (&crossrun.Report{Entries: res.Litmus}).Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed reports whether any variant failed a test.
+func (r *Report) Failed() bool {
+	for _, res := range r.Results {
+		for _, t := range res.Tests {
+			if !t.Passed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Forbidden reports whether any variant observed a forbidden litmus
+// outcome.
+func (r *Report) Forbidden() bool {
+	for _, res := range r.Results {
+		if (&crossrun.Report{Entries: res.Litmus}).Forbidden() {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary formats the comparison: one row per litmus case and test, one
+// column per variant.
+func (r *Report) Summary() string {
+	const width = 30
+	var (
+		b    strings.Builder
+		rows []string
+		cell = make(map[[2]string]string)
+	)
+	row := func(name string) {
+		for _, n := range rows {
+			if n == name {
+				return
+			}
+		}
+		rows = append(rows, name)
+	}
+	for _, res := range r.Results {
+		for _, e := range res.Litmus {
+			row(e.Case)
+			if e.Err != "" {
+				cell[[2]string{e.Case, res.Variant}] = "error"
+				continue
+			}
+			lit := e.Report.Result
+			freq := 0.0
+			if lit.Iterations > 0 {
+				freq = 100 * float64(lit.Forbidden) / float64(lit.Iterations)
+			}
+			cell[[2]string{e.Case, res.Variant}] = fmt.Sprintf("%d/%d (%.4f%%)", lit.Forbidden, lit.Iterations, freq)
+		}
+		for _, t := range res.Tests {
+			row(t.Name)
+			status := "ok"
+			if !t.Passed {
+				status = "FAIL"
+			}
+			cell[[2]string{t.Name, res.Variant}] = fmt.Sprintf("%s %v", status, time.Duration(t.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond))
+		}
+	}
+
+	line := func(first string, cells func(variant string) string) {
+		l := fmt.Sprintf("%-*s", width, first)
+		for _, res := range r.Results {
+			l += fmt.Sprintf(" %-*s", width, cells(res.Variant))
+		}
+		b.WriteString(strings.TrimRight(l, " ") + "\n")
+	}
+	line("", func(variant string) string { return variant })
+	for _, name := range rows {
+		line(name, func(variant string) string {
+			if c, ok := cell[[2]string{name, variant}]; ok {
+				return c
+			}
+			return "-"
+		})
+	}
+	// The errors last, they do not fit a cell.
+	for _, res := range r.Results {
+		for _, e := range res.Litmus {
+			if e.Err != "" {
+				fmt.Fprintf(&b, "%s: %s: %s\n", res.Variant, e.Case, e.Err)
+			}
+		}
+		if res.TestErr != "" {
+			fmt.Fprintf(&b, "%s: tests: %s\n", res.Variant, res.TestErr)
+		}
+	}
+	return b.String()
+}
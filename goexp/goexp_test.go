@@ -0,0 +1,114 @@
+package goexp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/crossrun"
+)
+
+// suite is the throwaway module TestRun runs the tests of, which checks
+// the environment and the arguments the tests run with.
+const suite = `package suite
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+var rounds = flag.Int("rounds", 0, "")
+
+func TestEnv(t *testing.T) {
+	if got := os.Getenv("GODEBUG"); got != "schedtrace=1000" {
+		t.Errorf("GODEBUG=%q", got)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	if *rounds != 20 {
+		t.Errorf("-rounds=%d", *rounds)
+	}
+}
+`
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cmd/litmus is built for linux")
+	}
+	if testing.Short() {
+		t.Skip("builds and runs the suite twice")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{"go.mod": "module suite\n\ngo 1.24\n", "suite_test.go": suite} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	variants := []Variant{
+		{Name: "schedtrace", Env: []string{"GODEBUG=schedtrace=1000"}},
+		{Name: "bogus", Env: []string{"GOEXPERIMENT=doesnotexist"}},
+	}
+	lit, err := Run(context.Background(), Options{
+		Variants:   variants,
+		Cases:      []crossrun.Case{{Preset: "SB", Prim: "atomic.Store"}},
+		LitmusArgs: []string{"-iters", "100"},
+		ModuleDir:  "..",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := Run(context.Background(), Options{
+		Variants:  variants,
+		Tests:     "^Test",
+		TestArgs:  []string{"-args", "-rounds=20"},
+		ModuleDir: dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lit.Results) != 2 || len(rep.Results) != 2 {
+		t.Fatalf("%d and %d results", len(lit.Results), len(rep.Results))
+	}
+	for i := range rep.Results {
+		rep.Results[i].Litmus = lit.Results[i].Litmus
+	}
+	def, bogus := rep.Results[0], rep.Results[1]
+	if len(def.Litmus) != 1 || def.Litmus[0].Err != "" || len(def.Tests) != 2 || !def.Tests[0].Passed || !def.Tests[1].Passed {
+		t.Errorf("schedtrace variant: %+v", def)
+	}
+	if len(def.SchedTrace) == 0 || !strings.HasPrefix(def.SchedTrace[0], "SCHED 0ms: ") {
+		t.Errorf("scheduler trace not collected: %q", def.SchedTrace)
+	}
+	if bogus.Litmus[0].Err == "" || bogus.TestErr == "" || !strings.Contains(bogus.TestErr, "doesnotexist") {
+		t.Errorf("bogus variant did not fail to build: %+v", bogus)
+	}
+	if !rep.Unavailable() || rep.Failed() || rep.Forbidden() {
+		t.Errorf("Unavailable = %v, Failed = %v, Forbidden = %v", rep.Unavailable(), rep.Failed(), rep.Forbidden())
+	}
+	sum := rep.Summary()
+	for _, want := range []string{"SB+atomic.Store", "TestArgs", "ok ", "bogus: tests: "} {
+		if !strings.Contains(sum, want) {
+			t.Errorf("summary lacks %q:\n%s", want, sum)
+		}
+	}
+}
+
+func TestCombinations(t *testing.T) {
+	vs := Combinations("a=1", "b=2")
+	var got []string
+	for _, v := range vs {
+		got = append(got, v.Name+":"+strings.Join(v.Env, " "))
+	}
+	want := "default: a=1:GODEBUG=a=1 b=2:GODEBUG=b=2 a=1+b=2:GODEBUG=a=1,b=2"
+	if g := strings.Join(got, " "); g != want {
+		t.Errorf("Combinations = %q, want %q", g, want)
+	}
+	if n := len(Combinations(SchedulerSettings()...)); n != 8 {
+		t.Errorf("%d combinations of the scheduler settings, want 8", n)
+	}
+}
@@ -1,5 +1,24 @@
+//go:build amd64 || arm64
+
 package asm
 
+// MemoryBarrier is a full fence: no load or store is reordered across it
+// (MFENCE, DMB ISH).
+//
 //go:nosplit
 //go:noescape
 func MemoryBarrier()
+
+// StoreBarrier orders stores before it against stores after it (SFENCE,
+// DMB ISHST).
+//
+//go:nosplit
+//go:noescape
+func StoreBarrier()
+
+// LoadBarrier orders loads before it against loads and stores after it
+// (LFENCE, DMB ISHLD).
+//
+//go:nosplit
+//go:noescape
+func LoadBarrier()
@@ -5,3 +5,11 @@
 TEXT ·MemoryBarrier(SB), NOSPLIT|NOFRAME, $0-0
 	MFENCE
 	RET
+
+TEXT ·StoreBarrier(SB), NOSPLIT|NOFRAME, $0-0
+	SFENCE
+	RET
+
+TEXT ·LoadBarrier(SB), NOSPLIT|NOFRAME, $0-0
+	LFENCE
+	RET
@@ -5,3 +5,11 @@
 TEXT ·MemoryBarrier(SB), NOSPLIT|NOFRAME, $0-0
 	DMB $0xb // DMB ISH
 	RET
+
+TEXT ·StoreBarrier(SB), NOSPLIT|NOFRAME, $0-0
+	DMB $0xa // DMB ISHST
+	RET
+
+TEXT ·LoadBarrier(SB), NOSPLIT|NOFRAME, $0-0
+	DMB $0x9 // DMB ISHLD
+	RET
@@ -0,0 +1,17 @@
+//go:build !amd64 && !arm64
+
+package asm
+
+import "sync/atomic"
+
+var fence atomic.Int64
+
+// MemoryBarrier falls back to an atomic read-modify-write, which every Go
+// port implements with a full fence.
+func MemoryBarrier() { fence.Add(1) }
+
+// StoreBarrier is MemoryBarrier on architectures without assembly.
+func StoreBarrier() { MemoryBarrier() }
+
+// LoadBarrier is MemoryBarrier on architectures without assembly.
+func LoadBarrier() { MemoryBarrier() }
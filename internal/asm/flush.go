@@ -0,0 +1,15 @@
+//go:build amd64
+
+package asm
+
+import "unsafe"
+
+// HasFlush reports whether Flush evicts cache lines on this architecture.
+const HasFlush = true
+
+// Flush evicts the cache line containing addr from every level of the cache
+// hierarchy (CLFLUSH).
+//
+//go:nosplit
+//go:noescape
+func Flush(addr unsafe.Pointer)
@@ -0,0 +1,8 @@
+//go:build amd64
+
+#include "textflag.h"
+
+TEXT ·Flush(SB), NOSPLIT|NOFRAME, $0-8
+	MOVQ addr+0(FP), AX
+	CLFLUSH (AX)
+	RET
@@ -0,0 +1,11 @@
+//go:build !amd64
+
+package asm
+
+import "unsafe"
+
+// HasFlush reports whether Flush evicts cache lines on this architecture.
+const HasFlush = false
+
+// Flush is a no-op on architectures without a user-space cache line flush.
+func Flush(addr unsafe.Pointer) {}
@@ -0,0 +1,16 @@
+//go:build amd64 || arm64
+
+package asm
+
+// HasTicks reports whether Ticks reads a hardware counter on this
+// architecture.
+const HasTicks = true
+
+// Ticks reads the CPU's constant rate counter: the time stamp counter
+// after an LFENCE, so it is not read ahead of earlier instructions
+// (RDTSC), or the virtual count of the generic timer (ISB, CNTVCT_EL0).
+// The rate is not known, calibrate it against a clock.
+//
+//go:nosplit
+//go:noescape
+func Ticks() uint64
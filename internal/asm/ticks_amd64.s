@@ -0,0 +1,11 @@
+//go:build amd64
+
+#include "textflag.h"
+
+TEXT ·Ticks(SB), NOSPLIT|NOFRAME, $0-8
+	LFENCE
+	RDTSC
+	SHLQ $32, DX
+	ORQ DX, AX
+	MOVQ AX, ret+0(FP)
+	RET
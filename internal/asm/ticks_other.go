@@ -0,0 +1,10 @@
+//go:build !amd64 && !arm64
+
+package asm
+
+// HasTicks reports whether Ticks reads a hardware counter on this
+// architecture.
+const HasTicks = false
+
+// Ticks is 0 on architectures without a user-space counter.
+func Ticks() uint64 { return 0 }
@@ -0,0 +1,94 @@
+// Package cpuload is the antagonist of the litmus and linearizability runs:
+// goroutines that keep CPUs busy in the background, so the goroutines under
+// test share the processors of GOMAXPROCS with them, are preempted and
+// migrate more often, and wait longer to be scheduled at all.
+package cpuload
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// period is the cycle of a goroutine with a duty below 100%.
+const period = time.Millisecond
+
+// Load configures the antagonist.
+type Load struct {
+	// Cores is the number of busy goroutines, as many CPUs as they keep
+	// busy. More than GOMAXPROCS minus the goroutines under test
+	// oversubscribes the processors.
+	Cores int `json:"cores,omitempty"`
+	// Duty is the percentage of every millisecond each goroutine spins, the
+	// rest it sleeps. 0 is 100.
+	Duty int `json:"duty,omitempty"`
+}
+
+// Validate reports the first problem with l.
+func (l Load) Validate() error {
+	switch {
+	case l.Cores < 0:
+		return errors.New("cpu load: cores must not be negative")
+	case l.Duty < 0 || l.Duty > 100:
+		return errors.New("cpu load: duty must be between 0 and 100")
+	}
+	return nil
+}
+
+func (l Load) duty() int {
+	if l.Duty == 0 {
+		return 100
+	}
+	return l.Duty
+}
+
+// Start starts the goroutines of l and returns the function that stops
+// them and waits for them to return.
+func (l Load) Start() (stop func()) {
+	if l.Cores == 0 {
+		return func() {}
+	}
+	var (
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+		busy = period * time.Duration(l.duty()) / 100
+	)
+	wg.Add(l.Cores)
+	for range l.Cores {
+		go func() {
+			defer wg.Done()
+			var x uint64
+			for {
+				select {
+				case <-done:
+					sink.Add(x)
+					return
+				default:
+				}
+				x += spin(busy)
+				if busy < period {
+					time.Sleep(period - busy)
+				}
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// spin keeps the CPU busy for d and returns what it computed.
+func spin(d time.Duration) uint64 {
+	x := uint64(1)
+	for start := time.Now(); time.Since(start) < d; {
+		for range 1 << 10 {
+			x = x*6364136223846793005 + 1442695040888963407
+		}
+	}
+	return x
+}
+
+// sink keeps the compiler from dropping the loop of spin.
+var sink atomic.Uint64
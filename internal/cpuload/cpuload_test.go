@@ -0,0 +1,37 @@
+package cpuload
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	before := runtime.NumGoroutine()
+	stop := Load{Cores: 3, Duty: 50}.Start()
+	if n := runtime.NumGoroutine(); n < before+3 {
+		t.Errorf("%d goroutines after Start, want at least %d", n, before+3)
+	}
+	time.Sleep(5 * time.Millisecond)
+	stop()
+	// The goroutines are done once stop returned, but may not have exited
+	// yet.
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines after stop, want %d", n, before)
+	}
+	Load{}.Start()()
+}
+
+func TestValidate(t *testing.T) {
+	for _, l := range []Load{{Cores: -1}, {Cores: 1, Duty: 101}, {Duty: -1}} {
+		if l.Validate() == nil {
+			t.Errorf("%+v is valid", l)
+		}
+	}
+	if err := (Load{Cores: 2, Duty: 100}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
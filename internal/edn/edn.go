@@ -0,0 +1,300 @@
+// Package edn reads and writes the subset of EDN that Jepsen and Knossos
+// histories are written in: nil, booleans, integers, floats, strings,
+// characters, keywords, symbols, lists, vectors, sets and maps. Tagged
+// values, such as the #jepsen.history.Op records of recent Jepsen
+// versions, are read as the value they tag.
+package edn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Keyword is a keyword without its colon, e.g. invoke for :invoke.
+type Keyword string
+
+// A Symbol is a symbol, e.g. jepsen.history.Op.
+type Symbol string
+
+// A Decoder reads EDN values from a stream.
+type Decoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewDecoder returns a decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), line: 1}
+}
+
+// Decode returns the next value, or io.EOF after the last one. Values are
+// nil, bool, int64, float64, string for strings and characters, Keyword,
+// Symbol, []any for lists, vectors and sets, and map[any]any for maps,
+// whose keys must be of the other types.
+func (d *Decoder) Decode() (any, error) {
+	v, err := d.value()
+	if err == errEnd {
+		return nil, d.errorf("unexpected closing delimiter")
+	}
+	return v, err
+}
+
+// errEnd is returned by value at the closing delimiter of a collection.
+var errEnd = errors.New("end of collection")
+
+func (d *Decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("edn: line %d: %s", d.line, fmt.Sprintf(format, args...))
+}
+
+func (d *Decoder) read() (rune, error) {
+	c, _, err := d.r.ReadRune()
+	if c == '\n' {
+		d.line++
+	}
+	return c, err
+}
+
+func (d *Decoder) unread(c rune) {
+	d.r.UnreadRune()
+	if c == '\n' {
+		d.line--
+	}
+}
+
+// skip skips whitespace, commas and comments and returns the next rune.
+func (d *Decoder) skip() (rune, error) {
+	for {
+		c, err := d.read()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case c == ';':
+			for c != '\n' {
+				if c, err = d.read(); err != nil {
+					return 0, err
+				}
+			}
+		case c == ',' || unicode.IsSpace(c):
+		default:
+			return c, nil
+		}
+	}
+}
+
+// value reads the next value, or returns errEnd at the closing delimiter
+// of a collection, which is left to read.
+func (d *Decoder) value() (any, error) {
+	c, err := d.skip()
+	if err != nil {
+		return nil, err
+	}
+	switch c {
+	case ')', ']', '}':
+		d.unread(c)
+		return nil, errEnd
+	case '(':
+		return d.seq(')')
+	case '[':
+		return d.seq(']')
+	case '{':
+		return d.mapping()
+	case '"':
+		return d.str()
+	case '\\':
+		return d.char()
+	case '#':
+		return d.dispatch()
+	case ':':
+		tok, err := d.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == "" {
+			return nil, d.errorf("empty keyword")
+		}
+		return Keyword(tok), nil
+	}
+	d.unread(c)
+	tok, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	return d.atom(tok)
+}
+
+// seq reads the values of a collection up to its closing delimiter.
+func (d *Decoder) seq(closing rune) ([]any, error) {
+	vals := []any{}
+	for {
+		v, err := d.value()
+		if err == errEnd {
+			c, _ := d.read()
+			if c != closing {
+				return nil, d.errorf("unexpected %q, want %q", c, closing)
+			}
+			return vals, nil
+		}
+		if err == io.EOF {
+			return nil, d.errorf("unexpected end of input, want %q", closing)
+		}
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+}
+
+func (d *Decoder) mapping() (map[any]any, error) {
+	vals, err := d.seq('}')
+	if err != nil {
+		return nil, err
+	}
+	if len(vals)%2 != 0 {
+		return nil, d.errorf("map with an odd number of forms")
+	}
+	m := make(map[any]any, len(vals)/2)
+	for i := 0; i < len(vals); i += 2 {
+		switch vals[i].(type) {
+		case nil, bool, int64, float64, string, Keyword, Symbol:
+		default:
+			return nil, d.errorf("map key %v of type %T is not supported", vals[i], vals[i])
+		}
+		m[vals[i]] = vals[i+1]
+	}
+	return m, nil
+}
+
+func (d *Decoder) str() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := d.read()
+		if err != nil {
+			return "", d.errorf("unterminated string")
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			e, err := d.read()
+			if err != nil {
+				return "", d.errorf("unterminated string")
+			}
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteRune(e)
+			default:
+				return "", d.errorf("unknown escape \\%c", e)
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+// char reads a character literal as a string.
+func (d *Decoder) char() (string, error) {
+	c, err := d.read()
+	if err != nil {
+		return "", d.errorf("unterminated character")
+	}
+	tok, err := d.token()
+	if err != nil {
+		return "", err
+	}
+	name := string(c) + tok
+	switch name {
+	case "newline":
+		return "\n", nil
+	case "space":
+		return " ", nil
+	case "tab":
+		return "\t", nil
+	case "return":
+		return "\r", nil
+	}
+	if len([]rune(name)) != 1 {
+		return "", d.errorf("unknown character \\%s", name)
+	}
+	return name, nil
+}
+
+// dispatch reads what follows a #: a set, a discarded value or a tagged
+// value.
+func (d *Decoder) dispatch() (any, error) {
+	c, err := d.read()
+	if err != nil {
+		return nil, d.errorf("unexpected end of input after #")
+	}
+	switch c {
+	case '{':
+		return d.seq('}')
+	case '_':
+		if _, err := d.value(); err != nil {
+			return nil, err
+		}
+		return d.value()
+	}
+	d.unread(c)
+	if tag, err := d.token(); err != nil || tag == "" {
+		return nil, d.errorf("invalid tag")
+	}
+	v, err := d.value()
+	if err == errEnd || err == io.EOF {
+		return nil, d.errorf("tag without a value")
+	}
+	return v, err
+}
+
+// token reads the runes up to the next delimiter.
+func (d *Decoder) token() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := d.read()
+		if err == io.EOF {
+			return b.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if unicode.IsSpace(c) || strings.ContainsRune(",;()[]{}\"", c) {
+			d.unread(c)
+			return b.String(), nil
+		}
+		b.WriteRune(c)
+	}
+}
+
+func (d *Decoder) atom(tok string) (any, error) {
+	switch tok {
+	case "nil":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if c := tok[0]; c >= '0' && c <= '9' || (c == '-' || c == '+') && len(tok) > 1 && tok[1] >= '0' && tok[1] <= '9' {
+		// Arbitrary precision suffixes.
+		num := strings.TrimSuffix(tok, "N")
+		if i, err := strconv.ParseInt(num, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(tok, "M"), 64); err == nil {
+			return f, nil
+		}
+		return nil, d.errorf("invalid number %s", tok)
+	}
+	return Symbol(tok), nil
+}
@@ -0,0 +1,63 @@
+package edn
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	const in = `; a history
+{:type :invoke, :f :cas, :value [1 2], :process 0, :time 12}
+#jepsen.history.Op{:type :ok :f :read :value nil :process :nemesis}
+[1 -2 3.5 12N "a \"b\"\n" \c \space true false #{:x} (sym) #_ignored 4]
+`
+	want := []any{
+		map[any]any{Keyword("type"): Keyword("invoke"), Keyword("f"): Keyword("cas"), Keyword("value"): []any{int64(1), int64(2)}, Keyword("process"): int64(0), Keyword("time"): int64(12)},
+		map[any]any{Keyword("type"): Keyword("ok"), Keyword("f"): Keyword("read"), Keyword("value"): nil, Keyword("process"): Keyword("nemesis")},
+		[]any{int64(1), int64(-2), 3.5, int64(12), "a \"b\"\n", "c", " ", true, false, []any{Keyword("x")}, []any{Symbol("sym")}, int64(4)},
+	}
+	d := NewDecoder(strings.NewReader(in))
+	for i, w := range want {
+		got, err := d.Decode()
+		if err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("value %d = %#v, want %#v", i, got, w)
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("Decode after the last value = %v, want io.EOF", err)
+	}
+
+	for _, bad := range []string{"[1 2", "{:a}", "]", `"open`, "{[1] 2}", "#tag", "1x", `"\q"`} {
+		if _, err := NewDecoder(strings.NewReader(bad)).Decode(); err == nil || err == io.EOF {
+			t.Errorf("Decode(%q) = %v, want an error", bad, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var b strings.Builder
+	e := NewEncoder(&b)
+	v := Map{{Key: Keyword("f"), Value: Keyword("cas")}, {Key: Keyword("value"), Value: []any{nil, 2, int64(-3)}}, {Key: "s", Value: "a \"b\"\n"}, {Key: Symbol("t"), Value: true}}
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{:f :cas, :value [nil 2 -3], "s" "a \"b\"\n", t true}` + "\n"
+	if b.String() != want {
+		t.Errorf("Encode = %q, want %q", b.String(), want)
+	}
+	got, err := NewDecoder(strings.NewReader(b.String())).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := got.(map[any]any); len(m) != 4 || m["s"] != "a \"b\"\n" {
+		t.Errorf("Decode = %#v", got)
+	}
+	if err := e.Encode(1.5); err == nil {
+		t.Error("Encode(1.5) succeeded")
+	}
+}
@@ -0,0 +1,106 @@
+package edn
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A Map is a map written with its keys in order.
+type Map []Pair
+
+// A Pair is a key and its value in a Map.
+type Pair struct {
+	Key, Value any
+}
+
+// Append appends the EDN of v to b. v is nil, a bool, an int, int64,
+// string, Keyword, Symbol, []any, written as a vector, or Map.
+func Append(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "nil"...), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case int:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case string:
+		return appendString(b, v), nil
+	case Keyword:
+		return append(append(b, ':'), v...), nil
+	case Symbol:
+		return append(b, v...), nil
+	case []any:
+		b = append(b, '[')
+		for i, e := range v {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			var err error
+			if b, err = Append(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case Map:
+		b = append(b, '{')
+		for i, p := range v {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			var err error
+			if b, err = Append(b, p.Key); err != nil {
+				return nil, err
+			}
+			b = append(b, ' ')
+			if b, err = Append(b, p.Value); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, '}'), nil
+	}
+	return nil, fmt.Errorf("edn: cannot write %T", v)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	for _, c := range s {
+		switch c {
+		case '"', '\\':
+			b = append(b, '\\', byte(c))
+		case '\n':
+			b = append(b, `\n`...)
+		case '\t':
+			b = append(b, `\t`...)
+		case '\r':
+			b = append(b, `\r`...)
+		default:
+			b = append(b, string(c)...)
+		}
+	}
+	return append(b, '"')
+}
+
+// An Encoder writes EDN values, one per line.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewEncoder returns an encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes v, see Append, and a newline.
+func (e *Encoder) Encode(v any) error {
+	b, err := Append(e.buf[:0], v)
+	if err != nil {
+		return err
+	}
+	e.buf = append(b, '\n')
+	_, err = e.w.Write(e.buf)
+	return err
+}
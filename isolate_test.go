@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// Isolation runs the rounds of every workload test in batches, each in a
+// child process of its own: the GC state, map internals and GOMAXPROCS
+// changes of one batch cannot carry over into the next, and a batch that
+// crashes only loses its own rounds:
+//
+//	go test -run 'TestSyncMap$' -isolate=1000
+var (
+	isolateFlag = flag.Int("isolate", 0, "run the rounds of each workload test in child processes of this many rounds each")
+	batchFlag   = flag.String("batch", "", "internal: run rounds <first>:<count> of the test, set by -isolate for its child processes")
+)
+
+// batch returns the rounds of the child process's batch, if it is one.
+func batch() (first, count int, ok bool) {
+	if *batchFlag == "" {
+		return 0, 0, false
+	}
+	f, c, found := strings.Cut(*batchFlag, ":")
+	first, err1 := strconv.Atoi(f)
+	count, err2 := strconv.Atoi(c)
+	if !found || err1 != nil || err2 != nil || first < 0 || count < 1 {
+		panic(fmt.Sprintf("-batch: invalid batch %q, want <first>:<count>", *batchFlag))
+	}
+	return first, count, true
+}
+
+// isolate runs the rounds of s in batches of -isolate child processes and
+// fails t for every batch that failed, after all of them ran.
+func isolate(t *testing.T, s workload.Spec) {
+	if *durationFlag > 0 {
+		t.Fatal("-isolate runs a number of rounds and does not combine with -duration")
+	}
+	// Every batch runs its rounds with the seeds they have in the whole
+	// run.
+	if s.Seed == 0 {
+		s.Seed = rand.Uint64()
+	}
+	t.Logf("config: %s", &s)
+
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		if !parentOnly(f.Name) {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	if *outDirFlag != "" {
+		// The children save their violations to the directory of this run.
+		args = append(args, "-run-name="+runName())
+	}
+
+	failed := 0
+	for first := 0; first < s.Rounds; first += *isolateFlag {
+		count := min(*isolateFlag, s.Rounds-first)
+		cmd := exec.Command(os.Args[0], append([]string{
+			"-test.run=^" + t.Name() + "$",
+			"-seed=" + strconv.FormatUint(s.Seed, 10),
+			"-batch=" + strconv.Itoa(first) + ":" + strconv.Itoa(count),
+		}, args...)...)
+		cmd.Env = append(childEnv(), "GOMAXPROCS="+strconv.Itoa(runtime.GOMAXPROCS(0)))
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		start := time.Now()
+		err := cmd.Run()
+		if err == nil {
+			t.Logf("rounds %d-%d: ok (%v)", first, first+count-1, time.Since(start).Round(time.Millisecond))
+			continue
+		}
+		failed++
+		t.Errorf("rounds %d-%d: %v\n%s", first, first+count-1, err, bytes.TrimSpace(out.Bytes()))
+	}
+	if failed == 0 {
+		t.Logf("no violation observed after %d rounds in %d processes", s.Rounds, (s.Rounds+*isolateFlag-1) / *isolateFlag)
+	}
+}
+
+// parentOnly reports whether the flag name is one the children of -isolate
+// do not get: one the parent handles itself or one naming files the parent
+// writes. The children get the others as they are set in the parent.
+func parentOnly(name string) bool {
+	switch name {
+	case "isolate", "batch", "seed", "gomaxprocs-sweep",
+		"duration", "progress", "checkpoint", "report", "junit", "results", "summary", "sqlite", "metrics", "run-name",
+		"test.run", "test.count", "test.cpu",
+		"test.testlogfile", "test.trace":
+		return true
+	}
+	return strings.HasSuffix(name, "profile")
+}
+
+// childEnv returns the environment of the children of -isolate: that of
+// the parent without the SYNCMAP_ variables of the flags they do not get,
+// which flagsFromEnv would otherwise set in them again.
+func childEnv() []string {
+	drop := make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) {
+		if parentOnly(f.Name) {
+			drop[flagEnv(f.Name)] = true
+		}
+	})
+	var env []string
+	for _, kv := range os.Environ() {
+		if k, _, _ := strings.Cut(kv, "="); !drop[k] {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+func TestChildEnv(t *testing.T) {
+	// The children of -isolate would all listen on -metrics, or append
+	// to -report, again.
+	t.Setenv("SYNCMAP_METRICS", "127.0.0.1:0")
+	t.Setenv("SYNCMAP_REPORT", "report.json")
+	t.Setenv("SYNCMAP_OPS", "7")
+	env := childEnv()
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "SYNCMAP_METRICS=") || strings.HasPrefix(kv, "SYNCMAP_REPORT=") {
+			t.Errorf("children get %s", kv)
+		}
+	}
+	if !slices.Contains(env, "SYNCMAP_OPS=7") {
+		t.Error("children do not get SYNCMAP_OPS")
+	}
+}
@@ -0,0 +1,92 @@
+package litmus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Affinity selects where the threads of a test run. Reordering frequency
+// depends heavily on placement, e.g. SMT siblings share a store buffer
+// drain path that distinct cores do not.
+type Affinity int
+
+const (
+	// AffinityNone leaves placement to the Go and OS schedulers.
+	AffinityNone Affinity = iota
+	// AffinityDistinct pins every thread to a different physical core.
+	AffinityDistinct
+	// AffinitySiblings pins the threads to hyperthread siblings of the
+	// same physical core, moving on to the next core once one is full.
+	AffinitySiblings
+	// AffinitySame pins every thread to the same logical CPU.
+	AffinitySame
+)
+
+var affinityNames = []string{"none", "distinct", "siblings", "same"}
+
+func (a Affinity) String() string {
+	if int(a) < len(affinityNames) {
+		return affinityNames[a]
+	}
+	return fmt.Sprintf("Affinity(%d)", int(a))
+}
+
+// ParseAffinity parses a placement name as printed by Affinity.String.
+func ParseAffinity(s string) (Affinity, error) {
+	for i, name := range affinityNames {
+		if s == name {
+			return Affinity(i), nil
+		}
+	}
+	return AffinityNone, fmt.Errorf("litmus: unknown affinity %q", s)
+}
+
+// errNoAffinity is returned where CPU pinning is not implemented.
+var errNoAffinity = errors.New("litmus: CPU affinity is only supported on linux")
+
+// Place returns the logical CPU for each of n threads under placement a,
+// for use as Options.CPUs. It returns nil for AffinityNone.
+func Place(a Affinity, n int) ([]int, error) {
+	if a == AffinityNone {
+		return nil, nil
+	}
+	cores, err := topology()
+	if err != nil {
+		return nil, err
+	}
+	if len(cores) == 0 {
+		return nil, errors.New("litmus: no usable CPUs found")
+	}
+
+	cpus := make([]int, 0, n)
+	switch a {
+	case AffinityDistinct:
+		if len(cores) < n {
+			return nil, fmt.Errorf("litmus: %d threads need %d physical cores, only %d available", n, n, len(cores))
+		}
+		for _, core := range cores[:n] {
+			cpus = append(cpus, core[0])
+		}
+	case AffinitySiblings:
+		for _, core := range cores {
+			if len(core) < 2 {
+				continue
+			}
+			for _, cpu := range core {
+				if len(cpus) < n {
+					cpus = append(cpus, cpu)
+				}
+			}
+		}
+		if len(cpus) < n {
+			return nil, fmt.Errorf("litmus: %d threads need %d hyperthread siblings, only %d available", n, n, len(cpus))
+		}
+	case AffinitySame:
+		for range n {
+			cpus = append(cpus, cores[0][0])
+		}
+	default:
+		return nil, fmt.Errorf("litmus: unknown affinity %v", a)
+	}
+	return cpus, nil
+}
@@ -0,0 +1,128 @@
+package litmus
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// cpuMask is a sched_setaffinity(2) CPU set large enough for CONFIG_NR_CPUS
+// on any common distribution kernel.
+type cpuMask [1024 / 64]uint64
+
+func (m *cpuMask) set(cpu int)      { m[cpu/64] |= 1 << (cpu % 64) }
+func (m *cpuMask) has(cpu int) bool { return m[cpu/64]&(1<<(cpu%64)) != 0 }
+
+func getAffinity() (cpuMask, error) {
+	var m cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(m), uintptr(unsafe.Pointer(&m)))
+	if errno != 0 {
+		return m, fmt.Errorf("litmus: sched_getaffinity: %w", errno)
+	}
+	return m, nil
+}
+
+func setAffinity(m *cpuMask) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(*m), uintptr(unsafe.Pointer(m)))
+	if errno != 0 {
+		return fmt.Errorf("litmus: sched_setaffinity: %w", errno)
+	}
+	return nil
+}
+
+// pinner moves the calling OS thread onto one CPU and back to the mask the
+// process started with. The caller must hold runtime.LockOSThread.
+type pinner struct {
+	orig cpuMask
+}
+
+func newPinner() (*pinner, error) {
+	orig, err := getAffinity()
+	if err != nil {
+		return nil, err
+	}
+	return &pinner{orig: orig}, nil
+}
+
+func (p *pinner) pin(cpu int) {
+	var m cpuMask
+	m.set(cpu)
+	if err := setAffinity(&m); err != nil {
+		panic(err)
+	}
+}
+
+func (p *pinner) unpin() {
+	if err := setAffinity(&p.orig); err != nil {
+		panic(err)
+	}
+}
+
+// currentCPU returns the CPU the calling thread runs on, see getcpu(2).
+func currentCPU() int {
+	var cpu uint32
+	if _, _, errno := syscall.RawSyscall(sysGetcpu, uintptr(unsafe.Pointer(&cpu)), 0, 0); errno != 0 {
+		return -1
+	}
+	return int(cpu)
+}
+
+// topology groups the CPUs this process may run on by physical core, using
+// the thread_siblings_list files in sysfs. Cores and siblings are sorted.
+func topology() ([][]int, error) {
+	allowed, err := getAffinity()
+	if err != nil {
+		return nil, err
+	}
+	var cores [][]int
+	seen := make(map[int]bool)
+	for cpu := range len(allowed) * 64 {
+		if !allowed.has(cpu) || seen[cpu] {
+			continue
+		}
+		data, err := os.ReadFile(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/topology/thread_siblings_list", cpu))
+		if err != nil {
+			return nil, fmt.Errorf("litmus: reading CPU topology: %w", err)
+		}
+		siblings, err := parseCPUList(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, err
+		}
+		var core []int
+		for _, s := range siblings {
+			if allowed.has(s) && !seen[s] {
+				seen[s] = true
+				core = append(core, s)
+			}
+		}
+		slices.Sort(core)
+		cores = append(cores, core)
+	}
+	return cores, nil
+}
+
+// parseCPUList parses the kernel's CPU list format, e.g. "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("litmus: bad CPU list %q", s)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("litmus: bad CPU list %q", s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
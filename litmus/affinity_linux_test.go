@@ -0,0 +1,16 @@
+package litmus
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	got, err := parseCPUList("0-2,8,10-11")
+	if err != nil || !slices.Equal(got, []int{0, 1, 2, 8, 10, 11}) {
+		t.Fatalf("parseCPUList = %v, %v", got, err)
+	}
+	if _, err := parseCPUList("0-x"); err == nil {
+		t.Fatal("parseCPUList accepted a bad range")
+	}
+}
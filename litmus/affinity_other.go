@@ -0,0 +1,15 @@
+//go:build !linux
+
+package litmus
+
+type pinner struct{}
+
+func newPinner() (*pinner, error) { return nil, errNoAffinity }
+
+func (p *pinner) pin(cpu int) {}
+
+func (p *pinner) unpin() {}
+
+func currentCPU() int { return -1 }
+
+func topology() ([][]int, error) { return nil, errNoAffinity }
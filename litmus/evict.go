@@ -0,0 +1,79 @@
+package litmus
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/asm"
+)
+
+// Evict selects how caches are evicted before every iteration, to test the
+// hypothesis that cold caches make reorderings less likely.
+type Evict int
+
+const (
+	EvictNone Evict = iota
+	// EvictThrash walks a buffer larger than the caches.
+	EvictThrash
+	// EvictFlush flushes the lines holding the locations, registers and the
+	// map's own struct (CLFLUSH, amd64 only).
+	EvictFlush
+)
+
+// DefaultEvictSize is the EvictThrash buffer size used when none is given.
+const DefaultEvictSize = 8 << 20
+
+var evictNames = []string{"none", "thrash", "clflush"}
+
+func (ev Evict) String() string {
+	if int(ev) < len(evictNames) {
+		return evictNames[ev]
+	}
+	return fmt.Sprintf("Evict(%d)", int(ev))
+}
+
+// ParseEvict parses an eviction mode name as printed by Evict.String.
+func ParseEvict(s string) (Evict, error) {
+	for i, name := range evictNames {
+		if s == name {
+			ev := Evict(i)
+			if ev == EvictFlush && !asm.HasFlush {
+				return EvictNone, fmt.Errorf("litmus: eviction mode %q is not supported on this architecture", s)
+			}
+			return ev, nil
+		}
+	}
+	return EvictNone, fmt.Errorf("litmus: unknown eviction mode %q", s)
+}
+
+type evictor struct {
+	mode Evict
+	buf  []byte
+}
+
+func newEvictor(mode Evict, size int) *evictor {
+	ev := &evictor{mode: mode}
+	if mode == EvictThrash {
+		if size <= 0 {
+			size = DefaultEvictSize
+		}
+		ev.buf = make([]byte, size)
+	}
+	return ev
+}
+
+func (ev *evictor) evict(e *Env) {
+	switch ev.mode {
+	case EvictThrash:
+		for i := 0; i < len(ev.buf); i += 64 {
+			ev.buf[i]++
+		}
+	case EvictFlush:
+		for i := 0; i < len(e.mem); i += e.stride {
+			asm.Flush(unsafe.Pointer(&e.mem[i]))
+		}
+		asm.Flush(reflect.ValueOf(e.Map).UnsafePointer())
+		asm.MemoryBarrier()
+	}
+}
@@ -0,0 +1,77 @@
+package litmus
+
+import "path"
+
+// Expect is what may happen to the outcome a test looks for.
+type Expect int
+
+const (
+	// ExpectAllowed outcomes may be observed and are only reported.
+	ExpectAllowed Expect = iota
+	// ExpectForbidden outcomes must never be observed.
+	ExpectForbidden
+)
+
+func (x Expect) String() string {
+	if x == ExpectForbidden {
+		return "forbidden"
+	}
+	return "allowed"
+}
+
+// expectations maps preset, primitive and GOARCH patterns, in path.Match
+// syntax, to whether the looked-for outcome is forbidden. The first match
+// wins, and anything unmatched is allowed.
+var expectations = []struct {
+	preset, prim, goarch string
+	expect               Expect
+}{
+	// Range is absent from the sync.Map memory model docs, see TestRange.
+	{"*", "Map.Range", "*", ExpectAllowed},
+
+	// SB is only forbidden by an operation ordering each thread's store
+	// before its load: the map writes TestStore and TestDeleteWithKeyPresent
+	// demonstrate, and the controls. Anything else is allowed to reorder.
+	{"SB*", "Map.Store", "*", ExpectForbidden},
+	{"SB*", "Map.DeleteWithKeyPresent", "*", ExpectForbidden},
+	{"SB*", "Mutex", "*", ExpectForbidden},
+	{"SB*", "atomic.StoreLoad", "*", ExpectForbidden},
+	{"SB*", "*", "*", ExpectAllowed},
+
+	// Readers disagreeing on the order of independent Stores needs
+	// hardware without multi-copy atomicity, like POWER. The map's Loads
+	// are not documented to be sequentially consistent.
+	{"IRIW", "Map*", "amd64", ExpectForbidden},
+	{"IRIW", "Map*", "arm64", ExpectForbidden},
+	{"IRIW", "Map*", "*", ExpectAllowed},
+
+	// Sequentially consistent atomics, and map and channel operations
+	// whose writes synchronize with the reads observing them, forbid the
+	// other shapes everywhere.
+	{"*", "atomic", "*", ExpectForbidden},
+	{"*", "Map*", "*", ExpectForbidden},
+	{"*", "chan", "*", ExpectForbidden},
+
+	// Plain accesses: x86-TSO never makes a store visible before an
+	// earlier load or store, and the compiler keeps the order of a load
+	// and a later store and of two stores. Two plain loads may be
+	// reordered by the compiler, so the other shapes stay allowed.
+	{"LB", "plain", "amd64", ExpectForbidden},
+	{"2+2W", "plain", "amd64", ExpectForbidden},
+}
+
+// Expected looks up whether the outcome a preset looks for is forbidden for
+// the named primitive on goarch.
+func Expected(preset, prim, goarch string) Expect {
+	for _, e := range expectations {
+		if match(e.preset, preset) && match(e.prim, prim) && match(e.goarch, goarch) {
+			return e.expect
+		}
+	}
+	return ExpectAllowed
+}
+
+func match(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
@@ -0,0 +1,85 @@
+package litmus
+
+import (
+	"fmt"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/asm"
+)
+
+// Fence selects a hardware fence to inject, to measure its effect on
+// results.
+type Fence int
+
+const (
+	FenceNone Fence = iota
+	// FenceFull is asm.MemoryBarrier.
+	FenceFull
+	// FenceStore is asm.StoreBarrier.
+	FenceStore
+	// FenceLoad is asm.LoadBarrier.
+	FenceLoad
+)
+
+var fenceNames = []string{"none", "full", "store", "load"}
+
+func (f Fence) String() string {
+	if int(f) < len(fenceNames) {
+		return fenceNames[f]
+	}
+	return fmt.Sprintf("Fence(%d)", int(f))
+}
+
+// ParseFence parses a fence name as printed by Fence.String.
+func ParseFence(s string) (Fence, error) {
+	for i, name := range fenceNames {
+		if s == name {
+			return Fence(i), nil
+		}
+	}
+	return FenceNone, fmt.Errorf("litmus: unknown fence %q", s)
+}
+
+// Do executes the fence.
+func (f Fence) Do() {
+	switch f {
+	case FenceFull:
+		asm.MemoryBarrier()
+	case FenceStore:
+		asm.StoreBarrier()
+	case FenceLoad:
+		asm.LoadBarrier()
+	}
+}
+
+// Fenced returns p with f executed before and after every Op, Write and
+// Read, named like p with the fence appended, e.g. Map.Load+full.
+func Fenced(p Prim, f Fence) Prim {
+	if f == FenceNone {
+		return p
+	}
+	q := p
+	q.Name = p.Name + "+" + f.String()
+	if p.Op != nil {
+		q.Op = func(e *Env, tid int) {
+			f.Do()
+			p.Op(e, tid)
+			f.Do()
+		}
+	}
+	if p.Write != nil {
+		q.Write = func(e *Env, loc int, v int64) {
+			f.Do()
+			p.Write(e, loc, v)
+			f.Do()
+		}
+	}
+	if p.Read != nil {
+		q.Read = func(e *Env, loc int) int64 {
+			f.Do()
+			v := p.Read(e, loc)
+			f.Do()
+			return v
+		}
+	}
+	return q
+}
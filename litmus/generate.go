@@ -0,0 +1,69 @@
+package litmus
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// Generate returns a random small litmus test: 2 to 4 threads over 2 to 4
+// locations, each thread doing 1 to 3 reads and writes, for at most 8
+// accesses in total. Every location is accessed through either plain or
+// mapped, chosen at random, every write stores a distinct value and the
+// final value of every location is read into a register too. An outcome is
+// forbidden if no sequentially consistent interleaving produces it.
+//
+// The name of the test spells out the program, so a surprising outcome can
+// be reproduced by hand.
+func Generate(rng *rand.Rand, plain, mapped Prim) Test {
+	threads := 2 + rng.IntN(3)
+	locs := 2 + rng.IntN(3)
+
+	at := make([]Prim, locs)
+	for loc := range at {
+		at[loc] = plain
+		if rng.IntN(2) == 0 {
+			at[loc] = mapped
+		}
+	}
+
+	pg := &program{threads: make([][]instr, threads)}
+	budget := 8
+	val := int64(0)
+	var desc []string
+	for tid := range threads {
+		// Leave at least one access for each of the remaining threads.
+		n := 1 + rng.IntN(min(3, budget-(threads-tid-1)))
+		budget -= n
+		var ops []string
+		for range n {
+			loc := rng.IntN(locs)
+			if rng.IntN(2) == 0 {
+				val++
+				pg.threads[tid] = append(pg.threads[tid], instr{op: 'w', loc: loc, val: val})
+				ops = append(ops, fmt.Sprintf("%s=%d", locName(loc), val))
+				continue
+			}
+			reg := len(pg.regNames)
+			pg.regNames = append(pg.regNames, strconv.Itoa(tid)+":r"+strconv.Itoa(len(pg.threads[tid])))
+			pg.finals = append(pg.finals, -1)
+			pg.threads[tid] = append(pg.threads[tid], instr{op: 'r', loc: loc, reg: reg})
+			ops = append(ops, fmt.Sprintf("r%d=%s", reg, locName(loc)))
+		}
+		desc = append(desc, strings.Join(ops, ";"))
+	}
+	var where []string
+	for loc := range locs {
+		pg.regNames = append(pg.regNames, locName(loc))
+		pg.finals = append(pg.finals, loc)
+		where = append(where, locName(loc)+":"+at[loc].Name)
+	}
+
+	sc := pg.sc(locs)
+	name := fmt.Sprintf("gen[%s] %s", strings.Join(where, " "), strings.Join(desc, " | "))
+	return pg.build(name, at, func(o Outcome) bool { return !sc[o] })
+}
+
+// locName names location loc like its map key, x, y, z and w.
+func locName(loc int) string { return locKeys[loc] }
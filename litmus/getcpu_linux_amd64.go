@@ -0,0 +1,4 @@
+package litmus
+
+// sysGetcpu is getcpu(2), which package syscall does not define on amd64.
+const sysGetcpu = 309
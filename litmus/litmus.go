@@ -0,0 +1,324 @@
+// Package litmus runs declarative memory-model litmus tests.
+//
+// A litmus test is a small set of per-thread programs over shared memory
+// locations plus a predicate over the registers the threads write. The runner
+// executes the programs concurrently for many iterations, one fresh goroutine
+// per thread per iteration, and counts how often each outcome is observed.
+package litmus
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/cpuload"
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+const (
+	// MaxLocs is the maximum number of shared locations in a test.
+	MaxLocs = 16
+	// MaxRegs is the maximum number of registers in a test.
+	MaxRegs = 16
+	// MaxThreads is the maximum number of threads whose tid primitives
+	// may depend on.
+	MaxThreads = 16
+)
+
+// Location indices, for readability in thread programs.
+const (
+	X = iota
+	Y
+	Z
+	W
+)
+
+// Env is the memory shared by the threads of one iteration. Locations and
+// registers are freshly allocated and zeroed for every iteration, packed
+// into consecutive words unless Options.Padded is set; Map is shared across
+// iterations unless the test asks for a fresh one.
+type Env struct {
+	// Map is the map under test.
+	Map mapimpl.MapUnderTest
+	// Iter is the current iteration number.
+	Iter int
+
+	mem    []int64
+	regs   int // index of the first register in mem
+	stride int // distance in mem between consecutive variables
+}
+
+// PadBytes is the distance between variables in the padded layout. It is
+// two 64-byte lines so the adjacent-line prefetcher on x86 and the 128-byte
+// lines on some arm64 parts do not pair them up either.
+const PadBytes = 128
+
+func newEnv(m mapimpl.MapUnderTest, iter, locs, regs int, padded bool) *Env {
+	stride := 1
+	if padded {
+		stride = PadBytes / 8
+	}
+	return &Env{Map: m, Iter: iter, mem: make([]int64, (locs+regs)*stride), regs: locs, stride: stride}
+}
+
+// Loc returns the address of shared location i. Accesses through it are
+// plain, non-atomic loads and stores.
+func (e *Env) Loc(i int) *int64 { return &e.mem[i*e.stride] }
+
+// Reg returns the address of register i. Registers are read by the runner
+// after all threads have finished.
+func (e *Env) Reg(i int) *int64 { return &e.mem[(e.regs+i)*e.stride] }
+
+// Locs returns the number of shared locations of the test.
+func (e *Env) Locs() int { return e.regs }
+
+// Outcome is the final register values of one iteration.
+type Outcome struct {
+	r [MaxRegs]int64
+	n int
+}
+
+// Regs returns the outcome with the given register values, for comparing
+// against observed outcomes.
+func Regs(vals ...int64) Outcome {
+	var o Outcome
+	o.n = copy(o.r[:], vals)
+	return o
+}
+
+// R returns the value of register i.
+func (o Outcome) R(i int) int64 { return o.r[i] }
+
+// String formats the outcome as {r0,r1,...}.
+func (o Outcome) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := range o.n {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprint(&b, o.r[i])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Placement is the CPU each thread of an iteration finished on, -1 where it
+// is unknown.
+type Placement struct {
+	cpu [MaxThreads]int16
+	n   int
+}
+
+// CPU returns the CPU of thread i.
+func (p Placement) CPU(i int) int { return int(p.cpu[i]) }
+
+// String formats the placement as (cpu0,cpu1,...).
+func (p Placement) String() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i := range p.n {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprint(&b, p.cpu[i])
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// Test is a declarative litmus test.
+type Test struct {
+	Name string
+	// Locs and Regs are the number of shared locations and registers used.
+	Locs, Regs int
+	// RegNames, if set, name the registers in litmus7 notation, "1:r0" for
+	// a register of thread 1 or "x" for a final value, see Result.Litmus7.
+	RegNames []string
+	// Threads are the per-thread programs, each runs on its own goroutine.
+	Threads []func(e *Env)
+	// Init, if set, runs before the threads of every iteration are started.
+	Init func(e *Env)
+	// Final, if set, runs after all threads of an iteration have finished,
+	// to copy final memory state into registers.
+	Final func(e *Env)
+	// FreshMap gives every iteration a new map instead of sharing one
+	// across iterations.
+	FreshMap bool
+	// Forbidden reports whether an outcome is the one the test looks for.
+	Forbidden func(o Outcome) bool
+}
+
+// Options control a run. A run ends after Iterations iterations, once
+// Budget has elapsed, or once the forbidden outcome has been observed
+// StopAfter times, whichever comes first. At least one of Iterations and
+// Budget must be set.
+type Options struct {
+	Iterations int
+	Budget     time.Duration
+	StopAfter  int
+	// NewMap creates the map under test. Defaults to sync.Map.
+	NewMap func() mapimpl.MapUnderTest
+	// Padded places every location and register PadBytes apart instead of
+	// packing them into one cache line, to control for false sharing.
+	Padded bool
+	// CPUs, if set, pins thread i of every iteration to logical CPU
+	// CPUs[i%len(CPUs)], see Place. Linux only.
+	CPUs []int
+	// Evict and EvictSize configure cache eviction between iterations.
+	Evict     Evict
+	EvictSize int
+	// RecordCPU records the CPU every thread finished on, see
+	// Result.Placements. Linux only, it costs a getcpu system call per
+	// thread and iteration.
+	RecordCPU bool
+	// CPULoad, if set, is the number of goroutines that keep a CPU busy
+	// during the run, CPUDuty percent of every millisecond or all of it if
+	// 0, so the threads of the test compete with them for processors.
+	CPULoad int
+	CPUDuty int
+	// Progress, if set, is the interval at which Logf receives the
+	// progress of the run: its iterations, their rate, how long it has
+	// left and the outcome counts so far.
+	Progress time.Duration
+	Logf     func(format string, args ...any)
+}
+
+// Result is the outcome histogram of a run.
+type Result struct {
+	Test string
+	// Iterations is the number of iterations actually run, in Elapsed.
+	Iterations int
+	Elapsed    time.Duration
+	Counts     map[Outcome]int
+	// Forbidden is the number of iterations with a forbidden outcome.
+	Forbidden int
+	// FirstForbidden is the iteration of the first forbidden outcome, or -1,
+	// and Witness is the outcome observed in it.
+	FirstForbidden int
+	Witness        Outcome
+	// Placements counts, per forbidden outcome, the CPUs the threads of
+	// its iterations ran on. Only set with Options.RecordCPU, so SMT
+	// sibling effects can be told apart from cross-core ones.
+	Placements map[Outcome]map[Placement]int
+
+	forbidden func(Outcome) bool
+	regNames  []string
+}
+
+// Run executes the test.
+func Run(t Test, opts Options) *Result {
+	if len(t.Threads) == 0 || len(t.Threads) > MaxThreads || t.Locs > MaxLocs || t.Regs > MaxRegs {
+		panic(fmt.Sprintf("litmus: invalid test %q", t.Name))
+	}
+	if opts.Iterations <= 0 && opts.Budget <= 0 {
+		panic("litmus: neither Iterations nor Budget set")
+	}
+	load := cpuload.Load{Cores: opts.CPULoad, Duty: opts.CPUDuty}
+	if err := load.Validate(); err != nil {
+		panic("litmus: " + err.Error())
+	}
+	newMap := opts.NewMap
+	if newMap == nil {
+		sm, _ := mapimpl.Lookup("sync.Map")
+		newMap = sm.New
+	}
+	ev := newEvictor(opts.Evict, opts.EvictSize)
+	var pin *pinner
+	if len(opts.CPUs) > 0 {
+		var err error
+		if pin, err = newPinner(); err != nil {
+			panic(err)
+		}
+	}
+
+	res := &Result{Test: t.Name, Counts: make(map[Outcome]int), FirstForbidden: -1, forbidden: t.Forbidden, regNames: t.RegNames}
+	var place *Placement
+	if opts.RecordCPU {
+		res.Placements = make(map[Outcome]map[Placement]int)
+		place = &Placement{n: len(t.Threads)}
+	}
+	m := newMap()
+	defer load.Start()()
+	start := time.Now()
+	defer func() { res.Elapsed = time.Since(start) }()
+	progress := start
+	for i := 0; opts.Iterations <= 0 || i < opts.Iterations; i++ {
+		// Checking the clock every iteration would cost a noticeable
+		// fraction of an iteration.
+		if (opts.Budget > 0 || opts.Progress > 0) && i%256 == 0 {
+			now := time.Now()
+			if opts.Budget > 0 && now.Sub(start) >= opts.Budget {
+				break
+			}
+			if opts.Progress > 0 && opts.Logf != nil && now.Sub(progress) >= opts.Progress {
+				progress = now
+				res.Elapsed = now.Sub(start)
+				opts.Logf("progress: %s", res.progress(opts))
+			}
+		}
+		if t.FreshMap {
+			m = newMap()
+		}
+		e := newEnv(m, i, t.Locs, t.Regs, opts.Padded)
+		if t.Init != nil {
+			t.Init(e)
+		}
+		ev.evict(e)
+
+		var wg sync.WaitGroup
+		wg.Add(len(t.Threads))
+		for tid, thread := range t.Threads {
+			if pin == nil {
+				go func() {
+					thread(e)
+					if place != nil {
+						place.cpu[tid] = int16(currentCPU())
+					}
+					wg.Done()
+				}()
+				continue
+			}
+			go func() {
+				runtime.LockOSThread()
+				pin.pin(opts.CPUs[tid%len(opts.CPUs)])
+				thread(e)
+				if place != nil {
+					place.cpu[tid] = int16(currentCPU())
+				}
+				pin.unpin()
+				runtime.UnlockOSThread()
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+		if t.Final != nil {
+			t.Final(e)
+		}
+
+		o := Outcome{n: t.Regs}
+		for r := range t.Regs {
+			o.r[r] = *e.Reg(r)
+		}
+		res.Counts[o]++
+		res.Iterations++
+		if t.Forbidden(o) {
+			res.Forbidden++
+			if res.FirstForbidden < 0 {
+				res.FirstForbidden, res.Witness = i, o
+			}
+			if place != nil {
+				if res.Placements[o] == nil {
+					res.Placements[o] = make(map[Placement]int)
+				}
+				res.Placements[o][*place]++
+			}
+			if opts.StopAfter > 0 && res.Forbidden >= opts.StopAfter {
+				break
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,346 @@
+package litmus
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+// counter writes the iteration parity into r0, so the outcome histogram is
+// known exactly.
+func counter() Test {
+	return Test{
+		Name: "parity",
+		Regs: 1,
+		Threads: []func(e *Env){
+			func(e *Env) { *e.Reg(0) = int64(e.Iter % 2) },
+		},
+		Forbidden: func(o Outcome) bool { return o == Regs(1) },
+	}
+}
+
+func TestRunCounts(t *testing.T) {
+	res := Run(counter(), Options{Iterations: 10})
+	if res.Iterations != 10 || res.Counts[Regs(0)] != 5 || res.Counts[Regs(1)] != 5 {
+		t.Fatalf("counts = %v after %d iterations", res.Counts, res.Iterations)
+	}
+	if res.Forbidden != 5 || res.FirstForbidden != 1 || res.Witness != Regs(1) {
+		t.Fatalf("forbidden = %d, first = %d (%v)", res.Forbidden, res.FirstForbidden, res.Witness)
+	}
+}
+
+func TestRunStopAfter(t *testing.T) {
+	res := Run(counter(), Options{Iterations: 10, StopAfter: 2})
+	if res.Iterations != 4 || res.Forbidden != 2 {
+		t.Fatalf("stopped after %d iterations with %d forbidden", res.Iterations, res.Forbidden)
+	}
+}
+
+func TestRunBudget(t *testing.T) {
+	res := Run(counter(), Options{Budget: 10 * time.Millisecond})
+	if res.Iterations == 0 || res.Elapsed < 10*time.Millisecond {
+		t.Fatalf("ran %d iterations in %v", res.Iterations, res.Elapsed)
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	var logs []string
+	Run(counter(), Options{Budget: 30 * time.Millisecond, Progress: 5 * time.Millisecond, Logf: func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}})
+	if len(logs) == 0 || !strings.HasPrefix(logs[0], "progress: parity, ") || !strings.Contains(logs[0], "{1}=") {
+		t.Fatalf("progress logged %q", logs)
+	}
+
+	res := Run(counter(), Options{Iterations: 4})
+	res.Elapsed = 2 * time.Second
+	if got, want := res.progress(Options{Iterations: 10, Budget: time.Minute}), "parity, 4 iterations in 2s, 2/s, ETA 3s, forbidden 2 times: {0}=2 {1}=2!"; got != want {
+		t.Errorf("progress = %q, want %q", got, want)
+	}
+	if got := res.progress(Options{Budget: 3 * time.Second}); !strings.Contains(got, "ETA 1s") {
+		t.Errorf("progress within the budget = %q", got)
+	}
+}
+
+func TestRunFreshMap(t *testing.T) {
+	for _, fresh := range []bool{false, true} {
+		maps := 0
+		test := counter()
+		test.FreshMap = fresh
+		test.Init = func(e *Env) {
+			if _, loaded := e.Map.LoadOrStore("k", 1); !loaded {
+				maps++
+			}
+		}
+		newMap := func() mapimpl.MapUnderTest {
+			impl, _ := mapimpl.Lookup("sync.Map")
+			return impl.New()
+		}
+		Run(test, Options{Iterations: 4, NewMap: newMap})
+		if want := map[bool]int{false: 1, true: 4}[fresh]; maps != want {
+			t.Errorf("FreshMap=%v: saw %d maps, want %d", fresh, maps, want)
+		}
+	}
+}
+
+// The control primitives make SB impossible, so any forbidden outcome points
+// at the runner rather than the hardware.
+func TestControls(t *testing.T) {
+	for _, name := range []string{"Mutex", "atomic.StoreLoad"} {
+		p, _ := LookupPrim(name)
+		for _, test := range []Test{SB(p), SBPairs(p, 4)} {
+			if res := Run(test, Options{Iterations: 10000}); res.Forbidden > 0 {
+				t.Errorf("%s: observed %v in iteration %d", test.Name, res.Witness, res.FirstForbidden)
+			}
+		}
+	}
+}
+
+func TestOutcomeString(t *testing.T) {
+	if got := Regs(1, 0).String(); got != "{1,0}" {
+		t.Fatalf("String() = %q", got)
+	}
+}
+
+func TestPrimAccessors(t *testing.T) {
+	impl, _ := mapimpl.Lookup("sync.Map")
+	for _, name := range PrimNames() {
+		p, _ := LookupPrim(name)
+		if !p.Accessor() {
+			continue
+		}
+		e := newEnv(impl.New(), 0, MaxLocs, 0, false)
+		if p.Init != nil {
+			p.Init(e)
+		}
+		if got := p.Read(e, Y); got != 0 {
+			t.Errorf("%s: initial Read = %d", name, got)
+		}
+		p.Write(e, Y, 1)
+		if got := p.Read(e, Y); got != 1 {
+			t.Errorf("%s: Read after Write = %d", name, got)
+		}
+		if got := p.Read(e, X); got != 0 {
+			t.Errorf("%s: Write to y changed x to %d", name, got)
+		}
+	}
+}
+
+func TestRunFinal(t *testing.T) {
+	p, _ := LookupPrim("atomic")
+	res := Run(CoWW(p), Options{Iterations: 100})
+	for o := range res.Counts {
+		if o != Regs(2) && o != Regs(3) {
+			t.Fatalf("CoWW final value %v, want {2} or {3}", o)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	res := Run(counter(), Options{Iterations: 4})
+	res.Elapsed = 1500 * time.Microsecond
+	want := "parity, 4 iterations in 2ms:\n" +
+		"  {0}                   2  50.0000%\n" +
+		"  {1}                   2  50.0000%  forbidden"
+	if got := res.Histogram(); got != want {
+		t.Fatalf("Histogram() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLitmus7(t *testing.T) {
+	res := Run(counter(), Options{Iterations: 4})
+	res.Elapsed = 1500 * time.Microsecond
+	want := "Test parity Allowed\n" +
+		"Histogram (2 states)\n" +
+		"2     :>r0=0;\n" +
+		"2     *>r0=1;\n" +
+		"Ok\n\n" +
+		"Witnesses\n" +
+		"Positive: 2, Negative: 2\n" +
+		"Observation parity Sometimes 2 2\n" +
+		"Time parity 0.00\n"
+	if got := res.Litmus7(); got != want {
+		t.Fatalf("Litmus7() =\n%s\nwant\n%s", got, want)
+	}
+
+	p, _ := LookupPrim("none")
+	res = Run(SB(p), Options{Iterations: 1})
+	if got := res.Litmus7(); !strings.Contains(got, ">0:r0=") || !strings.Contains(got, "; 1:r0=") {
+		t.Fatalf("SB states not named by thread:\n%s", got)
+	}
+}
+
+func TestRecordCPU(t *testing.T) {
+	res := Run(counter(), Options{Iterations: 10, RecordCPU: true})
+	if len(res.Placements) != 1 {
+		t.Fatalf("placements of %d outcomes, want only the forbidden one", len(res.Placements))
+	}
+	n := 0
+	for p, c := range res.Placements[Regs(1)] {
+		if runtime.GOOS == "linux" && p.CPU(0) < 0 {
+			t.Errorf("unknown CPU in %v", p)
+		}
+		n += c
+	}
+	if n != 5 {
+		t.Fatalf("%d placements recorded for 5 forbidden iterations", n)
+	}
+	if h := res.Histogram(); !strings.Contains(h, "\n    on (") {
+		t.Fatalf("placements missing from histogram:\n%s", h)
+	}
+}
+
+func TestPlace(t *testing.T) {
+	if cpus, err := Place(AffinityNone, 2); cpus != nil || err != nil {
+		t.Fatalf("Place(none) = %v, %v", cpus, err)
+	}
+	cpus, err := Place(AffinitySame, 3)
+	if err != nil {
+		t.Skip(err)
+	}
+	if len(cpus) != 3 || cpus[0] != cpus[1] || cpus[1] != cpus[2] {
+		t.Fatalf("Place(same, 3) = %v", cpus)
+	}
+	res := Run(counter(), Options{Iterations: 10, CPUs: cpus})
+	if res.Iterations != 10 {
+		t.Fatalf("pinned run did %d iterations", res.Iterations)
+	}
+}
+
+func TestPaddedLayout(t *testing.T) {
+	e := newEnv(nil, 0, 2, 2, true)
+	addrs := []*int64{e.Loc(X), e.Loc(Y), e.Reg(0), e.Reg(1)}
+	for i := 1; i < len(addrs); i++ {
+		if d := uintptr(unsafe.Pointer(addrs[i])) - uintptr(unsafe.Pointer(addrs[i-1])); d != PadBytes {
+			t.Fatalf("variables %d and %d are %d bytes apart, want %d", i-1, i, d, PadBytes)
+		}
+	}
+	res := Run(counter(), Options{Iterations: 10, Padded: true})
+	if res.Counts[Regs(0)] != 5 {
+		t.Fatalf("padded counts = %v", res.Counts)
+	}
+}
+
+func TestCPULoad(t *testing.T) {
+	res := Run(counter(), Options{Iterations: 10, CPULoad: 2, CPUDuty: 50})
+	if res.Iterations != 10 || res.Counts[Regs(0)] != 5 {
+		t.Fatalf("loaded run: %d iterations, counts %v", res.Iterations, res.Counts)
+	}
+}
+
+func TestMarginal(t *testing.T) {
+	res := &Result{Counts: map[Outcome]int{
+		Regs(0, 1, 1, 0): 3,
+		Regs(0, 1, 0, 0): 2,
+		Regs(1, 1, 0, 0): 1,
+	}}
+	got := res.Marginal(2, 3)
+	if len(got) != 2 || got[Regs(1, 0)] != 3 || got[Regs(0, 0)] != 3 {
+		t.Fatalf("Marginal(2, 3) = %v", got)
+	}
+}
+
+func TestSBPairs(t *testing.T) {
+	p, _ := LookupPrim("none")
+	test := SBPairs(p, 3)
+	if len(test.Threads) != 6 || test.Locs != 6 || test.Regs != 6 || test.Name != "SB3+none" {
+		t.Fatalf("SBPairs(none, 3) = %s with %d threads", test.Name, len(test.Threads))
+	}
+	if !test.Forbidden(Regs(1, 1, 0, 0, 1, 0)) || test.Forbidden(Regs(1, 1, 0, 1, 1, 0)) {
+		t.Fatal("Forbidden does not check every pair")
+	}
+	res := Run(test, Options{Iterations: 100})
+	if res.Iterations != 100 {
+		t.Fatalf("ran %d iterations", res.Iterations)
+	}
+}
+
+func TestSCOracle(t *testing.T) {
+	// SB: everything but {0,0} is sequentially consistent.
+	pg := &program{
+		regNames: []string{"0:r0", "1:r0"},
+		finals:   []int{-1, -1},
+		threads: [][]instr{
+			{{op: 'w', loc: X, val: 1}, {op: 'r', loc: Y, reg: 0}},
+			{{op: 'w', loc: Y, val: 1}, {op: 'r', loc: X, reg: 1}},
+		},
+	}
+	sc := pg.sc(2)
+	if len(sc) != 3 || sc[Regs(0, 0)] || !sc[Regs(0, 1)] || !sc[Regs(1, 0)] || !sc[Regs(1, 1)] {
+		t.Fatalf("SB sequentially consistent outcomes = %v", sc)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	plain, _ := LookupPrim("plain")
+	m, _ := LookupPrim("Map")
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 50 {
+		test := Generate(rng, plain, m)
+		if n := len(test.Threads); n < 2 || n > 4 || test.Locs < 2 || test.Locs > 4 {
+			t.Fatalf("%s: %d threads over %d locations", test.Name, n, test.Locs)
+		}
+		res := Run(test, Options{Iterations: 20})
+		if res.Iterations != 20 {
+			t.Fatalf("%s: ran %d iterations", test.Name, res.Iterations)
+		}
+	}
+}
+
+func TestFenced(t *testing.T) {
+	if _, err := ParseFence("acquire"); err == nil {
+		t.Fatal("ParseFence accepted an unknown fence")
+	}
+	p, _ := LookupPrim("plain")
+	for _, name := range []string{"none", "full", "store", "load"} {
+		f, err := ParseFence(name)
+		if err != nil || f.String() != name {
+			t.Fatalf("ParseFence(%q) = %v, %v", name, f, err)
+		}
+		q := Fenced(p, f)
+		if f != FenceNone && q.Name != "plain+"+name {
+			t.Errorf("Fenced name %q", q.Name)
+		}
+		e := newEnv(nil, 0, 2, 0, false)
+		q.Write(e, Y, 1)
+		if q.Read(e, Y) != 1 || q.Read(e, X) != 0 {
+			t.Errorf("%s: accesses changed by the fence", q.Name)
+		}
+	}
+	// A full fence between the plain accesses forbids SB.
+	none, _ := LookupPrim("none")
+	if res := Run(SB(Fenced(none, FenceFull)), Options{Iterations: 10000}); res.Forbidden > 0 {
+		t.Fatalf("SB with full fences: observed %v in iteration %d", res.Witness, res.FirstForbidden)
+	}
+}
+
+func TestExpected(t *testing.T) {
+	for _, c := range []struct {
+		preset, prim, goarch string
+		want                 Expect
+	}{
+		{"SB", "plain", "amd64", ExpectAllowed},
+		{"SB", "Map.Load", "arm64", ExpectAllowed},
+		{"SB4", "Map.Store", "riscv64", ExpectForbidden},
+		{"SB", "atomic.StoreLoad", "ppc64le", ExpectForbidden},
+		{"MP", "Map", "riscv64", ExpectForbidden},
+		{"MP", "Map.Range", "amd64", ExpectAllowed},
+		{"IRIW", "Map", "arm64", ExpectForbidden},
+		{"IRIW", "Map", "ppc64le", ExpectAllowed},
+		{"IRIW", "atomic", "ppc64le", ExpectForbidden},
+		{"LB", "plain", "amd64", ExpectForbidden},
+		{"LB", "plain", "arm64", ExpectAllowed},
+		{"WRC", "plain", "amd64", ExpectAllowed},
+		{"MP", "unknown", "amd64", ExpectAllowed},
+	} {
+		if got := Expected(c.preset, c.prim, c.goarch); got != c.want {
+			t.Errorf("Expected(%s, %s, %s) = %v, want %v", c.preset, c.prim, c.goarch, got, c.want)
+		}
+	}
+}
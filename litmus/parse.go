@@ -0,0 +1,302 @@
+package litmus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Parse reads a litmus test in the X86 subset of the litmus7 format and
+// builds it around an accessor primitive, so published suites can be run
+// directly:
+//
+//	X86 SB
+//	{ x=0; y=0; }
+//	 P0          | P1          ;
+//	 MOV [x],$1  | MOV [y],$1  ;
+//	 MOV EAX,[y] | MOV EAX,[x] ;
+//	exists (0:EAX=0 /\ 1:EAX=0)
+//
+// Stores of immediates go through p.Write, loads into registers through
+// p.Read, and MFENCE is asm.MemoryBarrier. The exists (or ~exists)
+// condition, a disjunction of conjunctions over thread registers and final
+// location values, is the forbidden outcome. Anything else is an error.
+func Parse(r io.Reader, p Prim) (Test, error) {
+	if !p.Accessor() {
+		return Test{}, fmt.Errorf("litmus: primitive %s has no Write and Read", p.Name)
+	}
+	ps := &parser{locs: map[string]int{}, regs: map[string]int{}}
+	if err := ps.parse(r); err != nil {
+		return Test{}, err
+	}
+	return ps.build(p), nil
+}
+
+// atom is one equality of a condition, reg indexes the outcome.
+type atom struct {
+	reg int
+	val int64
+}
+
+type parser struct {
+	program
+	name     string
+	locs     map[string]int
+	locNames []string
+	regs     map[string]int // "0:EAX" or a final location name
+	cond     [][]atom
+}
+
+func (ps *parser) parse(r io.Reader) error {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if l := strings.TrimSpace(sc.Text()); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("litmus: empty test")
+	}
+
+	head := strings.Fields(lines[0])
+	if len(head) != 2 || (head[0] != "X86" && head[0] != "X86_64") {
+		return fmt.Errorf("litmus: unsupported test header %q, want X86 <name>", lines[0])
+	}
+	ps.name = head[1]
+	lines = lines[1:]
+
+	// Skip the optional quoted cycle description, then read the init block.
+	for len(lines) > 0 && strings.HasPrefix(lines[0], `"`) {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "{") {
+		return fmt.Errorf("litmus: missing init block")
+	}
+	var init strings.Builder
+	for len(lines) > 0 {
+		l := lines[0]
+		lines = lines[1:]
+		init.WriteString(l + " ")
+		if strings.Contains(l, "}") {
+			break
+		}
+	}
+	if err := ps.parseInit(init.String()); err != nil {
+		return err
+	}
+
+	for i, l := range lines {
+		if isCondition(l) {
+			if err := ps.parseProgram(lines[:i]); err != nil {
+				return err
+			}
+			return ps.parseCondition(strings.Join(lines[i:], " "))
+		}
+	}
+	return fmt.Errorf("litmus: missing exists condition")
+}
+
+func isCondition(l string) bool {
+	return strings.HasPrefix(l, "exists") || strings.HasPrefix(l, "~exists") || strings.HasPrefix(l, "forall")
+}
+
+func (ps *parser) loc(name string) (int, error) {
+	if i, ok := ps.locs[name]; ok {
+		return i, nil
+	}
+	if len(ps.locNames) == MaxLocs {
+		return 0, fmt.Errorf("litmus: more than %d locations", MaxLocs)
+	}
+	ps.locs[name] = len(ps.locNames)
+	ps.locNames = append(ps.locNames, name)
+	return ps.locs[name], nil
+}
+
+func (ps *parser) reg(name string) (int, error) {
+	if i, ok := ps.regs[name]; ok {
+		return i, nil
+	}
+	if len(ps.regNames) == MaxRegs {
+		return 0, fmt.Errorf("litmus: more than %d registers", MaxRegs)
+	}
+	ps.regs[name] = len(ps.regNames)
+	ps.regNames = append(ps.regNames, name)
+	ps.finals = append(ps.finals, -1)
+	return ps.regs[name], nil
+}
+
+func (ps *parser) parseInit(s string) error {
+	s = strings.TrimSpace(strings.Trim(strings.TrimSpace(s), "{}"))
+	ps.init = map[int]int64{}
+	for _, e := range strings.Split(s, ";") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(e, "=")
+		if !ok {
+			return fmt.Errorf("litmus: invalid init entry %q", e)
+		}
+		name = strings.TrimSpace(name)
+		v, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			return fmt.Errorf("litmus: invalid init value in %q", e)
+		}
+		if strings.Contains(name, ":") {
+			if v != 0 {
+				return fmt.Errorf("litmus: unsupported register init %q", e)
+			}
+			continue
+		}
+		loc, err := ps.loc(name)
+		if err != nil {
+			return err
+		}
+		if v != 0 {
+			ps.init[loc] = v
+		}
+	}
+	return nil
+}
+
+func (ps *parser) parseProgram(lines []string) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("litmus: missing program")
+	}
+	for i, h := range row(lines[0]) {
+		if h != "P"+strconv.Itoa(i) {
+			return fmt.Errorf("litmus: invalid thread header %q", lines[0])
+		}
+	}
+	n := len(row(lines[0]))
+	if n > MaxThreads {
+		return fmt.Errorf("litmus: more than %d threads", MaxThreads)
+	}
+	ps.threads = make([][]instr, n)
+	for _, l := range lines[1:] {
+		cells := row(l)
+		if len(cells) > n {
+			return fmt.Errorf("litmus: too many columns in %q", l)
+		}
+		for tid, c := range cells {
+			if c == "" {
+				continue
+			}
+			in, err := ps.parseInstr(tid, c)
+			if err != nil {
+				return err
+			}
+			ps.threads[tid] = append(ps.threads[tid], in)
+		}
+	}
+	return nil
+}
+
+// row splits a program line into its trimmed per-thread cells.
+func row(l string) []string {
+	cells := strings.Split(strings.TrimSuffix(l, ";"), "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+func (ps *parser) parseInstr(tid int, s string) (instr, error) {
+	if strings.EqualFold(s, "MFENCE") {
+		return instr{op: 'f'}, nil
+	}
+	mnem, args, _ := strings.Cut(s, " ")
+	dst, src, ok := strings.Cut(args, ",")
+	if !strings.EqualFold(mnem, "MOV") || !ok {
+		return instr{}, fmt.Errorf("litmus: unsupported instruction %q", s)
+	}
+	dst, src = strings.TrimSpace(dst), strings.TrimSpace(src)
+	switch {
+	case isMem(dst) && strings.HasPrefix(src, "$"):
+		loc, err := ps.loc(dst[1 : len(dst)-1])
+		if err != nil {
+			return instr{}, err
+		}
+		v, err := strconv.ParseInt(src[1:], 10, 64)
+		if err != nil {
+			return instr{}, fmt.Errorf("litmus: invalid immediate in %q", s)
+		}
+		return instr{op: 'w', loc: loc, val: v}, nil
+	case isMem(src) && !isMem(dst) && !strings.HasPrefix(dst, "$"):
+		loc, err := ps.loc(src[1 : len(src)-1])
+		if err != nil {
+			return instr{}, err
+		}
+		reg, err := ps.reg(strconv.Itoa(tid) + ":" + dst)
+		if err != nil {
+			return instr{}, err
+		}
+		return instr{op: 'r', loc: loc, reg: reg}, nil
+	}
+	return instr{}, fmt.Errorf("litmus: unsupported instruction %q", s)
+}
+
+func isMem(s string) bool {
+	return len(s) > 2 && s[0] == '[' && s[len(s)-1] == ']'
+}
+
+func (ps *parser) parseCondition(s string) error {
+	if strings.HasPrefix(s, "forall") {
+		return fmt.Errorf("litmus: unsupported forall condition")
+	}
+	body := strings.TrimPrefix(strings.TrimPrefix(s, "~"), "exists")
+	for _, conj := range strings.Split(body, `\/`) {
+		var c []atom
+		for _, a := range strings.Split(conj, `/\`) {
+			a = strings.TrimSpace(strings.Trim(strings.TrimSpace(a), "()"))
+			name, val, ok := strings.Cut(a, "=")
+			if !ok {
+				return fmt.Errorf("litmus: invalid condition term %q", a)
+			}
+			name = strings.TrimSpace(name)
+			v, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+			if err != nil {
+				return fmt.Errorf("litmus: invalid condition value in %q", a)
+			}
+			reg, known := ps.regs[name]
+			switch {
+			case known:
+			case strings.Contains(name, ":"):
+				return fmt.Errorf("litmus: condition reads unknown register %s", name)
+			default:
+				loc, ok := ps.locs[name]
+				if !ok {
+					return fmt.Errorf("litmus: condition reads unknown location %s", name)
+				}
+				if reg, err = ps.reg(name); err != nil {
+					return err
+				}
+				ps.finals[reg] = loc
+			}
+			c = append(c, atom{reg: reg, val: v})
+		}
+		ps.cond = append(ps.cond, c)
+	}
+	return nil
+}
+
+func (ps *parser) build(p Prim) Test {
+	return ps.program.build(ps.name+"+"+p.Name, slices.Repeat([]Prim{p}, len(ps.locNames)), func(o Outcome) bool {
+		for _, c := range ps.cond {
+			match := true
+			for _, a := range c {
+				match = match && o.R(a.reg) == a.val
+			}
+			if match {
+				return true
+			}
+		}
+		return false
+	})
+}
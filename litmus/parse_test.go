@@ -0,0 +1,86 @@
+package litmus
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func parseFile(t *testing.T, name, prim string) Test {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, _ := LookupPrim(prim)
+	test, err := Parse(f, p)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return test
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		file    string
+		regs    []string
+		forbid  Outcome
+		threads int
+	}{
+		{file: "SB.litmus", regs: []string{"0:EAX", "1:EAX"}, forbid: Regs(0, 0), threads: 2},
+		{file: "MP.litmus", regs: []string{"1:EAX", "1:EBX"}, forbid: Regs(1, 0), threads: 2},
+		{file: "2+2W.litmus", regs: []string{"x", "y"}, forbid: Regs(1, 1), threads: 2},
+	} {
+		test := parseFile(t, c.file, "atomic")
+		if !slices.Equal(test.RegNames, c.regs) || len(test.Threads) != c.threads {
+			t.Errorf("%s: registers %v and %d threads", c.file, test.RegNames, len(test.Threads))
+		}
+		if !test.Forbidden(c.forbid) || test.Forbidden(Regs(2, 2)) {
+			t.Errorf("%s: condition does not match exactly %v", c.file, c.forbid)
+		}
+		res := Run(test, Options{Iterations: 100})
+		if res.Iterations != 100 {
+			t.Errorf("%s: ran %d iterations", c.file, res.Iterations)
+		}
+	}
+
+	// 2+2W reads the final values, which are one of the second stores.
+	res := Run(parseFile(t, "2+2W.litmus", "Map"), Options{Iterations: 100})
+	for o := range res.Counts {
+		if o.R(0) == 0 || o.R(1) == 0 {
+			t.Fatalf("2+2W final state %v", o)
+		}
+	}
+}
+
+func TestParseFence(t *testing.T) {
+	test := parseFile(t, "SB+mfences.litmus", "plain")
+	if res := Run(test, Options{Iterations: 10000}); res.Forbidden > 0 {
+		t.Fatalf("%s: observed %v in iteration %d", test.Name, res.Witness, res.FirstForbidden)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	p, _ := LookupPrim("atomic")
+	for _, src := range []string{
+		"",
+		"AArch64 SB\n{}\n P0 ;\n exists (x=0)",
+		"X86 SB\n P0 ;\n exists (x=0)",
+		"X86 SB\n{ x=0; }\n P0 ;\n MOV [x],$1 ;",
+		"X86 SB\n{ x=0; }\n P0 ;\n XCHG [x],EAX ;\nexists (x=1)",
+		"X86 SB\n{ x=0; }\n P0 ;\n MOV [x],$1 ;\nexists (0:EAX=1)",
+		"X86 SB\n{ x=0; }\n P0 ;\n MOV [x],$1 ;\nforall (x=1)",
+		"X86 SB\n{ x=0; }\n P1 ;\n MOV [x],$1 ;\nexists (x=1)",
+	} {
+		if _, err := Parse(strings.NewReader(src), p); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+	none, _ := LookupPrim("none")
+	if _, err := Parse(strings.NewReader("X86 SB\n{}\n P0 ;\nexists (x=0)"), none); err == nil {
+		t.Error("Parse accepted a primitive without accessors")
+	}
+}
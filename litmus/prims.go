@@ -0,0 +1,353 @@
+package litmus
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// primitives returns fresh instances of every registered primitive. Each
+// call allocates new state so concurrent runs never share it.
+func primitives() []Prim {
+	var (
+		atomics [MaxThreads]atomic.Int64
+		mu      sync.Mutex
+	)
+	var chans [MaxLocs]chan int64
+	for i := range chans {
+		chans[i] = make(chan int64, 1)
+	}
+	drain := func(*Env) {
+		for _, c := range chans {
+			select {
+			case <-c:
+			default:
+			}
+		}
+	}
+	return []Prim{
+		{
+			Name: "none",
+			Op:   func(*Env, int) {},
+		},
+		{
+			// Plain accesses to the location.
+			Name:  "plain",
+			Write: func(e *Env, loc int, v int64) { *e.Loc(loc) = v },
+			Read:  func(e *Env, loc int) int64 { return *e.Loc(loc) },
+		},
+		{
+			// Sequentially consistent atomic accesses to the location.
+			Name:  "atomic",
+			Write: func(e *Env, loc int, v int64) { atomic.StoreInt64(e.Loc(loc), v) },
+			Read:  func(e *Env, loc int) int64 { return atomic.LoadInt64(e.Loc(loc)) },
+		},
+		{
+			// Each location is a key of the map, written with Store and
+			// read with Load. Init resets every key to 0, so after the
+			// first iteration the keys stay in the read-only part of a
+			// sync.Map and Store takes the fast path.
+			Name:  "Map",
+			Write: func(e *Env, loc int, v int64) { e.Map.Store(locKeys[loc], int(v)) },
+			Read:  mapLoad,
+			Init: func(e *Env) {
+				for _, k := range locKeys[:e.Locs()] {
+					e.Map.Store(k, 0)
+				}
+			},
+		},
+		{
+			// Sequentially consistent store, XCHG on amd64 and STLR on arm64.
+			Name: "atomic.Store",
+			Op:   func(_ *Env, tid int) { atomics[tid].Store(1) },
+		},
+		{
+			// Sequentially consistent load, a plain MOV on amd64.
+			Name: "atomic.Load",
+			Op:   func(_ *Env, tid int) { atomics[tid].Load() },
+		},
+		{
+			// Lock and Unlock of one mutex shared by all threads. The
+			// critical sections are ordered, so SB through it is forbidden
+			// and serves as a control.
+			Name: "Mutex",
+			Op: func(*Env, int) {
+				mu.Lock()
+				mu.Unlock()
+			},
+		},
+		{
+			// Dekker through sequentially consistent atomics: Store the
+			// thread's own flag, then Load its SB partner's. One of the two
+			// Loads must observe the other thread's Store, so SB through it
+			// is forbidden and serves as a control.
+			Name: "atomic.StoreLoad",
+			Op: func(_ *Env, tid int) {
+				atomics[tid].Store(1)
+				atomics[tid^1].Load()
+			},
+			Init: func(e *Env) {
+				for i := range e.Locs() {
+					atomics[i].Store(0)
+				}
+			},
+		},
+		{
+			// Non-blocking send on the calling thread's empty channel, this
+			// always succeeds and takes the channel lock.
+			Name: "chan.Send",
+			Op: func(_ *Env, tid int) {
+				select {
+				case chans[tid] <- 1:
+				default:
+				}
+			},
+			Init: drain,
+		},
+		{
+			// Non-blocking receive on an empty channel, which returns from
+			// the lock-free fast path.
+			Name: "chan.Recv",
+			Op: func(_ *Env, tid int) {
+				select {
+				case <-chans[tid]:
+				default:
+				}
+			},
+			Init: drain,
+		},
+		{
+			// Each location is a channel with a buffer of one: writes are
+			// non-blocking sends and reads non-blocking receives, missing
+			// values read as 0. A read consumes the value and a second
+			// write to a full channel is dropped, so it only fits shapes
+			// that write and read each location once, like MP.
+			Name: "chan",
+			Write: func(_ *Env, loc int, v int64) {
+				select {
+				case chans[loc] <- v:
+				default:
+				}
+			},
+			Read: func(_ *Env, loc int) int64 {
+				select {
+				case v := <-chans[loc]:
+					return v
+				default:
+					return 0
+				}
+			},
+			Init: drain,
+		},
+		{
+			// LoadAndDelete of a missing key only performs atomic loads.
+			Name: "Map.LoadAndDelete",
+			Op:   func(e *Env, _ int) { _, _ = e.Map.LoadAndDelete("k") },
+		},
+		{
+			// Delete is just an alias for `_, _ = m.LoadAndDelete(key)`.
+			Name: "Map.Delete",
+			Op:   func(e *Env, _ int) { e.Map.Delete("k") },
+		},
+		{
+			// With the key present at least one Delete writes.
+			Name: "Map.DeleteWithKeyPresent",
+			Op:   func(e *Env, _ int) { e.Map.Delete("k") },
+			Init: func(e *Env) { e.Map.Store("k", 888) },
+		},
+		{
+			// Note different keys per thread.
+			Name: "Map.Store",
+			Op:   func(e *Env, tid int) { e.Map.Store(threadKeys[tid], e.Iter) },
+		},
+		{
+			Name: "Map.Load",
+			Op:   func(e *Env, _ int) { _, _ = e.Map.Load("k") },
+		},
+		{
+			Name:     "Map.LoadWithPerIterationMap",
+			Op:       func(e *Env, _ int) { _, _ = e.Map.Load("k") },
+			FreshMap: true,
+		},
+		{
+			// Swap always writes. Reads go through Load.
+			Name:  "Map.Swap",
+			Op:    func(e *Env, tid int) { e.Map.Swap(threadKeys[tid], e.Iter) },
+			Write: func(e *Env, loc int, v int64) { e.Map.Swap(locKeys[loc], int(v)) },
+			Read:  mapLoad,
+			Init:  resetKeys,
+		},
+		{
+			// Successful CompareAndSwap from the 0 written by Init, this is
+			// a write. Reads go through Load.
+			Name:  "Map.CompareAndSwap",
+			Op:    func(e *Env, tid int) { e.Map.CompareAndSwap(threadKeys[tid], 0, 1) },
+			Write: func(e *Env, loc int, v int64) { e.Map.CompareAndSwap(locKeys[loc], 0, int(v)) },
+			Read:  mapLoad,
+			Init:  resetKeys,
+		},
+		{
+			// Failing CompareAndSwap on a present key, a read only. As an
+			// accessor, writes go through Store and a read is a
+			// CompareAndSwap(0, 0) that fails exactly when the flag is set,
+			// so observing the flag happens on the failure path.
+			Name:  "Map.CompareAndSwapFailure",
+			Op:    func(e *Env, _ int) { e.Map.CompareAndSwap("k", 1, 2) },
+			Write: func(e *Env, loc int, v int64) { e.Map.Store(locKeys[loc], int(v)) },
+			Read: func(e *Env, loc int) int64 {
+				if e.Map.CompareAndSwap(locKeys[loc], 0, 0) {
+					return 0
+				}
+				return 1
+			},
+			Init: func(e *Env) {
+				e.Map.Store("k", 0)
+				resetKeys(e)
+			},
+		},
+		{
+			// Successful CompareAndDelete of the 0 written by Init, this is a
+			// write. As an accessor a location is a flag: writing deletes
+			// the key and reading returns 1 once it is gone, so it only fits
+			// shapes that write each location once, like MP.
+			Name:  "Map.CompareAndDelete",
+			Op:    func(e *Env, tid int) { e.Map.CompareAndDelete(threadKeys[tid], 0) },
+			Write: func(e *Env, loc int, _ int64) { e.Map.CompareAndDelete(locKeys[loc], 0) },
+			Read: func(e *Env, loc int) int64 {
+				if _, ok := e.Map.Load(locKeys[loc]); ok {
+					return 0
+				}
+				return 1
+			},
+			Init: resetKeys,
+		},
+		{
+			// Failing CompareAndDelete on a present key, a read only.
+			Name: "Map.CompareAndDeleteFailure",
+			Op:   func(e *Env, _ int) { e.Map.CompareAndDelete("k", 1) },
+			Init: func(e *Env) { e.Map.Store("k", 0) },
+		},
+		{
+			// Range is not among the read or write operations the sync.Map
+			// docs order. As an Op it visits every key, as an accessor
+			// writes go through Store and a read scans the map with Range
+			// for the location's key.
+			Name:  "Map.Range",
+			Op:    func(e *Env, _ int) { e.Map.Range(func(_, _ any) bool { return true }) },
+			Write: func(e *Env, loc int, v int64) { e.Map.Store(locKeys[loc], int(v)) },
+			Read: func(e *Env, loc int) int64 {
+				var n int
+				e.Map.Range(func(k, v any) bool {
+					if k != locKeys[loc] {
+						return true
+					}
+					n, _ = v.(int)
+					return false
+				})
+				return int64(n)
+			},
+			Init: resetKeys,
+		},
+	}
+}
+
+// mapLoad reads a location with Load, missing keys read as 0.
+func mapLoad(e *Env, loc int) int64 {
+	v, _ := e.Map.Load(locKeys[loc])
+	n, _ := v.(int)
+	return int64(n)
+}
+
+// resetKeys stores 0 under the location and thread keys a test can use, so
+// operations on them find the key present with a known value.
+func resetKeys(e *Env) {
+	for i := range e.Locs() {
+		e.Map.Store(locKeys[i], 0)
+		e.Map.Store(threadKeys[i], 0)
+	}
+}
+
+var (
+	// locKeys are the map keys standing for the locations, x, y, z and w
+	// for the first four.
+	locKeys = [MaxLocs]string{"x", "y", "z", "w"}
+	// threadKeys are per-thread map keys.
+	threadKeys [MaxThreads]string
+)
+
+func init() {
+	for i := 4; i < MaxLocs; i++ {
+		locKeys[i] = "l" + strconv.Itoa(i)
+	}
+	for i := range threadKeys {
+		threadKeys[i] = "k" + strconv.Itoa(i+1)
+	}
+}
+
+// PrimNames returns the names of the registered primitives.
+func PrimNames() []string {
+	var names []string
+	for _, p := range primitives() {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// LookupPrim returns a fresh instance of the named primitive.
+func LookupPrim(name string) (Prim, bool) {
+	ps := primitives()
+	i := slices.IndexFunc(ps, func(p Prim) bool { return p.Name == name })
+	if i < 0 {
+		return Prim{}, false
+	}
+	return ps[i], true
+}
+
+// A Preset builds a litmus test of a fixed shape around a primitive.
+// Access presets need an accessor primitive, the others need Op.
+type Preset struct {
+	Name   string
+	Build  func(p Prim) Test
+	Access bool
+}
+
+// Supports reports whether the preset can be built around p.
+func (ps Preset) Supports(p Prim) bool {
+	if ps.Access {
+		return p.Accessor()
+	}
+	return p.Op != nil
+}
+
+var presets = []Preset{
+	{Name: "SB", Build: SB},
+	{Name: "SB2", Build: func(p Prim) Test { return SBPairs(p, 2) }},
+	{Name: "SB4", Build: func(p Prim) Test { return SBPairs(p, 4) }},
+	{Name: "SB8", Build: func(p Prim) Test { return SBPairs(p, 8) }},
+	{Name: "MP", Build: MP, Access: true},
+	{Name: "LB", Build: LB, Access: true},
+	{Name: "IRIW", Build: IRIW, Access: true},
+	{Name: "CoRR", Build: CoRR, Access: true},
+	{Name: "CoWW", Build: CoWW, Access: true},
+	{Name: "2+2W", Build: TwoPlusTwoW, Access: true},
+	{Name: "WRC", Build: WRC, Access: true},
+}
+
+// PresetNames returns the names of the registered presets.
+func PresetNames() []string {
+	var names []string
+	for _, p := range presets {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// LookupPreset returns the named preset.
+func LookupPreset(name string) (Preset, bool) {
+	i := slices.IndexFunc(presets, func(p Preset) bool { return p.Name == name })
+	if i < 0 {
+		return Preset{}, false
+	}
+	return presets[i], true
+}
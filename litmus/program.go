@@ -0,0 +1,114 @@
+package litmus
+
+import "github.com/jmasters-git/porcupine-syncmap/internal/asm"
+
+// A program is a test given as a list of accesses per thread, the form both
+// Parse and Generate produce.
+type program struct {
+	init     map[int]int64 // non-zero initial values by location
+	regNames []string
+	finals   []int // location read into each register by Final, or -1
+	threads  [][]instr
+}
+
+type instr struct {
+	op  byte // 'w' store, 'r' load, 'f' fence
+	loc int
+	val int64
+	reg int
+}
+
+// build turns the program into a test, at[loc] is the accessor primitive of
+// location loc.
+func (pg *program) build(name string, at []Prim, forbidden func(Outcome) bool) Test {
+	t := Test{
+		Name:     name,
+		Locs:     len(at),
+		Regs:     len(pg.regNames),
+		RegNames: pg.regNames,
+		Init: func(e *Env) {
+			done := map[string]bool{}
+			for _, p := range at {
+				if p.Init != nil && !done[p.Name] {
+					p.Init(e)
+					done[p.Name] = true
+				}
+			}
+			for loc, v := range pg.init {
+				at[loc].Write(e, loc, v)
+			}
+		},
+		Final: func(e *Env) {
+			for reg, loc := range pg.finals {
+				if loc >= 0 {
+					*e.Reg(reg) = at[loc].Read(e, loc)
+				}
+			}
+		},
+		Forbidden: forbidden,
+	}
+	for _, p := range at {
+		t.FreshMap = t.FreshMap || p.FreshMap
+	}
+	for tid := range pg.threads {
+		prog := pg.threads[tid]
+		t.Threads = append(t.Threads, func(e *Env) {
+			for _, in := range prog {
+				switch in.op {
+				case 'w':
+					at[in.loc].Write(e, in.loc, in.val)
+				case 'r':
+					*e.Reg(in.reg) = at[in.loc].Read(e, in.loc)
+				case 'f':
+					asm.MemoryBarrier()
+				}
+			}
+		})
+	}
+	return t
+}
+
+// sc returns the outcomes of every sequentially consistent interleaving of
+// the threads over locs locations.
+func (pg *program) sc(locs int) map[Outcome]bool {
+	outs := map[Outcome]bool{}
+	mem := make([]int64, locs)
+	for loc, v := range pg.init {
+		mem[loc] = v
+	}
+	o := Outcome{n: len(pg.regNames)}
+	pcs := make([]int, len(pg.threads))
+	var step func()
+	step = func() {
+		done := true
+		for tid, prog := range pg.threads {
+			if pcs[tid] == len(prog) {
+				continue
+			}
+			done = false
+			in := prog[pcs[tid]]
+			saved, savedReg := mem[in.loc], o.r[in.reg]
+			switch in.op {
+			case 'w':
+				mem[in.loc] = in.val
+			case 'r':
+				o.r[in.reg] = mem[in.loc]
+			}
+			pcs[tid]++
+			step()
+			pcs[tid]--
+			mem[in.loc], o.r[in.reg] = saved, savedReg
+		}
+		if done {
+			final := o
+			for reg, loc := range pg.finals {
+				if loc >= 0 {
+					final.r[reg] = mem[loc]
+				}
+			}
+			outs[final] = true
+		}
+	}
+	step()
+	return outs
+}
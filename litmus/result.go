@@ -0,0 +1,212 @@
+package litmus
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutcomeCount is one entry of a Result's histogram.
+type OutcomeCount struct {
+	Outcome   Outcome
+	Count     int
+	Forbidden bool
+}
+
+// Outcomes returns the histogram sorted by outcome.
+func (r *Result) Outcomes() []OutcomeCount {
+	ocs := make([]OutcomeCount, 0, len(r.Counts))
+	for o, n := range r.Counts {
+		ocs = append(ocs, OutcomeCount{Outcome: o, Count: n, Forbidden: r.forbidden != nil && r.forbidden(o)})
+	}
+	slices.SortFunc(ocs, func(a, b OutcomeCount) int {
+		return slices.Compare(a.Outcome.Values(), b.Outcome.Values())
+	})
+	return ocs
+}
+
+// Values returns the register values of the outcome.
+func (o Outcome) Values() []int64 {
+	return append([]int64(nil), o.r[:o.n]...)
+}
+
+// placements returns the placements recorded for o, most frequent first.
+func (r *Result) placements(o Outcome) []placementCount {
+	var pcs []placementCount
+	for p, n := range r.Placements[o] {
+		pcs = append(pcs, placementCount{p, n})
+	}
+	slices.SortFunc(pcs, func(a, b placementCount) int {
+		if a.count != b.count {
+			return b.count - a.count
+		}
+		return slices.Compare(a.p.cpu[:a.p.n], b.p.cpu[:b.p.n])
+	})
+	return pcs
+}
+
+type placementCount struct {
+	p     Placement
+	count int
+}
+
+type outcomeJSON struct {
+	Regs       []int64         `json:"regs"`
+	Count      int             `json:"count"`
+	Forbidden  bool            `json:"forbidden"`
+	Placements []placementJSON `json:"placements,omitempty"`
+}
+
+type placementJSON struct {
+	CPUs  []int `json:"cpus"`
+	Count int   `json:"count"`
+}
+
+type resultJSON struct {
+	Test           string        `json:"test"`
+	Iterations     int           `json:"iterations"`
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+	Forbidden      int           `json:"forbidden"`
+	FirstForbidden int           `json:"first_forbidden"`
+	Outcomes       []outcomeJSON `json:"outcomes"`
+}
+
+// MarshalJSON encodes the result with a stable schema: outcomes are sorted
+// and listed as register value arrays.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	out := resultJSON{
+		Test:           r.Test,
+		Iterations:     r.Iterations,
+		ElapsedSeconds: r.Elapsed.Seconds(),
+		Forbidden:      r.Forbidden,
+		FirstForbidden: r.FirstForbidden,
+		Outcomes:       []outcomeJSON{},
+	}
+	for _, oc := range r.Outcomes() {
+		ocj := outcomeJSON{Regs: oc.Outcome.Values(), Count: oc.Count, Forbidden: oc.Forbidden}
+		for _, pc := range r.placements(oc.Outcome) {
+			var cpus []int
+			for i := range pc.p.n {
+				cpus = append(cpus, pc.p.CPU(i))
+			}
+			ocj.Placements = append(ocj.Placements, placementJSON{CPUs: cpus, Count: pc.count})
+		}
+		out.Outcomes = append(out.Outcomes, ocj)
+	}
+	return json.Marshal(out)
+}
+
+// Histogram formats the outcome counts and frequencies, one outcome per
+// line, marking forbidden outcomes. Recorded placements of a forbidden
+// outcome follow it, one per line.
+func (r *Result) Histogram() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, %d iterations in %v:", r.Test, r.Iterations, r.Elapsed.Round(time.Millisecond))
+	for _, oc := range r.Outcomes() {
+		mark := ""
+		if oc.Forbidden {
+			mark = "  forbidden"
+		}
+		fmt.Fprintf(&b, "\n  %-12v %10d %8.4f%%%s", oc.Outcome, oc.Count, 100*float64(oc.Count)/float64(r.Iterations), mark)
+		for _, pc := range r.placements(oc.Outcome) {
+			fmt.Fprintf(&b, "\n    on %-8v %10d", pc.p, pc.count)
+		}
+	}
+	return b.String()
+}
+
+// progress describes a run with opts that is still going: its iterations
+// in Elapsed so far and their rate, how long it has left until it runs out
+// of Iterations or Budget, and the counts of the outcomes, forbidden ones
+// marked.
+func (r *Result) progress(opts Options) string {
+	var b strings.Builder
+	rate := float64(r.Iterations) / r.Elapsed.Seconds()
+	fmt.Fprintf(&b, "%s, %d iterations in %v, %.0f/s", r.Test, r.Iterations, r.Elapsed.Round(100*time.Millisecond), rate)
+	left := time.Duration(-1)
+	if opts.Budget > 0 {
+		left = max(opts.Budget-r.Elapsed, 0)
+	}
+	if opts.Iterations > 0 && rate > 0 {
+		its := time.Duration(float64(opts.Iterations-r.Iterations) / rate * float64(time.Second))
+		if left < 0 || its < left {
+			left = its
+		}
+	}
+	if left >= 0 {
+		fmt.Fprintf(&b, ", ETA %v", left.Round(time.Second))
+	}
+	if r.Forbidden > 0 {
+		fmt.Fprintf(&b, ", forbidden %d times", r.Forbidden)
+	}
+	b.WriteString(":")
+	for _, oc := range r.Outcomes() {
+		fmt.Fprintf(&b, " %v=%d", oc.Outcome, oc.Count)
+		if oc.Forbidden {
+			b.WriteString("!")
+		}
+	}
+	return b.String()
+}
+
+// Marginal returns the histogram of the given registers alone, summed over
+// the values of all other registers, e.g. the outcomes of one SBPairs pair.
+func (r *Result) Marginal(regs ...int) map[Outcome]int {
+	m := make(map[Outcome]int)
+	for o, n := range r.Counts {
+		vals := make([]int64, len(regs))
+		for i, reg := range regs {
+			vals[i] = o.R(reg)
+		}
+		m[Regs(vals...)] += n
+	}
+	return m
+}
+
+// Litmus7 formats the result like a litmus7 log, so it can be compared
+// against herd7 predictions or other litmus7 runs with the usual tools.
+// Outcomes are states, the forbidden predicate is the exists condition and
+// registers are named by Test.RegNames, or r0, r1, ... without them. The
+// condition itself is only known as a predicate, so there is no Condition
+// line.
+func (r *Result) Litmus7() string {
+	var b strings.Builder
+	ocs := r.Outcomes()
+	fmt.Fprintf(&b, "Test %s Allowed\nHistogram (%d states)\n", r.Test, len(ocs))
+	for _, oc := range ocs {
+		mark := ':'
+		if oc.Forbidden {
+			mark = '*'
+		}
+		fmt.Fprintf(&b, "%-6d%c>", oc.Count, mark)
+		for i, v := range oc.Outcome.Values() {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(&b, "%s=%d;", r.regName(i), v)
+		}
+		b.WriteByte('\n')
+	}
+	pos, neg := r.Forbidden, r.Iterations-r.Forbidden
+	verdict, obs := "No", "Never"
+	if pos > 0 {
+		verdict, obs = "Ok", "Sometimes"
+		if neg == 0 {
+			obs = "Always"
+		}
+	}
+	fmt.Fprintf(&b, "%s\n\nWitnesses\nPositive: %d, Negative: %d\n", verdict, pos, neg)
+	fmt.Fprintf(&b, "Observation %s %s %d %d\n", r.Test, obs, pos, neg)
+	fmt.Fprintf(&b, "Time %s %.2f\n", r.Test, r.Elapsed.Seconds())
+	return b.String()
+}
+
+func (r *Result) regName(i int) string {
+	if i < len(r.regNames) {
+		return r.regNames[i]
+	}
+	return "r" + strconv.Itoa(i)
+}
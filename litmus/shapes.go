@@ -0,0 +1,292 @@
+package litmus
+
+import "strconv"
+
+// A Prim is the operation under test. Ordering shapes such as SB place Op
+// between their plain memory accesses, tid is the index of the calling
+// thread. Communication shapes such as MP instead route accesses to some
+// locations through Write and Read. A primitive provides either or both.
+type Prim struct {
+	Name  string
+	Op    func(e *Env, tid int)
+	Write func(e *Env, loc int, v int64)
+	Read  func(e *Env, loc int) int64
+	// Init and FreshMap are copied into the tests built from the primitive.
+	Init     func(e *Env)
+	FreshMap bool
+}
+
+// Accessor reports whether the primitive provides Write and Read.
+func (p Prim) Accessor() bool { return p.Write != nil && p.Read != nil }
+
+// SB is the store buffer shape:
+//
+//	Thread 0:   Thread 1:
+//	x = 1       y = 1
+//	prim        prim
+//	r0 = y      r1 = x
+//
+// r0=0 && r1=0 means both stores were reordered after the loads.
+func SB(p Prim) Test {
+	return SBPairs(p, 1)
+}
+
+// SBPairs runs k independent SB pairs at once, all sharing the same map so
+// contention grows with k. Pair j uses locations 2j and 2j+1 and registers
+// 2j and 2j+1; an outcome is forbidden if any pair saw {0,0}. Use
+// Result.Marginal to get per-pair outcomes.
+func SBPairs(p Prim, k int) Test {
+	name := "SB+" + p.Name
+	if k > 1 {
+		name = "SB" + strconv.Itoa(k) + "+" + p.Name
+	}
+	t := Test{
+		Name:     name,
+		Locs:     2 * k,
+		Regs:     2 * k,
+		Init:     p.Init,
+		FreshMap: p.FreshMap,
+		Forbidden: func(o Outcome) bool {
+			for j := range k {
+				if o.R(2*j) == 0 && o.R(2*j+1) == 0 {
+					return true
+				}
+			}
+			return false
+		},
+	}
+	for j := range k {
+		a, b := 2*j, 2*j+1
+		t.RegNames = append(t.RegNames, strconv.Itoa(a)+":r0", strconv.Itoa(b)+":r0")
+		t.Threads = append(t.Threads,
+			func(e *Env) {
+				*e.Loc(a) = 1
+				p.Op(e, a)
+				*e.Reg(a) = *e.Loc(b)
+			},
+			func(e *Env) {
+				*e.Loc(b) = 1
+				p.Op(e, b)
+				*e.Reg(b) = *e.Loc(a)
+			},
+		)
+	}
+	return t
+}
+
+// MP is the message passing shape, the flag y is accessed through the
+// primitive's Write and Read and the data x is plain:
+//
+//	Thread 0:        Thread 1:
+//	x = 1            r0 = read(y)
+//	write(y, 1)      r1 = x
+//
+// r0=1 && r1=0 means the flag was visible before the data it publishes.
+func MP(p Prim) Test {
+	return Test{
+		Name:     "MP+" + p.Name,
+		Locs:     2,
+		Regs:     2,
+		RegNames: []string{"1:r0", "1:r1"},
+		Threads: []func(e *Env){
+			func(e *Env) {
+				*e.Loc(X) = 1
+				p.Write(e, Y, 1)
+			},
+			func(e *Env) {
+				*e.Reg(0) = p.Read(e, Y)
+				*e.Reg(1) = *e.Loc(X)
+			},
+		},
+		Init:      p.Init,
+		FreshMap:  p.FreshMap,
+		Forbidden: func(o Outcome) bool { return o == Regs(1, 0) },
+	}
+}
+
+// LB is the load buffering shape, with every access routed through the
+// primitive:
+//
+//	Thread 0:        Thread 1:
+//	r0 = read(x)     r1 = read(y)
+//	write(y, 1)      write(x, 1)
+//
+// r0=1 && r1=1 means a store became visible before the program-order
+// earlier load of the same thread was satisfied.
+func LB(p Prim) Test {
+	return Test{
+		Name:     "LB+" + p.Name,
+		Locs:     2,
+		Regs:     2,
+		RegNames: []string{"0:r0", "1:r0"},
+		Threads: []func(e *Env){
+			func(e *Env) {
+				*e.Reg(0) = p.Read(e, X)
+				p.Write(e, Y, 1)
+			},
+			func(e *Env) {
+				*e.Reg(1) = p.Read(e, Y)
+				p.Write(e, X, 1)
+			},
+		},
+		Init:      p.Init,
+		FreshMap:  p.FreshMap,
+		Forbidden: func(o Outcome) bool { return o == Regs(1, 1) },
+	}
+}
+
+// IRIW is the independent reads of independent writes shape, with every
+// access routed through the primitive:
+//
+//	Thread 0:     Thread 1:     Thread 2:        Thread 3:
+//	write(x, 1)   write(y, 1)   r0 = read(x)     r2 = read(y)
+//	                            r1 = read(y)     r3 = read(x)
+//
+// r0=1 r1=0 r2=1 r3=0 means the readers saw the two independent writes in
+// opposite orders, which is impossible on multi-copy atomic hardware.
+func IRIW(p Prim) Test {
+	return Test{
+		Name:     "IRIW+" + p.Name,
+		Locs:     2,
+		Regs:     4,
+		RegNames: []string{"2:r0", "2:r1", "3:r0", "3:r1"},
+		Threads: []func(e *Env){
+			func(e *Env) { p.Write(e, X, 1) },
+			func(e *Env) { p.Write(e, Y, 1) },
+			func(e *Env) {
+				*e.Reg(0) = p.Read(e, X)
+				*e.Reg(1) = p.Read(e, Y)
+			},
+			func(e *Env) {
+				*e.Reg(2) = p.Read(e, Y)
+				*e.Reg(3) = p.Read(e, X)
+			},
+		},
+		Init:      p.Init,
+		FreshMap:  p.FreshMap,
+		Forbidden: func(o Outcome) bool { return o == Regs(1, 0, 1, 0) },
+	}
+}
+
+// CoRR checks read-read coherence of a single location:
+//
+//	Thread 0:        Thread 1:
+//	write(x, 1)      r0 = read(x)
+//	write(x, 2)      r1 = read(x)
+//
+// r1 < r0 means the second read observed a store that is earlier in x's
+// coherence order than the one observed by the first read.
+func CoRR(p Prim) Test {
+	return Test{
+		Name:     "CoRR+" + p.Name,
+		Locs:     1,
+		Regs:     2,
+		RegNames: []string{"1:r0", "1:r1"},
+		Threads: []func(e *Env){
+			func(e *Env) {
+				p.Write(e, X, 1)
+				p.Write(e, X, 2)
+			},
+			func(e *Env) {
+				*e.Reg(0) = p.Read(e, X)
+				*e.Reg(1) = p.Read(e, X)
+			},
+		},
+		Init:      p.Init,
+		FreshMap:  p.FreshMap,
+		Forbidden: func(o Outcome) bool { return o.R(1) < o.R(0) },
+	}
+}
+
+// CoWW checks write-write coherence of a single location, r0 is the final
+// value of x:
+//
+//	Thread 0:        Thread 1:
+//	write(x, 1)      write(x, 3)
+//	write(x, 2)
+//
+// r0=1 means the program-order earlier store of thread 0 won.
+func CoWW(p Prim) Test {
+	return Test{
+		Name:     "CoWW+" + p.Name,
+		Locs:     1,
+		Regs:     1,
+		RegNames: []string{"x"},
+		Threads: []func(e *Env){
+			func(e *Env) {
+				p.Write(e, X, 1)
+				p.Write(e, X, 2)
+			},
+			func(e *Env) { p.Write(e, X, 3) },
+		},
+		Final:     func(e *Env) { *e.Reg(0) = p.Read(e, X) },
+		Init:      p.Init,
+		FreshMap:  p.FreshMap,
+		Forbidden: func(o Outcome) bool { return o == Regs(1) },
+	}
+}
+
+// TwoPlusTwoW is the 2+2W shape, r0 and r1 are the final values of x and y:
+//
+//	Thread 0:        Thread 1:
+//	write(x, 1)      write(y, 1)
+//	write(y, 2)      write(x, 2)
+//
+// r0=1 && r1=1 means both first stores were ordered after the other
+// thread's second store.
+func TwoPlusTwoW(p Prim) Test {
+	return Test{
+		Name:     "2+2W+" + p.Name,
+		Locs:     2,
+		Regs:     2,
+		RegNames: []string{"x", "y"},
+		Threads: []func(e *Env){
+			func(e *Env) {
+				p.Write(e, X, 1)
+				p.Write(e, Y, 2)
+			},
+			func(e *Env) {
+				p.Write(e, Y, 1)
+				p.Write(e, X, 2)
+			},
+		},
+		Final: func(e *Env) {
+			*e.Reg(0) = p.Read(e, X)
+			*e.Reg(1) = p.Read(e, Y)
+		},
+		Init:      p.Init,
+		FreshMap:  p.FreshMap,
+		Forbidden: func(o Outcome) bool { return o == Regs(1, 1) },
+	}
+}
+
+// WRC is the write-to-read causality shape:
+//
+//	Thread 0:      Thread 1:        Thread 2:
+//	write(x, 1)    r0 = read(x)     r1 = read(y)
+//	               write(y, 1)      r2 = read(x)
+//
+// r0=1 r1=1 r2=0 means thread 2 saw the effect of thread 1 observing x
+// without seeing x itself.
+func WRC(p Prim) Test {
+	return Test{
+		Name:     "WRC+" + p.Name,
+		Locs:     2,
+		Regs:     3,
+		RegNames: []string{"1:r0", "2:r0", "2:r1"},
+		Threads: []func(e *Env){
+			func(e *Env) { p.Write(e, X, 1) },
+			func(e *Env) {
+				*e.Reg(0) = p.Read(e, X)
+				p.Write(e, Y, 1)
+			},
+			func(e *Env) {
+				*e.Reg(1) = p.Read(e, Y)
+				*e.Reg(2) = p.Read(e, X)
+			},
+		},
+		Init:      p.Init,
+		FreshMap:  p.FreshMap,
+		Forbidden: func(o Outcome) bool { return o == Regs(1, 1, 0) },
+	}
+}
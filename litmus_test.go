@@ -0,0 +1,346 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/cpuload"
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/notify"
+)
+
+// Litmus runs are time-budgeted: each one keeps iterating until the outcome
+// it looks for has been observed often enough, -litmus-budget has elapsed,
+// or -litmus-iters iterations have run.
+var (
+	litmusBudget = flag.Duration("litmus-budget", 2*time.Second, "wall-clock budget per litmus test and implementation, 0 for no limit")
+	litmusIters  = flag.Int("litmus-iters", 0, "maximum iterations per litmus test and implementation, 0 for no limit")
+	// litmusStats runs the whole budget of runLitmus tests and logs the
+	// outcome histogram, instead of failing at the first forbidden outcome.
+	litmusStats = flag.Bool("litmus-stats", false, "run all litmus iterations and log outcome histograms, failing only at the end")
+	// litmusExpect turns relaxedLitmus tests into assertions that the
+	// relaxed outcome is observed, so they keep demonstrating something on
+	// stricter hardware instead of silently passing.
+	litmusExpect = flag.Int("litmus-expect", 0, "fail relaxed litmus tests unless the relaxed outcome is observed at least this many times (0 to disable)")
+	// litmusLayout selects packed or cache-line padded litmus variables.
+	litmusLayout = flag.String("litmus-layout", "packed", "litmus variable layout: packed (one cache line), padded (one line each) or both")
+	// litmusAffinity pins the litmus goroutines, see litmus.Affinity.
+	litmusAffinity = flag.String("litmus-affinity", "none", "pin litmus goroutines: none, distinct (physical cores), siblings (hyperthreads) or same (one CPU); linux only")
+	// litmusCPUs records the CPU of every goroutine, see litmus.Placement.
+	litmusCPUs = flag.Bool("litmus-cpus", false, "record which CPUs the goroutines of forbidden-outcome iterations ran on (linux only)")
+	// litmusSoak runs TestLitmusSoak, each random shape for -litmus-budget.
+	litmusSoak     = flag.Duration("litmus-soak", 0, "run random litmus shapes for this long, 0 to skip")
+	litmusSoakSeed = flag.Uint64("litmus-soak-seed", 0, "seed of the random litmus shapes, 0 for a random seed")
+)
+
+// lookupLitmus builds the named preset around the named primitive.
+func lookupLitmus(t *testing.T, preset, prim string) litmus.Test {
+	ps, ok := litmus.LookupPreset(preset)
+	if !ok {
+		t.Fatalf("unknown litmus preset %q", preset)
+	}
+	p, ok := litmus.LookupPrim(prim)
+	if !ok || !ps.Supports(p) {
+		t.Fatalf("litmus primitive %q does not exist or does not fit preset %s", prim, preset)
+	}
+	return ps.Build(litmus.Fenced(p, fence(t)))
+}
+
+// litmusImpls returns the implementations to run a primitive against, only
+// the Map primitives touch the map so the others run once.
+func litmusImpls(prim string) []mapimpl.Impl {
+	if strings.HasPrefix(prim, "Map") {
+		return mapimpl.All()
+	}
+	return mapimpl.All()[:1]
+}
+
+// litmusOptions returns the options for running test from the flags.
+// stopAfter is the number of observations of the outcome after which the
+// run may end early.
+func litmusOptions(t *testing.T, test litmus.Test, impl mapimpl.Impl, stopAfter int) litmus.Options {
+	if *litmusIters <= 0 && *litmusBudget <= 0 {
+		t.Fatal("one of -litmus-iters and -litmus-budget must be set")
+	}
+	opts := litmus.Options{Iterations: *litmusIters, Budget: *litmusBudget, StopAfter: stopAfter, NewMap: impl.New, RecordCPU: *litmusCPUs}
+	evictOptions(t, &opts)
+	if err := (cpuload.Load{Cores: *cpuLoadFlag, Duty: *cpuDutyFlag}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	opts.CPULoad, opts.CPUDuty = *cpuLoadFlag, *cpuDutyFlag
+	opts.Progress, opts.Logf = *progressFlag, t.Logf
+
+	aff, err := litmus.ParseAffinity(*litmusAffinity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.CPUs, err = litmus.Place(aff, len(test.Threads)); err != nil {
+		t.Skip(err)
+	}
+	if opts.CPUs != nil {
+		t.Logf("affinity=%s cpus=%v", aff, opts.CPUs)
+	}
+	return opts
+}
+
+// litmusRuns runs test once per implementation and layout, each in its own
+// subtest, and calls check with the result. With -litmus-layout=both the
+// subtests are named impl/packed and impl/padded and the forbidden outcome
+// frequencies of the two layouts are compared afterwards.
+func litmusRuns(t *testing.T, test litmus.Test, prim string, stopAfter int, check func(t *testing.T, res *litmus.Result)) {
+	var layouts []bool
+	switch *litmusLayout {
+	case "packed":
+		layouts = []bool{false}
+	case "padded":
+		layouts = []bool{true}
+	case "both":
+		layouts = []bool{false, true}
+	default:
+		t.Fatalf("unknown -litmus-layout %q", *litmusLayout)
+	}
+
+	for _, impl := range litmusImpls(prim) {
+		var results []*litmus.Result
+		for _, padded := range layouts {
+			name := impl.Name
+			if len(layouts) > 1 {
+				name += "/" + layoutName(padded)
+			}
+			t.Run(name, func(t *testing.T) {
+				opts := litmusOptions(t, test, impl, stopAfter)
+				opts.Padded = padded
+				res := litmus.Run(test, opts)
+				defer addLitmusReport(t, res)
+				results = append(results, res)
+				check(t, res)
+			})
+		}
+		if len(results) == 2 {
+			t.Logf("%s with %s: forbidden outcome in %.4f%% of packed and %.4f%% of padded iterations",
+				test.Name, impl.Name, frequency(results[0]), frequency(results[1]))
+		}
+	}
+}
+
+func layoutName(padded bool) string {
+	if padded {
+		return "padded"
+	}
+	return "packed"
+}
+
+func frequency(res *litmus.Result) float64 {
+	if res.Iterations == 0 {
+		return 0
+	}
+	return 100 * float64(res.Forbidden) / float64(res.Iterations)
+}
+
+// runLitmus runs the named preset around the named primitive once per
+// registered map implementation and fails as soon as the forbidden outcome
+// is observed, or at the end of the run with -litmus-stats.
+func runLitmus(t *testing.T, preset, prim string) {
+	stopAfter := 1
+	if *litmusStats {
+		stopAfter = 0
+	}
+	litmusRuns(t, lookupLitmus(t, preset, prim), prim, stopAfter, func(t *testing.T, res *litmus.Result) {
+		if *litmusStats {
+			t.Log(res.Histogram())
+		}
+		if res.Forbidden > 0 {
+			notifyEvent(t, forbiddenEvent(res))
+			t.Fatalf("%s: observed %v in iteration %d of %d (%d occurrences)", res.Test, res.Witness, res.FirstForbidden, res.Iterations, res.Forbidden)
+		}
+		t.Logf("%s: did not observe the forbidden outcome in %d iterations (%v)", res.Test, res.Iterations, res.Elapsed.Round(time.Millisecond))
+	})
+}
+
+// forbiddenEvent returns the event of the forbidden outcome of res.
+func forbiddenEvent(res *litmus.Result) notify.Event {
+	return notify.Event{
+		Text: fmt.Sprintf("%s observed the forbidden outcome %v %d times in %d iterations, first in iteration %d",
+			res.Test, res.Witness, res.Forbidden, res.Iterations, res.FirstForbidden),
+		Kind:  notify.Forbidden,
+		Round: res.FirstForbidden,
+	}
+}
+
+// relaxedLitmus is for outcomes the Go memory model allows and the test
+// exists to demonstrate. It behaves like runLitmus by default, with
+// -litmus-expect=N it runs until the outcome has been seen N times and fails
+// if the budget runs out first.
+func relaxedLitmus(t *testing.T, preset, prim string) {
+	if *litmusExpect <= 0 {
+		runLitmus(t, preset, prim)
+		return
+	}
+	litmusRuns(t, lookupLitmus(t, preset, prim), prim, *litmusExpect, func(t *testing.T, res *litmus.Result) {
+		t.Log(res.Histogram())
+		if res.Forbidden < *litmusExpect {
+			t.Fatalf("%s on %s: observed the relaxed outcome %d times in %d iterations, expected at least %d",
+				res.Test, runtime.GOARCH, res.Forbidden, res.Iterations, *litmusExpect)
+		}
+	})
+}
+
+// reportLitmus is runLitmus for outcomes that are interesting but allowed
+// by the Go memory model: it runs the whole budget and only logs how often
+// the outcome was observed.
+func reportLitmus(t *testing.T, preset, prim string) {
+	litmusRuns(t, lookupLitmus(t, preset, prim), prim, 0, func(t *testing.T, res *litmus.Result) {
+		t.Log(res.Histogram())
+		if res.Forbidden > 0 {
+			t.Logf("%s on %s: observed the outcome %d times in %d iterations (%.4f%%), first in iteration %d",
+				res.Test, runtime.GOARCH, res.Forbidden, res.Iterations, frequency(res), res.FirstForbidden)
+			return
+		}
+		t.Logf("%s on %s: did not observe the outcome in %d iterations", res.Test, runtime.GOARCH, res.Iterations)
+	})
+}
+
+// checkLitmus runs the named preset around the named primitive like
+// runLitmus if litmus.Expected forbids its outcome on this GOARCH, and like
+// reportLitmus otherwise.
+func checkLitmus(t *testing.T, preset, prim string) {
+	if litmus.Expected(preset, prim, runtime.GOARCH) == litmus.ExpectForbidden {
+		runLitmus(t, preset, prim)
+		return
+	}
+	reportLitmus(t, preset, prim)
+}
+
+// Message passing through the map: data written before a Store of the flag
+// key must be visible to a Load that observes the flag. This checks the
+// acquire side of Load, complementing the store buffer tests.
+func TestMessagePassing(t *testing.T) {
+	checkLitmus(t, "MP", "Map")
+}
+
+// Load buffering: r1=1 && r2=1 needs a store to become visible before an
+// earlier load, a different reordering axis than store buffering. Run with
+// sync.Map Load/Store and with plain variables for comparison; plain
+// variables are only reported where the hardware allows it.
+func TestLoadBuffering(t *testing.T) {
+	for _, prim := range []string{"Map", "plain"} {
+		t.Run(prim, func(t *testing.T) { checkLitmus(t, "LB", prim) })
+	}
+}
+
+// Independent reads of independent writes: two readers observing the two
+// Stores in opposite orders. Allowed on non-multi-copy-atomic hardware such
+// as POWER, so this only reports the frequency there.
+func TestIRIW(t *testing.T) {
+	checkLitmus(t, "IRIW", "Map")
+}
+
+// Same-location coherence through the map: reads of one key never observe
+// its Stores out of order, and the last Store of a goroutine is never lost
+// to its own earlier Store. These are baseline guarantees and must hold.
+func TestCoherence(t *testing.T) {
+	for _, preset := range []string{"CoRR", "CoWW"} {
+		t.Run(preset, func(t *testing.T) { checkLitmus(t, preset, "Map") })
+	}
+}
+
+// 2+2W: two goroutines each Store two keys in opposite orders, the first
+// Stores of both cannot win. Plain variables are only reported where the
+// hardware allows it.
+func TestTwoPlusTwoW(t *testing.T) {
+	for _, prim := range []string{"Map", "plain"} {
+		t.Run(prim, func(t *testing.T) { checkLitmus(t, "2+2W", prim) })
+	}
+}
+
+// Write-to-read causality: a Load that observes a Store made after another
+// Load observed x must also observe x. Plain variables are only reported.
+func TestWRC(t *testing.T) {
+	for _, prim := range []string{"Map", "plain"} {
+		t.Run(prim, func(t *testing.T) { checkLitmus(t, "WRC", prim) })
+	}
+}
+
+// The read-modify-write operations as the synchronizing operation. A
+// successful Swap, CompareAndSwap or CompareAndDelete is a write in the
+// sync.Map docs and its Load side a read, so message passing through them
+// must hold, including when the flag is observed by a failing
+// CompareAndSwap. The store buffer variants are characterization only.
+func TestReadModifyWrite(t *testing.T) {
+	for _, prim := range []string{"Map.Swap", "Map.CompareAndSwap", "Map.CompareAndSwapFailure", "Map.CompareAndDelete"} {
+		t.Run("MP/"+prim, func(t *testing.T) { checkLitmus(t, "MP", prim) })
+	}
+	for _, prim := range []string{"Map.Swap", "Map.CompareAndSwap", "Map.CompareAndSwapFailure", "Map.CompareAndDelete", "Map.CompareAndDeleteFailure"} {
+		t.Run("SB/"+prim, func(t *testing.T) { checkLitmus(t, "SB", prim) })
+	}
+}
+
+// Range is absent from the operations the sync.Map docs order, so whether it
+// takes part in happens-before edges is only established empirically here:
+// SB with Range between the plain accesses, and MP with the flag Stored and
+// observed by scanning the map with Range. Both are only reported.
+func TestRange(t *testing.T) {
+	for _, preset := range []string{"SB", "MP"} {
+		t.Run(preset, func(t *testing.T) { checkLitmus(t, preset, "Map.Range") })
+	}
+}
+
+// Non-blocking channel operations as a control group for the Map results.
+// A send is synchronized before the receive that completes it, so MP through
+// channels must hold; the SB variants are only reported.
+func TestChannels(t *testing.T) {
+	t.Run("MP", func(t *testing.T) { checkLitmus(t, "MP", "chan") })
+	for _, prim := range []string{"chan.Send", "chan.Recv"} {
+		t.Run("SB/"+prim, func(t *testing.T) { checkLitmus(t, "SB", prim) })
+	}
+}
+
+// Controls from the same SB shape where reordering is impossible: a Lock and
+// Unlock of a shared sync.Mutex, and a sequentially consistent atomic Store
+// of the thread's flag followed by a Load of the other's. Observing {0,0}
+// here means the harness itself is broken.
+func TestControls(t *testing.T) {
+	for _, prim := range []string{"Mutex", "atomic.StoreLoad"} {
+		t.Run(prim, func(t *testing.T) { checkLitmus(t, "SB", prim) })
+	}
+}
+
+// Random shapes of plain and sync.Map accesses, run for -litmus-soak. Any
+// outcome no sequentially consistent interleaving produces is logged with
+// the program and the seed; the plain accesses race, so these are
+// surprises to look into rather than failures.
+func TestLitmusSoak(t *testing.T) {
+	if *litmusSoak <= 0 {
+		t.Skip("enable with -litmus-soak=<duration>")
+	}
+	seed := *litmusSoakSeed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	t.Logf("seed %d", seed)
+	rng := rand.New(rand.NewPCG(seed, 0))
+	plain, _ := litmus.LookupPrim("plain")
+	impl := mapimpl.All()[0]
+
+	shapes, surprising := 0, 0
+	for start := time.Now(); time.Since(start) < *litmusSoak; shapes++ {
+		mapped, _ := litmus.LookupPrim("Map")
+		test := litmus.Generate(rng, plain, mapped)
+		res := litmus.Run(test, litmusOptions(t, test, impl, 0))
+		if res.Forbidden > 0 {
+			surprising++
+			t.Logf("shape %d is not sequentially consistent:\n%s", shapes, res.Histogram())
+			ev := forbiddenEvent(res)
+			ev.Text = fmt.Sprintf("shape %d of seed %d: %s", shapes, seed, ev.Text)
+			ev.Seed = seed
+			notifyEvent(t, ev)
+		}
+	}
+	t.Logf("%d of %d random shapes showed outcomes outside sequential consistency", surprising, shapes)
+}
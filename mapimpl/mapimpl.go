@@ -0,0 +1,111 @@
+// Package mapimpl is the registry of concurrent map implementations that the
+// linearizability and litmus workloads are run against.
+//
+// Besides sync.Map and its typed wrapper, it has two baselines built on a
+// plain map and a mutex, one map and a sharded one, that are linearizable by
+// construction, and xsync.MapOf, a concurrent map of its own design. Other
+// maps are run through workload.Builder.Map from a module of their own.
+package mapimpl
+
+import (
+	"sync"
+
+	"github.com/jmasters-git/porcupine-syncmap/typedmap"
+)
+
+// MapUnderTest is the sync.Map method set exercised by the workloads.
+// *sync.Map satisfies it directly.
+type MapUnderTest interface {
+	Load(key any) (value any, ok bool)
+	Store(key, value any)
+	LoadOrStore(key, value any) (actual any, loaded bool)
+	LoadAndDelete(key any) (value any, loaded bool)
+	Delete(key any)
+	Swap(key, value any) (previous any, loaded bool)
+	CompareAndSwap(key, old, new any) (swapped bool)
+	CompareAndDelete(key, old any) (deleted bool)
+	Range(f func(key, value any) bool)
+}
+
+// Impl is a registered implementation.
+type Impl struct {
+	Name string
+	New  func() MapUnderTest
+}
+
+var impls = []Impl{
+	{Name: "sync.Map", New: func() MapUnderTest { return new(sync.Map) }},
+	{Name: "SyncMapOf", New: func() MapUnderTest { return new(typed[string, int]) }},
+	{Name: "MutexMap", New: func() MapUnderTest { return new(mutexMap) }},
+	{Name: "ShardedMap", New: func() MapUnderTest { return newSharded(16) }},
+	{Name: "xsync.MapOf", New: func() MapUnderTest { return newXsync() }},
+}
+
+// All returns every registered implementation, sync.Map first.
+func All() []Impl {
+	return append([]Impl(nil), impls...)
+}
+
+// Lookup returns the implementation registered under name.
+func Lookup(name string) (Impl, bool) {
+	for _, impl := range impls {
+		if impl.Name == name {
+			return impl, true
+		}
+	}
+	return Impl{}, false
+}
+
+// typed adapts a typedmap.SyncMapOf to MapUnderTest. Keys and values of the
+// wrong type panic, exactly as the type assertions in the caller would.
+type typed[K comparable, V any] struct {
+	m typedmap.SyncMapOf[K, V]
+}
+
+func (t *typed[K, V]) Load(key any) (any, bool) {
+	v, ok := t.m.Load(key.(K))
+	if !ok {
+		return nil, false
+	}
+	return v, true
+}
+
+func (t *typed[K, V]) Store(key, value any) {
+	t.m.Store(key.(K), value.(V))
+}
+
+func (t *typed[K, V]) LoadOrStore(key, value any) (any, bool) {
+	return t.m.LoadOrStore(key.(K), value.(V))
+}
+
+func (t *typed[K, V]) LoadAndDelete(key any) (any, bool) {
+	v, loaded := t.m.LoadAndDelete(key.(K))
+	if !loaded {
+		return nil, false
+	}
+	return v, true
+}
+
+func (t *typed[K, V]) Delete(key any) {
+	t.m.Delete(key.(K))
+}
+
+func (t *typed[K, V]) Swap(key, value any) (any, bool) {
+	p, loaded := t.m.Swap(key.(K), value.(V))
+	if !loaded {
+		return nil, false
+	}
+	return p, true
+}
+
+func (t *typed[K, V]) CompareAndSwap(key, old, new any) bool {
+	return t.m.CompareAndSwap(key.(K), old.(V), new.(V))
+}
+
+func (t *typed[K, V]) CompareAndDelete(key, old any) bool {
+	return t.m.CompareAndDelete(key.(K), old.(V))
+}
+
+func (t *typed[K, V]) Range(f func(key, value any) bool) {
+	t.m.Range(func(k K, v V) bool { return f(k, v) })
+}
@@ -0,0 +1,158 @@
+package mapimpl
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// mutexMap is a map guarded by a single mutex, the simplest linearizable
+// map to compare the others with.
+type mutexMap struct {
+	mu sync.Mutex
+	m  map[any]any
+}
+
+func (m *mutexMap) Load(key any) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.m[key]
+	return v, ok
+}
+
+func (m *mutexMap) Store(key, value any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(key, value)
+}
+
+// store stores value under key, m.mu must be held.
+func (m *mutexMap) store(key, value any) {
+	if m.m == nil {
+		m.m = make(map[any]any)
+	}
+	m.m[key] = value
+}
+
+func (m *mutexMap) LoadOrStore(key, value any) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.m[key]; ok {
+		return v, true
+	}
+	m.store(key, value)
+	return value, false
+}
+
+func (m *mutexMap) LoadAndDelete(key any) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.m[key]
+	delete(m.m, key)
+	return v, ok
+}
+
+func (m *mutexMap) Delete(key any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.m, key)
+}
+
+func (m *mutexMap) Swap(key, value any) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.m[key]
+	m.store(key, value)
+	return p, ok
+}
+
+func (m *mutexMap) CompareAndSwap(key, old, new any) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.m[key]; !ok || v != old {
+		return false
+	}
+	m.m[key] = new
+	return true
+}
+
+func (m *mutexMap) CompareAndDelete(key, old any) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.m[key]; !ok || v != old {
+		return false
+	}
+	delete(m.m, key)
+	return true
+}
+
+// Range calls f on a snapshot of the map, so f may call the map's methods
+// like it may with a sync.Map.
+func (m *mutexMap) Range(f func(key, value any) bool) {
+	m.mu.Lock()
+	keys := make([]any, 0, len(m.m))
+	vals := make([]any, 0, len(m.m))
+	for k, v := range m.m {
+		keys = append(keys, k)
+		vals = append(vals, v)
+	}
+	m.mu.Unlock()
+	for i, k := range keys {
+		if !f(k, vals[i]) {
+			return
+		}
+	}
+}
+
+// sharded spreads its keys over mutexMaps by their hash, so operations on
+// keys of different shards do not contend. Range visits the shards one
+// after the other and, like sync.Map's, is not a snapshot of the whole map.
+type sharded struct {
+	seed   maphash.Seed
+	shards []mutexMap
+}
+
+func newSharded(n int) *sharded {
+	return &sharded{seed: maphash.MakeSeed(), shards: make([]mutexMap, n)}
+}
+
+func (s *sharded) shard(key any) *mutexMap {
+	return &s.shards[maphash.Comparable(s.seed, key)%uint64(len(s.shards))]
+}
+
+func (s *sharded) Load(key any) (any, bool) { return s.shard(key).Load(key) }
+
+func (s *sharded) Store(key, value any) { s.shard(key).Store(key, value) }
+
+func (s *sharded) LoadOrStore(key, value any) (any, bool) {
+	return s.shard(key).LoadOrStore(key, value)
+}
+
+func (s *sharded) LoadAndDelete(key any) (any, bool) { return s.shard(key).LoadAndDelete(key) }
+
+func (s *sharded) Delete(key any) { s.shard(key).Delete(key) }
+
+func (s *sharded) Swap(key, value any) (any, bool) { return s.shard(key).Swap(key, value) }
+
+func (s *sharded) CompareAndSwap(key, old, new any) bool {
+	return s.shard(key).CompareAndSwap(key, old, new)
+}
+
+func (s *sharded) CompareAndDelete(key, old any) bool {
+	return s.shard(key).CompareAndDelete(key, old)
+}
+
+func (s *sharded) Range(f func(key, value any) bool) {
+	for i := range s.shards {
+		stopped := false
+		s.shards[i].Range(func(k, v any) bool {
+			if !f(k, v) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if stopped {
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package mapimpl
+
+import "github.com/puzpuzpuz/xsync/v3"
+
+// xsyncMap adapts an xsync.MapOf, a concurrent hash table of its own rather
+// than a wrapper around sync.Map, to MapUnderTest. Keys are strings, as in
+// every workload, values anything. It has no CompareAndSwap or
+// CompareAndDelete, which run as a Compute under the lock of the key's
+// bucket instead.
+type xsyncMap struct {
+	m *xsync.MapOf[string, any]
+}
+
+func newXsync() *xsyncMap {
+	return &xsyncMap{m: xsync.NewMapOf[string, any]()}
+}
+
+func (x *xsyncMap) Load(key any) (any, bool) {
+	return x.m.Load(key.(string))
+}
+
+func (x *xsyncMap) Store(key, value any) {
+	x.m.Store(key.(string), value)
+}
+
+func (x *xsyncMap) LoadOrStore(key, value any) (any, bool) {
+	return x.m.LoadOrStore(key.(string), value)
+}
+
+func (x *xsyncMap) LoadAndDelete(key any) (any, bool) {
+	return x.m.LoadAndDelete(key.(string))
+}
+
+func (x *xsyncMap) Delete(key any) {
+	x.m.Delete(key.(string))
+}
+
+func (x *xsyncMap) Swap(key, value any) (any, bool) {
+	p, loaded := x.m.LoadAndStore(key.(string), value)
+	if !loaded {
+		return nil, false
+	}
+	return p, true
+}
+
+func (x *xsyncMap) CompareAndSwap(key, old, new any) bool {
+	var swapped bool
+	x.m.Compute(key.(string), func(v any, loaded bool) (any, bool) {
+		if loaded && v == old {
+			swapped = true
+			return new, false
+		}
+		// Deleting an absent key stores nothing.
+		return v, !loaded
+	})
+	return swapped
+}
+
+func (x *xsyncMap) CompareAndDelete(key, old any) bool {
+	var deleted bool
+	x.m.Compute(key.(string), func(v any, loaded bool) (any, bool) {
+		deleted = loaded && v == old
+		return v, deleted || !loaded
+	})
+	return deleted
+}
+
+func (x *xsyncMap) Range(f func(key, value any) bool) {
+	x.m.Range(func(k string, v any) bool { return f(k, v) })
+}
@@ -0,0 +1,195 @@
+// Package metrics exposes the progress of a run in the Prometheus text
+// format, so that a soak can be scraped, graphed and alerted on by the
+// monitoring a team already has: the rounds checked by result, the
+// violations, the time the checker takes, the operations run by kind and
+// the outcomes of the litmus runs. A Registry holds counters and
+// histograms with labels and serves them over HTTP; Metrics is the
+// registry of a run of this module.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Registry is a set of metric families. Its methods are safe for
+// concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// A family is a metric with its series, by the values of its labels.
+type family struct {
+	name, help, kind string
+	labels           []string
+	// buckets are the upper bounds of a histogram, without +Inf.
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	values []string
+	// value is the value of a counter, and the sum of a histogram, whose
+	// counts are its cumulative bucket counts with +Inf last.
+	value  float64
+	counts []uint64
+}
+
+// A Counter is a family of counters.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// A Histogram is a family of histograms.
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// Counter adds the counter name, described by help, with the labels, and
+// returns it.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r, r.add(name, help, "counter", labels, nil)}
+}
+
+// Histogram adds the histogram name, described by help, with the upper
+// bounds of its buckets in increasing order and the labels, and returns
+// it.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !slices.IsSorted(buckets) {
+		panic("metrics: buckets of " + name + " out of order")
+	}
+	return &Histogram{r, r.add(name, help, "histogram", labels, buckets)}
+}
+
+func (r *Registry) add(name, help, kind string, labels []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.ContainsFunc(r.families, func(f *family) bool { return f.name == name }) {
+		panic("metrics: " + name + " added twice")
+	}
+	f := &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	r.families = append(r.families, f)
+	return f
+}
+
+// get returns the series of f with values, r.mu must be held.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, not %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\x00")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: slices.Clone(values)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Add adds v, which must not be negative, to the counter with the label
+// values.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: " + c.f.name + " decreased")
+	}
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.f.get(values).value += v
+}
+
+// Observe adds v to the histogram with the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	s := h.f.get(values)
+	s.value += v
+	for i, le := range h.f.buckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+	s.counts[len(h.f.buckets)]++
+}
+
+// WriteText writes the metrics to w in the Prometheus text format, the
+// families in the order they were added and their series by label values.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	for _, f := range r.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			s := f.series[k]
+			if f.kind == "counter" {
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, labels(f.labels, s.values, "", ""), number(s.value))
+				continue
+			}
+			for i, c := range s.counts {
+				le := "+Inf"
+				if i < len(f.buckets) {
+					le = number(f.buckets[i])
+				}
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, labels(f.labels, s.values, "le", le), c)
+			}
+			fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, labels(f.labels, s.values, "", ""), number(s.value))
+			fmt.Fprintf(&b, "%s_count%s %d\n", f.name, labels(f.labels, s.values, "", ""), s.counts[len(f.buckets)])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics in the text format, for a scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// labels formats the names and values of labels, with the extra one if
+// name is set.
+func labels(names, values []string, name, value string) string {
+	if name != "" {
+		names, values = append(slices.Clip(names), name), append(slices.Clip(values), value)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = n + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func number(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		// Counts in full rather than with an exponent.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
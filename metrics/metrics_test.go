@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+func TestWriteText(t *testing.T) {
+	var r Registry
+	c := r.Counter("things_total", "Things.\nCounted.", "kind")
+	h := r.Histogram("wait_seconds", "Waits.", []float64{0.1, 1})
+	c.Add(2e6, `a "b"`)
+	c.Add(1, "a")
+	c.Add(1, "a")
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP things_total Things.\nCounted.
+# TYPE things_total counter
+things_total{kind="a"} 2
+things_total{kind="a \"b\""} 2000000
+# HELP wait_seconds Waits.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{le="0.1"} 1
+wait_seconds_bucket{le="1"} 2
+wait_seconds_bucket{le="+Inf"} 3
+wait_seconds_sum 3.55
+wait_seconds_count 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteText:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := New()
+	m.AddChecked("TestSyncMap", "sync.Map", workload.Checked{Result: porcupine.Ok, Checking: workload.Duration(3 * time.Millisecond)})
+	m.AddChecked("TestSyncMap", "sync.Map", workload.Checked{Result: porcupine.Illegal, Checking: workload.Duration(time.Second)})
+	m.AddChecked("TestSyncMap", "sync.Map", workload.Checked{Result: porcupine.Unknown, Hung: "check"})
+	m.AddOps("TestSyncMap", "sync.Map", []porcupine.Operation{
+		{Input: model.Input{Op: model.Store}}, {Input: model.Input{Op: model.Load}}, {Input: model.Input{Op: model.Store}},
+	})
+	m.AddLitmus("TestMessagePassing/sync.Map", &litmus.Result{Test: "MP+Map", Iterations: 4, Counts: map[litmus.Outcome]int{litmus.Regs(0, 0): 3, litmus.Regs(1, 1): 1}})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type %q", ct)
+	}
+	text := rec.Body.String()
+	for _, want := range []string{
+		`syncmap_rounds_total{test="TestSyncMap",impl="sync.Map",result="Ok"} 1`,
+		`syncmap_rounds_total{test="TestSyncMap",impl="sync.Map",result="hung"} 1`,
+		`syncmap_violations_total{test="TestSyncMap",impl="sync.Map"} 1`,
+		`syncmap_check_duration_seconds_bucket{test="TestSyncMap",impl="sync.Map",le="0.005"} 1`,
+		`syncmap_check_duration_seconds_count{test="TestSyncMap",impl="sync.Map"} 2`,
+		`syncmap_ops_total{test="TestSyncMap",impl="sync.Map",op="Store"} 2`,
+		`syncmap_litmus_iterations_total{test="TestMessagePassing/sync.Map",shape="MP+Map"} 4`,
+		`syncmap_litmus_outcomes_total{test="TestMessagePassing/sync.Map",shape="MP+Map",outcome="{0,0}",forbidden="false"} 3`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics lack %s:\n%s", want, text)
+		}
+	}
+}
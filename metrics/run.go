@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// checkBuckets are the upper bounds of the buckets of the checking time of
+// a round, in seconds: from a millisecond to past the default timeout.
+var checkBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics are those of a run, labeled with the test and the implementation
+// under test:
+//
+//	syncmap_rounds_total{test, impl, result}    rounds checked, by porcupine result or "hung"
+//	syncmap_violations_total{test, impl}        rounds that are not linearizable
+//	syncmap_check_duration_seconds{test, impl}  histogram of the checking time of a round
+//	syncmap_ops_total{test, impl, op}           operations run, by kind
+//	syncmap_litmus_iterations_total{test, shape}
+//	syncmap_litmus_outcomes_total{test, shape, outcome, forbidden}
+type Metrics struct {
+	Registry
+	rounds, violations, ops, iterations, outcomes *Counter
+	checking                                      *Histogram
+}
+
+// New returns the metrics of a run, all zero.
+func New() *Metrics {
+	m := new(Metrics)
+	m.rounds = m.Counter("syncmap_rounds_total", "Workload rounds checked, by result.", "test", "impl", "result")
+	m.violations = m.Counter("syncmap_violations_total", "Workload rounds whose history is not linearizable.", "test", "impl")
+	m.checking = m.Histogram("syncmap_check_duration_seconds", "Time the checker took for a workload round.", checkBuckets, "test", "impl")
+	m.ops = m.Counter("syncmap_ops_total", "Operations of the workload rounds, by kind.", "test", "impl", "op")
+	m.iterations = m.Counter("syncmap_litmus_iterations_total", "Iterations of the litmus runs.", "test", "shape")
+	m.outcomes = m.Counter("syncmap_litmus_outcomes_total", "Outcomes of the litmus runs.", "test", "shape", "outcome", "forbidden")
+	return m
+}
+
+// AddChecked counts the outcome of a round of the workload test on impl,
+// as workload.RunOptions.Checked receives it.
+func (m *Metrics) AddChecked(test, impl string, c workload.Checked) {
+	result := string(c.Result)
+	if c.Hung != "" {
+		result = "hung"
+	}
+	m.rounds.Add(1, test, impl, result)
+	if c.Result == porcupine.Illegal {
+		m.violations.Add(1, test, impl)
+	}
+	if c.Hung == "" {
+		m.checking.Observe(time.Duration(c.Checking).Seconds(), test, impl)
+	}
+}
+
+// AddOps counts the operations of the history of a round of the workload
+// test on impl.
+func (m *Metrics) AddOps(test, impl string, ops []porcupine.Operation) {
+	byOp := make(map[model.Op]int)
+	for _, op := range ops {
+		if in, ok := op.Input.(model.Input); ok {
+			byOp[in.Op]++
+		}
+	}
+	for op, n := range byOp {
+		m.ops.Add(float64(n), test, impl, op.String())
+	}
+}
+
+// AddLitmus counts the iterations and outcomes of a litmus run of the
+// test.
+func (m *Metrics) AddLitmus(test string, res *litmus.Result) {
+	m.iterations.Add(float64(res.Iterations), test, res.Test)
+	for _, oc := range res.Outcomes() {
+		forbidden := "false"
+		if oc.Forbidden {
+			forbidden = "true"
+		}
+		m.outcomes.Add(float64(oc.Count), test, res.Test, oc.Outcome.String(), forbidden)
+	}
+}
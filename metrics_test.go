@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/jmasters-git/porcupine-syncmap/metrics"
+)
+
+// With -metrics, the run serves its metrics at /metrics on the address in
+// the Prometheus text format, for the monitoring of a soak: the rounds of
+// every workload test by result, its violations, checking times and
+// operations by kind, and the iterations and outcomes of every litmus run,
+// see metrics.Metrics. The batches of -isolate run in child processes that
+// do not serve them.
+//
+//	go test -timeout=0 -duration=72h -metrics=:9464
+var metricsFlag = flag.String("metrics", "", "serve Prometheus metrics of the run at /metrics on this address, e.g. :9464")
+
+// runMetrics are the metrics of -metrics, nil without it.
+var runMetrics *metrics.Metrics
+
+// startMetrics starts serving the metrics of the run, if there are any.
+func startMetrics() error {
+	if *metricsFlag == "" {
+		return nil
+	}
+	l, err := net.Listen("tcp", *metricsFlag)
+	if err != nil {
+		return fmt.Errorf("-metrics: %v", err)
+	}
+	runMetrics = metrics.New()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", runMetrics)
+	fmt.Fprintf(os.Stderr, "serving metrics at http://%s/metrics\n", l.Addr())
+	go http.Serve(l, mux)
+	return nil
+}
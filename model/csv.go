@@ -0,0 +1,127 @@
+package model
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// csvHeader names the columns of WriteOperationsCSV: the client, the
+// fields of the Input, those of the Output, with its Val as loaded and its
+// Entries as key=value pairs separated by spaces, which keys of a Range
+// must not contain, and the timestamps.
+var csvHeader = []string{"client", "op", "key", "val", "old", "found", "loaded", "entries", "pending", "call", "return"}
+
+// WriteOperationsCSV writes a history of this model as CSV, a header and
+// one row per operation, for spreadsheets and data frames. It fails on an
+// operation of another model.
+func WriteOperationsCSV(w io.Writer, ops []porcupine.Operation) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for i, op := range ops {
+		in, ok := op.Input.(Input)
+		if !ok {
+			return fmt.Errorf("model: operation %d has input %T", i, op.Input)
+		}
+		out, ok := op.Output.(Output)
+		if !ok {
+			return fmt.Errorf("model: operation %d has output %T", i, op.Output)
+		}
+		var entries []string
+		for _, k := range slices.Sorted(maps.Keys(out.Entries)) {
+			entries = append(entries, k+"="+strconv.Itoa(out.Entries[k]))
+		}
+		err := cw.Write([]string{
+			strconv.Itoa(op.ClientId), in.Op.String(), in.Key, strconv.Itoa(in.Val), strconv.Itoa(in.Old),
+			strconv.FormatBool(out.Found), strconv.Itoa(out.Val), strings.Join(entries, " "), strconv.FormatBool(out.Pending),
+			strconv.FormatInt(op.Call, 10), strconv.FormatInt(op.Return, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadOperationsCSV reads a history written by WriteOperationsCSV.
+func ReadOperationsCSV(r io.Reader) ([]porcupine.Operation, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("model: csv: %v", err)
+	}
+	if !slices.Equal(header, csvHeader) {
+		return nil, fmt.Errorf("model: csv: header %q, want %q", header, csvHeader)
+	}
+	var ops []porcupine.Operation
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("model: csv: %v", err)
+		}
+		op, err := parseCSVRow(row)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("model: csv: line %d: %v", line, err)
+		}
+		ops = append(ops, op)
+	}
+}
+
+func parseCSVRow(row []string) (porcupine.Operation, error) {
+	var (
+		op  porcupine.Operation
+		in  Input
+		out Output
+		err error
+	)
+	if in.Op, err = ParseOp(row[1]); err != nil {
+		return op, fmt.Errorf("op: unknown operation %q", row[1])
+	}
+	in.Key = row[2]
+	for col, dst := range map[int]*int{0: &op.ClientId, 3: &in.Val, 4: &in.Old, 6: &out.Val} {
+		if *dst, err = strconv.Atoi(row[col]); err != nil {
+			return op, fmt.Errorf("%s: %v", csvHeader[col], err)
+		}
+	}
+	for col, dst := range map[int]*bool{5: &out.Found, 8: &out.Pending} {
+		if *dst, err = strconv.ParseBool(row[col]); err != nil {
+			return op, fmt.Errorf("%s: %v", csvHeader[col], err)
+		}
+	}
+	for col, dst := range map[int]*int64{9: &op.Call, 10: &op.Return} {
+		if *dst, err = strconv.ParseInt(row[col], 10, 64); err != nil {
+			return op, fmt.Errorf("%s: %v", csvHeader[col], err)
+		}
+	}
+	for _, e := range strings.Fields(row[7]) {
+		i := strings.LastIndexByte(e, '=')
+		if i < 0 {
+			return op, fmt.Errorf("entries: %q is not key=value", e)
+		}
+		k, v := e[:i], e[i+1:]
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return op, fmt.Errorf("entries: %q is not key=value", e)
+		}
+		if out.Entries == nil {
+			out.Entries = make(map[string]int)
+		}
+		out.Entries[k] = n
+	}
+	op.Input, op.Output = in, out
+	return op, nil
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+)
+
+func TestOperationsCSV(t *testing.T) {
+	ops := []porcupine.Operation{
+		{ClientId: 0, Input: Input{Op: Store, Key: "k0", Val: 1}, Call: 10, Output: Output{}, Return: 20},
+		{ClientId: 1, Input: Input{Op: CompareAndSwap, Key: "k,0", Val: 2, Old: 1}, Call: 15, Output: Output{Found: true}, Return: 30},
+		{ClientId: 2, Input: Input{Op: Range}, Call: 25, Output: Output{Entries: map[string]int{"k0": 2, "a=b": 1}}, Return: 40},
+		{ClientId: 3, Input: Input{Op: Load, Key: "k1"}, Call: -26, Output: Output{Found: true, Val: 7, Pending: true}, Return: 50},
+	}
+	var b strings.Builder
+	if err := WriteOperationsCSV(&b, ops); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if lines[0] != "client,op,key,val,old,found,loaded,entries,pending,call,return" || lines[3] != "2,Range,,0,0,false,0,a=b=1 k0=2,false,25,40" {
+		t.Errorf("CSV:\n%s", b.String())
+	}
+	got, err := ReadOperationsCSV(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ops) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, ops)
+	}
+
+	if err := WriteOperationsCSV(&b, []porcupine.Operation{{Input: "nope", Output: Output{}}}); err == nil {
+		t.Error("WriteOperationsCSV accepted an input of another model")
+	}
+	header := lines[0] + "\n"
+	for _, bad := range []string{
+		"",
+		"client,op\n",
+		header + "0,Frobnicate,k,0,0,false,0,,false,1,2\n",
+		header + "0,Load,k,0,0,maybe,0,,false,1,2\n",
+		header + "0,Range,,0,0,false,0,k0,false,1,2\n",
+		header + "0,Load,k,0,0,false,0,,false,1\n",
+	} {
+		if _, err := ReadOperationsCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadOperationsCSV(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// MarshalText returns the name of op, so JSON histories and mixes name
+// the operations the same way.
+func (op Op) MarshalText() ([]byte, error) {
+	if int(op) < 0 || int(op) >= len(opNames) {
+		return nil, fmt.Errorf("model: unknown operation %d", int(op))
+	}
+	return []byte(opNames[op]), nil
+}
+
+// UnmarshalText parses an operation name, see ParseOp.
+func (op *Op) UnmarshalText(b []byte) error {
+	o, err := ParseOp(string(b))
+	if err != nil {
+		return err
+	}
+	*op = o
+	return nil
+}
+
+// operation is the JSON form of a porcupine.Operation of this model.
+type operation struct {
+	ClientId int    `json:"client_id"`
+	Input    Input  `json:"input"`
+	Call     int64  `json:"call"`
+	Output   Output `json:"output"`
+	Return   int64  `json:"return"`
+}
+
+// MarshalOperations returns the JSON encoding of a history of this model:
+// an array of operations with their clients, timestamps, and Inputs and
+// Outputs. It fails on an operation of another model.
+func MarshalOperations(ops []porcupine.Operation) ([]byte, error) {
+	enc := make([]operation, len(ops))
+	for i, op := range ops {
+		in, ok := op.Input.(Input)
+		if !ok {
+			return nil, fmt.Errorf("model: operation %d has input %T", i, op.Input)
+		}
+		out, ok := op.Output.(Output)
+		if !ok {
+			return nil, fmt.Errorf("model: operation %d has output %T", i, op.Output)
+		}
+		enc[i] = operation{ClientId: op.ClientId, Input: in, Call: op.Call, Output: out, Return: op.Return}
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalOperations decodes a history encoded by MarshalOperations.
+func UnmarshalOperations(b []byte) ([]porcupine.Operation, error) {
+	var enc []operation
+	if err := json.Unmarshal(b, &enc); err != nil {
+		return nil, fmt.Errorf("model: %v", err)
+	}
+	ops := make([]porcupine.Operation, len(enc))
+	for i, op := range enc {
+		ops[i] = porcupine.Operation{ClientId: op.ClientId, Input: op.Input, Call: op.Call, Output: op.Output, Return: op.Return}
+	}
+	return ops, nil
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+)
+
+func TestMarshalOperations(t *testing.T) {
+	ops := []porcupine.Operation{
+		{ClientId: 0, Input: Input{Op: Store, Key: "k0", Val: 1}, Call: 10, Output: Output{}, Return: 20},
+		{ClientId: 1, Input: Input{Op: CompareAndSwap, Key: "k0", Val: 2, Old: 1}, Call: 15, Output: Output{Found: true}, Return: 30},
+		{ClientId: 2, Input: Input{Op: Range}, Call: 25, Output: Output{Found: true, Entries: map[string]int{"k0": 2}}, Return: 40},
+		{ClientId: 3, Input: Input{Op: Load, Key: "k1"}, Call: 26, Output: Output{Pending: true}, Return: 50},
+	}
+	b, err := MarshalOperations(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"op":"CompareAndSwap"`) {
+		t.Errorf("operations not named: %s", b)
+	}
+	got, err := UnmarshalOperations(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ops) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, ops)
+	}
+
+	if _, err := MarshalOperations([]porcupine.Operation{{Input: "nope", Output: Output{}}}); err == nil {
+		t.Error("MarshalOperations accepted an input of another model")
+	}
+	if _, err := UnmarshalOperations([]byte(`[{"input":{"op":"Frobnicate"}}]`)); err == nil {
+		t.Error("UnmarshalOperations accepted an unknown operation")
+	}
+}
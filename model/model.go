@@ -0,0 +1,281 @@
+// Package model is the porcupine model of a sync.Map-like map, along with
+// the inputs and outputs the workloads record in their histories.
+package model
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// Op is a map operation.
+type Op int
+
+const (
+	Load Op = iota
+	Store
+	LoadOrStore
+	LoadAndDelete
+	Swap
+	CompareAndSwap
+	// Range visits every key. sync.Map does not promise a consistent
+	// snapshot, so each key it reports, or leaves out, is checked as a Load
+	// of that key somewhere within the call.
+	Range
+)
+
+var opNames = []string{"Load", "Store", "LoadOrStore", "LoadAndDelete", "Swap", "CompareAndSwap", "Range"}
+
+// Ops returns every operation, in order.
+func Ops() []Op {
+	ops := make([]Op, len(opNames))
+	for i := range ops {
+		ops[i] = Op(i)
+	}
+	return ops
+}
+
+func (op Op) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// ParseOp parses an operation name as printed by Op.String, which is the
+// name of the sync.Map method.
+func ParseOp(s string) (Op, error) {
+	for i, name := range opNames {
+		if s == name {
+			return Op(i), nil
+		}
+	}
+	return 0, fmt.Errorf("model: unknown operation %q", s)
+}
+
+// Input is an operation on a key. Val is the value stored by Store,
+// LoadOrStore, Swap and CompareAndSwap, Old the value CompareAndSwap
+// expects. Range has no key.
+type Input struct {
+	Op  Op     `json:"op"`
+	Key string `json:"key,omitempty"`
+	Val int    `json:"val"`
+	Old int    `json:"old,omitempty"`
+}
+
+// String describes the call of in, without its result, as
+// Model.DescribeOperation does.
+func (in Input) String() string {
+	return describeCall(in)
+}
+
+// Output is the result of an operation. Found is the loaded, ok or swapped
+// result of the sync.Map method, Val the value it loaded. Entries are the
+// keys and values visited by Range. Pending marks an operation that never
+// returned while it was recorded, like one of a crashed client: it may have
+// taken effect at any point after its call, and its result is unknown.
+type Output struct {
+	Found   bool           `json:"found,omitempty"`
+	Val     int            `json:"val,omitempty"`
+	Entries map[string]int `json:"entries,omitempty"`
+	Pending bool           `json:"pending,omitempty"`
+}
+
+// state is the content of one key.
+type state struct {
+	present bool
+	val     int
+}
+
+// Model checks every key on its own: operations on different keys
+// commute, so the history is partitioned by key. A Range is split into one
+// operation per key, its observation of that key.
+var Model = porcupine.Model{
+	Partition: partition,
+	Init:      func() interface{} { return state{} },
+	Step: func(st, input, output interface{}) (bool, interface{}) {
+		return step(st.(state), input.(Input), output.(Output))
+	},
+	DescribeOperation: describe,
+	DescribeState:     describeState,
+}
+
+// step checks an operation on a key in the state s of the key and returns
+// the state after it.
+func step(s state, in Input, out Output) (bool, state) {
+	if out.Pending {
+		return true, effect(s, in)
+	}
+	switch in.Op {
+	case Load, Range:
+		return out.Found == s.present && (!s.present || out.Val == s.val), s
+	case Store:
+		return true, state{present: true, val: in.Val}
+	case LoadOrStore:
+		if s.present {
+			return out.Found && out.Val == s.val, s
+		}
+		if out.Found {
+			return false, s
+		}
+		return true, state{present: true, val: in.Val}
+	case LoadAndDelete:
+		if s.present {
+			if out.Found && out.Val == s.val {
+				return true, state{}
+			}
+			return false, s
+		}
+		return !out.Found, s
+	case Swap:
+		if out.Found != s.present || (s.present && out.Val != s.val) {
+			return false, s
+		}
+		return true, state{present: true, val: in.Val}
+	case CompareAndSwap:
+		if s.present && s.val == in.Old {
+			return out.Found, state{present: true, val: in.Val}
+		}
+		return !out.Found, s
+	default:
+		return false, s
+	}
+}
+
+func describe(input, output interface{}) string {
+	in := input.(Input)
+	out := output.(Output)
+
+	if out.Pending {
+		return describeCall(in) + " -> pending"
+	}
+	switch in.Op {
+	case Load, Range:
+		if out.Found {
+			return fmt.Sprintf("%s(%s) -> %d", in.Op, in.Key, out.Val)
+		}
+		return fmt.Sprintf("%s(%s) -> not found", in.Op, in.Key)
+	case Store:
+		return fmt.Sprintf("Store(%s, %d)", in.Key, in.Val)
+	case LoadOrStore:
+		if out.Found {
+			return fmt.Sprintf("LoadOrStore(%s, %d) -> loaded %d", in.Key, in.Val, out.Val)
+		}
+		return fmt.Sprintf("LoadOrStore(%s, %d) -> stored", in.Key, in.Val)
+	case LoadAndDelete:
+		if out.Found {
+			return fmt.Sprintf("LoadAndDelete(%s) -> deleted %d", in.Key, out.Val)
+		}
+		return fmt.Sprintf("LoadAndDelete(%s) -> not found", in.Key)
+	case Swap:
+		if out.Found {
+			return fmt.Sprintf("Swap(%s, %d) -> previous %d", in.Key, in.Val, out.Val)
+		}
+		return fmt.Sprintf("Swap(%s, %d) -> stored", in.Key, in.Val)
+	case CompareAndSwap:
+		if out.Found {
+			return fmt.Sprintf("CompareAndSwap(%s, %d, %d) -> swapped", in.Key, in.Old, in.Val)
+		}
+		return fmt.Sprintf("CompareAndSwap(%s, %d, %d) -> failed", in.Key, in.Old, in.Val)
+	default:
+		return "Unknown operation"
+	}
+}
+
+// effect is the state after in, whatever it returned.
+func effect(s state, in Input) state {
+	switch in.Op {
+	case Store, Swap:
+		return state{present: true, val: in.Val}
+	case LoadOrStore:
+		if !s.present {
+			return state{present: true, val: in.Val}
+		}
+	case LoadAndDelete:
+		return state{}
+	case CompareAndSwap:
+		if s.present && s.val == in.Old {
+			return state{present: true, val: in.Val}
+		}
+	}
+	return s
+}
+
+// describeState describes the state of a key, its value or that it is
+// missing.
+func describeState(st interface{}) string {
+	s := st.(state)
+	if !s.present {
+		return "missing"
+	}
+	return strconv.Itoa(s.val)
+}
+
+// describeCall describes the call of in, without its result.
+func describeCall(in Input) string {
+	switch in.Op {
+	case Store, LoadOrStore, Swap:
+		return fmt.Sprintf("%s(%s, %d)", in.Op, in.Key, in.Val)
+	case CompareAndSwap:
+		return fmt.Sprintf("CompareAndSwap(%s, %d, %d)", in.Key, in.Old, in.Val)
+	}
+	return fmt.Sprintf("%s(%s)", in.Op, in.Key)
+}
+
+// partition groups history by key, the partitions of Model, see Split.
+func partition(history []porcupine.Operation) [][]porcupine.Operation {
+	_, parts := Split(history)
+	return parts
+}
+
+// Split groups history by key, in order of first appearance: parts[i] is
+// the history of keys[i], to check with Model on its own. Every Range is
+// added to the history of each key as that key's observation: present
+// with its value if Range visited it, missing otherwise.
+func Split(history []porcupine.Operation) (keys []string, parts [][]porcupine.Operation) {
+	var (
+		index  = make(map[string]int)
+		ranges []porcupine.Operation
+	)
+	part := func(key string) int {
+		i, ok := index[key]
+		if !ok {
+			i = len(parts)
+			index[key] = i
+			keys = append(keys, key)
+			parts = append(parts, nil)
+		}
+		return i
+	}
+	for _, op := range history {
+		in := op.Input.(Input)
+		if in.Op != Range {
+			i := part(in.Key)
+			parts[i] = append(parts[i], op)
+			continue
+		}
+		ranges = append(ranges, op)
+		// A key only ever seen by Range still needs its partition, in the
+		// same place every time.
+		for _, k := range slices.Sorted(maps.Keys(op.Output.(Output).Entries)) {
+			part(k)
+		}
+	}
+	for _, op := range ranges {
+		entries := op.Output.(Output).Entries
+		for k, i := range index {
+			v, ok := entries[k]
+			parts[i] = append(parts[i], porcupine.Operation{
+				ClientId: op.ClientId,
+				Input:    Input{Op: Range, Key: k},
+				Call:     op.Call,
+				Output:   Output{Found: ok, Val: v, Pending: op.Output.(Output).Pending},
+				Return:   op.Return,
+			})
+		}
+	}
+	return keys, parts
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+	"pgregory.net/rapid"
+)
+
+func op(client int, call, ret int64, in Input, out Output) porcupine.Operation {
+	return porcupine.Operation{ClientId: client, Input: in, Call: call, Output: out, Return: ret}
+}
+
+func TestModel(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		history []porcupine.Operation
+		ok      bool
+	}{
+		{"store then load", []porcupine.Operation{
+			op(0, 0, 1, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(1, 2, 3, Input{Op: Load, Key: "a"}, Output{Found: true, Val: 1}),
+		}, true},
+		{"stale load", []porcupine.Operation{
+			op(0, 0, 1, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(1, 2, 3, Input{Op: Load, Key: "a"}, Output{}),
+		}, false},
+		{"swap and compare", []porcupine.Operation{
+			op(0, 0, 1, Input{Op: Swap, Key: "a", Val: 1}, Output{}),
+			op(0, 2, 3, Input{Op: CompareAndSwap, Key: "a", Old: 1, Val: 2}, Output{Found: true}),
+			op(1, 4, 5, Input{Op: CompareAndSwap, Key: "a", Old: 1, Val: 3}, Output{}),
+			op(1, 6, 7, Input{Op: LoadAndDelete, Key: "a"}, Output{Found: true, Val: 2}),
+		}, true},
+		{"keys are independent", []porcupine.Operation{
+			op(0, 0, 1, Input{Op: LoadOrStore, Key: "a", Val: 1}, Output{}),
+			op(1, 0, 1, Input{Op: LoadOrStore, Key: "b", Val: 2}, Output{}),
+		}, true},
+		// Range may observe each key at a different point of its call.
+		{"range without snapshot", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Range}, Output{Entries: map[string]int{"b": 2}}),
+			op(1, 1, 2, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(1, 3, 4, Input{Op: Store, Key: "b", Val: 2}, Output{}),
+		}, true},
+		{"range misses a key", []porcupine.Operation{
+			op(1, 0, 1, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(1, 2, 3, Input{Op: Store, Key: "b", Val: 2}, Output{}),
+			op(0, 4, 5, Input{Op: Range}, Output{Entries: map[string]int{"b": 2}}),
+		}, false},
+		// A pending operation may take effect anywhere after its call, or
+		// not be seen at all.
+		{"pending store seen", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Store, Key: "a", Val: 1}, Output{Pending: true}),
+			op(1, 1, 2, Input{Op: Load, Key: "a"}, Output{}),
+			op(1, 3, 4, Input{Op: Load, Key: "a"}, Output{Found: true, Val: 1}),
+		}, true},
+		{"pending store unseen", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Store, Key: "a", Val: 1}, Output{Pending: true}),
+			op(1, 1, 2, Input{Op: Load, Key: "a"}, Output{}),
+		}, true},
+		{"pending store undone", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Store, Key: "a", Val: 1}, Output{Pending: true}),
+			op(1, 1, 2, Input{Op: Load, Key: "a"}, Output{Found: true, Val: 1}),
+			op(1, 3, 4, Input{Op: Load, Key: "a"}, Output{}),
+		}, false},
+		{"pending range", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Range}, Output{Pending: true}),
+			op(1, 1, 2, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+		}, true},
+	} {
+		if got := porcupine.CheckOperations(Model, tc.history); got != tc.ok {
+			t.Errorf("%s: CheckOperations = %v, want %v", tc.name, got, tc.ok)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	keys, parts := Split([]porcupine.Operation{
+		op(0, 0, 1, Input{Op: Store, Key: "b", Val: 1}, Output{}),
+		op(1, 0, 1, Input{Op: Load, Key: "a"}, Output{}),
+		op(0, 2, 3, Input{Op: Range}, Output{Entries: map[string]int{"b": 1, "d": 3, "c": 2}}),
+	})
+	// The keys only Range saw in order, to split the same way every time.
+	if !slices.Equal(keys, []string{"b", "a", "c", "d"}) {
+		t.Fatalf("keys %v, want b, a, c and d", keys)
+	}
+	// The Range observes every key, c and d only there.
+	for i, n := range []int{2, 2, 1, 1} {
+		if len(parts[i]) != n {
+			t.Errorf("history of %s has %d operations, want %d", keys[i], len(parts[i]), n)
+		}
+	}
+	if out := parts[1][1].Output.(Output); parts[1][1].Input.(Input).Key != "a" || out.Found {
+		t.Errorf("Range as seen by a: %+v", parts[1][1])
+	}
+}
+
+// A sequence is a sequential run of operations on a few keys, with values
+// that collide so that LoadOrStore loads and CompareAndSwap swaps often.
+type sequence []Input
+
+// sequences generates sequences, which rapid shrinks to fewer operations,
+// earlier ones of Ops, key a and value 0.
+func sequences() *rapid.Generator[sequence] {
+	input := rapid.Custom(func(t *rapid.T) Input {
+		in := Input{Op: rapid.SampledFrom(Ops()).Draw(t, "op"), Key: rapid.SampledFrom([]string{"a", "b", "c"}).Draw(t, "key"), Val: rapid.IntRange(0, 3).Draw(t, "val")}
+		switch in.Op {
+		case Range:
+			in.Key, in.Val = "", 0
+		case CompareAndSwap:
+			in.Old = rapid.IntRange(0, 3).Draw(t, "old")
+		}
+		return in
+	})
+	return rapid.Custom(func(t *rapid.T) sequence { return rapid.SliceOf(input).Draw(t, "calls") })
+}
+
+// GoString lists the calls of seq, for the failures rapid reports.
+func (seq sequence) GoString() string {
+	calls := make([]string, len(seq))
+	for i, in := range seq {
+		calls[i] = in.String()
+	}
+	return strings.Join(calls, ", ")
+}
+
+// run applies seq to a sync.Map and returns its history, every operation
+// after the previous one, then a Range of what the map holds at the end.
+func (seq sequence) run() []porcupine.Operation {
+	var (
+		m       sync.Map
+		history []porcupine.Operation
+	)
+	for i, in := range append(seq, Input{Op: Range}) {
+		var (
+			out Output
+			v   any
+		)
+		switch in.Op {
+		case Load:
+			v, out.Found = m.Load(in.Key)
+		case Store:
+			m.Store(in.Key, in.Val)
+		case LoadOrStore:
+			v, out.Found = m.LoadOrStore(in.Key, in.Val)
+		case LoadAndDelete:
+			v, out.Found = m.LoadAndDelete(in.Key)
+		case Swap:
+			v, out.Found = m.Swap(in.Key, in.Val)
+		case CompareAndSwap:
+			out.Found = m.CompareAndSwap(in.Key, in.Old, in.Val)
+		case Range:
+			out.Entries = make(map[string]int)
+			m.Range(func(k, v any) bool {
+				out.Entries[k.(string)] = v.(int)
+				return true
+			})
+		}
+		if out.Found && in.Op != CompareAndSwap {
+			out.Val = v.(int)
+		}
+		history = append(history, op(0, int64(2*i), int64(2*i+1), in, out))
+	}
+	return history
+}
+
+// wrong returns the outputs an operation of a sequential history must not
+// have had in place of out: found flipped and, if it loaded a value, a
+// different one, or for a Range a key more or less.
+func wrong(in Input, out Output) []Output {
+	switch in.Op {
+	case Store:
+		return nil
+	case Range:
+		e := maps.Clone(out.Entries)
+		if len(e) > 0 {
+			delete(e, slices.Min(slices.Collect(maps.Keys(e))))
+		} else {
+			e["a"] = 0
+		}
+		return []Output{{Entries: e}}
+	}
+	outs := []Output{{Found: !out.Found, Val: out.Val}}
+	if out.Found && in.Op != CompareAndSwap {
+		outs = append(outs, Output{Found: true, Val: out.Val + 1})
+	}
+	return outs
+}
+
+// Both models must accept exactly what a real sync.Map does, for every
+// operation: the history of a random sequence run against one, and none
+// with the output of any of its operations changed. A model drifting from
+// the semantics of the map, such as an operation added to one and not the
+// other, fails this before it fails a workload.
+func TestModelAgreesWithSyncMap(t *testing.T) {
+	// In order, for a shrunk sequence to fail the same way.
+	models := []struct {
+		name string
+		m    porcupine.Model
+	}{{"map", Model}, {"snapshot", Snapshot}}
+	rapid.Check(t, func(t *rapid.T) {
+		history := sequences().Draw(t, "seq").run()
+		for _, model := range models {
+			name, m := model.name, model.m
+			if !porcupine.CheckOperations(m, history) {
+				t.Fatalf("%s rejects the history of sync.Map", name)
+			}
+			for i, op := range history {
+				for _, out := range wrong(op.Input.(Input), op.Output.(Output)) {
+					changed := slices.Clone(history)
+					changed[i].Output = out
+					if porcupine.CheckOperations(m, changed) {
+						t.Fatalf("%s accepts %s in place of %s", name, m.DescribeOperation(op.Input, out), m.DescribeOperation(op.Input, op.Output))
+					}
+				}
+			}
+		}
+	})
+}
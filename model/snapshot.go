@@ -0,0 +1,94 @@
+package model
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// Snapshot checks the whole map at once, without partitioning the history
+// by key, and a Range as an atomic snapshot of it: the entries it visited
+// must be those of the map at a single point within the call. That is
+// more than sync.Map promises, a Range that passes Model may fail
+// Snapshot, but it is what a map with a consistent Range has to satisfy.
+// Without partitions the search is exponential in the operations of the
+// whole round, not those of a key, so Snapshot is only practical for
+// short histories.
+var Snapshot = porcupine.Model{
+	Init: func() interface{} { return map[string]int{} },
+	Step: func(st, input, output interface{}) (bool, interface{}) {
+		return stepSnapshot(st.(map[string]int), input.(Input), output.(Output))
+	},
+	Equal: func(a, b interface{}) bool {
+		return maps.Equal(a.(map[string]int), b.(map[string]int))
+	},
+	DescribeOperation: func(input, output interface{}) string {
+		in := input.(Input)
+		out := output.(Output)
+		if in.Op == Range && !out.Pending {
+			return "Range() -> " + describeEntries(out.Entries)
+		}
+		return describe(in, out)
+	},
+	DescribeState: func(st interface{}) string {
+		return describeEntries(st.(map[string]int))
+	},
+}
+
+// stepSnapshot checks an operation on the map m and returns the map after
+// it. m is never modified, an operation that changes it returns a copy.
+func stepSnapshot(m map[string]int, in Input, out Output) (bool, map[string]int) {
+	if in.Op == Range {
+		return out.Pending || maps.Equal(m, out.Entries), m
+	}
+	val, present := m[in.Key]
+	ok, next := step(state{present: present, val: val}, in, out)
+	if !ok || next == (state{present: present, val: val}) {
+		return ok, m
+	}
+	m = maps.Clone(m)
+	if next.present {
+		m[in.Key] = next.val
+	} else {
+		delete(m, in.Key)
+	}
+	return true, m
+}
+
+// describeEntries formats entries in order of their keys.
+func describeEntries(entries map[string]int) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range slices.Sorted(maps.Keys(entries)) {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s: %d", k, entries[k])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// models are the models Lookup knows, by name.
+var models = map[string]porcupine.Model{
+	"map":      Model,
+	"snapshot": Snapshot,
+}
+
+// Lookup returns the model of a name of Names.
+func Lookup(name string) (porcupine.Model, error) {
+	m, ok := models[name]
+	if !ok {
+		return porcupine.Model{}, fmt.Errorf("model: unknown model %q, want one of %s", name, strings.Join(Names(), ", "))
+	}
+	return m, nil
+}
+
+// Names returns the names of the models, sorted: map for Model and
+// snapshot for Snapshot.
+func Names() []string {
+	return slices.Sorted(maps.Keys(models))
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+)
+
+func TestSnapshot(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		history []porcupine.Operation
+		ok      bool
+	}{
+		{"store then load", []porcupine.Operation{
+			op(0, 0, 1, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(1, 2, 3, Input{Op: Load, Key: "a"}, Output{Found: true, Val: 1}),
+		}, true},
+		{"range snapshot", []porcupine.Operation{
+			op(1, 0, 1, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(0, 0, 10, Input{Op: Range}, Output{Entries: map[string]int{"a": 1}}),
+			op(1, 3, 4, Input{Op: LoadAndDelete, Key: "a"}, Output{Found: true, Val: 1}),
+		}, true},
+		// Model passes this one, each key is seen at its own point.
+		{"range without snapshot", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Range}, Output{Entries: map[string]int{"b": 2}}),
+			op(1, 1, 2, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(1, 3, 4, Input{Op: Store, Key: "b", Val: 2}, Output{}),
+		}, false},
+		{"pending store seen by range", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Store, Key: "a", Val: 1}, Output{Pending: true}),
+			op(1, 1, 2, Input{Op: Range}, Output{Entries: map[string]int{"a": 1}}),
+		}, true},
+		{"pending range", []porcupine.Operation{
+			op(0, 0, 10, Input{Op: Range}, Output{Pending: true}),
+			op(1, 1, 2, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+		}, true},
+		{"stale load", []porcupine.Operation{
+			op(0, 0, 1, Input{Op: Store, Key: "a", Val: 1}, Output{}),
+			op(0, 2, 3, Input{Op: Store, Key: "b", Val: 2}, Output{}),
+			op(1, 4, 5, Input{Op: Load, Key: "a"}, Output{}),
+		}, false},
+	} {
+		if got := porcupine.CheckOperations(Snapshot, tc.history); got != tc.ok {
+			t.Errorf("%s: CheckOperations = %v, want %v", tc.name, got, tc.ok)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range Names() {
+		if _, err := Lookup(name); err != nil {
+			t.Errorf("Lookup(%q): %v", name, err)
+		}
+	}
+	if _, err := Lookup("nope"); err == nil {
+		t.Error("Lookup of an unknown model succeeded")
+	}
+}
@@ -0,0 +1,135 @@
+// Package notify posts to a webhook when a run finds something to look
+// at, a linearizability violation or a forbidden litmus outcome, so that
+// a soak of several days needs nobody to watch its logs. The body is a
+// JSON object whose text field is a one-line summary, which is what the
+// incoming webhooks of Slack and Mattermost show, with the details of the
+// event in the other fields for any other receiver.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"time"
+)
+
+// The kinds of Event.
+const (
+	Violation = "violation"
+	Forbidden = "forbidden"
+)
+
+// An Event is something a run found.
+type Event struct {
+	// Text is the summary of the event, which Webhook.Notify prefixes with
+	// the host and platform.
+	Text string `json:"text"`
+	Kind string `json:"kind"`
+	Test string `json:"test"`
+	Impl string `json:"impl,omitempty"`
+	// Round and Seed are those of the round that is not linearizable, or
+	// the iteration of the first forbidden outcome and the seed of a
+	// random litmus shape.
+	Round int    `json:"round"`
+	Seed  uint64 `json:"seed,omitempty"`
+	// Artifacts are the files written about the event, such as the
+	// visualization and history of a violation.
+	Artifacts []string `json:"artifacts,omitempty"`
+
+	// The environment, set by Webhook.Notify.
+	Host   string    `json:"host"`
+	Go     string    `json:"go"`
+	GOOS   string    `json:"goos"`
+	GOARCH string    `json:"goarch"`
+	Time   time.Time `json:"time"`
+}
+
+// A Webhook receives events as HTTP POST requests.
+type Webhook struct {
+	URL string
+	// Client sends the requests, nil for one with a timeout of 10s.
+	Client *http.Client
+	// Attempts is the number of times a request is tried, 0 for 3; a
+	// failed attempt is retried after a second, then two, and so on.
+	Attempts int
+}
+
+// Notify posts ev to the webhook, retrying while it fails to connect or
+// answers with a server error. A redirect or a client error is not
+// retried.
+func (w *Webhook) Notify(ctx context.Context, ev Event) error {
+	ev.Host, _ = os.Hostname()
+	ev.Go, ev.GOOS, ev.GOARCH = runtime.Version(), runtime.GOOS, runtime.GOARCH
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.Text = fmt.Sprintf("%s (%s %s/%s): %s", ev.Host, ev.Go, ev.GOOS, ev.GOARCH, ev.Text)
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	attempts := w.Attempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	for i := 1; ; i++ {
+		retry, err := w.post(ctx, client, body)
+		if err == nil || !retry || i == attempts {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(i) * time.Second):
+		case <-ctx.Done():
+			return fmt.Errorf("notify: %v, then %v", err, ctx.Err())
+		}
+	}
+}
+
+// post posts body once and reports whether a failure is worth retrying.
+func (w *Webhook) post(ctx context.Context, client *http.Client, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("notify: %v", w.redact(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("notify: %v", w.redact(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.StatusCode >= 500, fmt.Errorf("notify: %s: %s: %s", w.String(), resp.Status, bytes.TrimSpace(msg))
+}
+
+// String returns the scheme and host of the URL, all of it that errors
+// and logs show: the path of a Slack or Discord webhook is its secret.
+func (w *Webhook) String() string {
+	u, err := url.Parse(w.URL)
+	if err != nil || u.Host == "" {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// redact replaces the URL of a *url.Error, which net/http returns with the
+// whole URL, with String.
+func (w *Webhook) redact(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		return &url.Error{Op: ue.Op, URL: w.String(), Err: ue.Err}
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	var got []Event
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type %q", ct)
+		}
+		var ev Event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Error(err)
+		}
+		got = append(got, ev)
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL}
+	ev := Event{Text: "round 3 is not linearizable", Kind: Violation, Test: "TestSyncMap", Impl: "sync.Map", Round: 3, Seed: 10, Artifacts: []string{"v.html", "v.json"}}
+	if err := w.Notify(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("%d events received, want 1 after the retry", len(got))
+	}
+	e := got[0]
+	if e.Kind != Violation || e.Round != 3 || e.Seed != 10 || len(e.Artifacts) != 2 || e.GOARCH != runtime.GOARCH || e.Time.IsZero() {
+		t.Errorf("event %+v", e)
+	}
+	if !strings.HasPrefix(e.Text, e.Host+" ("+runtime.Version()) || !strings.HasSuffix(e.Text, "): round 3 is not linearizable") {
+		t.Errorf("text %q", e.Text)
+	}
+}
+
+func TestNotifyClientError(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := (&Webhook{URL: srv.URL + "/services/T0/B0/secret"}).Notify(context.Background(), Event{Kind: Forbidden})
+	if err == nil || !strings.Contains(err.Error(), srv.URL+": 404 Not Found: no such hook") {
+		t.Errorf("error %v, want the host, status and body", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "secret") {
+		t.Errorf("error %v shows the path of the webhook", err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests, a client error is not retried", requests)
+	}
+}
+
+func TestNotifyRedactsURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	for _, u := range []string{srv.URL + "/hooks/secret", "http://host/hooks/secret%zz"} {
+		err := (&Webhook{URL: u, Attempts: 1}).Notify(context.Background(), Event{Kind: Violation})
+		if err == nil || strings.Contains(err.Error(), "secret") {
+			t.Errorf("%s: error %v, want one without the path", u, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/notify"
+)
+
+// With -webhook, every violation and forbidden litmus outcome is posted
+// to a webhook as it is found, with its seed and the files written about
+// it, for soaks nobody watches; Slack and Mattermost incoming webhooks
+// show its summary:
+//
+//	go test -timeout=0 -duration=72h -webhook=https://hooks.slack.com/services/...
+var webhookFlag = flag.String("webhook", "", "URL to POST every violation and forbidden litmus outcome to as JSON")
+
+// notifyEvent posts ev about the test t to the -webhook, if any. A failed
+// post is logged rather than failing t, which has an outcome of its own.
+func notifyEvent(t *testing.T, ev notify.Event) {
+	if *webhookFlag == "" {
+		return
+	}
+	ev.Test = t.Name()
+	ev.Text = t.Name() + ": " + ev.Text
+	w := &notify.Webhook{URL: *webhookFlag}
+	if err := w.Notify(context.Background(), ev); err != nil {
+		t.Logf("webhook: %v", err)
+	}
+}
+
+// violationEvent returns the event of the violation of round on impl,
+// saved to filename by saveViolation.
+func violationEvent(impl string, round int, seed uint64, filename string) notify.Event {
+	base := strings.TrimSuffix(filename, ".html")
+	artifacts := []string{filename, base + ".json"}
+	switch *snapshotFlag {
+	case "svg":
+		artifacts = append(artifacts, base+".svg")
+	case "png":
+		artifacts = append(artifacts, base+".svg", base+".png")
+	}
+	return notify.Event{
+		Text:      fmt.Sprintf("%s round %d (seed %d) is not linearizable, saved to %s", impl, round, seed, filename),
+		Kind:      notify.Violation,
+		Impl:      impl,
+		Round:     round,
+		Seed:      seed,
+		Artifacts: artifacts,
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// An InvariantProbe checks a domain-specific property of the live map at a
+// quiescent point, after every worker of the round has stopped and before the
+// history is checked. history is the round's recorded operations.
+type InvariantProbe func(m mapimpl.MapUnderTest, history []porcupine.Operation) error
+
+type namedProbe struct {
+	name  string
+	probe InvariantProbe
+}
+
+var probes []namedProbe
+
+// RegisterProbe adds p to the probes run after every linearizability round.
+// Call it from an init function.
+func RegisterProbe(name string, p InvariantProbe) {
+	probes = append(probes, namedProbe{name: name, probe: p})
+}
+
+// InvariantViolation is reported separately from a linearizability
+// violation: the history may be legal while the map's state is not.
+type InvariantViolation struct {
+	Probe string
+	Err   error
+}
+
+func (v *InvariantViolation) Error() string {
+	return fmt.Sprintf("invariant %q violated: %v", v.Probe, v.Err)
+}
+
+func (v *InvariantViolation) Unwrap() error { return v.Err }
+
+// runProbes runs every registered probe and returns the first failure as an
+// *InvariantViolation.
+func runProbes(m mapimpl.MapUnderTest, history []porcupine.Operation) error {
+	for _, p := range probes {
+		if err := p.probe(m, history); err != nil {
+			return &InvariantViolation{Probe: p.name, Err: err}
+		}
+	}
+	return nil
+}
+
+func init() {
+	// The workload only ever touches the keys of its own operations.
+	RegisterProbe("keyspace", func(m mapimpl.MapUnderTest, history []porcupine.Operation) error {
+		keys := historyKeys(history)
+		var err error
+		m.Range(func(key, _ any) bool {
+			if k, ok := key.(string); !ok || !keys[k] {
+				err = fmt.Errorf("unexpected key %v", key)
+				return false
+			}
+			return true
+		})
+		return err
+	})
+	// Once quiescent, Range and Load must agree on the map's contents.
+	RegisterProbe("range-matches-load", func(m mapimpl.MapUnderTest, history []porcupine.Operation) error {
+		ranged := make(map[string]any)
+		m.Range(func(key, value any) bool {
+			if k, ok := key.(string); ok {
+				ranged[k] = value
+			}
+			return true
+		})
+		for k := range historyKeys(history) {
+			rangedVal, rangeOK := ranged[k]
+			loaded, loadOK := m.Load(k)
+			if rangeOK != loadOK || rangedVal != loaded {
+				return fmt.Errorf("key %s: Range saw (%v, %v), Load saw (%v, %v)", k, rangedVal, rangeOK, loaded, loadOK)
+			}
+		}
+		return nil
+	})
+}
+
+// historyKeys returns the keys the operations of history touch.
+func historyKeys(history []porcupine.Operation) map[string]bool {
+	keys := make(map[string]bool)
+	for _, op := range history {
+		if in, ok := op.Input.(model.Input); ok {
+			keys[in.Key] = true
+		}
+	}
+	return keys
+}
+
+func TestRunProbesReportsInvariantViolation(t *testing.T) {
+	saved := probes
+	t.Cleanup(func() { probes = saved })
+
+	errBroken := errors.New("broken")
+	RegisterProbe("always-fails", func(mapimpl.MapUnderTest, []porcupine.Operation) error { return errBroken })
+
+	impl, _ := mapimpl.Lookup("sync.Map")
+	err := runProbes(impl.New(), nil)
+
+	var iv *InvariantViolation
+	if !errors.As(err, &iv) || iv.Probe != "always-fails" || !errors.Is(err, errBroken) {
+		t.Fatalf("runProbes = %v, want InvariantViolation from always-fails", err)
+	}
+}
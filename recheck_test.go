@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// The recheck checks the rounds of a saved history file again, without
+// running the workload: a stream recorded with -record or the JSON of a
+// single history. -recheck-model picks the model, of model.Names, and
+// -check-timeout the checker's timeout per round, none by default.
+// -recheck-skew widens every operation by the skew of the clocks that
+// timed a history recorded elsewhere, see workload.Widen:
+//
+//	go test -run TestRecheck -v -recheck=soak/TestSyncMap.hist -recheck-model=snapshot
+//	go test -run TestRecheck -v -recheck=store/history.edn -recheck-skew=2ms
+var (
+	recheckFlag  = flag.String("recheck", "", "saved history file TestRecheck checks again, recorded with -record or saved as JSON")
+	recheckModel = flag.String("recheck-model", "map", "model TestRecheck checks with: "+strings.Join(model.Names(), ", "))
+	recheckSkew  = flag.Duration("recheck-skew", 0, "skew of the clocks of the -recheck history, by which TestRecheck widens every operation")
+)
+
+func TestRecheck(t *testing.T) {
+	if *recheckFlag == "" {
+		t.Skip("no -recheck given")
+	}
+	m, err := model.Lookup(*recheckModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := workload.LoadRecords(*recheckFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		timeout  = *checkTimeout
+		unknown  int
+		checking time.Duration
+	)
+	for _, r := range records {
+		if r.History, err = workload.Widen(r.History, *recheckSkew); err != nil {
+			t.Fatal(err)
+		}
+		res := r.Recheck(m, timeout)
+		checking += res.Checking
+		if testing.Verbose() {
+			t.Logf("round %d (seed %d): %d operations, %s in %v", res.Round, res.Seed, res.Ops, res.Result, res.Checking.Round(time.Millisecond))
+		}
+		if *statsFlag {
+			st := r.History.Stats()
+			t.Logf("round %d: %s, durations:\n%s", r.Round, st, st.Histogram(40))
+		}
+		switch res.Result {
+		case porcupine.Unknown:
+			unknown++
+		case porcupine.Illegal:
+			filename := saveViolation(t, "recheck_"+*recheckModel, res.Violation)
+			t.Errorf("%v, saved to %s", res.Violation, filename)
+		}
+	}
+	t.Logf("%d rounds checked with the %s model in %v, %d timed out", len(records), *recheckModel, checking.Round(time.Millisecond), unknown)
+}
@@ -0,0 +1,97 @@
+package recorder
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	_ "unsafe" // for go:linkname
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/asm"
+)
+
+// The clocks timestamping the operations of a history. Each operation is
+// timed twice, so the cost of reading the clock widens every operation and
+// narrows the window in which operations overlap.
+const (
+	// ClockTime is time.Since the last reset of the clock, the default.
+	ClockTime = "time"
+	// ClockNanotime reads the runtime's monotonic clock directly, without
+	// time.Now's wall clock reading and time.Time arithmetic.
+	ClockNanotime = "nanotime"
+	// ClockTSC reads the CPU's counter, see asm.Ticks, and converts it to
+	// nanoseconds with a rate calibrated against nanotime once per process.
+	// It assumes the counter is synchronized across cores, as an invariant
+	// TSC and the arm64 generic timer are.
+	ClockTSC = "tsc"
+)
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+// ValidateClock returns an error if kind is not a clock of this
+// architecture. The empty kind is ClockTime.
+func ValidateClock(kind string) error {
+	switch kind {
+	case "", ClockTime, ClockNanotime:
+		return nil
+	case ClockTSC:
+		if !asm.HasTicks {
+			return fmt.Errorf("recorder: clock %s is not available on this architecture", kind)
+		}
+		return nil
+	}
+	return fmt.Errorf("recorder: unknown clock %q, want %s, %s or %s", kind, ClockTime, ClockNanotime, ClockTSC)
+}
+
+// A Clock counts nanoseconds since its last reset, such as the start of a
+// round.
+type Clock struct {
+	kind  string
+	start time.Time
+	base  int64
+	// nsPerTick converts ticks to nanoseconds for ClockTSC.
+	nsPerTick float64
+}
+
+// NewClock returns a clock of a kind that passes ValidateClock, reset to
+// now.
+func NewClock(kind string) *Clock {
+	c := &Clock{kind: kind}
+	if kind == ClockTSC {
+		c.nsPerTick = tickRate()
+	}
+	c.Reset()
+	return c
+}
+
+// Reset makes Now count from this instant.
+func (c *Clock) Reset() {
+	switch c.kind {
+	case ClockNanotime:
+		c.base = nanotime()
+	case ClockTSC:
+		c.base = int64(asm.Ticks())
+	default:
+		c.start = time.Now()
+	}
+}
+
+// Now returns the nanoseconds since the last reset.
+func (c *Clock) Now() int64 {
+	switch c.kind {
+	case ClockNanotime:
+		return nanotime() - c.base
+	case ClockTSC:
+		return int64(float64(int64(asm.Ticks())-c.base) * c.nsPerTick)
+	}
+	return time.Since(c.start).Nanoseconds()
+}
+
+// tickRate measures the nanoseconds per tick of asm.Ticks over 20ms of
+// nanotime.
+var tickRate = sync.OnceValue(func() float64 {
+	t0, n0 := asm.Ticks(), nanotime()
+	time.Sleep(20 * time.Millisecond)
+	t1, n1 := asm.Ticks(), nanotime()
+	return float64(n1-n0) / float64(t1-t0)
+})
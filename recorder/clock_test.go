@@ -0,0 +1,34 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/asm"
+)
+
+func TestClocks(t *testing.T) {
+	for _, kind := range []string{ClockTime, ClockNanotime, ClockTSC} {
+		t.Run(kind, func(t *testing.T) {
+			if kind == ClockTSC && !asm.HasTicks {
+				t.Skip("no counter on this architecture")
+			}
+			if err := ValidateClock(kind); err != nil {
+				t.Fatal(err)
+			}
+			c := NewClock(kind)
+			begin := time.Now()
+			first := c.Now()
+			time.Sleep(10 * time.Millisecond)
+			elapsed := c.Now() - first
+			want := time.Since(begin).Nanoseconds()
+			// Generous, the calibration and the sleep are not exact.
+			if first < 0 || elapsed < want/2 || elapsed > 2*want {
+				t.Errorf("clock counted %dns from %dns across %dns", elapsed, first, want)
+			}
+		})
+	}
+	if ValidateClock("sundial") == nil {
+		t.Error("ValidateClock accepted an unknown clock")
+	}
+}
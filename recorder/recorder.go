@@ -0,0 +1,182 @@
+// Package recorder records the histories of concurrent clients for
+// porcupine, the way the workloads of this module record their rounds, so
+// a system of another project can produce histories to check as well.
+//
+// Every client records into a buffer of its own, merged only by History
+// once they are done: a shared buffer would synchronize the clients
+// between their operations and skew the timestamps around it. Recording an
+// operation takes no lock and, within the capacity of a client's buffer,
+// allocates nothing. Its call is timestamped before the fence and its
+// return after the second one, so the fences order the operation between
+// its timestamps:
+//
+//	rec := recorder.New(recorder.NewClock(recorder.ClockNanotime), nil)
+//	c := rec.Client(id, ops)
+//	op := c.Begin(input)
+//	output := run(input)
+//	op.End(output)
+//	...
+//	history, annotations := rec.History()
+package recorder
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// A Recorder collects the operations of its clients in a history.
+type Recorder struct {
+	clock *Clock
+	fence func()
+
+	mu      sync.Mutex
+	clients []*Client
+}
+
+// New returns a recorder timestamping operations with clock. fence, if
+// not nil, is called between each timestamp and the operation, e.g. a
+// memory barrier.
+func New(clock *Clock, fence func()) *Recorder {
+	if fence == nil {
+		fence = func() {}
+	}
+	return &Recorder{clock: clock, fence: fence}
+}
+
+// Clock returns the clock of r, to timestamp annotations in the same time
+// base as the operations.
+func (r *Recorder) Clock() *Clock { return r.clock }
+
+// Client returns a new client of r with the id, of a porcupine client, and
+// room for capacity operations before its buffer grows. A client records
+// the operations of one goroutine at a time.
+func (r *Recorder) Client(id, capacity int) *Client {
+	c := &Client{r: r, id: id, ops: make([]porcupine.Operation, 0, capacity)}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients = append(r.clients, c)
+	return c
+}
+
+// A Client records the operations of one client of a history.
+type Client struct {
+	r           *Recorder
+	id          int
+	ops         []porcupine.Operation
+	abandoned   []porcupine.Operation
+	annotations []porcupine.Annotation
+}
+
+// An Op is an operation of a client between its call and return.
+type Op struct {
+	c     *Client
+	input any
+	call  int64
+}
+
+// Begin timestamps the call of an operation with input and returns its
+// handle, whose End records the operation once it returns.
+func (c *Client) Begin(input any) Op {
+	call := c.r.clock.Now()
+	c.r.fence()
+	return Op{c: c, input: input, call: call}
+}
+
+// End timestamps the return of op and records it with output.
+func (op Op) End(output any) {
+	op.c.r.fence()
+	ret := op.c.r.clock.Now()
+	op.c.ops = append(op.c.ops, porcupine.Operation{
+		ClientId: op.c.id,
+		Input:    op.input,
+		Call:     op.call,
+		Output:   output,
+		Return:   ret,
+	})
+}
+
+// Record records an operation the caller timestamped, such as one of a
+// setup before the history that is not timed.
+func (c *Client) Record(op porcupine.Operation) {
+	op.ClientId = c.id
+	c.ops = append(c.ops, op)
+}
+
+// Len returns the number of operations in the buffer of c.
+func (c *Client) Len() int { return len(c.ops) }
+
+// Flush passes the operations in the buffer of c to write, e.g. to write
+// them out as they complete rather than keep them until History, and
+// empties the buffer if write succeeds. The buffer is reused: write must
+// not keep the operations.
+func (c *Client) Flush(write func([]porcupine.Operation) error) error {
+	if err := write(c.ops); err != nil {
+		return err
+	}
+	clear(c.ops)
+	c.ops = c.ops[:0]
+	return nil
+}
+
+// Abandon records an operation with input that is called now but that
+// the client does not wait for, like one of a crashed client. History
+// records it with output, which should tell the model its result is
+// unknown, returning after every other operation, by a client of its own:
+// it may take effect at any point after its call.
+func (c *Client) Abandon(input, output any) {
+	c.abandoned = append(c.abandoned, porcupine.Operation{
+		Input:  input,
+		Call:   c.r.clock.Now(),
+		Output: output,
+	})
+}
+
+// Annotate records an annotation of the client, such as of an event
+// between its operations.
+func (c *Client) Annotate(a porcupine.Annotation) {
+	a.ClientId = c.id
+	c.annotations = append(c.annotations, a)
+}
+
+// History returns the operations and annotations of every client, in
+// order of their ids, once none of them records any more. The abandoned
+// operations return now, each by a new client numbered after the others,
+// with an annotation of its own.
+func (r *Recorder) History() ([]porcupine.Operation, []porcupine.Annotation) {
+	end := r.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := slices.Clone(r.clients)
+	slices.SortStableFunc(clients, func(a, b *Client) int { return a.id - b.id })
+	next := 0
+	for _, c := range clients {
+		next = max(next, c.id+1)
+	}
+
+	var (
+		ops         []porcupine.Operation
+		annotations []porcupine.Annotation
+	)
+	for _, c := range clients {
+		ops = append(ops, c.ops...)
+		annotations = append(annotations, c.annotations...)
+	}
+	for _, c := range clients {
+		for _, op := range c.abandoned {
+			op.ClientId, op.Return = next, end
+			ops = append(ops, op)
+			annotations = append(annotations, porcupine.Annotation{
+				ClientId:    next,
+				Start:       op.Call,
+				End:         end,
+				Description: "pending",
+				Details:     fmt.Sprintf("left outstanding by client %d", c.id),
+			})
+			next++
+		}
+	}
+	return ops, annotations
+}
@@ -0,0 +1,107 @@
+package recorder
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestRecorder(t *testing.T) {
+	const clients, ops = 4, 100
+	var fences atomic.Int64
+	rec := New(NewClock(ClockNanotime), func() { fences.Add(1) })
+	var (
+		m  sync.Map
+		wg sync.WaitGroup
+	)
+	for id := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := rec.Client(id, ops)
+			for i := range ops {
+				in := model.Input{Op: model.Store, Key: "k", Val: id*ops + i}
+				if i == ops-1 {
+					// Never run, which the pending output allows.
+					c.Abandon(model.Input{Op: model.Store, Key: "k", Val: -1}, model.Output{Pending: true})
+					continue
+				}
+				op := c.Begin(in)
+				m.Store(in.Key, in.Val)
+				op.End(model.Output{})
+				op = c.Begin(model.Input{Op: model.Load, Key: "k"})
+				v, ok := m.Load("k")
+				op.End(model.Output{Found: ok, Val: v.(int)})
+			}
+			c.Annotate(porcupine.Annotation{Description: "done", Start: rec.Clock().Now()})
+		}()
+	}
+	wg.Wait()
+	history, annotations := rec.History()
+
+	if want := 2 * clients * (ops - 1); int(fences.Load()) != 2*want {
+		t.Errorf("%d fences for %d operations", fences.Load(), want)
+	}
+	if len(history) != 2*clients*(ops-1)+clients {
+		t.Fatalf("%d operations recorded", len(history))
+	}
+	pending := make(map[int]bool)
+	for i, op := range history {
+		if op.Return < op.Call {
+			t.Fatalf("operation %d returns before its call", i)
+		}
+		if op.Output.(model.Output).Pending {
+			if op.ClientId < clients || pending[op.ClientId] {
+				t.Errorf("pending operation %d by client %d", i, op.ClientId)
+			}
+			pending[op.ClientId] = true
+		} else if i > 0 && op.ClientId < history[i-1].ClientId {
+			t.Errorf("operation %d by client %d after one of client %d", i, op.ClientId, history[i-1].ClientId)
+		}
+	}
+	if len(annotations) != 2*clients {
+		t.Errorf("%d annotations, want one per client and pending operation", len(annotations))
+	}
+	if !porcupine.CheckOperations(model.Model, history) {
+		t.Error("history is not linearizable")
+	}
+}
+
+func TestRecorderAllocs(t *testing.T) {
+	rec := New(NewClock(ClockTime), nil)
+	c := rec.Client(0, 1000)
+	var (
+		in  any = model.Input{Op: model.Load, Key: "k"}
+		out any = model.Output{}
+	)
+	if n := testing.AllocsPerRun(100, func() { c.Begin(in).End(out) }); n != 0 {
+		t.Errorf("%v allocations per operation", n)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	rec := New(NewClock(ClockTime), nil)
+	c := rec.Client(0, 2)
+	for i := range 3 {
+		c.Begin(model.Input{Op: model.Store, Key: "k", Val: i}).End(model.Output{})
+	}
+	if err := c.Flush(func([]porcupine.Operation) error { return errors.New("disk full") }); err == nil || c.Len() != 3 {
+		t.Fatalf("failed Flush = %v, left %d operations", err, c.Len())
+	}
+	var flushed []porcupine.Operation
+	if err := c.Flush(func(ops []porcupine.Operation) error {
+		flushed = append(flushed, ops...)
+		return nil
+	}); err != nil || c.Len() != 0 || len(flushed) != 3 {
+		t.Fatalf("Flush = %v, flushed %d and left %d operations", err, len(flushed), c.Len())
+	}
+	c.Begin(model.Input{Op: model.Load, Key: "k"}).End(model.Output{Found: true, Val: 2})
+	if ops, _ := rec.History(); len(ops) != 1 || flushed[0].Input.(model.Input).Val != 0 {
+		t.Errorf("History after Flush = %v, flushed %v", ops, flushed)
+	}
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// The layout of latencyChart, in pixels.
+const (
+	chartWidth  = 560
+	chartHeight = 240
+	chartLeft   = 64
+	chartRight  = 110
+	chartTop    = 12
+	chartBottom = 28
+)
+
+// chartNines is the most nines of the percentiles latencyChart plots, up
+// to 99.999%.
+const chartNines = 5
+
+// chartColors are the colors of the lines of a chart, in turn.
+var chartColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#17becf"}
+
+// A chartLine is a line of latencyChart.
+type chartLine struct {
+	Label string
+	H     *workload.Histogram
+}
+
+// latencyChart draws the percentile distributions of the histograms of
+// lines as an inline SVG chart, the way HdrHistogram plots them: the
+// percentile on a scale of nines across, so that 90%, 99% and 99.9% are
+// as far apart, and the latency on a logarithmic scale up. A line stops
+// at the percentiles its count cannot tell apart from the maximum.
+func latencyChart(lines []chartLine) template.HTML {
+	// The range of the latencies and of the nines.
+	lo, hi, nines := math.Inf(1), math.Inf(-1), 1.0
+	for _, l := range lines {
+		if l.H.Count() == 0 {
+			continue
+		}
+		lo = min(lo, math.Log10(float64(l.H.Quantile(0))))
+		hi = max(hi, math.Log10(float64(l.H.Quantile(1))))
+		nines = max(nines, min(math.Log10(float64(l.H.Count())), chartNines))
+	}
+	if math.IsInf(lo, 0) {
+		return ""
+	}
+	lo, hi = math.Floor(lo), math.Max(math.Ceil(hi), math.Floor(lo)+1)
+	nines = math.Ceil(nines)
+
+	plotW, plotH := chartWidth-chartLeft-chartRight, chartHeight-chartTop-chartBottom
+	x := func(n float64) float64 { return chartLeft + n/nines*float64(plotW) }
+	y := func(d workload.Duration) float64 {
+		return chartTop + (hi-math.Log10(float64(max(d, 1))))/(hi-lo)*float64(plotH)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %[1]d %[2]d" font-family="sans-serif" font-size="11">`+"\n", chartWidth, chartHeight)
+	for n := 0; n <= int(nines); n++ {
+		label := "0%"
+		if n > 0 {
+			label = fmt.Sprintf("%.*f%%", max(n-2, 0), 100*(1-math.Pow(10, -float64(n))))
+		}
+		fmt.Fprintf(&b, `<line x1="%.1[1]f" y1="%[2]d" x2="%.1[1]f" y2="%[3]d" stroke="#ddd"/><text x="%.1[1]f" y="%[4]d" text-anchor="middle">%[5]s</text>`+"\n",
+			x(float64(n)), chartTop, chartTop+plotH, chartHeight-10, label)
+	}
+	for e := lo; e <= hi; e++ {
+		d := workload.Duration(math.Pow(10, e))
+		fmt.Fprintf(&b, `<line x1="%[1]d" y1="%.1[2]f" x2="%[3]d" y2="%.1[2]f" stroke="#ddd"/><text x="%[4]d" y="%.1[2]f" dy="4" text-anchor="end">%[5]s</text>`+"\n",
+			chartLeft, y(d), chartLeft+plotW, chartLeft-6, time.Duration(d))
+	}
+	for i, l := range lines {
+		if l.H.Count() == 0 {
+			continue
+		}
+		color := chartColors[i%len(chartColors)]
+		// Twenty points a nine, up to those the count can tell.
+		last := min(math.Log10(float64(l.H.Count())), nines)
+		var points []string
+		for n := 0.0; ; n += 0.05 {
+			n = min(n, last)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(n), y(l.H.Quantile(1-math.Pow(10, -n)))))
+			if n == last {
+				break
+			}
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"><title>%s: %d operations</title></polyline>`+"\n",
+			color, strings.Join(points, " "), template.HTMLEscapeString(l.Label), l.H.Count())
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="12" height="3" fill="%s"/><text x="%d" y="%d">%s</text>`+"\n",
+			chartLeft+plotW+10, chartTop+i*16+4, color, chartLeft+plotW+26, chartTop+i*16+8, template.HTMLEscapeString(l.Label))
+	}
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
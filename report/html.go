@@ -0,0 +1,271 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// page is what the template of WriteHTML renders.
+type page struct {
+	*Report
+	Workloads []workloadView
+	// Impls are the latency charts of each operation, a line per
+	// implementation, across its workloads.
+	Impls   []chartView
+	Litmus  []litmusView
+	Elapsed time.Duration
+	// Passed and Failed count the workloads and litmus runs.
+	Passed, Failed int
+}
+
+type workloadView struct {
+	*Workload
+	// ID is the anchor of its section, as a sweep repeats the names.
+	ID        string
+	Link      string
+	Rounds    int
+	Elapsed   time.Duration
+	Checking  time.Duration
+	PerRound  time.Duration
+	OpsPerSec float64
+	Overlap   float64
+	Most      int
+	Latency   []latencyView
+	Durations []barView
+	Chart     template.HTML
+}
+
+type chartView struct {
+	Op    string
+	Chart template.HTML
+}
+
+type latencyView struct {
+	Op                 string
+	Count              int
+	P50, P90, P99, Max time.Duration
+}
+
+type litmusView struct {
+	*Litmus
+	Elapsed  time.Duration
+	Outcomes []barView
+}
+
+// barView is a row of a histogram, Width the percentage of the widest.
+type barView struct {
+	Label     string
+	Count     int
+	Percent   float64
+	Width     int
+	Forbidden bool
+}
+
+// WriteHTML writes the report as a self-contained HTML page. Links to the
+// visualizations of violations are relative to dir, the directory the
+// page is written to.
+func (r *Report) WriteHTML(w io.Writer, dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := page{Report: r, Elapsed: r.End.Sub(r.Start).Round(time.Millisecond)}
+	// The histograms of every operation by implementation, in the order
+	// they first ran.
+	var (
+		impls  []string
+		byImpl = make(map[string]map[string]*workload.Histogram)
+	)
+	for i, wl := range r.workloads {
+		wl.mu.Lock()
+		v := workloadView{Workload: wl, ID: fmt.Sprintf("w%d", i), Rounds: wl.rounds, Elapsed: time.Duration(wl.Stats.Elapsed).Round(time.Millisecond), Checking: time.Duration(wl.Stats.Checking).Round(time.Millisecond), Most: wl.most}
+		if wl.Violation != "" {
+			v.Link = link(dir, wl.Violation)
+		}
+		if wl.rounds > 0 {
+			v.OpsPerSec, v.Overlap = wl.opsPerSec/float64(wl.rounds), wl.overlap/float64(wl.rounds)
+		}
+		if wl.Stats.Rounds > 0 {
+			v.PerRound = time.Duration(wl.Stats.Checking) / time.Duration(wl.Stats.Rounds)
+		}
+		if byImpl[wl.Impl] == nil {
+			impls = append(impls, wl.Impl)
+			byImpl[wl.Impl] = make(map[string]*workload.Histogram)
+		}
+		var lines []chartLine
+		for _, op := range model.Ops() {
+			if l, ok := wl.latency[op.String()]; ok {
+				v.Latency = append(v.Latency, latencyView{op.String(), l.Count, time.Duration(l.P50), time.Duration(l.P90), time.Duration(l.P99), time.Duration(l.Max)})
+			}
+			if h, ok := wl.histograms[op.String()]; ok {
+				lines = append(lines, chartLine{op.String(), h})
+				if byImpl[wl.Impl][op.String()] == nil {
+					byImpl[wl.Impl][op.String()] = new(workload.Histogram)
+				}
+				byImpl[wl.Impl][op.String()].Merge(h)
+			}
+		}
+		v.Durations = durationBars(wl.durations)
+		v.Chart = latencyChart(lines)
+		wl.mu.Unlock()
+		p.count(v.Failed)
+		p.Workloads = append(p.Workloads, v)
+	}
+	for _, op := range model.Ops() {
+		var lines []chartLine
+		for _, impl := range impls {
+			if h, ok := byImpl[impl][op.String()]; ok {
+				lines = append(lines, chartLine{impl, h})
+			}
+		}
+		if lines != nil {
+			p.Impls = append(p.Impls, chartView{op.String(), latencyChart(lines)})
+		}
+	}
+	for _, l := range r.litmus {
+		v := litmusView{Litmus: l, Elapsed: l.Result.Elapsed.Round(time.Millisecond)}
+		most := 0
+		for _, oc := range l.Result.Outcomes() {
+			most = max(most, oc.Count)
+		}
+		for _, oc := range l.Result.Outcomes() {
+			v.Outcomes = append(v.Outcomes, bar(oc.Outcome.String(), oc.Count, l.Result.Iterations, most, oc.Forbidden))
+		}
+		p.count(l.Failed)
+		p.Litmus = append(p.Litmus, v)
+	}
+	return pageTemplate.Execute(w, p)
+}
+
+func (p *page) count(failed bool) {
+	if failed {
+		p.Failed++
+	} else {
+		p.Passed++
+	}
+}
+
+// link returns the path of file relative to dir, or file if there is
+// none.
+func link(dir, file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return file
+	}
+	rel, err := filepath.Rel(absDir, abs)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+func durationBars(buckets []workload.Bucket) []barView {
+	most, total := 0, 0
+	for _, b := range buckets {
+		most, total = max(most, b.Count), total+b.Count
+	}
+	var bars []barView
+	for _, b := range buckets {
+		bars = append(bars, bar("< "+time.Duration(b.Below).String(), b.Count, total, most, false))
+	}
+	return bars
+}
+
+func bar(label string, count, total, most int, forbidden bool) barView {
+	b := barView{Label: label, Count: count, Forbidden: forbidden}
+	if total > 0 {
+		b.Percent = 100 * float64(count) / float64(total)
+	}
+	if most > 0 {
+		b.Width = 100 * count / most
+	}
+	return b
+}
+
+var pageTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.4f%%", f) },
+	"float":   func(f float64) string { return fmt.Sprintf("%.2f", f) },
+	"time":    func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #ddd; vertical-align: top; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+.fail { color: #b00; font-weight: bold; }
+.pass { color: #070; }
+.bar { background: #8ab; height: 0.9em; display: inline-block; }
+.bar.forbidden { background: #d66; }
+code { font-size: 0.9em; }
+.chart { display: block; margin: 0.5em 0 1.5em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{time .Start}}, {{.Elapsed}}: <span class="pass">{{.Passed}} passed</span>{{if .Failed}}, <span class="fail">{{.Failed}} failed</span>{{end}}</p>
+
+<h2>Environment</h2>
+<table>
+<tr><th>Go</th><td>{{.Env.Go}} {{.Env.GOOS}}/{{.Env.GOARCH}}</td></tr>
+<tr><th>CPU</th><td>{{if .Env.CPU}}{{.Env.CPU}}, {{end}}{{.Env.NumCPU}} CPUs, GOMAXPROCS {{.Env.GOMAXPROCS}}</td></tr>
+{{if .Env.Host}}<tr><th>Host</th><td>{{.Env.Host}}</td></tr>{{end}}
+{{if .Flags}}<tr><th>Flags</th><td>{{range .Flags}}<code>-{{.}}</code> {{end}}</td></tr>{{end}}
+</table>
+
+{{if .Workloads}}<h2>Workloads</h2>
+<table>
+<tr><th>Test</th><th>Result</th><th>Rounds</th><th>Ops</th><th>Unknown</th><th>Elapsed</th><th>Checking</th><th>Per round</th><th>Ops/s</th><th>Overlap</th><th>GOMAXPROCS</th></tr>
+{{range .Workloads}}<tr>
+<td><a href="#{{.ID}}">{{.Name}}</a></td>
+<td>{{if .Failed}}<span class="fail">FAIL</span>{{if .Link}} <a href="{{.Link}}">violation</a>{{end}}{{else}}<span class="pass">ok</span>{{end}}</td>
+<td class="n">{{.Stats.Rounds}}</td><td class="n">{{.Stats.Ops}}</td><td class="n">{{.Stats.Unknown}}</td>
+<td class="n">{{.Elapsed}}</td><td class="n">{{.Checking}}</td><td class="n">{{.PerRound}}</td>
+<td class="n">{{printf "%.0f" .OpsPerSec}}</td><td class="n">{{float .Overlap}} (max {{.Most}})</td><td class="n">{{.Procs}}</td>
+</tr>
+{{end}}</table>
+{{range .Workloads}}
+<h3 id="{{.ID}}">{{.Name}}</h3>
+<p><code>{{.Config}}</code></p>
+{{if .Err}}<p class="fail">{{.Err}}{{if .Link}}, see <a href="{{.Link}}">{{.Link}}</a>{{end}}</p>{{end}}
+{{if .Latency}}<p>Latencies of {{.Rounds}} rounds, the highest of any round:</p>
+<table>
+<tr><th>Operation</th><th>Count</th><th>p50</th><th>p90</th><th>p99</th><th>Max</th></tr>
+{{range .Latency}}<tr><td>{{.Op}}</td><td class="n">{{.Count}}</td><td class="n">{{.P50}}</td><td class="n">{{.P90}}</td><td class="n">{{.P99}}</td><td class="n">{{.Max}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Duration</th><th>Count</th><th></th><th></th></tr>
+{{range .Durations}}<tr><td>{{.Label}}</td><td class="n">{{.Count}}</td><td class="n">{{percent .Percent}}</td><td><span class="bar" style="width: {{.Width}}px"></span></td></tr>
+{{end}}</table>{{end}}
+{{.Chart}}
+{{end}}{{end}}
+
+{{if .Impls}}<h2>Latency by implementation</h2>
+<p>The latency percentiles of each operation, across the rounds of every workload of an implementation.</p>
+{{range .Impls}}<h3>{{.Op}}</h3>
+{{.Chart}}
+{{end}}{{end}}
+
+{{if .Litmus}}<h2>Litmus tests</h2>
+{{range .Litmus}}
+<h3>{{.Name}} {{if .Failed}}<span class="fail">FAIL</span>{{else}}<span class="pass">ok</span>{{end}}</h3>
+<p>{{.Result.Test}}: {{.Result.Iterations}} iterations in {{.Elapsed}}, GOMAXPROCS {{.Procs}}{{if .Result.Forbidden}}, forbidden outcome {{.Result.Forbidden}} times, first in iteration {{.Result.FirstForbidden}}{{end}}</p>
+<table>
+<tr><th>Outcome</th><th>Count</th><th></th><th></th></tr>
+{{range .Outcomes}}<tr><td>{{.Label}}{{if .Forbidden}} <span class="fail">forbidden</span>{{end}}</td><td class="n">{{.Count}}</td><td class="n">{{percent .Percent}}</td><td><span class="bar{{if .Forbidden}} forbidden{{end}}" style="width: {{.Width}}px"></span></td></tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
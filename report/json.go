@@ -0,0 +1,100 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// resultsVersion is the version of the document of WriteJSON. Fields are
+// only ever added within a version.
+const resultsVersion = 1
+
+type resultsJSON struct {
+	Version   int            `json:"version"`
+	Title     string         `json:"title"`
+	Start     time.Time      `json:"start"`
+	End       time.Time      `json:"end"`
+	Env       Env            `json:"env"`
+	Flags     []string       `json:"flags"`
+	Workloads []workloadJSON `json:"workloads"`
+	Litmus    []litmusJSON   `json:"litmus"`
+}
+
+type workloadJSON struct {
+	Name      string             `json:"name"`
+	Impl      string             `json:"impl"`
+	Config    string             `json:"config"`
+	Procs     int                `json:"gomaxprocs"`
+	Stats     workload.Stats     `json:"stats"`
+	Failed    bool               `json:"failed"`
+	Err       string             `json:"error,omitempty"`
+	Violation string             `json:"violation,omitempty"`
+	Rounds    []workload.Checked `json:"rounds"`
+}
+
+type litmusJSON struct {
+	Name   string         `json:"name"`
+	Procs  int            `json:"gomaxprocs"`
+	Failed bool           `json:"failed"`
+	Result *litmus.Result `json:"result"`
+}
+
+// WriteJSON writes the report to w as a versioned JSON document: the
+// environment and flags of the run, every workload test with its stats,
+// outcome and the outcome of each of its rounds, by round, and every
+// litmus run with its result.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc := resultsJSON{
+		Version:   resultsVersion,
+		Title:     r.Title,
+		Start:     r.Start,
+		End:       r.End,
+		Env:       r.Env,
+		Flags:     r.Flags,
+		Workloads: []workloadJSON{},
+		Litmus:    []litmusJSON{},
+	}
+	for _, wl := range r.workloads {
+		doc.Workloads = append(doc.Workloads, wl.json())
+	}
+	for _, l := range r.litmus {
+		doc.Litmus = append(doc.Litmus, litmusJSON{Name: l.Name, Procs: l.Procs, Failed: l.Failed, Result: l.Result})
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(doc)
+}
+
+func (w *Workload) json() workloadJSON {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return workloadJSON{
+		Name:      w.Name,
+		Impl:      w.Impl,
+		Config:    w.Config,
+		Procs:     w.Procs,
+		Stats:     w.Stats,
+		Failed:    w.Failed,
+		Err:       w.Err,
+		Violation: w.Violation,
+		Rounds:    w.sortedRounds(),
+	}
+}
+
+// sortedRounds returns the outcomes of the rounds by round, w.mu must be
+// held.
+func (w *Workload) sortedRounds() []workload.Checked {
+	rounds := slices.Clone(w.checked)
+	slices.SortFunc(rounds, func(a, b workload.Checked) int { return a.Round - b.Round })
+	if rounds == nil {
+		rounds = []workload.Checked{}
+	}
+	return rounds
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	r := testReport(t, t.TempDir())
+	var b strings.Builder
+	if err := r.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version   int
+		Env       struct{ GOARCH string }
+		Workloads []struct {
+			Name   string
+			Failed bool
+			Rounds []struct {
+				Round  int
+				Seed   uint64
+				Result string
+				Hung   string
+			}
+		}
+		Litmus []struct {
+			Result struct{ Iterations int }
+		}
+	}
+	if err := json.Unmarshal([]byte(b.String()), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != resultsVersion || doc.Env.GOARCH != r.Env.GOARCH || len(doc.Workloads) != 2 || len(doc.Litmus) != 1 {
+		t.Fatalf("document:\n%s", b.String())
+	}
+	// The rounds by round, however they were checked.
+	if rounds := doc.Workloads[0].Rounds; len(rounds) != 2 || rounds[0].Round != 0 || rounds[0].Seed != 7 || rounds[0].Result != "Ok" {
+		t.Errorf("rounds of %s: %+v", doc.Workloads[0].Name, rounds)
+	}
+	if w := doc.Workloads[1]; !w.Failed || w.Rounds[1].Hung != "check" || w.Rounds[3].Result != "Illegal" {
+		t.Errorf("failed workload: %+v", w)
+	}
+	if doc.Litmus[0].Result.Iterations != 4 {
+		t.Errorf("litmus run: %+v", doc.Litmus[0])
+	}
+}
@@ -0,0 +1,164 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report to w as JUnit XML, for test management
+// systems and CI dashboards: a test suite per workload test, with a test
+// case for the test itself and one per round, and one for the litmus
+// runs, with a test case each. A round that is not linearizable fails, one
+// that hung is an error and one the checker timed out on is skipped; the
+// environment and flags of the run are the properties of every suite.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	props := []junitProperty{
+		{"go", r.Env.Go}, {"goos", r.Env.GOOS}, {"goarch", r.Env.GOARCH},
+		{"cpu", r.Env.CPU}, {"num_cpu", fmt.Sprint(r.Env.NumCPU)}, {"host", r.Env.Host},
+	}
+	for _, f := range r.Flags {
+		props = append(props, junitProperty{"flag", f})
+	}
+	doc := junitSuites{Name: r.Title, Time: seconds(r.End.Sub(r.Start))}
+	for _, wl := range r.workloads {
+		doc.add(wl.junit(props, r.Start))
+	}
+	if len(r.litmus) > 0 {
+		s := junitSuite{Name: "litmus", Timestamp: r.Start.Format(time.RFC3339), Properties: props}
+		var total time.Duration
+		for _, l := range r.litmus {
+			c := junitCase{Name: l.Name, Classname: "litmus", Time: seconds(l.Result.Elapsed), SystemOut: l.Result.Histogram()}
+			if l.Failed {
+				c.Failure = &junitMessage{Message: fmt.Sprintf("forbidden outcome %d times in %d iterations", l.Result.Forbidden, l.Result.Iterations)}
+			}
+			total += l.Result.Elapsed
+			s.addCase(c)
+		}
+		s.Time = seconds(total)
+		doc.add(s)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (w *Workload) junit(props []junitProperty, start time.Time) junitSuite {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := junitSuite{
+		Name:       w.Name,
+		Time:       seconds(time.Duration(w.Stats.Elapsed)),
+		Timestamp:  start.Format(time.RFC3339),
+		Properties: append([]junitProperty{{"config", w.Config}, {"gomaxprocs", fmt.Sprint(w.Procs)}}, props...),
+	}
+	test := junitCase{Name: w.Name, Classname: w.Name, Time: s.Time, SystemOut: w.Stats.String()}
+	if w.Failed {
+		msg := w.Err
+		if msg == "" {
+			msg = "failed"
+		}
+		test.Failure = &junitMessage{Message: msg}
+		if w.Violation != "" {
+			test.Failure.Text = "visualized in " + w.Violation
+		}
+	}
+	s.addCase(test)
+	for _, c := range w.sortedRounds() {
+		rc := junitCase{Name: fmt.Sprintf("round %d", c.Round), Classname: w.Name, Time: seconds(time.Duration(c.Checking)),
+			SystemOut: fmt.Sprintf("seed %d, %d operations", c.Seed, c.Ops)}
+		switch {
+		case c.Hung != "":
+			rc.Error = &junitMessage{Message: c.Hung + " hung"}
+		case c.Result == porcupine.Illegal && c.Key != "":
+			rc.Failure = &junitMessage{Message: "history of key " + c.Key + " is not linearizable"}
+		case c.Result == porcupine.Illegal:
+			rc.Failure = &junitMessage{Message: "history is not linearizable"}
+		case c.Result == porcupine.Unknown:
+			rc.Skipped = &junitMessage{Message: "the checker timed out"}
+		}
+		s.addCase(rc)
+	}
+	return s
+}
+
+func (s *junitSuite) addCase(c junitCase) {
+	s.Tests++
+	switch {
+	case c.Failure != nil:
+		s.Failures++
+	case c.Error != nil:
+		s.Errors++
+	case c.Skipped != nil:
+		s.Skipped++
+	}
+	s.Cases = append(s.Cases, c)
+}
+
+func (d *junitSuites) add(s junitSuite) {
+	d.Tests += s.Tests
+	d.Failures += s.Failures
+	d.Errors += s.Errors
+	d.Skipped += s.Skipped
+	d.Suites = append(d.Suites, s)
+}
+
+// seconds formats d in seconds, as JUnit times are.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,40 @@
+package report
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	r := testReport(t, t.TempDir())
+	var b strings.Builder
+	if err := r.WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal([]byte(b.String()), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, b.String())
+	}
+	// Each workload test and a round each, and the litmus run.
+	if doc.Tests != 9 || doc.Failures != 2 || doc.Errors != 1 || doc.Skipped != 1 || len(doc.Suites) != 3 {
+		t.Fatalf("%d tests, %d failures, %d errors, %d skipped in %d suites; want 9, 2, 1, 1 in 3:\n%s",
+			doc.Tests, doc.Failures, doc.Errors, doc.Skipped, len(doc.Suites), b.String())
+	}
+	if c := doc.Suites[0].Cases; c[1].Name != "round 0" || !strings.Contains(c[1].SystemOut, "seed 7") {
+		t.Errorf("first round of TestSyncMap: %+v", c[1])
+	}
+	failed := doc.Suites[1]
+	if f := failed.Cases[0].Failure; f == nil || !strings.Contains(f.Text, "syncmap_violation_3_150405.html") {
+		t.Errorf("failure of TestSyncMapOf: %+v", f)
+	}
+	if f := failed.Cases[4].Failure; f == nil || f.Message != "history is not linearizable" {
+		t.Errorf("failure of round 3: %+v", f)
+	}
+	if doc.Suites[2].Name != "litmus" || !strings.Contains(doc.Suites[2].Cases[0].SystemOut, "MP+Map") {
+		t.Errorf("litmus suite: %+v", doc.Suites[2])
+	}
+	if !strings.Contains(b.String(), `<property name="flag" value="rounds=2">`) {
+		t.Errorf("flags are not properties:\n%s", b.String())
+	}
+}
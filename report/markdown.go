@@ -0,0 +1,100 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteMarkdown writes a summary of the report to w as GitHub-flavored
+// Markdown, compact enough to paste into an issue: the environment and
+// flags of the run, a table of the workload tests with their configuration,
+// rounds and violations, and one of the litmus runs with the frequency of
+// each outcome. It leaves out the host name, which an issue has no use
+// for.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	passed, failed := 0, 0
+	for _, wl := range r.workloads {
+		if wl.Failed {
+			failed++
+		} else {
+			passed++
+		}
+	}
+	for _, l := range r.litmus {
+		if l.Failed {
+			failed++
+		} else {
+			passed++
+		}
+	}
+	fmt.Fprintf(&b, "**%s**: %d passed, %d failed in %v\n\n", r.Title, passed, failed, r.End.Sub(r.Start).Round(time.Second))
+
+	cpu := fmt.Sprintf("%d CPUs", r.Env.NumCPU)
+	if r.Env.CPU != "" {
+		cpu = r.Env.CPU + ", " + cpu
+	}
+	b.WriteString("| Go | Platform | CPU | GOMAXPROCS | Flags |\n|---|---|---|---|---|\n")
+	var flags []string
+	for _, f := range r.Flags {
+		flags = append(flags, code("-"+f))
+	}
+	fmt.Fprintf(&b, "| %s | %s/%s | %s | %d | %s |\n", r.Env.Go, r.Env.GOOS, r.Env.GOARCH, cell(cpu), r.Env.GOMAXPROCS, strings.Join(flags, " "))
+
+	if len(r.workloads) > 0 {
+		b.WriteString("\n| Test | Result | Rounds | Ops | Violations | Unknown | Hung | GOMAXPROCS | Config |\n|---|---|--:|--:|--:|--:|--:|--:|---|\n")
+		for _, wl := range r.workloads {
+			wl.mu.Lock()
+			result := "ok"
+			if wl.Failed {
+				result = "**FAIL**"
+				if wl.Err != "" {
+					result += ": " + cell(wl.Err)
+				}
+			}
+			st := wl.Stats
+			fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %d | %d | %d | %s |\n",
+				cell(wl.Name), result, st.Rounds, st.Ops, st.Violations, st.Unknown, st.Hung, wl.Procs, code(wl.Config))
+			wl.mu.Unlock()
+		}
+	}
+
+	if len(r.litmus) > 0 {
+		b.WriteString("\n| Litmus | Test | Result | Iterations | Outcomes |\n|---|---|---|--:|---|\n")
+		for _, l := range r.litmus {
+			result := "ok"
+			if l.Failed {
+				result = "**FAIL**"
+			}
+			var outcomes []string
+			for _, oc := range l.Result.Outcomes() {
+				o := fmt.Sprintf("%s %.4f%%", code(oc.Outcome.String()), 100*float64(oc.Count)/float64(max(l.Result.Iterations, 1)))
+				if oc.Forbidden {
+					o = "**" + o + " forbidden**"
+				}
+				outcomes = append(outcomes, o)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %d | %s |\n",
+				cell(l.Name), cell(l.Result.Test), result, l.Result.Iterations, strings.Join(outcomes, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cell escapes s for a cell of a Markdown table.
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "*", `\*`, "_", `\_`, "`", "\\`").Replace(s)
+}
+
+// code returns s as a code span in a cell of a Markdown table.
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.NewReplacer("|", `\|`, "\n", " ", "`", "'").Replace(s) + "`"
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	r := testReport(t, t.TempDir())
+	r.Env.Host = "secret-host"
+	var b strings.Builder
+	if err := r.WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	md := b.String()
+	for _, want := range []string{
+		"**run <1>**: 2 passed, 1 failed in 1m0s",
+		"| " + r.Env.Go + " | " + r.Env.GOOS + "/" + r.Env.GOARCH + " |", "`-rounds=2`",
+		"| TestSyncMap | ok | 2 | 4 | 0 | 0 | 0 |", "`impl=sync.Map rounds=2` |",
+		"| TestSyncMapOf | **FAIL**: " + cell("file already closed") + " | 4 |",
+		"| TestMessagePassing/sync.Map | MP+Map | ok | 4 | `{0,0}` 75.0000%, `{1,1}` 25.0000% |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("summary lacks %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, r.Env.Host) {
+		t.Errorf("summary has the host name:\n%s", md)
+	}
+	// Every row of a table has as many cells as its header.
+	cols := 0
+	for _, line := range strings.Split(md, "\n") {
+		if !strings.HasPrefix(line, "|") {
+			cols = 0
+			continue
+		}
+		n := strings.Count(strings.ReplaceAll(line, `\|`, ""), "|")
+		if cols == 0 {
+			cols = n
+		} else if n != cols {
+			t.Errorf("row %q has %d cells, the table %d", line, n-1, cols-1)
+		}
+	}
+}
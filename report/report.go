@@ -0,0 +1,223 @@
+// Package report collects the outcome of a whole run, every workload test
+// and litmus test in it, and writes it as one HTML page: the environment
+// and the flags it ran with, the configuration, rounds, checking time and
+// latencies of each workload, with links to the visualizations of its
+// violations, and the outcome histogram of each litmus run. For dashboards
+// and test management systems, it writes the same as JUnit XML or as a
+// JSON document, with the outcome of every round. The tests of a package
+// add to one Report as they finish, it is written at the end.
+package report
+
+import (
+	"bufio"
+	"cmp"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// A Report is the outcome of a run. Its methods are safe for concurrent
+// use.
+type Report struct {
+	Title string
+	Start time.Time
+	End   time.Time
+	Env   Env
+	// Flags are the command line flags set for the run, as name=value.
+	Flags []string
+
+	mu        sync.Mutex
+	workloads []*Workload
+	litmus    []*Litmus
+}
+
+// Env is the environment of a run.
+type Env struct {
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	Go     string `json:"go"`
+	// CPU is the model name of the processor, empty where it is not known.
+	CPU        string `json:"cpu,omitempty"`
+	NumCPU     int    `json:"num_cpu"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	Host       string `json:"host,omitempty"`
+}
+
+// Environment returns the environment of this process.
+func Environment() Env {
+	host, _ := os.Hostname()
+	return Env{
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		Go:         runtime.Version(),
+		CPU:        cpuModel(),
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Host:       host,
+	}
+}
+
+// cpuModel reads the model name of the first processor from
+// /proc/cpuinfo, where there is one.
+func cpuModel() string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// "model name" on x86, "Model" on the arm64 kernels that name it.
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if k = strings.TrimSpace(k); ok && (k == "model name" || k == "Model") {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// New returns a report starting now, in the environment of this process.
+func New(title string) *Report {
+	return &Report{Title: title, Start: time.Now(), Env: Environment()}
+}
+
+// A Workload is the outcome of a workload test.
+type Workload struct {
+	Name string
+	// Impl is the implementation under test, Config the spec it ran.
+	Impl   string
+	Config string
+	// Procs is GOMAXPROCS during the test, which a sweep changes.
+	Procs int
+	Stats workload.Stats
+	// Failed is set if the test failed, Err is its error, if any, and
+	// Violation the file name of the visualization of its violation.
+	Failed    bool
+	Err       string
+	Violation string
+
+	// The statistics of the rounds, see AddRound, and their outcomes, see
+	// AddChecked.
+	mu        sync.Mutex
+	checked   []workload.Checked
+	rounds    int
+	opsPerSec float64
+	overlap   float64
+	most      int
+	latency   map[string]workload.Latency
+	durations []workload.Bucket
+	// histograms are those of the durations of every round, by operation.
+	histograms map[string]*workload.Histogram
+}
+
+// Workload adds the workload test name, running the spec config against
+// the implementation impl, and returns it to add its rounds and outcome
+// to.
+func (r *Report) Workload(name, impl, config string) *Workload {
+	w := &Workload{
+		Name:       name,
+		Impl:       impl,
+		Config:     config,
+		Procs:      runtime.GOMAXPROCS(0),
+		latency:    make(map[string]workload.Latency),
+		histograms: make(map[string]*workload.Histogram),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workloads = append(r.workloads, w)
+	return w
+}
+
+// AddRound adds the statistics of the history of a round.
+func (w *Workload) AddRound(st workload.HistoryStats) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rounds++
+	w.opsPerSec += st.OpsPerSec
+	w.overlap += st.Overlap
+	w.most = max(w.most, st.MaxOverlap)
+	for name, l := range st.ByOp {
+		w.latency[name] = worst(w.latency[name], l)
+	}
+	w.addHistograms(st.Histograms)
+	for _, b := range st.Durations {
+		i, ok := slices.BinarySearchFunc(w.durations, b.Below, func(a workload.Bucket, below workload.Duration) int {
+			return cmp.Compare(a.Below, below)
+		})
+		if !ok {
+			w.durations = slices.Insert(w.durations, i, workload.Bucket{Below: b.Below})
+		}
+		w.durations[i].Count += b.Count
+	}
+}
+
+// AddHistograms adds the histograms of the durations of operations, by
+// name, of rounds whose other statistics are not known, such as those of a
+// workload.Comparison.
+func (w *Workload) AddHistograms(hs map[string]*workload.Histogram) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.addHistograms(hs)
+}
+
+// addHistograms merges hs into the histograms of w, w.mu must be held.
+func (w *Workload) addHistograms(hs map[string]*workload.Histogram) {
+	for name, h := range hs {
+		if w.histograms[name] == nil {
+			w.histograms[name] = new(workload.Histogram)
+		}
+		w.histograms[name].Merge(h)
+	}
+}
+
+// AddChecked adds the outcome of a round.
+func (w *Workload) AddChecked(c workload.Checked) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.checked = append(w.checked, c)
+}
+
+// worst returns the higher of each percentile of a and b, and the sum of
+// their counts.
+func worst(a, b workload.Latency) workload.Latency {
+	return workload.Latency{
+		Count: a.Count + b.Count,
+		P50:   max(a.P50, b.P50),
+		P90:   max(a.P90, b.P90),
+		P99:   max(a.P99, b.P99),
+		Max:   max(a.Max, b.Max),
+	}
+}
+
+// Done records the outcome of the test: the stats of its run, whether it
+// failed, its error and the file of its violation, empty if none.
+func (w *Workload) Done(stats workload.Stats, failed bool, err error, violation string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.Stats, w.Failed, w.Violation = stats, failed, violation
+	if err != nil {
+		w.Err = err.Error()
+	}
+}
+
+// A Litmus is the outcome of a litmus run.
+type Litmus struct {
+	Name   string
+	Procs  int
+	Failed bool
+	Result *litmus.Result
+}
+
+// AddLitmus adds the litmus run name with its result, which failed the
+// test if failed is set.
+func (r *Report) AddLitmus(name string, res *litmus.Result, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.litmus = append(r.litmus, &Litmus{Name: name, Procs: runtime.GOMAXPROCS(0), Failed: failed, Result: res})
+}
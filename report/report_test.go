@@ -0,0 +1,88 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// testReport returns a report of a workload test that passed, one that
+// failed, and a litmus run, with violations in dir.
+func testReport(t *testing.T, dir string) *Report {
+	r := New("run <1>")
+	r.Flags = []string{"rounds=2"}
+
+	w := r.Workload("TestSyncMap", "sync.Map", "impl=sync.Map rounds=2")
+	for i, ret := range []int64{100, 300} {
+		h := &workload.History{Operations: []porcupine.Operation{
+			{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a"}, Call: 0, Output: model.Output{}, Return: ret},
+			{ClientId: 1, Input: model.Input{Op: model.Load, Key: "a"}, Call: 10, Output: model.Output{}, Return: 20},
+		}}
+		w.AddRound(h.Stats())
+		// Checked out of order, as in flight.
+		w.AddChecked(workload.Checked{Round: 1 - i, Seed: uint64(8 - i), Ops: 2, Result: porcupine.Ok})
+	}
+	w.Done(workload.Stats{Rounds: 2, Ops: 4, Elapsed: workload.Duration(time.Second)}, false, nil, "")
+	if l := w.latency["Store"]; l.Count != 2 || l.Max != 300 {
+		t.Errorf("Store latency %+v of both rounds, want 2 with max 300ns", l)
+	}
+	if len(w.durations) != 6 || w.durations[0] != (workload.Bucket{Below: 16, Count: 2}) {
+		t.Errorf("durations %+v of both rounds", w.durations)
+	}
+	if h := w.histograms["Store"]; h.Count() != 2 || h.Quantile(1) != 320 {
+		t.Errorf("Store histogram of %d operations up to %v, want 2 up to 320ns", h.Count(), h.Quantile(1))
+	}
+
+	violation := filepath.Join(dir, "violations", "syncmap_violation_3_150405.html")
+	failed := r.Workload("TestSyncMapOf", "SyncMapOf", "impl=SyncMapOf")
+	for _, c := range []workload.Checked{
+		{Round: 0, Result: porcupine.Unknown},
+		{Round: 1, Result: porcupine.Unknown, Hung: "check"},
+		{Round: 2, Result: porcupine.Ok},
+		{Round: 3, Result: porcupine.Illegal},
+	} {
+		failed.AddChecked(c)
+	}
+	failed.Done(workload.Stats{Rounds: 4}, true, os.ErrClosed, violation)
+	r.AddLitmus("TestMessagePassing/sync.Map", &litmus.Result{
+		Test:       "MP+Map",
+		Iterations: 4,
+		Counts:     map[litmus.Outcome]int{litmus.Regs(0, 0): 3, litmus.Regs(1, 1): 1},
+	}, false)
+	r.End = r.Start.Add(time.Minute)
+	return r
+}
+
+func TestWriteHTML(t *testing.T) {
+	dir := t.TempDir()
+	r := testReport(t, dir)
+	var b strings.Builder
+	if err := r.WriteHTML(&b, dir); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	for _, want := range []string{
+		"<title>run &lt;1&gt;</title>",
+		"2 passed", "1 failed",
+		r.Env.GOARCH, "<code>-rounds=2</code>",
+		"impl=sync.Map rounds=2", "Latencies of 2 rounds",
+		`<a href="violations/syncmap_violation_3_150405.html">`, os.ErrClosed.Error(),
+		"MP&#43;Map: 4 iterations", "{1,1}", "25.0000%",
+		"Latency by implementation", "<h3>Store</h3>", `<polyline fill="none"`, "<title>sync.Map: 2 operations</title>", ">90%</text>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report lacks %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "%!") {
+		t.Errorf("report has a bad format:\n%s", page)
+	}
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// sqlSchema creates the tables of WriteSQL, if the database does not have
+// them yet. Times are RFC 3339 text and durations integer nanoseconds.
+const sqlSchema = `CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	title TEXT, start TEXT, end TEXT,
+	go TEXT, goos TEXT, goarch TEXT, cpu TEXT, num_cpu INTEGER, gomaxprocs INTEGER, host TEXT,
+	flags TEXT
+);
+CREATE TABLE IF NOT EXISTS workloads (
+	id INTEGER PRIMARY KEY,
+	run INTEGER REFERENCES runs(id),
+	name TEXT, impl TEXT, config TEXT, gomaxprocs INTEGER,
+	rounds INTEGER, ops INTEGER, unknown INTEGER, violations INTEGER, hung INTEGER,
+	elapsed INTEGER, checking INTEGER,
+	failed INTEGER, error TEXT, violation TEXT
+);
+CREATE TABLE IF NOT EXISTS rounds (
+	workload INTEGER REFERENCES workloads(id),
+	round INTEGER, seed INTEGER, ops INTEGER, result TEXT, checking INTEGER, key TEXT, hung TEXT
+);
+CREATE TABLE IF NOT EXISTS litmus (
+	id INTEGER PRIMARY KEY,
+	run INTEGER REFERENCES runs(id),
+	name TEXT, test TEXT, gomaxprocs INTEGER,
+	iterations INTEGER, elapsed INTEGER, forbidden INTEGER, failed INTEGER
+);
+CREATE TABLE IF NOT EXISTS outcomes (
+	litmus INTEGER REFERENCES litmus(id),
+	outcome TEXT, count INTEGER, forbidden INTEGER
+);
+`
+
+// WriteSQL writes the report to w as a script of SQLite statements that
+// adds it to a database of results, creating its tables if need be, in
+// one transaction: a row of runs for the run, with its environment and
+// flags, one of workloads for every workload test, with its stats, outcome
+// and the file of its violation, one of rounds for every round, with its
+// seed, result and the key that is not linearizable, one of litmus for
+// every litmus run and one of outcomes for every outcome it saw. Runs add
+// up in the same database, so that
+//
+//	SELECT go, goarch, sum(violations) FROM runs JOIN workloads ON workloads.run = runs.id GROUP BY go, goarch
+//
+// counts the violations of every Go version and architecture over all of
+// them. The seed of a round is stored as a signed integer, as SQLite has
+// no other: cast negative ones back to uint64 to rerun them.
+func (r *Report) WriteSQL(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	b.WriteString(sqlSchema)
+	b.WriteString("BEGIN;\n")
+	e := r.Env
+	fmt.Fprintf(&b, "INSERT INTO runs (title, start, end, go, goos, goarch, cpu, num_cpu, gomaxprocs, host, flags) VALUES (%s, %s, %s, %s, %s, %s, %s, %d, %d, %s, %s);\n",
+		quote(r.Title), quote(r.Start.Format(time.RFC3339Nano)), quote(r.End.Format(time.RFC3339Nano)),
+		quote(e.Go), quote(e.GOOS), quote(e.GOARCH), quote(e.CPU), e.NumCPU, e.GOMAXPROCS, quote(e.Host), quote(strings.Join(r.Flags, " ")))
+	// The rows of a run refer to it, and those of a workload or litmus
+	// run to it, as the last row of its table.
+	const run = "(SELECT max(id) FROM runs)"
+	for _, wl := range r.workloads {
+		wl.mu.Lock()
+		st := wl.Stats
+		fmt.Fprintf(&b, "INSERT INTO workloads (run, name, impl, config, gomaxprocs, rounds, ops, unknown, violations, hung, elapsed, checking, failed, error, violation) "+
+			"VALUES (%s, %s, %s, %s, %d, %d, %d, %d, %d, %d, %d, %d, %d, %s, %s);\n",
+			run, quote(wl.Name), quote(wl.Impl), quote(wl.Config), wl.Procs, st.Rounds, st.Ops, st.Unknown, st.Violations, st.Hung,
+			st.Elapsed, st.Checking, boolean(wl.Failed), quote(wl.Err), quote(wl.Violation))
+		for _, c := range wl.sortedRounds() {
+			fmt.Fprintf(&b, "INSERT INTO rounds VALUES ((SELECT max(id) FROM workloads), %d, %d, %d, %s, %d, %s, %s);\n",
+				c.Round, int64(c.Seed), c.Ops, quote(string(c.Result)), c.Checking, quote(c.Key), quote(c.Hung))
+		}
+		wl.mu.Unlock()
+	}
+	for _, l := range r.litmus {
+		res := l.Result
+		fmt.Fprintf(&b, "INSERT INTO litmus (run, name, test, gomaxprocs, iterations, elapsed, forbidden, failed) VALUES (%s, %s, %s, %d, %d, %d, %d, %d);\n",
+			run, quote(l.Name), quote(res.Test), l.Procs, res.Iterations, res.Elapsed, res.Forbidden, boolean(l.Failed))
+		for _, oc := range res.Outcomes() {
+			fmt.Fprintf(&b, "INSERT INTO outcomes VALUES ((SELECT max(id) FROM litmus), %s, %d, %d);\n",
+				quote(oc.Outcome.String()), oc.Count, boolean(oc.Forbidden))
+		}
+	}
+	b.WriteString("COMMIT;\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote returns s as an SQL string literal.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func boolean(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteSQL(t *testing.T) {
+	r := testReport(t, t.TempDir())
+	r.Title = "Bob's run"
+	var b strings.Builder
+	if err := r.WriteSQL(&b); err != nil {
+		t.Fatal(err)
+	}
+	script := b.String()
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS rounds (",
+		"BEGIN;\nINSERT INTO runs (", "VALUES ('Bob''s run', ",
+		"VALUES ((SELECT max(id) FROM runs), 'TestSyncMap', 'sync.Map', 'impl=sync.Map rounds=2', ",
+		// Round 0 first, with its seed.
+		"INSERT INTO rounds VALUES ((SELECT max(id) FROM workloads), 0, 7, 2, 'Ok', 0, '', '');\nINSERT INTO rounds VALUES ((SELECT max(id) FROM workloads), 1, 8,",
+		", 1, 0, 0, 'Unknown', 0, '', 'check');",
+		"'TestMessagePassing/sync.Map', 'MP+Map', ",
+		"INSERT INTO outcomes VALUES ((SELECT max(id) FROM litmus), '{1,1}', 1, 0);",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script lacks %q:\n%s", want, script)
+		}
+	}
+	if !strings.HasSuffix(script, "COMMIT;\n") {
+		t.Errorf("script does not commit:\n%s", script)
+	}
+	if got := quote("it's ''"); got != "'it''s '''''" {
+		t.Errorf("quote = %s", got)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/litmus"
+	"github.com/jmasters-git/porcupine-syncmap/notify"
+	"github.com/jmasters-git/porcupine-syncmap/report"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// With -report, the whole run, every workload and litmus test in it and
+// every setting of a sweep, is summarized as one HTML page, with links to
+// the visualizations of the violations it found. -junit and -results
+// write it for dashboards as JUnit XML and as a JSON document, with the
+// outcome of every round as well. -summary=markdown prints a summary to
+// paste into an issue when the run ends, and -sqlite adds the run to a
+// database of results, with the sqlite3 command, or writes the statements
+// that do to a .sql file:
+//
+//	go test -gomaxprocs-sweep=1,4 -report=report.html -junit=junit.xml
+//	go test -timeout=0 -duration=72h -sqlite=soak.db
+var (
+	reportFlag  = flag.String("report", "", "write an HTML report of the run to this file")
+	junitFlag   = flag.String("junit", "", "write the outcome of every test and workload round of the run to this file as JUnit XML")
+	resultsFlag = flag.String("results", "", "write the outcome of every test and workload round of the run to this file as JSON")
+	summaryFlag = flag.String("summary", "", "print a summary of the run when it ends: markdown")
+	sqliteFlag  = flag.String("sqlite", "", "add the run and every round of it to this SQLite database, or write the SQL statements that do to this .sql file")
+)
+
+// runReport is the report of -report, -junit, -results, -summary and
+// -sqlite, nil without them.
+var runReport *report.Report
+
+// startReport starts the report of the run, if there is one.
+func startReport() error {
+	switch *summaryFlag {
+	case "", "markdown":
+	default:
+		return fmt.Errorf("-summary: unknown format %q, want markdown", *summaryFlag)
+	}
+	if *reportFlag == "" && *junitFlag == "" && *resultsFlag == "" && *summaryFlag == "" && *sqliteFlag == "" {
+		return nil
+	}
+	runReport = report.New("porcupine-syncmap")
+	flag.Visit(func(f *flag.Flag) {
+		v := f.Value.String()
+		if f.Name == "webhook" {
+			v = (&notify.Webhook{URL: v}).String()
+		}
+		runReport.Flags = append(runReport.Flags, f.Name+"="+v)
+	})
+	return nil
+}
+
+// writeReport ends the report, writes it to its files and prints its
+// summary.
+func writeReport() error {
+	if runReport == nil {
+		return nil
+	}
+	runReport.End = time.Now()
+	if *summaryFlag == "markdown" {
+		fmt.Println()
+		if err := runReport.WriteMarkdown(os.Stdout); err != nil {
+			return err
+		}
+	}
+	var script string
+	if strings.HasSuffix(*sqliteFlag, ".sql") {
+		script = *sqliteFlag
+	} else if *sqliteFlag != "" {
+		if err := addToDatabase(*sqliteFlag); err != nil {
+			return err
+		}
+	}
+	for _, out := range []struct {
+		file  string
+		write func(io.Writer) error
+	}{
+		{*reportFlag, func(w io.Writer) error { return runReport.WriteHTML(w, filepath.Dir(*reportFlag)) }},
+		{*junitFlag, runReport.WriteJUnit},
+		{*resultsFlag, runReport.WriteJSON},
+		{script, runReport.WriteSQL},
+	} {
+		if out.file == "" {
+			continue
+		}
+		f, err := os.Create(out.file)
+		if err != nil {
+			return err
+		}
+		if err := out.write(f); err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %v", out.file, err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addToDatabase adds the report to the SQLite database db with the sqlite3
+// command, there being no SQLite driver in the standard library.
+func addToDatabase(db string) error {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return fmt.Errorf("%s: no sqlite3 to add the run to it, give -sqlite a .sql file to write the statements to instead", db)
+	}
+	var script bytes.Buffer
+	if err := runReport.WriteSQL(&script); err != nil {
+		return err
+	}
+	cmd := exec.Command("sqlite3", "-bail", db)
+	cmd.Stdin = &script
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: sqlite3: %v: %s", db, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// addWorkloadReport adds the workload test t running s to the report and
+// returns it, nil without a report.
+func addWorkloadReport(t *testing.T, s *workload.Spec) *report.Workload {
+	if runReport == nil {
+		return nil
+	}
+	return runReport.Workload(t.Name(), s.Impl, s.String())
+}
+
+// addLitmusReport adds the litmus run t with its result to the report,
+// and counts it in the metrics.
+func addLitmusReport(t *testing.T, res *litmus.Result) {
+	if runReport != nil {
+		runReport.AddLitmus(t.Name(), res, t.Failed())
+	}
+	if runMetrics != nil {
+		runMetrics.AddLitmus(t.Name(), res)
+	}
+}
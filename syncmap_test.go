@@ -1,168 +1,253 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"math/rand/v2"
 	"os"
-	"runtime"
-	"sync"
+	"os/exec"
+	"strings"
 	"testing"
-	"time"
 
 	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
 )
 
-func TestSyncMap(t *testing.T) {
-	var (
-		numRounds = 10000
-		numOps    = 50
-		workers   = runtime.GOMAXPROCS(0)
-	)
+var workloadFile = flag.String("workload", "", "JSON workload spec or workload profile name run by TestWorkload")
 
-	t.Logf("config: rounds=%d ops=%d workers=%d", numRounds, numOps, workers)
-
-	for round := range numRounds {
-		var (
-			m          sync.Map
-			operations []porcupine.Operation
-			mu         sync.Mutex
-			wg         sync.WaitGroup
-			start      = time.Now()
-		)
-
-		for g := range workers {
-			wg.Add(1)
-			go func(id int) {
-				defer wg.Done()
-				for i := range numOps {
-					// var atm atomic.Int64
-					call := time.Since(start).Nanoseconds()
-					// asm.MemoryBarrier()
-					// atm.Store(call)
-
-					input, output := executeOperation(id, i, &m)
-
-					// atm.Load()
-					// asm.MemoryBarrier()
-					returnTime := time.Since(start).Nanoseconds()
-
-					mu.Lock()
-					operations = append(operations, porcupine.Operation{
-						ClientId: id,
-						Input:    input,
-						Call:     call,
-						Output:   output,
-						Return:   returnTime,
-					})
-					mu.Unlock()
-				}
-			}(g)
-		}
+// A violation found by a workload test can be rerun under runtime/trace,
+// with its seed until it is not linearizable again, at most -trace-violations
+// times. The trace is saved next to the visualization, with the
+// visualization of the traced round if it reproduced the violation:
+//
+//	go test -run 'TestSyncMap$' -trace-violations=100
+//	go tool trace syncmap_violation_12_150405.trace
+var traceViolations = flag.Int("trace-violations", 0, "rerun the round of a violation under runtime/trace up to this many times until it reproduces, 0 not to")
 
-		wg.Wait()
+// Besides its visualization, a violation is logged as text, where the
+// search for a linearization of its history got stuck, for soak machines
+// without a browser. -render=color draws it with ANSI colors, none leaves
+// it out.
+var renderFlag = flag.String("render", "plain", "log violations as text: plain, color or none")
 
-		result, info := porcupine.CheckOperationsVerbose(Model, operations, 5*time.Second)
+// To attach a violation to an issue or a chat, -snapshot=svg saves a
+// static image of it next to its visualization, and -snapshot=png a PNG
+// too, which takes rsvg-convert or ImageMagick to rasterize the SVG.
+var snapshotFlag = flag.String("snapshot", "", "save violations as images as well: svg, png or empty for none")
 
-		if result == porcupine.Illegal {
-			filename := fmt.Sprintf("syncmap_violation_%d_%s.html", round, time.Now().Format("150405"))
-			file, err := os.Create(filename)
-			if err != nil {
-				t.Fatalf("Round %d: failed to create file %s: %v", round, filename, err)
-			}
-			porcupine.Visualize(Model, info, file)
-			file.Close()
-			t.Fatalf("Round %d: sync.Map violation saved to %s", round, filename)
-		}
-	}
-	t.Logf("no violation observed after %d rounds", numRounds)
+func TestSyncMap(t *testing.T) {
+	checkWorkload(t, workload.Default())
 }
 
-func executeOperation(workerID, iter int, m *sync.Map) (SyncMapInput, SyncMapOutput) {
-	if iter%3 == 0 { // delete every 3rd op.
-		val, ok := m.LoadAndDelete("k")
-		if ok {
-			return SyncMapInput{op: OpDelete}, SyncMapOutput{found: true, val: val.(int)}
-		}
-		return SyncMapInput{op: OpDelete}, SyncMapOutput{found: false}
+// Runs the same workload through the typed wrapper, any difference to
+// TestSyncMap would come from the wrapper itself.
+func TestSyncMapOf(t *testing.T) {
+	s := workload.Default()
+	s.Impl = "SyncMapOf"
+	checkWorkload(t, s)
+}
+
+// TestWorkload runs the spec given with -workload:
+//
+//	go test -run TestWorkload -workload=spec.json
+func TestWorkload(t *testing.T) {
+	if *workloadFile == "" {
+		t.Skip("no -workload spec given")
 	}
+	checkWorkload(t, loadWorkload(t))
+}
 
-	value := workerID*1000 + iter
-	actual, loaded := m.LoadOrStore("k", value)
-	if loaded {
-		return SyncMapInput{op: OpInsert, val: value}, SyncMapOutput{found: false, val: actual.(int)}
+// loadWorkload returns the profile or spec file named by -workload.
+func loadWorkload(t *testing.T) workload.Spec {
+	s, ok := workload.Profile(*workloadFile)
+	if !ok {
+		var err error
+		if s, err = workload.Load(*workloadFile); err != nil {
+			t.Fatal(err)
+		}
 	}
-	return SyncMapInput{op: OpInsert, val: value}, SyncMapOutput{found: true}
+	return s
 }
 
-type OpKind int
+// Drives sync.Map through read-only map misses, dirty map promotions and
+// expunged entries, see workload.Promotion.
+func TestPromotion(t *testing.T) {
+	checkWorkload(t, workload.Promotion())
+}
 
-const (
-	OpInsert OpKind = iota
-	OpDelete
-)
+func checkWorkload(t *testing.T, s workload.Spec) {
+	applyHarness(&s)
+	if s.Seed == 0 {
+		// Set here rather than by Run, for the report to show it.
+		s.Seed = rand.Uint64()
+	}
+	var (
+		rep       = addWorkloadReport(t, &s)
+		summary   workload.Stats
+		err       error
+		violation string
+	)
+	if rep != nil {
+		defer func() { rep.Done(summary, t.Failed(), err, violation) }()
+	}
+	if *isolateFlag > 0 && *batchFlag == "" {
+		isolate(t, s)
+		return
+	}
+	fence := fence(t)
 
-type SyncMapInput struct {
-	op  OpKind
-	val int
+	opts := workload.RunOptions{
+		Fence: fence.Do,
+		AfterRound: func(_ int, m mapimpl.MapUnderTest, history []porcupine.Operation) error {
+			if rep != nil {
+				rep.AddRound((&workload.History{Operations: history}).Stats())
+			}
+			if runMetrics != nil {
+				runMetrics.AddOps(t.Name(), s.Impl, history)
+			}
+			if s.Checker.SkipProbes {
+				return nil
+			}
+			return runProbes(m, history)
+		},
+		Logf: t.Logf,
+		// Rerun a failed round with -seed=<its seed> -rounds=1.
+		Verbose:  testing.Verbose(),
+		InFlight: *checkInFlight,
+	}
+	soakOptions(t, &opts)
+	opts.Summary = &summary
+	opts.Checked = func(c workload.Checked) {
+		if rep != nil {
+			rep.AddChecked(c)
+		}
+		if runMetrics != nil {
+			runMetrics.AddChecked(t.Name(), s.Impl, c)
+		}
+	}
+	if *sampleFlag > 0 {
+		opts.Sample = *sampleFlag
+		opts.Sampled = func(sm *workload.Sample) { saveSample(t, s.Impl, sm) }
+	}
+	if first, count, ok := batch(); ok {
+		opts.FirstRound, s.Rounds = first, first+count
+	}
+	err = s.Run(opts)
+
+	var v *workload.Violation
+	if errors.As(err, &v) {
+		filename := saveViolation(t, s.Impl, v)
+		violation = filename
+		traceViolation(t, &s, v, fence.Do, filename)
+		notifyEvent(t, violationEvent(s.Impl, v.Round, v.Seed, filename))
+		t.Fatalf("Round %d (seed %d): %s violation saved to %s", v.Round, v.Seed, s.Impl, filename)
+	}
+	if errors.Is(err, workload.ErrStopped) {
+		t.Skipf("%s stopped after %s", s.Impl, summary)
+	}
+	if err != nil {
+		t.Fatalf("%s %v", s.Impl, err)
+	}
+	if opts.Duration == 0 {
+		t.Logf("no violation observed after %d rounds", s.Rounds)
+	}
 }
 
-type SyncMapOutput struct {
-	found bool
-	val   int
+// saveViolation writes the visualization of v on impl to a file, and its
+// history as JSON next to it, and returns the name of the visualization.
+func saveViolation(t *testing.T, impl string, v *workload.Violation) string {
+	base := artifact(t, workload.ViolationName(impl, v.Round))
+	if err := v.WriteFiles(base); err != nil {
+		t.Fatalf("Round %d (seed %d): %v", v.Round, v.Seed, err)
+	}
+	if *snapshotFlag != "" {
+		if err := snapshot(v, base, *snapshotFlag); err != nil {
+			t.Errorf("Round %d (seed %d): %v", v.Round, v.Seed, err)
+		}
+	}
+	pruneViolations(t)
+	switch *renderFlag {
+	case "none":
+	case "plain", "color":
+		var b strings.Builder
+		if err := v.Render(&b, *renderFlag == "color"); err != nil {
+			t.Errorf("Round %d (seed %d): %v", v.Round, v.Seed, err)
+		}
+		t.Logf("%s", b.String())
+	default:
+		t.Errorf("unknown -render %q", *renderFlag)
+	}
+	return base + ".html"
 }
 
-type MapState struct {
-	present bool
-	val     int
+// snapshot writes the image of v to base.svg and, if format is png,
+// rasterizes it to base.png.
+func snapshot(v *workload.Violation, base, format string) error {
+	if format != "svg" && format != "png" {
+		return fmt.Errorf("unknown -snapshot %q", format)
+	}
+	file, err := os.Create(base + ".svg")
+	if err != nil {
+		return err
+	}
+	err = v.WriteSVG(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || format == "svg" {
+		return err
+	}
+	return rasterize(base+".svg", base+".png")
 }
 
-var Model = porcupine.Model{
-	Init: func() interface{} { return MapState{} },
-	Step: func(state, input, output interface{}) (bool, interface{}) {
-		st := state.(MapState)
-		in := input.(SyncMapInput)
-		out := output.(SyncMapOutput)
-
-		switch in.op {
-		case OpInsert:
-			if st.present {
-				if !out.found && out.val == st.val {
-					return true, st
-				}
-				return false, st
-			}
-			if out.found {
-				return true, MapState{present: true, val: in.val}
-			}
-			return false, st
-		case OpDelete:
-			if st.present {
-				if out.found && out.val == st.val {
-					return true, MapState{}
-				}
-				return false, st
-			}
-			return !out.found, st
-		default:
-			return false, st
+// rasterize converts the SVG image svg to the PNG image png with the
+// first rasterizer found.
+func rasterize(svg, png string) error {
+	for _, args := range [][]string{
+		{"rsvg-convert", "-o", png, svg},
+		{"magick", svg, png},
+		{"convert", svg, png},
+	} {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
 		}
-	},
-	DescribeOperation: func(input, output interface{}) string {
-		inp := input.(SyncMapInput)
-		out := output.(SyncMapOutput)
-
-		switch inp.op {
-		case OpInsert:
-			if out.found {
-				return fmt.Sprintf("Insert(%d) -> ok", inp.val)
-			}
-			return fmt.Sprintf("Insert(%d) -> key exists (prev %d)", inp.val, out.val)
-		case OpDelete:
-			if out.found {
-				return fmt.Sprintf("Delete() -> deleted (was %d)", out.val)
-			}
-			return "Delete() -> not found"
-		default:
-			return "Unknown operation"
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v: %s", args[0], err, bytes.TrimSpace(out))
 		}
-	},
+		return nil
+	}
+	return fmt.Errorf("%s: no rsvg-convert or ImageMagick to rasterize it to PNG", svg)
+}
+
+// traceViolation reruns the round of v under runtime/trace if
+// -trace-violations asks for it, and saves the trace and, if the violation
+// reproduced, its visualization next to filename.
+func traceViolation(t *testing.T, s *workload.Spec, v *workload.Violation, fence func(), filename string) {
+	if *traceViolations <= 0 {
+		return
+	}
+	base := strings.TrimSuffix(filename, ".html")
+	file, err := os.Create(base + ".trace")
+	if err != nil {
+		t.Errorf("Round %d (seed %d): failed to create trace: %v", v.Round, v.Seed, err)
+		return
+	}
+	defer file.Close()
+	again, err := s.Retrace(v, fence, *traceViolations, file)
+	switch {
+	case err != nil:
+		t.Errorf("Round %d (seed %d): %v", v.Round, v.Seed, err)
+	case again == nil:
+		t.Logf("Round %d (seed %d): not reproduced in %d attempts, trace of the last saved to %s", v.Round, v.Seed, *traceViolations, file.Name())
+	default:
+		if err := again.WriteFiles(base + "_traced"); err != nil {
+			t.Errorf("Round %d (seed %d): %v", v.Round, v.Seed, err)
+			return
+		}
+		t.Logf("Round %d (seed %d): trace saved to %s, its violation to %s", v.Round, v.Seed, file.Name(), base+"_traced.html")
+	}
 }
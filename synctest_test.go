@@ -0,0 +1,59 @@
+//go:build go1.25
+
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// TestSyncMapSynctest runs the rounds of the workload of TestSyncMap, or
+// of -workload, as schedules of workload.Spec.Schedule in bubbles of
+// testing/synctest, 1000 unless -rounds is given: the workers sleep on its
+// fake clock between their operations, so they take turns in an order
+// the seed of the round fixes, and only the operations of the instants
+// two of them wake at race. A violation is rerun with the same instants
+// by -seed=<its seed> -rounds=1, where the previous stress rounds could
+// only repeat the operations. It needs Go 1.25:
+//
+//	go test -run TestSyncMapSynctest -rounds=100000
+func TestSyncMapSynctest(t *testing.T) {
+	s := workload.Default()
+	if *workloadFile != "" {
+		s = loadWorkload(t)
+	}
+	s.Rounds = 1000
+	applyHarness(&s)
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Seed == 0 {
+		s.Seed = rand.Uint64()
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	for round := range s.Rounds {
+		seed := s.RoundSeed(round)
+		sc := s.Schedule(seed)
+		var h *workload.History
+		synctest.Test(t, func(t *testing.T) {
+			h = sc.Run(impl.New())
+		})
+		r := workload.Check(h, nil, time.Duration(s.Checker.Timeout))
+		switch r.Check {
+		case porcupine.Illegal:
+			v := &workload.Violation{Round: round, Seed: seed, Info: r.Info, History: h}
+			filename := saveViolation(t, s.Impl, v)
+			t.Fatalf("Round %d (seed %d): %s violation saved to %s", round, seed, s.Impl, filename)
+		case porcupine.Unknown:
+			t.Logf("Round %d (seed %d): check timed out", round, seed)
+		}
+	}
+	t.Logf("no violation observed after %d rounds", s.Rounds)
+}
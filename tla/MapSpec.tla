@@ -0,0 +1,112 @@
+------------------------------- MODULE MapSpec -------------------------------
+(***************************************************************************)
+(* The map of model.Model, that of sync.Map: every key has a value or is   *)
+(* Absent, and the operation a client calls takes effect at once at some  *)
+(* point between its call and its return. A Range observes each key at a  *)
+(* point of its own within its call, as sync.Map promises no snapshot.    *)
+(* Every behavior of Spec is linearizable by construction; MapTrace keeps  *)
+(* those that follow a history.                                            *)
+(***************************************************************************)
+EXTENDS Integers
+
+CONSTANTS Keys,     \* the keys of the map
+          Values,   \* the values stored, with 0
+          Clients,  \* the clients calling operations
+          Absent    \* the value of a missing key, a model value
+
+VARIABLES store,    \* the value of every key, or Absent
+          pending,  \* the input of the operation of every client, or Idle
+          result,   \* its output once it took effect, or None
+          seen      \* the keys a Range in progress observed
+
+vars == <<store, pending, result, seen>>
+
+Ops == {"Load", "Store", "LoadOrStore", "LoadAndDelete", "Swap", "CompareAndSwap"}
+
+(***************************************************************************)
+(* An input is a model.Input: val is the value of Store, LoadOrStore, Swap *)
+(* and CompareAndSwap, old the one CompareAndSwap expects, 0 where they do *)
+(* not apply. A Range has no key.                                          *)
+(***************************************************************************)
+Inputs == [op : Ops, key : Keys, val : Values, old : Values]
+          \cup [op : {"Range"}, key : {""}, val : {0}, old : {0}]
+
+Idle == [op |-> "Idle", key |-> "", val |-> 0, old |-> 0]
+
+(***************************************************************************)
+(* An output is a model.Output: found is the loaded, ok or swapped result  *)
+(* of the operation, val the value it loaded, 0 if none, and entries the   *)
+(* pairs of a key and its value a Range visited.                           *)
+(***************************************************************************)
+Out(found, v) == [found |-> found, val |-> IF found /\ v # Absent THEN v ELSE 0, entries |-> {}]
+
+None == [found |-> FALSE, val |-> -1, entries |-> {}]
+
+\* The value of a key after in when it had v, and the output of in.
+Apply(v, in) ==
+    CASE in.op = "Load"           -> <<v, Out(v # Absent, v)>>
+      [] in.op = "Store"          -> <<in.val, Out(FALSE, Absent)>>
+      [] in.op = "LoadOrStore"    -> IF v # Absent THEN <<v, Out(TRUE, v)>>
+                                     ELSE <<in.val, Out(FALSE, Absent)>>
+      [] in.op = "LoadAndDelete"  -> <<Absent, Out(v # Absent, v)>>
+      [] in.op = "Swap"           -> <<in.val, Out(v # Absent, v)>>
+      [] in.op = "CompareAndSwap" -> IF v # Absent /\ v = in.old THEN <<in.val, Out(TRUE, Absent)>>
+                                     ELSE <<v, Out(FALSE, Absent)>>
+
+TypeOK ==
+    /\ store \in [Keys -> Values \cup {Absent}]
+    /\ pending \in [Clients -> Inputs \cup {Idle}]
+    /\ seen \in [Clients -> SUBSET Keys]
+
+Init ==
+    /\ store = [k \in Keys |-> Absent]
+    /\ pending = [c \in Clients |-> Idle]
+    /\ result = [c \in Clients |-> None]
+    /\ seen = [c \in Clients |-> {}]
+
+\* Client c calls in.
+Call(c, in) ==
+    /\ pending[c].op = "Idle"
+    /\ pending' = [pending EXCEPT ![c] = in]
+    /\ result' = [result EXCEPT ![c] = IF in.op = "Range" THEN Out(FALSE, Absent) ELSE None]
+    /\ seen' = [seen EXCEPT ![c] = {}]
+    /\ UNCHANGED store
+
+\* The operation of client c, other than a Range, takes effect.
+Effect(c) ==
+    /\ pending[c].op \notin {"Idle", "Range"}
+    /\ result[c] = None
+    /\ LET in == pending[c]
+           r  == Apply(store[in.key], in)
+       IN  /\ store' = [store EXCEPT ![in.key] = r[1]]
+           /\ result' = [result EXCEPT ![c] = r[2]]
+    /\ UNCHANGED <<pending, seen>>
+
+\* The Range of client c observes the key k.
+Observe(c, k) ==
+    /\ pending[c].op = "Range"
+    /\ k \notin seen[c]
+    /\ seen' = [seen EXCEPT ![c] = @ \cup {k}]
+    /\ result' = IF store[k] = Absent THEN result
+                 ELSE [result EXCEPT ![c].entries = @ \cup {<<k, store[k]>>}]
+    /\ UNCHANGED <<store, pending>>
+
+\* Whether the operation of client c took effect.
+Done(c) == IF pending[c].op = "Range" THEN seen[c] = Keys ELSE result[c] # None
+
+\* Client c returns.
+Return(c) ==
+    /\ pending[c].op # "Idle"
+    /\ Done(c)
+    /\ pending' = [pending EXCEPT ![c] = Idle]
+    /\ UNCHANGED <<store, result, seen>>
+
+Next ==
+    \E c \in Clients :
+        \/ \E in \in Inputs : Call(c, in)
+        \/ Effect(c)
+        \/ \E k \in Keys : Observe(c, k)
+        \/ Return(c)
+
+Spec == Init /\ [][Next]_vars
+=============================================================================
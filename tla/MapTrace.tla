@@ -0,0 +1,36 @@
+------------------------------ MODULE MapTrace ------------------------------
+(***************************************************************************)
+(* The behaviors of MapSpec that follow a history, Trace, as               *)
+(* workload.WriteTLA writes it: each call of Trace happens in turn with    *)
+(* its input and each return with the output the operation had, while the *)
+(* operations in progress take effect in between in any order MapSpec      *)
+(* allows. A behavior gets through the whole of Trace if and only if the   *)
+(* history is linearizable: TLC checking TraceNotAccepted, without         *)
+(* deadlocks, reports a linearization as the behavior that violates it,    *)
+(* and finds no error if there is none.                                    *)
+(***************************************************************************)
+EXTENDS MapSpec, Sequences
+
+CONSTANT Trace    \* the calls and returns of the history, in order
+VARIABLE i        \* the index of the next event of Trace
+
+TraceInit == Init /\ i = 1
+
+\* The next event of Trace happens.
+Event ==
+    /\ i <= Len(Trace)
+    /\ LET e == Trace[i]
+       IN  IF e.type = "call"
+           THEN Call(e.client, e.input)
+           ELSE Return(e.client) /\ result[e.client] = e.output
+    /\ i' = i + 1
+
+\* An operation in progress takes effect, or a Range observes a key.
+Internal ==
+    /\ \E c \in Clients : Effect(c) \/ \E k \in Keys : Observe(c, k)
+    /\ UNCHANGED i
+
+TraceSpec == TraceInit /\ [][Event \/ Internal]_<<vars, i>>
+
+TraceNotAccepted == i <= Len(Trace)
+=============================================================================
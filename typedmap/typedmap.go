@@ -0,0 +1,90 @@
+// Package typedmap provides a type-safe generic wrapper over sync.Map.
+//
+// The wrapper only adds type assertions on the way in and out, it performs
+// exactly one sync.Map call per method so it has the same memory ordering
+// behavior as the underlying sync.Map.
+package typedmap
+
+import "sync"
+
+// SyncMapOf is a sync.Map restricted to keys of type K and values of type V.
+// The zero value is empty and ready for use. A SyncMapOf must not be copied
+// after first use.
+type SyncMapOf[K comparable, V any] struct {
+	m sync.Map
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no
+// value is present. The ok result indicates whether value was found in the map.
+func (m *SyncMapOf[K, V]) Load(key K) (value V, ok bool) {
+	v, ok := m.m.Load(key)
+	if !ok {
+		return value, false
+	}
+	return v.(V), true
+}
+
+// Store sets the value for a key.
+func (m *SyncMapOf[K, V]) Store(key K, value V) {
+	m.m.Store(key, value)
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *SyncMapOf[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	a, loaded := m.m.LoadOrStore(key, value)
+	return a.(V), loaded
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *SyncMapOf[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	v, loaded := m.m.LoadAndDelete(key)
+	if !loaded {
+		return value, false
+	}
+	return v.(V), true
+}
+
+// Delete deletes the value for a key.
+func (m *SyncMapOf[K, V]) Delete(key K) {
+	m.m.Delete(key)
+}
+
+// Swap swaps the value for a key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *SyncMapOf[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	p, loaded := m.m.Swap(key, value)
+	if !loaded {
+		return previous, false
+	}
+	return p.(V), true
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the map is equal to old.
+// As with sync.Map, V must be comparable at run time.
+func (m *SyncMapOf[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	return m.m.CompareAndSwap(key, old, new)
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// As with sync.Map, V must be comparable at run time.
+func (m *SyncMapOf[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	return m.m.CompareAndDelete(key, old)
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+// See sync.Map.Range for the consistency guarantees.
+func (m *SyncMapOf[K, V]) Range(f func(key K, value V) bool) {
+	m.m.Range(func(k, v any) bool {
+		return f(k.(K), v.(V))
+	})
+}
+
+// Clear deletes all the entries.
+func (m *SyncMapOf[K, V]) Clear() {
+	m.m.Clear()
+}
@@ -0,0 +1,50 @@
+package typedmap
+
+import "testing"
+
+func TestSyncMapOf(t *testing.T) {
+	var m SyncMapOf[string, int]
+
+	if v, ok := m.Load("k"); ok || v != 0 {
+		t.Fatalf("Load on empty map = %d, %v", v, ok)
+	}
+	if actual, loaded := m.LoadOrStore("k", 1); loaded || actual != 1 {
+		t.Fatalf("LoadOrStore on empty map = %d, %v", actual, loaded)
+	}
+	if actual, loaded := m.LoadOrStore("k", 2); !loaded || actual != 1 {
+		t.Fatalf("LoadOrStore on present key = %d, %v", actual, loaded)
+	}
+	if prev, loaded := m.Swap("k", 3); !loaded || prev != 1 {
+		t.Fatalf("Swap = %d, %v", prev, loaded)
+	}
+	if m.CompareAndSwap("k", 1, 4) {
+		t.Fatal("CompareAndSwap succeeded with stale old value")
+	}
+	if !m.CompareAndSwap("k", 3, 4) {
+		t.Fatal("CompareAndSwap failed with current old value")
+	}
+	if m.CompareAndDelete("k", 3) {
+		t.Fatal("CompareAndDelete succeeded with stale old value")
+	}
+	if v, loaded := m.LoadAndDelete("k"); !loaded || v != 4 {
+		t.Fatalf("LoadAndDelete = %d, %v", v, loaded)
+	}
+	if v, loaded := m.LoadAndDelete("k"); loaded || v != 0 {
+		t.Fatalf("LoadAndDelete on missing key = %d, %v", v, loaded)
+	}
+
+	m.Store("a", 1)
+	m.Store("b", 2)
+	sum := 0
+	m.Range(func(_ string, v int) bool {
+		sum += v
+		return true
+	})
+	if sum != 3 {
+		t.Fatalf("Range sum = %d, want 3", sum)
+	}
+	m.Clear()
+	if _, ok := m.Load("a"); ok {
+		t.Fatal("Load after Clear found a value")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"unique"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+// unique.Make interns values on top of the runtime's internal concurrent
+// HashTrieMap. Every worker interns a handful of values that are fresh for
+// the round, so the first Make of each value races with the others.
+func TestUniqueMake(t *testing.T) {
+	cfg := harnessConfig(2000, 50)
+	var (
+		numRounds = cfg.rounds
+		numOps    = cfg.ops
+		numValues = 4
+		workers   = cfg.workers
+	)
+
+	t.Logf("config: rounds=%d ops=%d values=%d workers=%d", numRounds, numOps, numValues, workers)
+
+	for round := range numRounds {
+		var (
+			operations []porcupine.Operation
+			// One buffer per worker, merged after the round, so recording
+			// does not synchronize the workers.
+			recorded = make([][]porcupine.Operation, workers)
+			wg       sync.WaitGroup
+			start    = time.Now()
+		)
+
+		for g := range workers {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				history := make([]porcupine.Operation, 0, numOps)
+				for i := range numOps {
+					// Build the string at run time so every Make sees a
+					// distinct allocation with equal contents.
+					val := "r" + strconv.Itoa(round) + "-v" + strconv.Itoa((id+i)%numValues)
+
+					call := time.Since(start).Nanoseconds()
+					h := unique.Make(val)
+					returnTime := time.Since(start).Nanoseconds()
+
+					history = append(history, porcupine.Operation{
+						ClientId: id,
+						Input:    UniqueInput{val: val},
+						Call:     call,
+						// Keeping the handle in the history also keeps it
+						// reachable, so the runtime can't reclaim the entry
+						// and legitimately hand out a new handle mid-round.
+						Output: UniqueOutput{handle: h, val: h.Value()},
+						Return: returnTime,
+					})
+				}
+				recorded[id] = history
+			}(g)
+		}
+
+		wg.Wait()
+		for _, history := range recorded {
+			operations = append(operations, history...)
+		}
+
+		result, info := porcupine.CheckOperationsVerbose(UniqueModel, operations, cfg.checkTimeout)
+
+		if result == porcupine.Illegal {
+			filename := artifact(t, workload.ViolationName("unique", round)+".html")
+			file, err := os.Create(filename)
+			if err != nil {
+				t.Fatalf("Round %d: failed to create file %s: %v", round, filename, err)
+			}
+			porcupine.Visualize(UniqueModel, info, file)
+			file.Close()
+			pruneViolations(t)
+			t.Fatalf("Round %d: unique.Make violation saved to %s", round, filename)
+		}
+	}
+	t.Logf("no violation observed after %d rounds", numRounds)
+}
+
+type UniqueInput struct {
+	val string
+}
+
+type UniqueOutput struct {
+	handle unique.Handle[string]
+	val    string
+}
+
+// UniqueState maps each value interned so far to its canonical handle.
+type UniqueState map[string]unique.Handle[string]
+
+// UniqueModel: the first Make of a value fixes its handle, every later Make
+// of an equal value must return that same handle, handles of different values
+// must never be equal, and Value must round-trip.
+var UniqueModel = porcupine.Model{
+	Init: func() interface{} { return UniqueState{} },
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		st := state.(UniqueState)
+		in := input.(UniqueInput)
+		out := output.(UniqueOutput)
+
+		if out.val != in.val {
+			return false, st
+		}
+		if h, ok := st[in.val]; ok {
+			return out.handle == h, st
+		}
+		for _, h := range st {
+			if out.handle == h {
+				return false, st
+			}
+		}
+		next := maps.Clone(st)
+		next[in.val] = out.handle
+		return true, next
+	},
+	Equal: func(state1, state2 interface{}) bool {
+		return maps.Equal(state1.(UniqueState), state2.(UniqueState))
+	},
+	DescribeOperation: func(input, output interface{}) string {
+		in := input.(UniqueInput)
+		out := output.(UniqueOutput)
+		return fmt.Sprintf("Make(%q) -> %v (%q)", in.val, out.handle, out.val)
+	},
+}
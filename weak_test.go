@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+	"weak"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/workload"
+)
+
+type cacheValue struct {
+	val int
+	_   [2]int // keep it out of the tiny allocator so it is collected on its own.
+}
+
+// A sync.Map of weak pointers used as a cache: entries may vanish whenever
+// the GC runs, but a vanished entry must never come back with its old value.
+func TestWeakCache(t *testing.T) {
+	cfg := harnessConfig(200, 50)
+	var (
+		numRounds = cfg.rounds
+		numOps    = cfg.ops
+		workers   = cfg.workers
+	)
+
+	t.Logf("config: rounds=%d ops=%d workers=%d", numRounds, numOps, workers)
+
+	vanished := 0
+	for round := range numRounds {
+		var (
+			m          sync.Map
+			operations []porcupine.Operation
+			// One buffer per worker, merged after the round, so recording
+			// does not synchronize the workers.
+			recorded = make([][]porcupine.Operation, workers)
+			wg       sync.WaitGroup
+			start    = time.Now()
+		)
+
+		for g := range workers {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				// Each worker keeps its most recent value strongly reachable
+				// so only older values become collectable.
+				var pinned *cacheValue
+				history := make([]porcupine.Operation, 0, numOps)
+				for i := range numOps {
+					if i%10 == 9 { // unrecorded GC every 10th op.
+						if i%20 == 19 { // every other GC may collect our latest value too.
+							pinned = nil
+							// One cycle is not always enough to clear the
+							// weak pointer to a value that was just unpinned.
+							runtime.GC()
+						}
+						runtime.GC()
+						continue
+					}
+
+					var (
+						input  WeakCacheInput
+						output WeakCacheOutput
+					)
+					call := time.Since(start).Nanoseconds()
+					if i%4 == 1 { // put every 4th op.
+						pinned = &cacheValue{val: id*1000 + i}
+						m.Store("k", weak.Make(pinned))
+						input = WeakCacheInput{op: OpPut, val: pinned.val}
+					} else {
+						input = WeakCacheInput{op: OpGet}
+						if wp, ok := m.Load("k"); ok {
+							if v := wp.(weak.Pointer[cacheValue]).Value(); v != nil {
+								output = WeakCacheOutput{found: true, val: v.val}
+							}
+						}
+					}
+					returnTime := time.Since(start).Nanoseconds()
+
+					history = append(history, porcupine.Operation{
+						ClientId: id,
+						Input:    input,
+						Call:     call,
+						Output:   output,
+						Return:   returnTime,
+					})
+				}
+				recorded[id] = history
+				runtime.KeepAlive(pinned)
+			}(g)
+		}
+
+		wg.Wait()
+		for _, history := range recorded {
+			operations = append(operations, history...)
+		}
+
+		for _, op := range operations {
+			if op.Input.(WeakCacheInput).op == OpGet && !op.Output.(WeakCacheOutput).found {
+				vanished++
+			}
+		}
+
+		result, info := porcupine.CheckOperationsVerbose(WeakCacheModel, operations, cfg.checkTimeout)
+
+		if result == porcupine.Illegal {
+			filename := artifact(t, workload.ViolationName("weakcache", round)+".html")
+			file, err := os.Create(filename)
+			if err != nil {
+				t.Fatalf("Round %d: failed to create file %s: %v", round, filename, err)
+			}
+			porcupine.Visualize(WeakCacheModel, info, file)
+			file.Close()
+			pruneViolations(t)
+			t.Fatalf("Round %d: weak cache violation saved to %s", round, filename)
+		}
+	}
+	t.Logf("no violation observed after %d rounds (%d gets found the entry vanished)", numRounds, vanished)
+}
+
+type CacheOpKind int
+
+const (
+	OpPut CacheOpKind = iota
+	OpGet
+)
+
+type WeakCacheInput struct {
+	op  CacheOpKind
+	val int
+}
+
+type WeakCacheOutput struct {
+	found bool
+	val   int
+}
+
+// WeakCacheState is the value last put, and whether it may still be observed.
+type WeakCacheState struct {
+	alive bool
+	val   int
+}
+
+var WeakCacheModel = porcupine.Model{
+	Init: func() interface{} { return WeakCacheState{} },
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		st := state.(WeakCacheState)
+		in := input.(WeakCacheInput)
+		out := output.(WeakCacheOutput)
+
+		switch in.op {
+		case OpPut:
+			return true, WeakCacheState{alive: true, val: in.val}
+		case OpGet:
+			if !out.found {
+				// The GC may have collected the value at any point before
+				// this Get; from now on it is gone for good.
+				return true, WeakCacheState{}
+			}
+			return st.alive && out.val == st.val, st
+		default:
+			return false, st
+		}
+	},
+	DescribeOperation: func(input, output interface{}) string {
+		inp := input.(WeakCacheInput)
+		out := output.(WeakCacheOutput)
+
+		switch inp.op {
+		case OpPut:
+			return fmt.Sprintf("Put(%d)", inp.val)
+		case OpGet:
+			if out.found {
+				return fmt.Sprintf("Get() -> %d", out.val)
+			}
+			return "Get() -> vanished"
+		default:
+			return "Unknown operation"
+		}
+	},
+}
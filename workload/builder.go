@@ -0,0 +1,181 @@
+package workload
+
+import (
+	"maps"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// A Builder builds a Spec from Default, for programs that check their own
+// structures with the workloads of this module:
+//
+//	s, err := workload.New().
+//		Map("mymap", func() mapimpl.MapUnderTest { return mymap.New() }).
+//		Workers(8).Ops(50).Keys(16, workload.Dist{Kind: "zipf"}).
+//		Mix(map[model.Op]int{model.Load: 8, model.Store: 1, model.Range: 1}).
+//		Build()
+//	if err == nil {
+//		err = s.Run(workload.RunOptions{})
+//	}
+type Builder struct {
+	s Spec
+}
+
+// New returns a Builder starting from Default.
+func New() *Builder {
+	return &Builder{s: Default()}
+}
+
+// Name labels the workload.
+func (b *Builder) Name(name string) *Builder {
+	b.s.Name = name
+	return b
+}
+
+// Impl selects a registered implementation.
+func (b *Builder) Impl(name string) *Builder {
+	b.s.Impl, b.s.NewMap = name, nil
+	return b
+}
+
+// Map checks the maps returned by newMap, labeled name.
+func (b *Builder) Map(name string, newMap func() mapimpl.MapUnderTest) *Builder {
+	b.s.Impl, b.s.NewMap = name, newMap
+	return b
+}
+
+// Workers sets the number of workers per round, 0 for GOMAXPROCS.
+func (b *Builder) Workers(n int) *Builder {
+	b.s.Workers = n
+	return b
+}
+
+// Rounds sets the number of rounds.
+func (b *Builder) Rounds(n int) *Builder {
+	b.s.Rounds = n
+	return b
+}
+
+// Ops sets the number of operations per worker and round.
+func (b *Builder) Ops(n int) *Builder {
+	b.s.Ops = n
+	return b
+}
+
+// Keys sets the size of the key space and the distribution of the keys.
+func (b *Builder) Keys(n int, d Dist) *Builder {
+	b.s.Keys, b.s.Dist = n, d
+	return b
+}
+
+// Values stores values as payloads of the kind, see ValueString, and
+// size in bytes.
+func (b *Builder) Values(kind string, size int) *Builder {
+	b.s.ValueKind, b.s.ValueSize = kind, size
+	return b
+}
+
+// Mix replaces the operation mix with weights.
+func (b *Builder) Mix(weights map[model.Op]int) *Builder {
+	b.s.Mix = make(map[string]int, len(weights))
+	for op, w := range weights {
+		b.s.Mix[op.String()] = w
+	}
+	return b
+}
+
+// Roles splits the workers into roles with their own mixes, replacing
+// Workers and Mix.
+func (b *Builder) Roles(roles ...Role) *Builder {
+	b.s.Roles, b.s.Workers = roles, 0
+	return b
+}
+
+// Seed sets the seed of the first round.
+func (b *Builder) Seed(seed uint64) *Builder {
+	b.s.Seed = seed
+	return b
+}
+
+// Warmup sets how the map is prepared before each round.
+func (b *Builder) Warmup(w Warmup) *Builder {
+	b.s.Warmup = w
+	return b
+}
+
+// Start sets how the workers of a round start.
+func (b *Builder) Start(st Start) *Builder {
+	b.s.Start = st
+	return b
+}
+
+// Nemesis sets the scheduler perturbations.
+func (b *Builder) Nemesis(n Nemesis) *Builder {
+	b.s.Nemesis = n
+	return b
+}
+
+// GC sets the garbage collector pressure.
+func (b *Builder) GC(g GC) *Builder {
+	b.s.GC = g
+	return b
+}
+
+// CPULoad sets the antagonist keeping CPUs busy during each round.
+func (b *Builder) CPULoad(l CPULoad) *Builder {
+	b.s.CPULoad = l
+	return b
+}
+
+// Pending sets the operations left outstanding.
+func (b *Builder) Pending(p Pending) *Builder {
+	b.s.Pending = p
+	return b
+}
+
+// Yields sets the yield points around the operations.
+func (b *Builder) Yields(y Yields) *Builder {
+	b.s.Yields = y
+	return b
+}
+
+// Stream sets the batches the operations of each round are written to
+// disk in.
+func (b *Builder) Stream(st Stream) *Builder {
+	b.s.Stream = st
+	return b
+}
+
+// Watchdog sets the deadline of running and checking each round.
+func (b *Builder) Watchdog(w Watchdog) *Builder {
+	b.s.Watchdog = w
+	return b
+}
+
+// Clock sets the clock timestamping the operations, see ClockTime.
+func (b *Builder) Clock(kind string) *Builder {
+	b.s.Clock = kind
+	return b
+}
+
+// Timeout sets the checker timeout per round.
+func (b *Builder) Timeout(d time.Duration) *Builder {
+	b.s.Checker.Timeout = Duration(d)
+	return b
+}
+
+// Shards checks each history key by key, n keys at a time, see
+// Checker.Shards.
+func (b *Builder) Shards(n int) *Builder {
+	b.s.Checker.Shards = n
+	return b
+}
+
+// Build validates and returns the spec.
+func (b *Builder) Build() (Spec, error) {
+	s := b.s
+	s.Mix = maps.Clone(s.Mix)
+	return s, s.Validate()
+}
@@ -0,0 +1,70 @@
+package workload
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// forgetful drops every Store.
+type forgetful struct{ sync.Map }
+
+func (*forgetful) Store(key, value any) {}
+
+func TestBuilder(t *testing.T) {
+	s, err := New().Name("b").Impl("SyncMapOf").Workers(2).Rounds(3).Ops(40).
+		Keys(5, Dist{Kind: "zipf"}).Seed(9).Timeout(time.Second).
+		Mix(map[model.Op]int{model.Load: 2, model.Store: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "b: impl=SyncMapOf rounds=3 ops=40 workers=2 keys=5 mix=Load=2,Store=1 dist=zipf(s=1.1) seed=9"
+	if got := s.String(); got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	if err := s.Run(RunOptions{}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := New().Ops(0).Build(); err == nil {
+		t.Error("Build accepted 0 ops")
+	}
+}
+
+func TestRunCustomMap(t *testing.T) {
+	var rounds []int
+	s, err := New().Map("sync.Map", func() mapimpl.MapUnderTest { return new(sync.Map) }).
+		Workers(3).Rounds(5).Keys(2, Dist{}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Run(RunOptions{AfterRound: func(round int, _ mapimpl.MapUnderTest, _ []porcupine.Operation) error {
+		rounds = append(rounds, round)
+		return nil
+	}})
+	if err != nil || len(rounds) != 5 {
+		t.Errorf("Run = %v after rounds %v", err, rounds)
+	}
+
+	s, err = New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(1).Rounds(1).Ops(20).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Run(RunOptions{})
+	var v *Violation
+	if !errors.As(err, &v) || v.Seed != s.Seed {
+		t.Fatalf("Run = %v, want a Violation for seed %d", err, s.Seed)
+	}
+	var html strings.Builder
+	if err := v.Visualize(&html); err != nil || !strings.Contains(html.String(), "Store(k,") {
+		t.Errorf("Visualize = %v", err)
+	}
+}
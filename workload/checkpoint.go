@@ -0,0 +1,142 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Stats count the rounds of a Run.
+type Stats struct {
+	Rounds int `json:"rounds"`
+	Ops    int `json:"ops"`
+	// Unknown is the number of rounds the checker timed out on.
+	Unknown int `json:"unknown"`
+	// Violations is the number of rounds that were not linearizable, more
+	// than one only if several were in flight, or in Compare.
+	Violations int `json:"violations,omitempty"`
+	// Hung is the number of rounds the Watchdog gave up on.
+	Hung    int      `json:"hung,omitempty"`
+	Elapsed Duration `json:"elapsed"`
+	// Checking is the time spent checking the histories, summed over the
+	// histories checked at once.
+	Checking Duration `json:"checking,omitempty"`
+}
+
+func (st Stats) String() string {
+	str := fmt.Sprintf("%d rounds (%d ops) in %s, %d passed, %d unknown",
+		st.Rounds, st.Ops, time.Duration(st.Elapsed).Round(time.Second), st.Rounds-st.Unknown, st.Unknown)
+	if st.Hung > 0 {
+		str += fmt.Sprintf(", %d hung", st.Hung)
+	}
+	return str
+}
+
+// Add returns the sum of st and o, of runs one after the other or side by
+// side.
+func (st Stats) Add(o Stats) Stats {
+	return Stats{
+		Rounds:     st.Rounds + o.Rounds,
+		Ops:        st.Ops + o.Ops,
+		Unknown:    st.Unknown + o.Unknown,
+		Violations: st.Violations + o.Violations,
+		Hung:       st.Hung + o.Hung,
+		Elapsed:    st.Elapsed + o.Elapsed,
+		Checking:   st.Checking + o.Checking,
+	}
+}
+
+// A checkpoint is the state of a soak, written to RunOptions.Checkpoint so
+// an interrupted soak can resume with the round after the last one it
+// wrote.
+type checkpoint struct {
+	// Config is the String of the spec, to refuse resuming another
+	// workload.
+	Config string `json:"config"`
+	Seed   uint64 `json:"seed"`
+	Next   int    `json:"next_round"`
+	Stats  Stats  `json:"stats"`
+	// Spec is the JSON of the spec, and Duration that of the soak, for
+	// LoadCheckpoint.
+	Spec     json.RawMessage `json:"spec,omitempty"`
+	Duration Duration        `json:"duration,omitempty"`
+}
+
+// LoadCheckpoint returns the spec and the RunOptions.Duration of the run
+// whose checkpoint is at path, to resume it with the checkpoint without
+// giving them again.
+func LoadCheckpoint(path string) (Spec, time.Duration, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Spec{}, 0, fmt.Errorf("workload: %v", err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return Spec{}, 0, fmt.Errorf("workload: checkpoint %s: %v", path, err)
+	}
+	if len(cp.Spec) == 0 {
+		return Spec{}, 0, fmt.Errorf("workload: checkpoint %s has no spec, of a map the caller made or an older version", path)
+	}
+	s, err := Parse(bytes.NewReader(cp.Spec))
+	if err != nil {
+		return Spec{}, 0, fmt.Errorf("workload: checkpoint %s: %v", path, err)
+	}
+	return s, time.Duration(cp.Duration), nil
+}
+
+// resume reads the checkpoint at path into s and returns it, or a fresh
+// one if there is none yet.
+func (s *Spec) resume(path string) (checkpoint, error) {
+	if path == "" {
+		return checkpoint{}, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return checkpoint{}, nil
+	}
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("workload: %v", err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return checkpoint{}, fmt.Errorf("workload: checkpoint %s: %v", path, err)
+	}
+	if s.Seed != 0 && s.Seed != cp.Seed {
+		return checkpoint{}, fmt.Errorf("workload: checkpoint %s has seed %d, not %d", path, cp.Seed, s.Seed)
+	}
+	s.Seed = cp.Seed
+	if cfg := s.String(); cfg != cp.Config {
+		return checkpoint{}, fmt.Errorf("workload: checkpoint %s is of another workload: %s", path, cp.Config)
+	}
+	return cp, nil
+}
+
+// write replaces the checkpoint at path, through a temporary file so an
+// interruption never leaves half of one.
+func (cp checkpoint) write(path string) error {
+	b, err := json.MarshalIndent(cp, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("workload: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("workload: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("workload: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("workload: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func readCheckpoint(t *testing.T, path string) checkpoint {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		t.Fatal(err)
+	}
+	return cp
+}
+
+func TestSoak(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soak.json")
+	s, err := New().Workers(2).Rounds(1).Ops(20).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs []string
+	opts := RunOptions{
+		Duration:   100 * time.Millisecond,
+		Progress:   20 * time.Millisecond,
+		Checkpoint: path,
+		Logf:       func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) },
+	}
+	if err := s.Run(opts); err != nil {
+		t.Fatal(err)
+	}
+	first := readCheckpoint(t, path)
+	if first.Stats.Rounds <= s.Rounds || first.Next != first.Stats.Rounds || first.Seed != s.Seed {
+		t.Errorf("checkpoint after soak = %+v, want more than %d rounds of seed %d", first, s.Rounds, s.Seed)
+	}
+	if log := strings.Join(logs, "\n"); !strings.Contains(log, "progress: ") || !strings.Contains(log, "done: ") {
+		t.Errorf("soak logged:\n%s", log)
+	}
+
+	// The elapsed time counts toward the duration of the resumed soak.
+	logs = nil
+	resumed := s
+	resumed.Seed = 0
+	opts.Duration *= 2
+	if err := resumed.Run(opts); err != nil {
+		t.Fatal(err)
+	}
+	second := readCheckpoint(t, path)
+	if resumed.Seed != s.Seed || second.Next <= first.Next || time.Duration(second.Stats.Elapsed) < opts.Duration {
+		t.Errorf("checkpoint after resume = %+v, first %+v", second, first)
+	}
+	if !strings.Contains(logs[1], fmt.Sprintf("resuming at round %d", first.Next)) {
+		t.Errorf("resumed soak logged %q", logs[1])
+	}
+
+	other := s
+	other.Ops = 10
+	if err := other.Run(opts); err == nil || !strings.Contains(err.Error(), "another workload") {
+		t.Errorf("Run of another workload from the checkpoint = %v", err)
+	}
+}
+
+func TestCheckpointAfterViolation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soak.json")
+	s, err := New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(1).Rounds(1).Ops(20).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Run(RunOptions{Duration: time.Minute, Checkpoint: path})
+	var v *Violation
+	if !errors.As(err, &v) {
+		t.Fatalf("Run = %v, want a Violation", err)
+	}
+	if cp := readCheckpoint(t, path); cp.Next != v.Round+1 {
+		t.Errorf("checkpoint resumes with round %d, want %d after the violation", cp.Next, v.Round+1)
+	}
+}
+
+func TestStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soak.json")
+	s, err := New().Impl("MutexMap").Workers(2).Rounds(1000000).Ops(20).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var summary Stats
+	opts := RunOptions{
+		AfterRound: func(round int, _ mapimpl.MapUnderTest, _ []porcupine.Operation) error {
+			if round == 9 {
+				close(stop)
+			}
+			return nil
+		},
+		Duration:   time.Hour,
+		Checkpoint: path,
+		InFlight:   4,
+		Stop:       stop,
+		Summary:    &summary,
+	}
+	if err := s.Run(opts); !errors.Is(err, ErrStopped) {
+		t.Fatalf("Run = %v, want ErrStopped", err)
+	}
+	// The round the stop came in is finished and checked.
+	if cp := readCheckpoint(t, path); cp.Next != 10 || cp.Stats.Rounds != 10 || summary.Rounds != 10 {
+		t.Errorf("checkpoint %+v and summary %+v after the stop in round 9", cp, summary)
+	}
+
+	resumed, duration, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duration != time.Hour || resumed.String() != s.String() {
+		t.Fatalf("LoadCheckpoint = %s, %v, want %s, %v", &resumed, duration, &s, time.Hour)
+	}
+	opts.AfterRound, opts.Stop, opts.Duration = nil, nil, 0
+	resumed.Rounds = 15
+	if err := resumed.Run(opts); err == nil || !strings.Contains(err.Error(), "another workload") {
+		t.Errorf("Run with other rounds from the checkpoint = %v", err)
+	}
+	if _, _, err := LoadCheckpoint(filepath.Join(t.TempDir(), "none.json")); err == nil {
+		t.Error("LoadCheckpoint of no file succeeded")
+	}
+}
+
+func TestProgressReport(t *testing.T) {
+	cp := checkpoint{Next: 40, Stats: Stats{Rounds: 40, Ops: 400, Violations: 1, Elapsed: Duration(30 * time.Second)}}
+	// 30 rounds in 10s since resuming after 10, and 60 rounds to go.
+	got := progressReport(cp, 10, 10*time.Second, 100, 0)
+	if !strings.HasSuffix(got, ", 1 violations, 3.0 rounds/s, ETA 20s") {
+		t.Errorf("progress of the rounds: %s", got)
+	}
+	if got := progressReport(cp, 10, 10*time.Second, 0, time.Minute); !strings.HasSuffix(got, "3.0 rounds/s, ETA 30s") {
+		t.Errorf("progress of the soak: %s", got)
+	}
+	if got := progressReport(checkpoint{}, 0, time.Second, 100, 0); !strings.HasSuffix(got, "0.0 rounds/s") {
+		t.Errorf("progress without rounds: %s", got)
+	}
+}
+
+func TestStatsAdd(t *testing.T) {
+	a := Stats{Rounds: 3, Ops: 30, Unknown: 1, Elapsed: Duration(time.Second)}
+	b := Stats{Rounds: 2, Ops: 20, Violations: 1, Hung: 1, Checking: Duration(time.Millisecond)}
+	want := Stats{Rounds: 5, Ops: 50, Unknown: 1, Violations: 1, Hung: 1, Elapsed: Duration(time.Second), Checking: Duration(time.Millisecond)}
+	if got := a.Add(b); got != want {
+		t.Errorf("Add = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,11 @@
+package workload
+
+import "github.com/jmasters-git/porcupine-syncmap/recorder"
+
+// The clocks timestamping the operations of a round, see Spec.Clock and
+// the clocks of package recorder.
+const (
+	ClockTime     = recorder.ClockTime
+	ClockNanotime = recorder.ClockNanotime
+	ClockTSC      = recorder.ClockTSC
+)
@@ -0,0 +1,38 @@
+package workload
+
+import (
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/asm"
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestClocks(t *testing.T) {
+	for _, kind := range []string{ClockTime, ClockNanotime, ClockTSC} {
+		t.Run(kind, func(t *testing.T) {
+			if kind == ClockTSC && !asm.HasTicks {
+				t.Skip("no counter on this architecture")
+			}
+			s, err := New().Workers(4).Ops(50).Keys(2, Dist{}).Clock(kind).Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			impl, _ := mapimpl.Lookup(s.Impl)
+			h := s.Round(impl.New(), 1, nil)
+			for _, op := range h.Operations {
+				if op.Call < 0 || op.Return < op.Call {
+					t.Fatalf("operation %+v has timestamps out of order", op)
+				}
+			}
+			if !porcupine.CheckOperations(model.Model, h.Operations) {
+				t.Error("history is not linearizable")
+			}
+		})
+	}
+	if _, err := New().Clock("sundial").Build(); err == nil {
+		t.Error("Build accepted an unknown clock")
+	}
+}
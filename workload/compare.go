@@ -0,0 +1,149 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+// A Comparison is the outcome of the workload of Compare on one
+// implementation.
+type Comparison struct {
+	Impl  string `json:"impl"`
+	Stats Stats  `json:"stats"`
+	// Running is the time spent running the rounds, without checking them.
+	Running Duration `json:"running"`
+	// OpsPerSec are the operations of the workers per second of Running.
+	OpsPerSec float64 `json:"ops_per_sec"`
+	// Timestamps is the number of rounds with timestamps out of order,
+	// which are not checked, and Probes the number of rounds AfterRound
+	// failed.
+	Timestamps int `json:"timestamps,omitempty"`
+	Probes     int `json:"probes,omitempty"`
+	// Histograms are those of the durations of each kind of operation of
+	// every round, by name, as in HistoryStats.
+	Histograms map[string]*Histogram `json:"-"`
+	// Violation is the first round that was not linearizable, if any.
+	Violation *Violation `json:"-"`
+	// Err is the first error of AfterRound, ValidateTimestamps or reading
+	// back the streamed operations of a round.
+	Err error `json:"-"`
+}
+
+// Passed reports whether every round of c was linearizable and free of
+// other anomalies.
+func (c *Comparison) Passed() bool {
+	return c.Stats.Violations == 0 && c.Stats.Hung == 0 && c.Timestamps == 0 && c.Probes == 0
+}
+
+// Compare runs s against every one of impls, with the same seeds, and
+// returns their comparisons in order. Unlike Run it does not stop at the
+// first anomaly of an implementation but runs and counts all of its
+// rounds, and skips those that hang past the spec's Watchdog. Of opts, only Fence, AfterRound, Logf, Verbose, Logger and InFlight
+// apply. If Seed is 0, Compare picks a random one for all of them, the
+// configuration it logs shows it.
+func Compare(s Spec, impls []mapimpl.Impl, opts RunOptions) ([]Comparison, error) {
+	log := opts.logger()
+	if s.Seed == 0 {
+		s.Seed = rand.Uint64()
+	}
+	var results []Comparison
+	for _, impl := range impls {
+		s := s
+		s.Impl, s.NewMap = impl.Name, impl.New
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		log := log.With("impl", s.Impl)
+		log.Info(fmt.Sprintf("config: %s", &s), "config", s.String(), "seed", s.Seed)
+		c := s.compare(opts, log)
+		log.Info(fmt.Sprintf("%s: %s, %.0f ops/s", c.Impl, c.Stats, c.OpsPerSec), "stats", c.Stats)
+		results = append(results, c)
+	}
+	return results, nil
+}
+
+// compare runs and checks every round of s.
+func (s *Spec) compare(opts RunOptions, log *slog.Logger) Comparison {
+	res := Comparison{Impl: s.Impl, Histograms: make(map[string]*Histogram)}
+	start := time.Now()
+	c := newChecker(time.Duration(s.Checker.Timeout), opts.InFlight, Stats{}, 0)
+	// A hung round is counted like the other anomalies.
+	c.watchdog, c.log, c.shards = s.Watchdog, log, s.Checker.Shards
+	c.watchdog.Skip = true
+	var running time.Duration
+	for round := range s.Rounds {
+		seed := s.RoundSeed(round)
+		log.Log(context.Background(), opts.roundLevel(), fmt.Sprintf("%s round %d: seed %d", s.Impl, round, seed), "round", round, "seed", seed)
+		m := s.newMap()
+		t := time.Now()
+		h, err := s.watchRound(c, m, round, seed, opts.Fence)
+		running += time.Since(t)
+		if err != nil {
+			if res.Err == nil {
+				res.Err = fmt.Errorf("round %d (seed %d): %w", round, seed, err)
+			}
+			continue
+		}
+		if h == nil {
+			continue
+		}
+		for name, hist := range h.Stats().Histograms {
+			if res.Histograms[name] == nil {
+				res.Histograms[name] = new(Histogram)
+			}
+			res.Histograms[name].Merge(hist)
+		}
+
+		// A probe failure still leaves a history to check.
+		if opts.AfterRound != nil {
+			if err := opts.AfterRound(round, m, h.Operations); err != nil {
+				res.Probes++
+				if res.Err == nil {
+					res.Err = fmt.Errorf("round %d (seed %d): %w", round, seed, err)
+				}
+			}
+		}
+		if err := ValidateTimestamps(h.Operations, time.Duration(s.Checker.Epsilon)); err != nil {
+			res.Timestamps++
+			if res.Err == nil {
+				res.Err = fmt.Errorf("round %d (seed %d): %w", round, seed, err)
+			}
+			continue
+		}
+		c.submit(round, seed, h)
+	}
+	c.wait()
+
+	res.Stats, _ = c.progress()
+	res.Stats.Elapsed = Duration(time.Since(start))
+	res.Running = Duration(running)
+	if running > 0 {
+		res.OpsPerSec = float64(s.Rounds*s.Ops*s.NumWorkers()) / running.Seconds()
+	}
+	res.Violation = c.failed()
+	return res
+}
+
+// CompareTable formats comparisons as a table, one row per implementation.
+func CompareTable(results []Comparison) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-12s %-6s %7s %12s %10s %10s %8s %5s %10s %6s\n",
+		"impl", "result", "rounds", "ops/s", "check", "violations", "unknown", "hung", "timestamps", "probes")
+	for _, c := range results {
+		status := "ok"
+		if !c.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%-12s %-6s %7d %12.0f %10v %10d %8d %5d %10d %6d\n",
+			c.Impl, status, c.Stats.Rounds+c.Stats.Hung+c.Timestamps, c.OpsPerSec,
+			time.Duration(c.Stats.Checking).Round(time.Millisecond),
+			c.Stats.Violations, c.Stats.Unknown, c.Stats.Hung, c.Timestamps, c.Probes)
+	}
+	return b.String()
+}
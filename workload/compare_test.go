@@ -0,0 +1,39 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestCompare(t *testing.T) {
+	s, err := New().Workers(2).Rounds(10).Ops(30).
+		Mix(map[model.Op]int{model.Store: 1, model.Load: 1, model.Range: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impls := append(mapimpl.All(), mapimpl.Impl{Name: "forgetful", New: func() mapimpl.MapUnderTest { return new(forgetful) }})
+	results, err := Compare(s, impls, RunOptions{InFlight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(impls) {
+		t.Fatalf("%d results for %d implementations", len(results), len(impls))
+	}
+	for _, c := range results[:len(results)-1] {
+		if !c.Passed() || c.Stats.Rounds != 10 || c.OpsPerSec <= 0 {
+			t.Errorf("%s: %+v", c.Impl, c)
+		}
+	}
+	if f := results[len(results)-1]; f.Passed() || f.Violation == nil || f.Stats.Rounds != 10 {
+		t.Errorf("forgetful map passed or stopped early: %+v", f)
+	}
+	table := CompareTable(results)
+	for _, want := range []string{"MutexMap", "ShardedMap", "forgetful    FAIL"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("table lacks %q:\n%s", want, table)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package workload
+
+import (
+	"fmt"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/cpuload"
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// CPULoad runs an antagonist during each round: Cores goroutines that keep
+// a CPU busy each, so the workers share the processors of GOMAXPROCS with
+// them and are preempted, descheduled and migrated more often than on an
+// idle machine. The load covers the round in the visualization of a
+// violation.
+type CPULoad struct {
+	Cores int `json:"cores,omitempty"`
+	// Duty is the percentage of every millisecond each goroutine is busy,
+	// the rest it sleeps. 0 is 100.
+	Duty int `json:"duty,omitempty"`
+}
+
+func (l CPULoad) String() string {
+	return fmt.Sprintf("%dx%d%%", l.Cores, l.duty())
+}
+
+func (l CPULoad) duty() int {
+	if l.Duty == 0 {
+		return 100
+	}
+	return l.Duty
+}
+
+func (l CPULoad) validate() error {
+	if err := cpuload.Load(l).Validate(); err != nil {
+		return fmt.Errorf("workload: %v", err)
+	}
+	return nil
+}
+
+// start starts the load for the round and returns the function that stops
+// it and adds its annotation to h.
+func (l CPULoad) start(clk *recorder.Clock, h *History) (stop func()) {
+	if l.Cores == 0 {
+		return func() {}
+	}
+	at := clk.Now()
+	stopLoad := cpuload.Load(l).Start()
+	return func() {
+		stopLoad()
+		h.Annotations = append(h.Annotations, porcupine.Annotation{
+			Tag:             "cpu load",
+			Start:           at,
+			End:             clk.Now(),
+			Description:     l.String(),
+			Details:         fmt.Sprintf("%d goroutines busy %d%% of the time", l.Cores, l.duty()),
+			BackgroundColor: "#f6e1d7",
+		})
+	}
+}
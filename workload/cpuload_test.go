@@ -0,0 +1,33 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCPULoad(t *testing.T) {
+	s := Default()
+	s.Rounds, s.Workers = 5, 2
+	s.CPULoad = CPULoad{Cores: 2, Duty: 50}
+	if !strings.Contains(s.String(), " cpu_load=2x50%") {
+		t.Errorf("String = %q", s.String())
+	}
+	if err := s.Run(RunOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	h := s.Round(s.newMap(), 1, nil)
+	loads := 0
+	for _, a := range h.Annotations {
+		if a.Tag == "cpu load" {
+			loads++
+		}
+	}
+	if loads != 1 {
+		t.Errorf("%d cpu load annotations, want 1", loads)
+	}
+
+	s.CPULoad.Duty = 101
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "duty") {
+		t.Errorf("Validate = %v, want a duty error", err)
+	}
+}
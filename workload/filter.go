@@ -0,0 +1,85 @@
+package workload
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// A Filter picks the part of a history to look at, see Restrict. The zero
+// Filter keeps all of it.
+type Filter struct {
+	// From and To bound the window of time to keep, after the first call
+	// of the history; a To of 0 keeps the rest of it.
+	From, To time.Duration
+	// Clients, if set, are the only clients to keep.
+	Clients []int
+	// Keys, if set, are the only keys to keep.
+	Keys []string
+}
+
+// Restrict returns the operations of h that f keeps, and its annotations
+// that overlap the window and are not those of clients it drops. An
+// operation is kept if it overlaps the window. With Keys, every Range is
+// kept with only the entries of those keys, and since the model checks
+// each key on its own, the history of those keys checks as it did as a
+// whole. A window or
+// subset of clients, on the other hand, may leave out the operations that
+// explain what the others returned: it is a view, to visualize, and need
+// not be linearizable when h is. Restrict fails if the window ends before
+// it starts.
+func Restrict(h *History, f Filter) (*History, error) {
+	if f.From < 0 || f.To < 0 || f.To > 0 && f.To < f.From {
+		return nil, errors.New("workload: window of the filter ends before it starts")
+	}
+	ops := h.Operations
+	if len(f.Keys) > 0 {
+		ops = nil
+		for _, op := range h.Operations {
+			in := op.Input.(model.Input)
+			if in.Op != model.Range {
+				if slices.Contains(f.Keys, in.Key) {
+					ops = append(ops, op)
+				}
+				continue
+			}
+			out := op.Output.(model.Output)
+			entries := make(map[string]int)
+			for k, v := range out.Entries {
+				if slices.Contains(f.Keys, k) {
+					entries[k] = v
+				}
+			}
+			out.Entries = entries
+			op.Output = out
+			ops = append(ops, op)
+		}
+	}
+	var first int64
+	for i, op := range h.Operations {
+		if i == 0 || op.Call < first {
+			first = op.Call
+		}
+	}
+	from, to := first+f.From.Nanoseconds(), first+f.To.Nanoseconds()
+	within := func(start, end int64) bool {
+		return end >= from && (f.To == 0 || start <= to)
+	}
+	client := func(id int) bool {
+		return len(f.Clients) == 0 || slices.Contains(f.Clients, id)
+	}
+	r := new(History)
+	for _, op := range ops {
+		if within(op.Call, op.Return) && client(op.ClientId) {
+			r.Operations = append(r.Operations, op)
+		}
+	}
+	for _, a := range h.Annotations {
+		if within(a.Start, max(a.Start, a.End)) && (a.Tag != "" || client(a.ClientId)) {
+			r.Annotations = append(r.Annotations, a)
+		}
+	}
+	return r, nil
+}
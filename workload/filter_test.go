@@ -0,0 +1,55 @@
+package workload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestRestrict(t *testing.T) {
+	h := &History{
+		Operations: []porcupine.Operation{
+			{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a", Val: 1}, Output: model.Output{}, Call: 100, Return: 110},
+			{ClientId: 1, Input: model.Input{Op: model.Store, Key: "b", Val: 2}, Output: model.Output{}, Call: 105, Return: 120},
+			{ClientId: 0, Input: model.Input{Op: model.Range}, Output: model.Output{Entries: map[string]int{"a": 1, "b": 2}}, Call: 130, Return: 150},
+			{ClientId: 1, Input: model.Input{Op: model.Load, Key: "a"}, Output: model.Output{Found: true, Val: 1}, Call: 200, Return: 210},
+		},
+		Annotations: []porcupine.Annotation{
+			{ClientId: 1, Start: 106, End: 106, Description: "gosched"},
+			{Tag: "phase", Start: 100, End: 210, Description: "workers"},
+		},
+	}
+	for _, tt := range []struct {
+		f           Filter
+		ops, annots int
+	}{
+		{Filter{}, 4, 2},
+		{Filter{Clients: []int{0}}, 2, 1},
+		{Filter{From: 15 * time.Nanosecond, To: 40 * time.Nanosecond}, 2, 1},
+		{Filter{From: 60 * time.Nanosecond}, 1, 1},
+		// The Range is kept with its entry of a.
+		{Filter{Keys: []string{"a"}}, 3, 2},
+	} {
+		r, err := Restrict(h, tt.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.Operations) != tt.ops || len(r.Annotations) != tt.annots {
+			t.Errorf("Restrict(%+v): %d operations and %d annotations, want %d and %d", tt.f, len(r.Operations), len(r.Annotations), tt.ops, tt.annots)
+		}
+	}
+
+	r, _ := Restrict(h, Filter{Keys: []string{"a"}})
+	if out := r.Operations[1].Output.(model.Output); len(out.Entries) != 1 || out.Entries["a"] != 1 {
+		t.Errorf("Range kept with %v", out.Entries)
+	}
+	if !porcupine.CheckOperations(model.Model, r.Operations) {
+		t.Error("history of key a is not linearizable")
+	}
+	if _, err := Restrict(h, Filter{From: time.Second, To: time.Millisecond}); err == nil {
+		t.Error("Restrict accepted a window that ends before it starts")
+	}
+}
@@ -0,0 +1,98 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// GC puts each round under garbage collector pressure: a background
+// goroutine keeps allocating, and runtime.GC runs at a fixed cadence. The
+// sync.Map entries expunged on promotion and the HashTrieMap nodes of
+// unique and weak are reclaimed by the GC, which the workload alone
+// barely triggers.
+type GC struct {
+	// AllocSize, if set, is the size of the objects the background
+	// allocator keeps allocating, it retains the most recent 64 so they
+	// survive a little while.
+	AllocSize int `json:"alloc_size,omitempty"`
+	// Interval, if set, is the cadence of runtime.GC calls, each recorded
+	// as an annotation.
+	Interval Duration `json:"interval,omitempty"`
+}
+
+func (g GC) enabled() bool { return g.AllocSize > 0 || g.Interval > 0 }
+
+func (g GC) String() string {
+	return fmt.Sprintf("alloc_size=%d interval=%v", g.AllocSize, time.Duration(g.Interval))
+}
+
+func (g GC) validate() error {
+	if g.AllocSize < 0 || g.Interval < 0 {
+		return errors.New("workload: gc: alloc_size and interval must not be negative")
+	}
+	return nil
+}
+
+// start starts the pressure for the round and returns the function that
+// stops it and adds its annotations to h.
+func (g GC) start(clk *recorder.Clock, h *History) (stop func()) {
+	if !g.enabled() {
+		return func() {}
+	}
+	var (
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+		anns []porcupine.Annotation
+	)
+	if g.AllocSize > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retained := make([][]byte, 64)
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				retained[i%len(retained)] = make([]byte, g.AllocSize)
+			}
+		}()
+	}
+	if g.Interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tick := time.NewTicker(time.Duration(g.Interval))
+			defer tick.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-tick.C:
+				}
+				at := clk.Now()
+				runtime.GC()
+				anns = append(anns, porcupine.Annotation{
+					Tag:             "gc",
+					Start:           at,
+					End:             clk.Now(),
+					Description:     "runtime.GC",
+					BackgroundColor: "#d7e8f6",
+				})
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+		h.Annotations = append(h.Annotations, anns...)
+	}
+}
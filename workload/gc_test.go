@@ -0,0 +1,41 @@
+package workload
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+func TestGC(t *testing.T) {
+	s := Default()
+	s.Workers, s.Ops = 2, 2000
+	s.GC = GC{AllocSize: 1 << 10, Interval: Duration(50 * time.Microsecond)}
+	impl, _ := mapimpl.Lookup(s.Impl)
+
+	// With few Ps the round can be over before the ticker's goroutine is
+	// scheduled, so give it a few rounds.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	gcs := 0
+	for range 20 {
+		h := s.Round(impl.New(), 1, nil)
+		for _, a := range h.Annotations {
+			if a.Tag == "gc" {
+				gcs++
+			}
+		}
+		if gcs > 0 {
+			break
+		}
+	}
+	runtime.ReadMemStats(&after)
+
+	if after.NumGC == before.NumGC {
+		t.Error("no GC ran during the rounds")
+	}
+	if gcs == 0 {
+		t.Error("no runtime.GC annotation")
+	}
+}
@@ -0,0 +1,94 @@
+package workload
+
+import (
+	"math"
+	"math/bits"
+)
+
+// histogramBits is the log2 of the number of buckets each power of two of
+// a Histogram is split into, which bounds its relative error to 1/8.
+const histogramBits = 3
+
+// A Histogram counts durations in buckets the way HdrHistogram does, with
+// linear buckets within each power of two: exact below 8ns and within an
+// eighth above, however long the durations. Unlike the percentiles of a
+// history, which need all of its durations, histograms merge, so that
+// those of many rounds add up to the distribution of all of them.
+type Histogram struct {
+	counts []int
+	total  int
+}
+
+// histogramIndex returns the bucket of d.
+func histogramIndex(d Duration) int {
+	v := uint64(max(d, 0))
+	if v < 1<<histogramBits {
+		return int(v)
+	}
+	// v is in [2^e, 2^(e+1)), split into 2^histogramBits buckets.
+	e := bits.Len64(v) - 1
+	shift := e - histogramBits
+	return (shift+1)<<histogramBits + int(v>>shift) - 1<<histogramBits
+}
+
+// histogramBelow returns the upper bound of bucket i, the least duration
+// of the next one.
+func histogramBelow(i int) Duration {
+	if i < 1<<histogramBits {
+		return Duration(i + 1)
+	}
+	shift := i>>histogramBits - 1
+	sub := i&(1<<histogramBits-1) + 1<<histogramBits
+	return Duration((sub + 1) << shift)
+}
+
+// Record counts d.
+func (h *Histogram) Record(d Duration) {
+	i := histogramIndex(d)
+	for len(h.counts) <= i {
+		h.counts = append(h.counts, 0)
+	}
+	h.counts[i]++
+	h.total++
+}
+
+// Merge adds the counts of o to h.
+func (h *Histogram) Merge(o *Histogram) {
+	for len(h.counts) < len(o.counts) {
+		h.counts = append(h.counts, 0)
+	}
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+	h.total += o.total
+}
+
+// Count returns the number of durations counted.
+func (h *Histogram) Count() int { return h.total }
+
+// Quantile returns the upper bound of the bucket of the duration at
+// quantile q, from 0 to 1, by nearest rank, or 0 if h is empty.
+func (h *Histogram) Quantile(q float64) Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := max(int(math.Ceil(q*float64(h.total))), 1)
+	n := 0
+	for i, c := range h.counts {
+		if n += c; n >= rank {
+			return histogramBelow(i)
+		}
+	}
+	return histogramBelow(len(h.counts) - 1)
+}
+
+// Buckets returns the buckets of h that are not empty, in order.
+func (h *Histogram) Buckets() []Bucket {
+	var b []Bucket
+	for i, c := range h.counts {
+		if c > 0 {
+			b = append(b, Bucket{Below: histogramBelow(i), Count: c})
+		}
+	}
+	return b
+}
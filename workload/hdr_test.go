@@ -0,0 +1,45 @@
+package workload
+
+import (
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	// Every bucket holds the durations from the bound of the one before
+	// it up to its own, within an eighth of them.
+	prev := Duration(0)
+	for i := range 200 {
+		below := histogramBelow(i)
+		if below <= prev || (i > 8 && float64(below-prev)/float64(prev) > 1.0/8) {
+			t.Fatalf("bucket %d is [%d, %d)", i, prev, below)
+		}
+		for _, d := range []Duration{prev, below - 1} {
+			if got := histogramIndex(d); got != i {
+				t.Fatalf("%d is in bucket %d, want %d", d, got, i)
+			}
+		}
+		prev = below
+	}
+
+	var a, b Histogram
+	for d := range Duration(100) {
+		a.Record(d * 1000)
+	}
+	b.Record(5)
+	b.Merge(&a)
+	if b.Count() != 101 {
+		t.Errorf("%d durations merged, want 101", b.Count())
+	}
+	if p50 := b.Quantile(0.5); p50 < 49000 || p50 > 49000*9/8 {
+		t.Errorf("p50 = %v, want about 49µs", p50)
+	}
+	if min, max := b.Quantile(0), b.Quantile(1); min != 1 || max < 99000 || max > 99000*9/8 {
+		t.Errorf("min %v, max %v, want 1ns and about 99µs", min, max)
+	}
+	if bs := b.Buckets(); bs[0] != (Bucket{Below: 1, Count: 1}) || bs[1] != (Bucket{Below: 6, Count: 1}) {
+		t.Errorf("buckets %v", bs[:2])
+	}
+	if new(Histogram).Quantile(0.99) != 0 {
+		t.Error("quantile of an empty histogram")
+	}
+}
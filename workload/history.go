@@ -0,0 +1,89 @@
+package workload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// historyVersion is the version of the JSON form of a History. Fields are
+// only ever added, a change that breaks readers bumps it.
+const historyVersion = 1
+
+// historyJSON is the JSON form of a History.
+type historyJSON struct {
+	Version     int              `json:"version"`
+	Operations  json.RawMessage  `json:"operations"`
+	Annotations []annotationJSON `json:"annotations,omitempty"`
+}
+
+type annotationJSON struct {
+	ClientId        int    `json:"client_id"`
+	Tag             string `json:"tag,omitempty"`
+	Start           int64  `json:"start"`
+	End             int64  `json:"end"`
+	Description     string `json:"description,omitempty"`
+	Details         string `json:"details,omitempty"`
+	TextColor       string `json:"text_color,omitempty"`
+	BackgroundColor string `json:"background_color,omitempty"`
+}
+
+// MarshalJSON encodes h with its operations in the form of
+// model.MarshalOperations, so the history of a round can be saved and
+// checked or visualized again later.
+func (h *History) MarshalJSON() ([]byte, error) {
+	ops, err := model.MarshalOperations(h.Operations)
+	if err != nil {
+		return nil, err
+	}
+	enc := historyJSON{Version: historyVersion, Operations: ops}
+	for _, a := range h.Annotations {
+		enc.Annotations = append(enc.Annotations, annotationJSON(a))
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON decodes a history encoded by MarshalJSON.
+func (h *History) UnmarshalJSON(b []byte) error {
+	var enc historyJSON
+	if err := json.Unmarshal(b, &enc); err != nil {
+		return err
+	}
+	if enc.Version != historyVersion {
+		return fmt.Errorf("workload: history version %d, want %d", enc.Version, historyVersion)
+	}
+	ops, err := model.UnmarshalOperations(enc.Operations)
+	if err != nil {
+		return err
+	}
+	h.Operations, h.Annotations = ops, nil
+	for _, a := range enc.Annotations {
+		h.Annotations = append(h.Annotations, porcupine.Annotation(a))
+	}
+	return nil
+}
+
+// WriteCSV writes the operations of h as CSV, see
+// model.WriteOperationsCSV, for spreadsheets and data frames. The
+// annotations are left out.
+func (h *History) WriteCSV(w io.Writer) error {
+	return model.WriteOperationsCSV(w, h.Operations)
+}
+
+// LoadHistory reads a history saved as JSON.
+func LoadHistory(path string) (*History, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("workload: %v", err)
+	}
+	h := new(History)
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return h, nil
+}
@@ -0,0 +1,53 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestHistoryJSON(t *testing.T) {
+	s := Default()
+	s.Workers, s.Keys = 3, 4
+	s.Mix = map[string]int{"Store": 2, "Load": 2, "Range": 1, "CompareAndSwap": 1}
+	s.Nemesis = Nemesis{SleepPercent: 10, MaxSleep: Duration(time.Microsecond)}
+	h := s.Round(s.newMap(), 7, nil)
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An empty Range decodes with nil entries, compare the encodings.
+	again, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, b) {
+		t.Errorf("round trip changed the history:\n%s\n%s", b, again)
+	}
+	if len(got.Operations) != len(h.Operations) || len(got.Annotations) != len(h.Annotations) || len(h.Annotations) == 0 {
+		t.Errorf("%d operations and %d annotations, want %d and %d", len(got.Operations), len(got.Annotations), len(h.Operations), len(h.Annotations))
+	}
+	if res := porcupine.CheckOperations(model.Model, got.Operations); !res {
+		t.Error("decoded history is not linearizable")
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":2,"operations":[]}`), new(History)); err == nil {
+		t.Error("a history of another version was accepted")
+	}
+}
@@ -0,0 +1,169 @@
+package workload
+
+import (
+	"cmp"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// HistoryStats describe the operations of a history, those that returned
+// within it: pending operations have no duration and the Stores of a
+// warmup, before the round starts at 0, are not timed.
+type HistoryStats struct {
+	Ops int `json:"ops"`
+	// Span is the time from the first call to the last return.
+	Span Duration `json:"span"`
+	// OpsPerSec is the number of operations per second of the span.
+	OpsPerSec float64 `json:"ops_per_sec"`
+	// Overlap is the average number of operations in progress over the
+	// span, the sum of their durations divided by it, and MaxOverlap the
+	// most at any time. An overlap of 1 or less means the workers barely
+	// ran at the same time, and the history says little about concurrency.
+	Overlap    float64 `json:"overlap"`
+	MaxOverlap int     `json:"max_overlap"`
+	// Latency is the distribution of the durations of every operation,
+	// ByOp that of each kind of operation, by name.
+	Latency Latency            `json:"latency"`
+	ByOp    map[string]Latency `json:"by_op,omitempty"`
+	// Durations counts the operations by duration, in buckets of powers
+	// of two, and Histograms those of each kind of operation, by name, in
+	// finer ones to merge with those of other histories.
+	Durations  []Bucket              `json:"durations,omitempty"`
+	Histograms map[string]*Histogram `json:"-"`
+}
+
+// Latency is a distribution of operation durations.
+type Latency struct {
+	Count int      `json:"count"`
+	P50   Duration `json:"p50"`
+	P90   Duration `json:"p90"`
+	P99   Duration `json:"p99"`
+	Max   Duration `json:"max"`
+}
+
+func (l Latency) String() string {
+	return fmt.Sprintf("p50=%v p90=%v p99=%v max=%v", time.Duration(l.P50), time.Duration(l.P90), time.Duration(l.P99), time.Duration(l.Max))
+}
+
+// A Bucket counts the operations that took less than Below, and at least
+// the Below of the bucket before it.
+type Bucket struct {
+	Below Duration `json:"below"`
+	Count int      `json:"count"`
+}
+
+// Stats returns the statistics of the operations of h.
+func (h *History) Stats() HistoryStats {
+	var (
+		st     HistoryStats
+		all    []int64
+		byOp   = make(map[string][]int64)
+		events []int64
+		first  int64
+		last   int64
+		sum    int64
+	)
+	for _, op := range h.Operations {
+		if op.Call < 0 || op.Output.(model.Output).Pending {
+			continue
+		}
+		d := op.Return - op.Call
+		if len(all) == 0 {
+			first, last = op.Call, op.Return
+		}
+		first, last = min(first, op.Call), max(last, op.Return)
+		sum += d
+		all = append(all, d)
+		name := op.Input.(model.Input).Op.String()
+		byOp[name] = append(byOp[name], d)
+		// Calls as +1 and returns as -1, in time, returns first at equal
+		// times.
+		events = append(events, op.Call<<1|1, op.Return<<1)
+	}
+	st.Ops = len(all)
+	if st.Ops == 0 {
+		return st
+	}
+	st.Span = Duration(last - first)
+	if st.Span > 0 {
+		st.OpsPerSec = float64(st.Ops) / time.Duration(st.Span).Seconds()
+		st.Overlap = float64(sum) / float64(st.Span)
+	}
+	slices.Sort(events)
+	n := 0
+	for _, e := range events {
+		if e&1 == 1 {
+			n++
+			st.MaxOverlap = max(st.MaxOverlap, n)
+		} else {
+			n--
+		}
+	}
+
+	st.Latency = latency(all)
+	st.ByOp = make(map[string]Latency, len(byOp))
+	st.Histograms = make(map[string]*Histogram, len(byOp))
+	for name, ds := range byOp {
+		st.ByOp[name] = latency(ds)
+		h := new(Histogram)
+		for _, d := range ds {
+			h.Record(Duration(d))
+		}
+		st.Histograms[name] = h
+	}
+	for _, d := range all {
+		// Bucket i holds the durations below 1<<i.
+		i := bits.Len64(uint64(d))
+		for len(st.Durations) <= i {
+			st.Durations = append(st.Durations, Bucket{Below: Duration(int64(1) << len(st.Durations))})
+		}
+		st.Durations[i].Count++
+	}
+	// Leave out the empty buckets of the shortest durations.
+	i := slices.IndexFunc(st.Durations, func(b Bucket) bool { return b.Count > 0 })
+	st.Durations = st.Durations[i:]
+	return st
+}
+
+// latency sorts ds and returns their distribution.
+func latency(ds []int64) Latency {
+	slices.Sort(ds)
+	at := func(p int) Duration {
+		// The nearest rank.
+		return Duration(ds[max((p*len(ds)+99)/100, 1)-1])
+	}
+	return Latency{Count: len(ds), P50: at(50), P90: at(90), P99: at(99), Max: Duration(ds[len(ds)-1])}
+}
+
+func (st HistoryStats) String() string {
+	if st.Ops == 0 {
+		return "no operations"
+	}
+	str := fmt.Sprintf("%d ops in %v, %.0f ops/s, overlap %.2f (max %d), latency %v",
+		st.Ops, time.Duration(st.Span), st.OpsPerSec, st.Overlap, st.MaxOverlap, st.Latency)
+	var ops []string
+	for _, op := range model.Ops() {
+		if l, ok := st.ByOp[op.String()]; ok {
+			ops = append(ops, fmt.Sprintf("%s(%d) p50=%v p99=%v", op, l.Count, time.Duration(l.P50), time.Duration(l.P99)))
+		}
+	}
+	return str + "; " + strings.Join(ops, ", ")
+}
+
+// Histogram returns the Durations as lines of bars of up to width.
+func (st HistoryStats) Histogram(width int) string {
+	if len(st.Durations) == 0 {
+		return ""
+	}
+	most := slices.MaxFunc(st.Durations, func(a, b Bucket) int { return cmp.Compare(a.Count, b.Count) })
+	var b strings.Builder
+	for _, d := range st.Durations {
+		fmt.Fprintf(&b, "<%10v %7d %s\n", time.Duration(d.Below), d.Count, strings.Repeat("#", d.Count*width/most.Count))
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestHistoryStats(t *testing.T) {
+	h := &History{Operations: []porcupine.Operation{
+		// A warmup Store and a pending Load, both left out.
+		{ClientId: 2, Input: model.Input{Op: model.Store, Key: "a"}, Call: -2, Output: model.Output{}, Return: -1},
+		{ClientId: 3, Input: model.Input{Op: model.Load, Key: "a"}, Call: 5, Output: model.Output{Pending: true}, Return: 1000},
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a"}, Call: 0, Output: model.Output{}, Return: 100},
+		{ClientId: 1, Input: model.Input{Op: model.Load, Key: "a"}, Call: 50, Output: model.Output{}, Return: 60},
+		{ClientId: 0, Input: model.Input{Op: model.Load, Key: "a"}, Call: 100, Output: model.Output{}, Return: 200},
+	}}
+	st := h.Stats()
+	if st.Ops != 3 || st.Span != 200 || st.OpsPerSec != 3/(200*time.Nanosecond).Seconds() {
+		t.Errorf("%d ops in %v at %g/s, want 3 in 200ns", st.Ops, time.Duration(st.Span), st.OpsPerSec)
+	}
+	if st.Overlap != 210.0/200 || st.MaxOverlap != 2 {
+		t.Errorf("overlap %g (max %d), want 1.05 (max 2)", st.Overlap, st.MaxOverlap)
+	}
+	if l := st.ByOp["Load"]; l.Count != 2 || l.P50 != 10 || l.Max != 100 || st.Latency.P90 != 100 {
+		t.Errorf("latencies %+v of %+v", st.ByOp, st.Latency)
+	}
+	// 10ns in [8, 16), 100ns twice in [64, 128).
+	if len(st.Durations) != 4 || st.Durations[0] != (Bucket{Below: 16, Count: 1}) || st.Durations[3] != (Bucket{Below: 128, Count: 2}) {
+		t.Errorf("durations %+v", st.Durations)
+	}
+	if hist := st.Histogram(10); strings.Count(hist, "\n") != 4 || !strings.Contains(hist, "##########") {
+		t.Errorf("histogram:\n%s", hist)
+	}
+	if got := st.String(); !strings.Contains(got, "overlap 1.05 (max 2)") || !strings.Contains(got, "Load(2)") {
+		t.Errorf("String = %q", got)
+	}
+	if st := new(History).Stats(); st.Ops != 0 || st.String() != "no operations" {
+		t.Errorf("stats of an empty history %+v", st)
+	}
+}
@@ -0,0 +1,277 @@
+package workload
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/edn"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// ReadJepsen reads a Jepsen history in EDN, a sequence or vector of
+// operation maps with :type, :f, :value, :process and :time, and converts
+// it to a History of the operations of model: a :read is a Load, a :write
+// a Store and a :cas, with [old new] as its value, a CompareAndSwap. The
+// values are integers and nil for no value, so a :cas from nil is a
+// LoadOrStore that stored, one to nil a LoadAndDelete, and a :write of nil
+// a LoadAndDelete of an unknown value, pending. If every :read and :write
+// is invoked with a [key value] pair, as jepsen.independent writes them,
+// those are the keys, otherwise every operation is on the single key "k".
+//
+// An :invoke is paired with the next :ok, :fail or :info of its process.
+// Operations that :fail did not happen and are left out, those that end in
+// :info or never complete are pending until the end of the history. Every
+// process is a client, in order of appearance, and the operations of the
+// :nemesis are annotations, from one of its :info entries to the next with
+// the same :f. Histories without :time are timed by their order.
+func ReadJepsen(r io.Reader) (*History, error) {
+	var entries []map[any]any
+	d := edn.NewDecoder(r)
+	for {
+		v, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("workload: %v", err)
+		}
+		vals, ok := v.([]any)
+		if !ok {
+			vals = []any{v}
+		}
+		for _, v := range vals {
+			m, ok := v.(map[any]any)
+			if !ok {
+				return nil, fmt.Errorf("workload: jepsen entry %d is %T, not a map", len(entries), v)
+			}
+			entries = append(entries, m)
+		}
+	}
+	h, err := convertJepsen(entries)
+	if err != nil {
+		return nil, fmt.Errorf("workload: %v", err)
+	}
+	return h, nil
+}
+
+// LoadJepsen reads a Jepsen history from a file, see ReadJepsen.
+func LoadJepsen(path string) (*History, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("workload: %v", err)
+	}
+	defer f.Close()
+	h, err := ReadJepsen(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return h, nil
+}
+
+// jepsenEntry is the part of a Jepsen operation this package reads.
+type jepsenEntry struct {
+	typ, f  edn.Keyword
+	value   any
+	process any
+	time    int64
+}
+
+func convertJepsen(raw []map[any]any) (*History, error) {
+	var (
+		entries = make([]jepsenEntry, len(raw))
+		timed   = len(raw) > 0
+		end     int64
+	)
+	for i, m := range raw {
+		e := &entries[i]
+		e.typ, _ = m[edn.Keyword("type")].(edn.Keyword)
+		e.f, _ = m[edn.Keyword("f")].(edn.Keyword)
+		e.value, e.process = m[edn.Keyword("value")], m[edn.Keyword("process")]
+		t, ok := m[edn.Keyword("time")].(int64)
+		timed = timed && ok
+		e.time = t
+		if e.typ == "" || e.f == "" {
+			return nil, fmt.Errorf("jepsen entry %d: no :type or :f", i)
+		}
+	}
+	for i := range entries {
+		if !timed {
+			entries[i].time = int64(i)
+		}
+		end = max(end, entries[i].time+1)
+	}
+
+	// The keys are those of jepsen.independent if every read and write
+	// says so.
+	independent := false
+	for _, e := range entries {
+		if e.typ != "invoke" || (e.f != "read" && e.f != "write") {
+			continue
+		}
+		pair, ok := e.value.([]any)
+		if independent = ok && len(pair) == 2; !independent {
+			break
+		}
+	}
+
+	var (
+		h       = new(History)
+		clients = make(map[any]int)
+		invoked = make(map[any]int)
+		nemesis = make(map[edn.Keyword]int)
+	)
+	for i, e := range entries {
+		if _, ok := e.process.(int64); !ok {
+			// The nemesis and other processes outside the clients.
+			if start, ok := nemesis[e.f]; ok {
+				delete(nemesis, e.f)
+				h.Annotations = append(h.Annotations, jepsenAnnotation(entries[start], e.time, e.value))
+			} else {
+				nemesis[e.f] = i
+			}
+			continue
+		}
+		client, ok := clients[e.process]
+		if !ok {
+			client = len(clients)
+			clients[e.process] = client
+		}
+		if e.typ == "invoke" {
+			if _, ok := invoked[e.process]; ok {
+				return nil, fmt.Errorf("jepsen entry %d: process %v invokes before its previous operation completed", i, e.process)
+			}
+			invoked[e.process] = i
+			continue
+		}
+		j, ok := invoked[e.process]
+		if !ok {
+			return nil, fmt.Errorf("jepsen entry %d: :%s of process %v without an :invoke", i, e.typ, e.process)
+		}
+		delete(invoked, e.process)
+		inv := entries[j]
+		if inv.f != e.f {
+			return nil, fmt.Errorf("jepsen entry %d: :%s of :%s completes an :invoke of :%s", i, e.typ, e.f, inv.f)
+		}
+		switch e.typ {
+		case "fail":
+			continue
+		case "ok", "info":
+		default:
+			return nil, fmt.Errorf("jepsen entry %d: unknown type :%s", i, e.typ)
+		}
+		op, err := jepsenOperation(inv, e, independent)
+		if err != nil {
+			return nil, fmt.Errorf("jepsen entry %d: %v", i, err)
+		}
+		op.ClientId, op.Call, op.Return = client, inv.time, e.time
+		if e.typ == "info" {
+			op.Output, op.Return = model.Output{Pending: true}, end
+		}
+		h.Operations = append(h.Operations, op)
+	}
+	for _, j := range slices.Sorted(maps.Values(invoked)) {
+		inv := entries[j]
+		op, err := jepsenOperation(inv, inv, independent)
+		if err != nil {
+			return nil, fmt.Errorf("jepsen entry %d: %v", j, err)
+		}
+		op.ClientId, op.Call, op.Output, op.Return = clients[inv.process], inv.time, model.Output{Pending: true}, end
+		h.Operations = append(h.Operations, op)
+	}
+	for _, start := range slices.Sorted(maps.Values(nemesis)) {
+		h.Annotations = append(h.Annotations, jepsenAnnotation(entries[start], end, nil))
+	}
+	return h, nil
+}
+
+// jepsenOperation converts the invocation inv and completion done of an
+// operation.
+func jepsenOperation(inv, done jepsenEntry, independent bool) (porcupine.Operation, error) {
+	key, in, out := "k", inv.value, done.value
+	if independent {
+		pair, ok := in.([]any)
+		if !ok || len(pair) != 2 {
+			return porcupine.Operation{}, fmt.Errorf("value %v is not a [key value] pair", in)
+		}
+		key, in = fmt.Sprint(pair[0]), pair[1]
+		if pair, ok := out.([]any); ok && len(pair) == 2 {
+			out = pair[1]
+		}
+	}
+	var op porcupine.Operation
+	switch inv.f {
+	case "read":
+		op.Input = model.Input{Op: model.Load, Key: key}
+		v, found, err := jepsenValue(out)
+		if err != nil {
+			return op, err
+		}
+		op.Output = model.Output{Found: found, Val: v}
+	case "write":
+		v, found, err := jepsenValue(in)
+		if err != nil {
+			return op, err
+		}
+		op.Input, op.Output = model.Input{Op: model.Store, Key: key, Val: v}, model.Output{}
+		if !found {
+			// A delete, whose value is unknown.
+			op.Input, op.Output = model.Input{Op: model.LoadAndDelete, Key: key}, model.Output{Pending: true}
+		}
+	case "cas":
+		pair, ok := in.([]any)
+		if !ok || len(pair) != 2 {
+			return op, fmt.Errorf("cas value %v is not [old new]", in)
+		}
+		old, hadOld, err := jepsenValue(pair[0])
+		if err != nil {
+			return op, err
+		}
+		v, hasNew, err := jepsenValue(pair[1])
+		if err != nil {
+			return op, err
+		}
+		switch {
+		case hadOld && hasNew:
+			op.Input, op.Output = model.Input{Op: model.CompareAndSwap, Key: key, Old: old, Val: v}, model.Output{Found: true}
+		case hasNew:
+			op.Input, op.Output = model.Input{Op: model.LoadOrStore, Key: key, Val: v}, model.Output{}
+		case hadOld:
+			op.Input, op.Output = model.Input{Op: model.LoadAndDelete, Key: key}, model.Output{Found: true, Val: old}
+		default:
+			op.Input, op.Output = model.Input{Op: model.Load, Key: key}, model.Output{}
+		}
+	default:
+		return op, fmt.Errorf("unknown function :%s, want :read, :write or :cas", inv.f)
+	}
+	return op, nil
+}
+
+// jepsenValue returns the integer value v, or false for nil.
+func jepsenValue(v any) (int, bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, false, nil
+	case int64:
+		return int(v), true, nil
+	}
+	return 0, false, fmt.Errorf("value %v is not an integer", v)
+}
+
+func jepsenAnnotation(start jepsenEntry, end int64, value any) porcupine.Annotation {
+	a := porcupine.Annotation{
+		Tag:             fmt.Sprint(start.process),
+		Start:           start.time,
+		End:             end,
+		Description:     string(start.f),
+		BackgroundColor: "#f6d7d7",
+	}
+	if value != nil {
+		a.Details = fmt.Sprint(value)
+	}
+	return a
+}
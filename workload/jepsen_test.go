@@ -0,0 +1,81 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestLoadJepsen(t *testing.T) {
+	records, err := LoadRecords("testdata/jepsen.edn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := records[0].History
+	// The failed cas is left out, the timed out write is pending.
+	if len(h.Operations) != 6 {
+		t.Fatalf("%d operations, want 6: %+v", len(h.Operations), h.Operations)
+	}
+	var pending, cas int
+	for _, op := range h.Operations {
+		in, out := op.Input.(model.Input), op.Output.(model.Output)
+		if out.Pending {
+			pending++
+			if in.Op != model.Store || in.Key != "2" || in.Val != 5 || op.Return != 1001 {
+				t.Errorf("pending operation %+v", op)
+			}
+		}
+		if in.Op == model.CompareAndSwap {
+			cas++
+			if in.Key != "1" || in.Old != 1 || in.Val != 3 || !out.Found || op.Call != 300 || op.Return != 400 {
+				t.Errorf("cas %+v", op)
+			}
+		}
+	}
+	if pending != 1 || cas != 1 {
+		t.Errorf("%d pending operations and %d cas, want 1 each", pending, cas)
+	}
+	if len(h.Annotations) != 2 || h.Annotations[0].Tag != "nemesis" || h.Annotations[0].Start != 250 || h.Annotations[1].End != 860 {
+		t.Errorf("annotations %+v", h.Annotations)
+	}
+	if !porcupine.CheckOperations(model.Model, h.Operations) {
+		t.Error("history is not linearizable")
+	}
+}
+
+func TestReadJepsen(t *testing.T) {
+	// A single register, untimed: the read sees a value never written.
+	h, err := ReadJepsen(strings.NewReader(`[
+		{:type :invoke :f :write :value 1 :process 0}
+		{:type :ok :f :write :value 1 :process 0}
+		{:type :invoke :f :read :value nil :process 0}
+		{:type :ok :f :read :value 2 :process 0}
+		{:type :invoke :f :read :value nil :process 1}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Operations) != 3 || h.Operations[0].Input.(model.Input).Key != "k" || h.Operations[1].Call != 2 || !h.Operations[2].Output.(model.Output).Pending {
+		t.Fatalf("operations %+v", h.Operations)
+	}
+	if porcupine.CheckOperations(model.Model, h.Operations) {
+		t.Error("history is linearizable")
+	}
+
+	for _, bad := range []string{
+		`{:type :invoke :f :read :process 0} {:type :invoke :f :read :process 0}`,
+		`{:type :ok :f :read :process 0}`,
+		`{:type :invoke :f :read :process 0} {:type :ok :f :write :process 0}`,
+		`{:type :invoke :f :incr :value 1 :process 0} {:type :ok :f :incr :process 0}`,
+		`{:type :invoke :f :write :value "a" :process 0} {:type :ok :f :write :process 0}`,
+		`{:f :read :process 0}`,
+		`[1]`,
+	} {
+		if _, err := ReadJepsen(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadJepsen(%s) succeeded", bad)
+		}
+	}
+}
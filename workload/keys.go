@@ -0,0 +1,82 @@
+package workload
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// Dist is the distribution of the keys of the operations over the key
+// space, which sets the level of contention: every operation of a hot key
+// distribution hits the same key a given share of the time, a Zipfian one
+// makes the first keys ever more popular.
+type Dist struct {
+	// Kind is "uniform", the default, "zipf" or "hot".
+	Kind string `json:"kind,omitempty"`
+	// S is the exponent of a Zipfian distribution, above 1; 1.1 if 0.
+	// Key k is drawn with a probability proportional to 1/(1+k)^S.
+	S float64 `json:"s,omitempty"`
+	// HotPercent is the percentage of operations on the first key of a hot
+	// key distribution, the others are uniform over the remaining keys.
+	HotPercent int `json:"hot_percent,omitempty"`
+}
+
+func (d Dist) String() string {
+	switch d.kind() {
+	case "zipf":
+		return fmt.Sprintf("zipf(s=%g)", d.s())
+	case "hot":
+		return fmt.Sprintf("hot(%d%%)", d.HotPercent)
+	}
+	return "uniform"
+}
+
+func (d Dist) kind() string {
+	if d.Kind == "" {
+		return "uniform"
+	}
+	return d.Kind
+}
+
+func (d Dist) s() float64 {
+	if d.S == 0 {
+		return 1.1
+	}
+	return d.S
+}
+
+func (d Dist) validate() error {
+	switch d.kind() {
+	case "uniform":
+	case "zipf":
+		if d.s() <= 1 {
+			return fmt.Errorf("workload: dist: zipf exponent %g must be above 1", d.S)
+		}
+	case "hot":
+		if d.HotPercent < 0 || d.HotPercent > 100 {
+			return fmt.Errorf("workload: dist: hot_percent %d is not a percentage", d.HotPercent)
+		}
+	default:
+		return fmt.Errorf("workload: dist: unknown kind %q, want uniform, zipf or hot", d.Kind)
+	}
+	return nil
+}
+
+// chooser returns a function drawing key indices below n from rng.
+func (d Dist) chooser(rng *rand.Rand, n int) func() int {
+	if n == 1 {
+		return func() int { return 0 }
+	}
+	switch d.kind() {
+	case "zipf":
+		z := rand.NewZipf(rng, d.s(), 1, uint64(n-1))
+		return func() int { return int(z.Uint64()) }
+	case "hot":
+		return func() int {
+			if rng.IntN(100) < d.HotPercent {
+				return 0
+			}
+			return 1 + rng.IntN(n-1)
+		}
+	}
+	return func() int { return rng.IntN(n) }
+}
@@ -0,0 +1,54 @@
+package workload
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestDist(t *testing.T) {
+	const n, draws = 8, 100000
+	counts := func(d Dist) []int {
+		c := make([]int, n)
+		key := d.chooser(rand.New(rand.NewPCG(1, 2)), n)
+		for range draws {
+			c[key()]++
+		}
+		return c
+	}
+
+	for i, c := range counts(Dist{}) {
+		if c < draws/n*9/10 || c > draws/n*11/10 {
+			t.Errorf("uniform: key %d drawn %d times of %d", i, c, draws)
+		}
+	}
+
+	hot := counts(Dist{Kind: "hot", HotPercent: 90})
+	if hot[0] < draws*88/100 || hot[0] > draws*92/100 {
+		t.Errorf("hot(90%%): hot key drawn %d times of %d", hot[0], draws)
+	}
+	for i, c := range hot[1:] {
+		if c == 0 {
+			t.Errorf("hot(90%%): key %d never drawn", i+1)
+		}
+	}
+
+	zipf := counts(Dist{Kind: "zipf", S: 2})
+	for i := 1; i < n; i++ {
+		if zipf[i] > zipf[i-1] {
+			t.Errorf("zipf: key %d drawn more often than key %d: %v", i, i-1, zipf)
+		}
+	}
+}
+
+func TestDistErrors(t *testing.T) {
+	for _, tc := range []struct{ spec, want string }{
+		{`{"keys": 4, "dist": {"kind": "pareto"}}`, "unknown kind"},
+		{`{"keys": 4, "dist": {"kind": "zipf", "s": 0.5}}`, "above 1"},
+		{`{"keys": 4, "dist": {"kind": "hot", "hot_percent": 120}}`, "percentage"},
+	} {
+		if _, err := Parse(strings.NewReader(tc.spec)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%s) = %v, want error containing %q", tc.spec, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package workload
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/internal/edn"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// WriteKnossos writes h as a Jepsen history in EDN, one operation map per
+// line, for Knossos to check with its cas-register model, as a second
+// checker independent of porcupine and of model. With an empty key, every
+// key is written, with [key value] values for jepsen.independent to split;
+// otherwise only the operations on key, with plain values.
+//
+// A missing value is nil, a Load is a :read, a Store a :write, and the
+// other operations a :read or a :cas, by what they returned, which
+// ReadJepsen reads back. A Range is a read of every key of h. Knossos
+// takes a read of nil for a read of an unknown value and leaves failed
+// operations out, so the reads of missing keys and the CompareAndSwaps
+// that failed are written but do not constrain it: a history Knossos
+// accepts may still be one porcupine rejects, never the other way around.
+// Pending operations that write end in :info, the others are left out, as
+// are the annotations. A client that calls its next operation before the
+// previous one returns, as at equal timestamps, which porcupine allows,
+// continues as a new process, as Jepsen does after a crash.
+func WriteKnossos(w io.Writer, h *History, key string) error {
+	type event struct {
+		op   int
+		time int64
+		ret  bool
+	}
+	var (
+		ops    []knossosOp
+		events []event
+		keys   = make(map[string]bool)
+	)
+	for _, op := range h.Operations {
+		in := op.Input.(model.Input)
+		if in.Op != model.Range {
+			keys[in.Key] = true
+			continue
+		}
+		for k := range op.Output.(model.Output).Entries {
+			keys[k] = true
+		}
+	}
+	sorted := slices.Sorted(maps.Keys(keys))
+	for _, op := range h.Operations {
+		for _, k := range knossosOps(op, sorted) {
+			if key != "" && k.key != key {
+				continue
+			}
+			events = append(events, event{len(ops), op.Call, false}, event{len(ops), op.Return, true})
+			ops = append(ops, k)
+		}
+	}
+	// Calls before returns at equal times, as porcupine orders them.
+	slices.SortStableFunc(events, func(a, b event) int {
+		if c := cmp.Compare(a.time, b.time); c != 0 {
+			return c
+		}
+		if a.ret != b.ret {
+			if a.ret {
+				return 1
+			}
+			return -1
+		}
+		return 0
+	})
+
+	var (
+		e       = edn.NewEncoder(w)
+		process = make(map[int]int)
+		busy    = make(map[int]bool)
+		next    = NextClient(h)
+	)
+	for i, ev := range events {
+		op := &ops[ev.op]
+		if !ev.ret {
+			p, ok := process[op.client]
+			if !ok {
+				p = op.client
+			}
+			if busy[p] {
+				p = next
+				next++
+			}
+			process[op.client], busy[p], op.process = p, true, p
+		} else {
+			busy[op.process] = false
+		}
+		typ, value := edn.Keyword("invoke"), op.invoke
+		if ev.ret {
+			typ, value = op.typ, op.done
+		}
+		if key == "" {
+			value = []any{op.key, value}
+		}
+		err := e.Encode(edn.Map{
+			{Key: edn.Keyword("type"), Value: typ},
+			{Key: edn.Keyword("f"), Value: op.f},
+			{Key: edn.Keyword("value"), Value: value},
+			{Key: edn.Keyword("process"), Value: op.process},
+			{Key: edn.Keyword("time"), Value: ev.time},
+			{Key: edn.Keyword("index"), Value: i},
+		})
+		if err != nil {
+			return fmt.Errorf("workload: %v", err)
+		}
+	}
+	return nil
+}
+
+// knossosOp is an operation as Knossos sees it, on one key.
+type knossosOp struct {
+	client, process int
+	key             string
+	f, typ          edn.Keyword
+	invoke, done    any
+}
+
+// knossosOps returns the Knossos operations of op, one for each of keys
+// for a Range.
+func knossosOps(op porcupine.Operation, keys []string) []knossosOp {
+	in, out := op.Input.(model.Input), op.Output.(model.Output)
+	k := knossosOp{client: op.ClientId, key: in.Key, typ: "ok"}
+	read := func(found bool, v int) knossosOp {
+		k.f, k.done = "read", nil
+		if found {
+			k.done = v
+		}
+		return k
+	}
+	cas := func(old, v any) knossosOp {
+		k.f, k.invoke, k.done = "cas", []any{old, v}, []any{old, v}
+		return k
+	}
+	write := func(v any) knossosOp {
+		k.f, k.invoke, k.done = "write", v, v
+		return k
+	}
+	if out.Pending {
+		k.typ = "info"
+		switch in.Op {
+		case model.Store, model.Swap:
+			return []knossosOp{write(in.Val)}
+		case model.LoadOrStore:
+			return []knossosOp{cas(nil, in.Val)}
+		case model.LoadAndDelete:
+			return []knossosOp{write(nil)}
+		case model.CompareAndSwap:
+			return []knossosOp{cas(in.Old, in.Val)}
+		}
+		return nil
+	}
+	switch in.Op {
+	case model.Load:
+		return []knossosOp{read(out.Found, out.Val)}
+	case model.Store:
+		return []knossosOp{write(in.Val)}
+	case model.LoadOrStore:
+		if out.Found {
+			return []knossosOp{read(true, out.Val)}
+		}
+		return []knossosOp{cas(nil, in.Val)}
+	case model.LoadAndDelete:
+		if out.Found {
+			return []knossosOp{cas(out.Val, nil)}
+		}
+		return []knossosOp{read(false, 0)}
+	case model.Swap:
+		if out.Found {
+			return []knossosOp{cas(out.Val, in.Val)}
+		}
+		return []knossosOp{cas(nil, in.Val)}
+	case model.CompareAndSwap:
+		k = cas(in.Old, in.Val)
+		if !out.Found {
+			k.typ = "fail"
+		}
+		return []knossosOp{k}
+	case model.Range:
+		ops := make([]knossosOp, len(keys))
+		for i, key := range keys {
+			k.key = key
+			v, ok := out.Entries[key]
+			ops[i] = read(ok, v)
+		}
+		return ops
+	}
+	return nil
+}
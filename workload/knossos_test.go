@@ -0,0 +1,61 @@
+package workload
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestWriteKnossos(t *testing.T) {
+	s := Default()
+	s.Workers, s.Keys = 3, 3
+	s.Mix = make(map[string]int)
+	for _, op := range model.Ops() {
+		s.Mix[op.String()] = 1
+	}
+	h := s.Round(s.newMap(), 3, nil)
+	for _, key := range []string{"", "k1"} {
+		var b bytes.Buffer
+		if err := WriteKnossos(&b, h, key); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadJepsen(&b)
+		if err != nil {
+			t.Fatalf("key %q: %v", key, err)
+		}
+		if len(got.Operations) == 0 || !porcupine.CheckOperations(model.Model, got.Operations) {
+			t.Errorf("key %q: %d operations read back, not linearizable", key, len(got.Operations))
+		}
+	}
+
+	// A Swap that saw the value of a LoadOrStore, then a Load of a value
+	// never stored.
+	h = &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.LoadOrStore, Key: "a", Val: 1}, Call: 0, Output: model.Output{}, Return: 10},
+		{ClientId: 1, Input: model.Input{Op: model.Swap, Key: "a", Val: 2}, Call: 10, Output: model.Output{Found: true, Val: 1}, Return: 20},
+		{ClientId: 0, Input: model.Input{Op: model.Load, Key: "a"}, Call: 30, Output: model.Output{Found: true, Val: 3}, Return: 40},
+	}}
+	var b bytes.Buffer
+	if err := WriteKnossos(&b, h, "a"); err != nil {
+		t.Fatal(err)
+	}
+	// The Swap calls as the LoadOrStore returns.
+	if !strings.Contains(b.String(), `{:type :invoke, :f :cas, :value [1 2], :process 1, :time 10, :index 1}`) {
+		t.Errorf("history:\n%s", b.String())
+	}
+	got, err := ReadJepsen(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if porcupine.CheckOperations(model.Model, got.Operations) {
+		t.Error("history read back is linearizable")
+	}
+	got.Operations = got.Operations[:2]
+	if !porcupine.CheckOperations(model.Model, got.Operations) {
+		t.Error("history without the Load is not linearizable")
+	}
+}
@@ -0,0 +1,123 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A LogfHandler is a slog.Handler that passes every record at Level or
+// above, Info if nil, to Logf, like testing.T.Logf, as one line: the
+// message followed by the attributes, those of WithAttrs first, as
+// key=value with the keys of WithGroup and slog.Group prefixed by the
+// group and a dot, as slog.TextHandler writes them.
+type LogfHandler struct {
+	Logf  func(format string, args ...any)
+	Level slog.Leveler
+	// AddSource starts every line with the file and line of the call that
+	// logged it, as testing.T.Logf does. A Logf passed t.Logf otherwise
+	// only shows that of Handle.
+	AddSource bool
+
+	// attrs are those of WithAttrs, formatted, and group the prefix of the
+	// keys of WithGroup.
+	attrs string
+	group string
+}
+
+func (h LogfHandler) Enabled(_ context.Context, level slog.Level) bool {
+	least := slog.LevelInfo
+	if h.Level != nil {
+		least = h.Level.Level()
+	}
+	return level >= least
+}
+
+func (h LogfHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if h.AddSource && r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		fmt.Fprintf(&b, "%s:%d: ", filepath.Base(f.File), f.Line)
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	h.Logf("%s", b.String())
+	return nil
+}
+
+func (h LogfHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range as {
+		appendAttr(&b, h.group, a)
+	}
+	h.attrs = b.String()
+	return h
+}
+
+func (h LogfHandler) WithGroup(name string) slog.Handler {
+	if name != "" {
+		h.group += name + "."
+	}
+	return h
+}
+
+// appendAttr writes a to b as " key=value", with the key after group, and
+// each attribute of a group with the group's key added to it. Empty
+// attributes and groups are left out, as slog.Handler asks.
+func appendAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, group, ga)
+		}
+		return
+	}
+	fmt.Fprintf(b, " %s=%s", quote(group+a.Key), quote(a.Value.String()))
+}
+
+// quote quotes s if it is empty or has spaces, quotes, an equals sign or
+// characters that are not printable, for the line to split back into
+// attributes.
+func quote(s string) string {
+	if s == "" || strings.ContainsFunc(s, func(r rune) bool { return r <= ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) }) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// logger returns the logger of opts: Logger, or one passing the records to
+// Logf with the lines that logged them, or one that discards them.
+func (opts *RunOptions) logger() *slog.Logger {
+	switch {
+	case opts.Logger != nil:
+		return opts.Logger
+	case opts.Logf != nil:
+		return slog.New(LogfHandler{Logf: opts.Logf, AddSource: true})
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// roundLevel is the level of the seed of every round, Info with
+// opts.Verbose for Logf to receive it and Debug otherwise.
+func (opts *RunOptions) roundLevel() slog.Level {
+	if opts.Verbose {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
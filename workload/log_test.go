@@ -0,0 +1,75 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	s, err := New().Workers(2).Rounds(3).Ops(20).Seed(7).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	opts := RunOptions{Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	if err := s.Run(opts); err != nil {
+		t.Fatal(err)
+	}
+	var rounds []int
+	for line := range bytes.Lines(buf.Bytes()) {
+		var rec struct {
+			Level, Msg, Impl, Config string
+			Round                    *int
+			Seed                     uint64
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if rec.Impl != s.Impl {
+			t.Errorf("%s: impl %q, want %q", rec.Msg, rec.Impl, s.Impl)
+		}
+		switch {
+		case rec.Config != "":
+			if rec.Level != "INFO" || rec.Seed != 7 {
+				t.Errorf("config logged at %s with seed %d, want INFO and 7", rec.Level, rec.Seed)
+			}
+		case rec.Round != nil:
+			if rec.Level != "DEBUG" || rec.Seed != s.RoundSeed(*rec.Round) {
+				t.Errorf("round %d logged at %s with seed %d, want DEBUG and %d", *rec.Round, rec.Level, rec.Seed, s.RoundSeed(*rec.Round))
+			}
+			rounds = append(rounds, *rec.Round)
+		}
+	}
+	if fmt.Sprint(rounds) != "[0 1 2]" {
+		t.Errorf("rounds logged: %v, want [0 1 2]", rounds)
+	}
+
+	// Logf only receives the seeds of the rounds with Verbose.
+	for _, verbose := range []bool{false, true} {
+		var logs []string
+		opts := RunOptions{Logf: func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }, Verbose: verbose}
+		if err := s.Run(opts); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]int{false: 1, true: 4}[verbose]; len(logs) != want {
+			t.Errorf("Verbose %v: logs = %q, want %d of them", verbose, logs, want)
+		}
+	}
+}
+
+func TestLogfHandler(t *testing.T) {
+	var logs []string
+	h := LogfHandler{Logf: func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }, AddSource: true}
+	log := slog.New(h).With("impl", "sync.Map").WithGroup("round")
+	_, _, line, _ := runtime.Caller(0)
+	log.Info("done", "seed", 7, slog.Group("stats", "result", "not linearizable"), slog.Group("empty"))
+	log.Debug("not logged")
+	want := fmt.Sprintf(`log_test.go:%d: done impl=sync.Map round.seed=7 round.stats.result="not linearizable"`, line+1)
+	if len(logs) != 1 || logs[0] != want {
+		t.Errorf("logs = %q, want %q", logs, want)
+	}
+}
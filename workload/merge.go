@@ -0,0 +1,100 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// A Part is the history one process recorded, to Merge with those of the
+// others. Each process timestamps with a clock of its own, Offset is the
+// estimate of how far ahead of the reference clock it is, subtracted from
+// its timestamps, and Uncertainty the error of that estimate, by which
+// every operation of the part is widened at both ends. Wider intervals
+// only ever allow more orders, so an uncertainty as large as the error
+// cannot produce a violation, it can only hide one.
+type Part struct {
+	History     *History
+	Offset      time.Duration
+	Uncertainty time.Duration
+	// ClientBase is added to the client ids of the part, so that the
+	// clients of different processes stay apart.
+	ClientBase int
+}
+
+// Merge returns the history of all the parts, in the time base of the
+// reference clock, to check as one. The annotations of clients are moved
+// with them, those of tags are only shifted in time. Merge fails if two
+// parts share a client, or if an uncertainty is negative. Widening can
+// overlap consecutive operations of a client, which ValidateTimestamps
+// reports and porcupine does not mind.
+func Merge(parts []Part) (*History, error) {
+	var (
+		h     = new(History)
+		owner = make(map[int]int)
+	)
+	for i, p := range parts {
+		if p.Uncertainty < 0 {
+			return nil, fmt.Errorf("workload: part %d: uncertainty must not be negative", i)
+		}
+		shift, widen := p.Offset.Nanoseconds(), p.Uncertainty.Nanoseconds()
+		for _, op := range p.History.Operations {
+			op.ClientId += p.ClientBase
+			if j, ok := owner[op.ClientId]; ok && j != i {
+				return nil, fmt.Errorf("workload: parts %d and %d share client %d", j, i, op.ClientId)
+			}
+			owner[op.ClientId] = i
+			op.Call -= shift + widen
+			op.Return += widen - shift
+			h.Operations = append(h.Operations, op)
+		}
+		for _, a := range p.History.Annotations {
+			if a.Tag == "" {
+				a.ClientId += p.ClientBase
+			}
+			a.Start -= shift
+			a.End -= shift
+			h.Annotations = append(h.Annotations, a)
+		}
+	}
+	return h, nil
+}
+
+// Widen returns a copy of h with every operation widened by skew at both
+// ends, for a history whose clients were timed by clocks that may be skew
+// apart, such as one imported from another checker or recorded by several
+// machines, so that the skew cannot order two operations that overlapped.
+// Widening only ever allows more orders, it cannot produce a violation. The
+// annotations are left as they are. Widen fails if skew is negative.
+func Widen(h *History, skew time.Duration) (*History, error) {
+	if skew < 0 {
+		return nil, errors.New("workload: skew must not be negative")
+	}
+	w := &History{
+		Operations:  make([]porcupine.Operation, len(h.Operations)),
+		Annotations: h.Annotations,
+	}
+	for i, op := range h.Operations {
+		op.Call -= skew.Nanoseconds()
+		op.Return += skew.Nanoseconds()
+		w.Operations[i] = op
+	}
+	return w, nil
+}
+
+// NextClient returns the client id after the highest of h, the smallest
+// ClientBase that keeps the clients of another part apart from those of h.
+func NextClient(h *History) int {
+	next := 0
+	for _, op := range h.Operations {
+		next = max(next, op.ClientId+1)
+	}
+	for _, a := range h.Annotations {
+		if a.Tag == "" {
+			next = max(next, a.ClientId+1)
+		}
+	}
+	return next
+}
@@ -0,0 +1,80 @@
+package workload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestMerge(t *testing.T) {
+	a := &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "k", Val: 1}, Call: 0, Output: model.Output{}, Return: 10},
+		{ClientId: 0, Input: model.Input{Op: model.Load, Key: "k"}, Call: 50, Output: model.Output{Found: true, Val: 2}, Return: 60},
+	}}
+	// The second process's clock is 1000ns ahead: its Store ran between
+	// the two operations of the first.
+	b := &History{
+		Operations: []porcupine.Operation{
+			{ClientId: 0, Input: model.Input{Op: model.Store, Key: "k", Val: 2}, Call: 1020, Output: model.Output{}, Return: 1030},
+		},
+		Annotations: []porcupine.Annotation{{ClientId: 0, Start: 1000, End: 1040, Description: "b"}},
+	}
+
+	if _, err := Merge([]Part{{History: a}, {History: b}}); err == nil {
+		t.Error("Merge of parts sharing client 0 succeeded")
+	}
+	for _, tc := range []struct {
+		offset, uncertainty time.Duration
+		ok                  bool
+	}{
+		{0, 0, false},
+		{1000 * time.Nanosecond, 0, true},
+		// Off by 1030ns, the Store seems to start after the Load, until
+		// the uncertainty covers the error.
+		{-30 * time.Nanosecond, 0, false},
+		{-30 * time.Nanosecond, 1000 * time.Nanosecond, true},
+	} {
+		h, err := Merge([]Part{{History: a}, {History: b, Offset: tc.offset, Uncertainty: tc.uncertainty, ClientBase: NextClient(a)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h.Operations) != 3 || h.Operations[2].ClientId != 1 || h.Annotations[0].ClientId != 1 {
+			t.Fatalf("merged %+v", h)
+		}
+		if got := porcupine.CheckOperations(model.Model, h.Operations); got != tc.ok {
+			t.Errorf("offset %v ± %v: linearizable %v, want %v", tc.offset, tc.uncertainty, got, tc.ok)
+		}
+	}
+	if _, err := Merge([]Part{{History: a, Uncertainty: -1}}); err == nil {
+		t.Error("Merge accepted a negative uncertainty")
+	}
+}
+
+func TestWiden(t *testing.T) {
+	// The Store looks like it ran after the Load by 5ns.
+	h := &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Load, Key: "k"}, Call: 0, Output: model.Output{Found: true, Val: 1}, Return: 10},
+		{ClientId: 1, Input: model.Input{Op: model.Store, Key: "k", Val: 1}, Call: 15, Output: model.Output{}, Return: 20},
+	}}
+	for _, tc := range []struct {
+		skew time.Duration
+		ok   bool
+	}{{0, false}, {2, false}, {3, true}} {
+		w, err := Widen(h, tc.skew)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := porcupine.CheckOperations(model.Model, w.Operations); got != tc.ok {
+			t.Errorf("skew %v: linearizable %v, want %v", tc.skew, got, tc.ok)
+		}
+	}
+	if h.Operations[0].Return != 10 {
+		t.Error("Widen changed the history")
+	}
+	if _, err := Widen(h, -1); err == nil {
+		t.Error("Widen accepted a negative skew")
+	}
+}
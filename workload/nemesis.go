@@ -0,0 +1,154 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// Nemesis perturbs the scheduler during a round, for more diverse
+// interleavings: workers yield or sleep after random operations, and
+// GOMAXPROCS changes at random intervals. Every perturbation is recorded as
+// an annotation of the round's history, shown in the visualization of a
+// violation.
+type Nemesis struct {
+	// GoschedPercent is the percentage of operations followed by
+	// runtime.Gosched.
+	GoschedPercent int `json:"gosched_percent,omitempty"`
+	// SleepPercent is the percentage of operations followed by a sleep of
+	// up to MaxSleep, 100µs if 0.
+	SleepPercent int      `json:"sleep_percent,omitempty"`
+	MaxSleep     Duration `json:"max_sleep,omitempty"`
+	// ProcsInterval, if set, changes GOMAXPROCS to a random value between 1
+	// and twice its setting at the start of the round after random
+	// intervals of up to ProcsInterval. It is restored after the round.
+	ProcsInterval Duration `json:"procs_interval,omitempty"`
+}
+
+// nemesisSalt separates the nemesis's generators from the workers'.
+const nemesisSalt = 0x6e656d65736973
+
+func (n Nemesis) enabled() bool {
+	return n.GoschedPercent > 0 || n.SleepPercent > 0 || n.ProcsInterval > 0
+}
+
+func (n Nemesis) String() string {
+	return fmt.Sprintf("gosched=%d%% sleep=%d%%(<%v) procs_interval=%v",
+		n.GoschedPercent, n.SleepPercent, time.Duration(n.maxSleep()), time.Duration(n.ProcsInterval))
+}
+
+func (n Nemesis) maxSleep() time.Duration {
+	if n.MaxSleep == 0 {
+		return 100 * time.Microsecond
+	}
+	return time.Duration(n.MaxSleep)
+}
+
+func (n Nemesis) validate() error {
+	switch {
+	case n.GoschedPercent < 0 || n.GoschedPercent > 100 || n.SleepPercent < 0 || n.SleepPercent > 100:
+		return errors.New("workload: nemesis: percentages must be between 0 and 100")
+	case n.MaxSleep < 0 || n.ProcsInterval < 0:
+		return errors.New("workload: nemesis: durations must not be negative")
+	}
+	return nil
+}
+
+// workerNemesis perturbs one worker and records what it did.
+type workerNemesis struct {
+	n           Nemesis
+	rng         *rand.Rand
+	id          int
+	clk         *recorder.Clock
+	annotations []porcupine.Annotation
+}
+
+func (n Nemesis) worker(seed uint64, id int, clk *recorder.Clock) *workerNemesis {
+	return &workerNemesis{n: n, rng: rand.New(rand.NewPCG(seed^nemesisSalt, uint64(id))), id: id, clk: clk}
+}
+
+// perturb is called after each operation of the worker.
+func (w *workerNemesis) perturb() {
+	if !w.n.enabled() {
+		return
+	}
+	p := w.rng.IntN(100)
+	switch {
+	case p < w.n.GoschedPercent:
+		at := w.clk.Now()
+		runtime.Gosched()
+		w.annotate("Gosched", at, w.clk.Now())
+	case p < w.n.GoschedPercent+w.n.SleepPercent:
+		d := time.Duration(w.rng.Int64N(int64(w.n.maxSleep())) + 1)
+		at := w.clk.Now()
+		time.Sleep(d)
+		w.annotate("Sleep "+d.String(), at, w.clk.Now())
+	}
+}
+
+func (w *workerNemesis) annotate(desc string, start, end int64) {
+	w.annotations = append(w.annotations, porcupine.Annotation{
+		ClientId:        w.id,
+		Start:           start,
+		End:             end,
+		Description:     desc,
+		Details:         "nemesis",
+		BackgroundColor: "#f6e3b4",
+	})
+}
+
+// start starts changing GOMAXPROCS for the round and returns the function
+// that stops it, restores GOMAXPROCS and adds the changes to h, each
+// spanning the time its setting was in effect.
+func (n Nemesis) start(seed uint64, clk *recorder.Clock, h *History) (stop func()) {
+	if n.ProcsInterval <= 0 {
+		return func() {}
+	}
+	var (
+		rng   = rand.New(rand.NewPCG(seed^nemesisSalt, 1<<32))
+		procs = runtime.GOMAXPROCS(0)
+		done  = make(chan struct{})
+		wg    sync.WaitGroup
+		anns  []porcupine.Annotation
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Duration(rng.Int64N(int64(n.ProcsInterval)) + 1)):
+			}
+			p := 1 + rng.IntN(2*procs)
+			at := clk.Now()
+			runtime.GOMAXPROCS(p)
+			// Each setting lasts until the next one.
+			if len(anns) > 0 {
+				anns[len(anns)-1].End = at
+			}
+			anns = append(anns, porcupine.Annotation{
+				Tag:             "nemesis",
+				Start:           at,
+				Description:     fmt.Sprintf("GOMAXPROCS=%d", p),
+				BackgroundColor: "#f6e3b4",
+			})
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		if len(anns) > 0 {
+			anns[len(anns)-1].End = clk.Now()
+		}
+		runtime.GOMAXPROCS(procs)
+		h.Annotations = append(h.Annotations, anns...)
+	}
+}
@@ -0,0 +1,48 @@
+package workload
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+func TestNemesis(t *testing.T) {
+	s := Default()
+	s.Workers, s.Ops = 3, 200
+	impl, _ := mapimpl.Lookup(s.Impl)
+	calm := s.Round(impl.New(), 5, nil)
+
+	s.Nemesis = Nemesis{GoschedPercent: 20, SleepPercent: 5, MaxSleep: Duration(20 * time.Microsecond), ProcsInterval: Duration(100 * time.Microsecond)}
+	procs := runtime.GOMAXPROCS(0)
+	perturbed := s.Round(impl.New(), 5, nil)
+	if got := runtime.GOMAXPROCS(0); got != procs {
+		t.Errorf("GOMAXPROCS is %d after the round, want %d", got, procs)
+	}
+	if inputs(calm.Operations) != inputs(perturbed.Operations) {
+		t.Error("the nemesis changed the operations of the seed")
+	}
+	kinds := make(map[string]int)
+	for _, a := range perturbed.Annotations {
+		kind, _, _ := strings.Cut(a.Description, " ")
+		kind, _, _ = strings.Cut(kind, "=")
+		kinds[kind]++
+	}
+	if kinds["Gosched"] == 0 || kinds["Sleep"] == 0 {
+		t.Errorf("annotations %v, want Gosched and Sleep", kinds)
+	}
+	if len(calm.Annotations) != 1 || calm.Annotations[0].Tag != "phase" {
+		t.Errorf("round without a nemesis has annotations %v, want its phase", calm.Annotations)
+	}
+	for _, a := range perturbed.Annotations {
+		if strings.HasPrefix(a.Description, "GOMAXPROCS=") && a.End < a.Start {
+			t.Errorf("annotation %+v does not span the time of its setting", a)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader(`{"nemesis": {"gosched_percent": 101}}`)); err == nil {
+		t.Error("Parse accepted gosched_percent 101")
+	}
+}
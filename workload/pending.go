@@ -0,0 +1,63 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Pending leaves operations outstanding, to model crashed clients: a
+// worker hands Percent of its operations to a goroutine of their own and
+// goes on without waiting for them. They are recorded as pending, by a
+// client of their own that never returns within the round, so the checker
+// has to allow for them taking effect at any point after their call, or
+// after the round. Round still waits for them before it returns, so the
+// map is quiescent for AfterRound and nothing leaks into the next round.
+type Pending struct {
+	Percent int `json:"percent,omitempty"`
+	// MaxDelay, if set, delays each pending operation by a random duration
+	// of up to MaxDelay, so more of them are still outstanding when the
+	// round ends.
+	MaxDelay Duration `json:"max_delay,omitempty"`
+}
+
+// pendingSalt separates the generators choosing pending operations from
+// the workers'.
+const pendingSalt = 0x70656e64696e67
+
+func (p Pending) String() string {
+	return fmt.Sprintf("%d%%(<%v)", p.Percent, time.Duration(p.MaxDelay))
+}
+
+func (p Pending) validate() error {
+	switch {
+	case p.Percent < 0 || p.Percent > 100:
+		return errors.New("workload: pending: percent must be between 0 and 100")
+	case p.MaxDelay < 0:
+		return errors.New("workload: pending: max_delay must not be negative")
+	}
+	return nil
+}
+
+// workerPending chooses the pending operations of one worker.
+type workerPending struct {
+	p   Pending
+	rng *rand.Rand
+}
+
+func (p Pending) worker(seed uint64, id int) *workerPending {
+	return &workerPending{p: p, rng: rand.New(rand.NewPCG(seed^pendingSalt, uint64(id)))}
+}
+
+// next reports whether the worker's next operation is left pending, and
+// by how much to delay it.
+func (w *workerPending) next() (bool, time.Duration) {
+	if w.p.Percent == 0 || w.rng.IntN(100) >= w.p.Percent {
+		return false, 0
+	}
+	if w.p.MaxDelay == 0 {
+		return true, 0
+	}
+	return true, time.Duration(w.rng.Int64N(int64(w.p.MaxDelay)) + 1)
+}
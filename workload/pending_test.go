@@ -0,0 +1,98 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestPending(t *testing.T) {
+	s, err := New().Workers(3).Ops(100).Keys(2, Dist{}).
+		Mix(map[model.Op]int{model.Load: 2, model.Store: 1, model.LoadAndDelete: 1, model.CompareAndSwap: 1, model.Range: 1}).
+		Pending(Pending{Percent: 20, MaxDelay: Duration(50 * time.Microsecond)}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	h := s.Round(impl.New(), 1, nil)
+	if len(h.Operations) != 3*100 {
+		t.Fatalf("recorded %d operations, want %d", len(h.Operations), 3*100)
+	}
+	var (
+		end     int64
+		pending []porcupine.Operation
+	)
+	clients := make(map[int]bool)
+	for _, op := range h.Operations {
+		end = max(end, op.Return)
+		if op.Output.(model.Output).Pending {
+			pending = append(pending, op)
+			if op.ClientId <= s.NumWorkers() || clients[op.ClientId] {
+				t.Errorf("pending operation by client %d, want a client of its own", op.ClientId)
+			}
+			clients[op.ClientId] = true
+		}
+	}
+	if len(pending) == 0 {
+		t.Fatal("no operation left pending")
+	}
+	for _, op := range pending {
+		if op.Return != end {
+			t.Errorf("pending operation returns at %d, want %d after every other", op.Return, end)
+		}
+	}
+	if err := ValidateTimestamps(h.Operations, 0); err != nil {
+		t.Error(err)
+	}
+	var phases []string
+	for _, a := range h.Annotations {
+		if a.Tag == "phase" {
+			phases = append(phases, a.Description)
+		}
+	}
+	if !slices.Equal(phases, []string{"workers", "pending"}) {
+		t.Errorf("phases %v, want workers and pending", phases)
+	}
+	if !porcupine.CheckOperations(model.Model, h.Operations) {
+		t.Error("history with pending operations is not linearizable")
+	}
+
+	// The pending operations come from their own generator, the seed
+	// still draws the same inputs.
+	sorted := func(h *History) []string {
+		var ins []string
+		for _, op := range h.Operations {
+			in := op.Input.(model.Input)
+			in.Old = 0
+			ins = append(ins, fmt.Sprint(in))
+		}
+		slices.Sort(ins)
+		return ins
+	}
+	s.Pending = Pending{}
+	if !slices.Equal(sorted(s.Round(impl.New(), 1, nil)), sorted(h)) {
+		t.Error("leaving operations pending changed the inputs of the seed")
+	}
+}
+
+func TestPendingViolation(t *testing.T) {
+	// Pending operations widen what is legal, but not so much that a map
+	// dropping its Stores goes unnoticed.
+	s, err := New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(2).Rounds(20).Ops(20).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).
+		Pending(Pending{Percent: 20}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v *Violation
+	if err := s.Run(RunOptions{}); !errors.As(err, &v) {
+		t.Fatalf("Run = %v, want a Violation", err)
+	}
+}
@@ -0,0 +1,191 @@
+package workload
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// A Checked is the outcome of a round, as RunOptions.Checked receives it.
+type Checked struct {
+	Round  int                   `json:"round"`
+	Seed   uint64                `json:"seed"`
+	Ops    int                   `json:"ops"`
+	Result porcupine.CheckResult `json:"result"`
+	// Checking is the time the check took.
+	Checking Duration `json:"checking"`
+	// Key is the key that is not linearizable, if the history was checked
+	// key by key.
+	Key string `json:"key,omitempty"`
+	// Hung is the Stage of the Hung round, if the Watchdog gave up on it;
+	// its Result is then Unknown.
+	Hung string `json:"hung,omitempty"`
+}
+
+// A checker is the checking stage of Run. The rounds are generated in
+// order by Run, the checker either checks each history as it is submitted
+// or, with inFlight, in the background while the next rounds run, at most
+// inFlight histories at a time. Histories can then finish checking out of
+// order, next tracks the first round not checked yet.
+type checker struct {
+	timeout time.Duration
+	// shards, if set, checks each history key by key, see checkSharded.
+	shards int
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	// watchdog, if it has a deadline, bounds every check and, through
+	// watchRound, every round. log receives the stacks of the hung ones.
+	watchdog Watchdog
+	log      *slog.Logger
+	// sample, if set, passes a random one in sample of the rounds that
+	// pass to sampled, see RunOptions.Sample.
+	sample  int
+	sampled func(*Sample)
+	// report, if set, receives the outcome of every round, see
+	// RunOptions.Checked.
+	report func(Checked)
+
+	mu        sync.Mutex
+	stats     Stats
+	next      int
+	checked   map[int]bool
+	violation *Violation
+	hung      *Hung
+}
+
+func newChecker(timeout time.Duration, inFlight int, stats Stats, next int) *checker {
+	c := &checker{timeout: timeout, stats: stats, next: next, checked: make(map[int]bool), log: slog.New(slog.DiscardHandler)}
+	if inFlight > 0 {
+		c.sem = make(chan struct{}, inFlight)
+	}
+	return c
+}
+
+// submit checks the history of round, in the background if the checker
+// has room for it in flight, otherwise once it has.
+func (c *checker) submit(round int, seed uint64, h *History) {
+	if c.sem == nil {
+		c.check(round, seed, h)
+		return
+	}
+	c.sem <- struct{}{}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer func() { <-c.sem }()
+		c.check(round, seed, h)
+	}()
+}
+
+func (c *checker) check(round int, seed uint64, h *History) {
+	var (
+		result porcupine.CheckResult
+		info   porcupine.LinearizationInfo
+		key    string
+	)
+	sample := sampled(seed, c.sample)
+	start := time.Now()
+	stacks, ok := c.watchdog.watch(func() {
+		labeled("check", func() {
+			// A history checked key by key has no information to visualize
+			// once it passes.
+			if c.shards > 0 && !sample {
+				result, info, key = checkSharded(h.Operations, c.shards, c.timeout)
+				return
+			}
+			result, info = porcupine.CheckOperationsVerbose(model.Model, h.Operations, c.timeout)
+		})
+	})
+	if !ok {
+		c.hang(&Hung{Round: round, Seed: seed, Stage: "check", After: time.Duration(c.watchdog.Deadline), Stacks: stacks})
+		return
+	}
+	elapsed := time.Since(start)
+	if sample && result == porcupine.Ok {
+		info.AddAnnotations(h.Annotations)
+		c.sampled(&Sample{Round: round, Seed: seed, Info: info, History: h})
+	}
+	if c.report != nil {
+		c.report(Checked{Round: round, Seed: seed, Ops: len(h.Operations), Result: result, Checking: Duration(elapsed), Key: key})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Rounds++
+	c.stats.Ops += len(h.Operations)
+	c.stats.Checking += Duration(elapsed)
+	switch result {
+	case porcupine.Illegal:
+		c.stats.Violations++
+		// Of several violations in flight, report the first round's.
+		if c.violation == nil || round < c.violation.Round {
+			info.AddAnnotations(h.Annotations)
+			c.violation = &Violation{Round: round, Seed: seed, Info: info, History: h, Key: key}
+		}
+	case porcupine.Unknown:
+		c.stats.Unknown++
+	}
+	c.done(round)
+}
+
+// hang logs the stacks of a round that hung and records it, as the error
+// to stop at unless the watchdog skips hung rounds.
+func (c *checker) hang(h *Hung) {
+	c.log.Warn(fmt.Sprintf("%v, goroutines:\n%s", h, h.Stacks), "round", h.Round, "seed", h.Seed)
+	if c.report != nil {
+		c.report(Checked{Round: h.Round, Seed: h.Seed, Result: porcupine.Unknown, Hung: h.Stage})
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Hung++
+	if !c.watchdog.Skip && (c.hung == nil || h.Round < c.hung.Round) {
+		c.hung = h
+	}
+	c.done(h.Round)
+}
+
+// done marks round as checked and advances the first round not checked
+// yet past it, c.mu must be held.
+func (c *checker) done(round int) {
+	c.checked[round] = true
+	for c.checked[c.next] {
+		delete(c.checked, c.next)
+		c.next++
+	}
+}
+
+// wait waits for the histories in flight.
+func (c *checker) wait() { c.wg.Wait() }
+
+// failed returns the violation found so far, if any.
+func (c *checker) failed() *Violation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.violation
+}
+
+// stopped returns the violation or, failing that, the hung round found so
+// far that stops Run, if any.
+func (c *checker) stopped() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.violation != nil {
+		return c.violation
+	}
+	if c.hung != nil {
+		return c.hung
+	}
+	return nil
+}
+
+// progress returns the stats so far and the first round not checked yet.
+func (c *checker) progress() (Stats, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats, c.next
+}
@@ -0,0 +1,81 @@
+package workload
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestChecker(t *testing.T) {
+	s, err := New().Workers(2).Ops(10).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	c := newChecker(time.Second, 0, Stats{Rounds: 3}, 3)
+	for _, round := range []int{4, 5, 3, 7} {
+		c.submit(round, 1, s.Round(impl.New(), 1, nil))
+	}
+	if st, next := c.progress(); st.Rounds != 7 || next != 6 {
+		t.Errorf("progress = %d rounds, next %d; want 7 rounds, next 6", st.Rounds, next)
+	}
+}
+
+func TestRunInFlight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	s, err := New().Workers(2).Rounds(50).Ops(20).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(RunOptions{InFlight: 4, Checkpoint: path}); err != nil {
+		t.Fatal(err)
+	}
+	if cp := readCheckpoint(t, path); cp.Stats.Rounds != 50 || cp.Next != 50 {
+		t.Errorf("checkpoint = %+v, want all 50 rounds checked", cp)
+	}
+
+	// Every round of the forgetful map is a violation, the first one is
+	// reported however the rounds in flight finish.
+	s, err = New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(1).Rounds(100).Ops(20).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Run(RunOptions{InFlight: 8})
+	var v *Violation
+	if !errors.As(err, &v) || v.Round != 0 {
+		t.Fatalf("Run = %v, want a Violation in round 0", err)
+	}
+}
+
+func TestRunChecked(t *testing.T) {
+	s, err := New().Workers(2).Rounds(20).Ops(10).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu      sync.Mutex
+		checked = make(map[int]Checked)
+	)
+	opts := RunOptions{InFlight: 4, Checked: func(c Checked) {
+		mu.Lock()
+		defer mu.Unlock()
+		checked[c.Round] = c
+	}}
+	if err := s.Run(opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(checked) != 20 {
+		t.Fatalf("%d rounds checked, want 20", len(checked))
+	}
+	if c := checked[7]; c.Seed != s.RoundSeed(7) || c.Ops != 20 || c.Result != porcupine.Ok || c.Checking <= 0 {
+		t.Errorf("round 7 checked as %+v", c)
+	}
+}
@@ -0,0 +1,59 @@
+package workload
+
+import (
+	"slices"
+	"time"
+)
+
+// profiles are the named workloads, for a -workload given by name rather
+// than by file.
+var profiles = map[string]func() Spec{
+	"default":   Default,
+	"promotion": Promotion,
+}
+
+// Profile returns the named workload profile.
+func Profile(name string) (Spec, bool) {
+	p, ok := profiles[name]
+	if !ok {
+		return Spec{}, false
+	}
+	s := p()
+	s.Name = name
+	return s, true
+}
+
+// ProfileNames returns the names of the profiles, sorted.
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Promotion returns a workload that keeps a sync.Map cycling between its
+// read-only and dirty maps instead of settling on either. Each round
+// starts from a filled and promoted map over 64 keys. A deleter empties
+// entries of the read-only map, which the next Store of a new key expunges
+// when it copies the read-only map into a fresh dirty one, and the writers
+// store over expunged and new keys. The readers load all 64 keys at random,
+// which misses the read-only map on every key only the dirty map holds, so
+// bursts of misses promote the dirty map again and again during the round.
+func Promotion() Spec {
+	return Spec{
+		Impl:   "sync.Map",
+		Rounds: 1000,
+		Ops:    100,
+		Keys:   64,
+		Roles: []Role{
+			{Name: "writer", Workers: 2, Mix: map[string]int{"Store": 3, "LoadOrStore": 2, "Swap": 1}},
+			{Name: "reader", Workers: 4, Mix: map[string]int{"Load": 9, "Range": 1}},
+			{Name: "deleter", Workers: 2, Mix: map[string]int{"LoadAndDelete": 3, "CompareAndSwap": 1}},
+		},
+		Warmup:  Warmup{Fill: true},
+		Start:   Start{Barrier: true},
+		Checker: Checker{Timeout: Duration(5 * time.Second)},
+	}
+}
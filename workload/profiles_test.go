@@ -0,0 +1,53 @@
+package workload
+
+import (
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestProfiles(t *testing.T) {
+	for _, name := range ProfileNames() {
+		s, ok := Profile(name)
+		if !ok {
+			t.Fatalf("Profile(%q) not found", name)
+		}
+		if s.Name != name {
+			t.Errorf("Profile(%q).Name = %q", name, s.Name)
+		}
+		if err := s.Validate(); err != nil {
+			t.Errorf("profile %s: %v", name, err)
+		}
+	}
+	if _, ok := Profile("nope"); ok {
+		t.Error("Profile(nope) found")
+	}
+}
+
+func TestPromotion(t *testing.T) {
+	s := Promotion()
+	s.Seed = 1
+	impl, _ := mapimpl.Lookup(s.Impl)
+	h := s.Round(impl.New(), s.RoundSeed(0), nil)
+	var hits, misses, deletes int
+	for _, op := range h.Operations {
+		in, out := op.Input.(model.Input), op.Output.(model.Output)
+		switch {
+		case in.Op == model.Load && out.Found:
+			hits++
+		case in.Op == model.Load:
+			misses++
+		case in.Op == model.LoadAndDelete && out.Found:
+			deletes++
+		}
+	}
+	if hits == 0 || misses == 0 || deletes == 0 {
+		t.Errorf("round had %d Load hits, %d misses and %d deletes, want all of them", hits, misses, deletes)
+	}
+	if !porcupine.CheckOperations(model.Model, h.Operations) {
+		t.Error("history is not linearizable")
+	}
+}
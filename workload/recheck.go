@@ -0,0 +1,98 @@
+package workload
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// LoadRecords reads the rounds of a saved history file: either a stream of
+// records written through RunOptions.Record, or a History saved as JSON,
+// the operations of one in a .csv file, see History.WriteCSV, or a Jepsen
+// history in a .edn file, see ReadJepsen, which are returned as a record
+// of round 0 with no seed.
+func LoadRecords(path string) ([]Record, error) {
+	if strings.HasSuffix(path, ".edn") {
+		h, err := LoadJepsen(path)
+		if err != nil {
+			return nil, err
+		}
+		return []Record{{History: h}}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("workload: %v", err)
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".csv") {
+		ops, err := model.ReadOperationsCSV(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return []Record{{History: &History{Operations: ops}}}, nil
+	}
+	r := bufio.NewReader(f)
+	magic, _ := r.Peek(len(recordMagic))
+	if !bytes.Equal(magic, []byte(recordMagic)) {
+		h, err := LoadHistory(path)
+		if err != nil {
+			return nil, err
+		}
+		return []Record{{History: h}}, nil
+	}
+
+	var records []Record
+	hr := NewHistoryReader(r)
+	for {
+		rec, err := hr.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, len(records), err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// A Rechecked is the outcome of checking a saved round again.
+type Rechecked struct {
+	Round  int
+	Seed   uint64
+	Ops    int
+	Result porcupine.CheckResult
+	// Checking is the time the check took.
+	Checking time.Duration
+	// Violation is set if Result is porcupine.Illegal, and visualizes the
+	// history with the model it was checked with.
+	Violation *Violation
+}
+
+// Recheck checks the history of r against m, without running anything,
+// e.g. to check a saved history with another model or a longer timeout
+// than the run that recorded it. A timeout of 0 never gives up, and a
+// check that times out is porcupine.Unknown.
+func (r Record) Recheck(m porcupine.Model, timeout time.Duration) Rechecked {
+	start := time.Now()
+	result, info := porcupine.CheckOperationsVerbose(m, r.History.Operations, timeout)
+	res := Rechecked{
+		Round:    r.Round,
+		Seed:     r.Seed,
+		Ops:      len(r.History.Operations),
+		Result:   result,
+		Checking: time.Since(start),
+	}
+	if result == porcupine.Illegal {
+		info.AddAnnotations(r.History.Annotations)
+		res.Violation = &Violation{Round: r.Round, Seed: r.Seed, Info: info, History: r.History, model: &m}
+	}
+	return res
+}
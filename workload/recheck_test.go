@@ -0,0 +1,118 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestRecheck(t *testing.T) {
+	dir := t.TempDir()
+	s := Default()
+	s.Rounds = 3
+	f, err := os.Create(filepath.Join(dir, "rounds.hist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(RunOptions{Record: NewHistoryWriter(f)}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	records, err := LoadRecords(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != s.Rounds {
+		t.Fatalf("%d records, want %d", len(records), s.Rounds)
+	}
+	for _, r := range records {
+		if res := r.Recheck(model.Model, 0); res.Result != porcupine.Ok || res.Seed != s.RoundSeed(res.Round) {
+			t.Errorf("round %d (seed %d): %s", res.Round, res.Seed, res.Result)
+		}
+	}
+
+	// A Range that is no snapshot passes the map model but not the
+	// snapshot model, which then visualizes the violation.
+	h := &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Range}, Call: 0, Output: model.Output{Entries: map[string]int{"b": 2}}, Return: 10},
+		{ClientId: 1, Input: model.Input{Op: model.Store, Key: "a", Val: 1}, Call: 1, Output: model.Output{}, Return: 2},
+		{ClientId: 1, Input: model.Input{Op: model.Store, Key: "b", Val: 2}, Call: 3, Output: model.Output{}, Return: 4},
+	}}
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "history.json")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records, err = LoadRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("%d records of a JSON history, want 1", len(records))
+	}
+	if res := records[0].Recheck(model.Model, 0); res.Result != porcupine.Ok {
+		t.Errorf("map model: %s", res.Result)
+	}
+	res := records[0].Recheck(model.Snapshot, 0)
+	if res.Result != porcupine.Illegal || res.Violation == nil {
+		t.Fatalf("snapshot model: %s", res.Result)
+	}
+	var html bytes.Buffer
+	if err := res.Violation.Visualize(&html); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(html.Bytes(), []byte("{b: 2}")) {
+		t.Error("visualization does not describe the Range with the snapshot model")
+	}
+
+	if _, err := LoadRecords(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadRecords of a missing file succeeded")
+	}
+}
+
+func TestViolationHistory(t *testing.T) {
+	s, err := New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(1).Rounds(1).Ops(20).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v *Violation
+	if err := s.Run(RunOptions{}); !errors.As(err, &v) {
+		t.Fatalf("Run = %v, want a Violation", err)
+	}
+
+	// The saved history fails again without running the round.
+	name := ViolationName(s.Impl, v.Round)
+	if !strings.HasPrefix(name, "forgetful_violation_0_") || !strings.HasPrefix(ViolationName("sync.Map", 3), "syncmap_violation_3_") {
+		t.Errorf("ViolationName = %q", name)
+	}
+	base := filepath.Join(t.TempDir(), "run", name)
+	if err := v.WriteFiles(base); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(base + ".html"); err != nil {
+		t.Error(err)
+	}
+	records, err := LoadRecords(base + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].History.Operations) != len(v.History.Operations) {
+		t.Fatalf("loaded %d records of %d operations", len(records), len(v.History.Operations))
+	}
+	if res := records[0].Recheck(model.Model, 0); res.Result != porcupine.Illegal {
+		t.Errorf("Recheck of the saved violation = %s", res.Result)
+	}
+}
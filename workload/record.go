@@ -0,0 +1,351 @@
+package workload
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// A Record is the history of one round, as RunOptions.Record writes it.
+type Record struct {
+	Round   int
+	Seed    uint64
+	History *History
+}
+
+// The binary form of records is a stream of segments, each a header, the
+// magic and a version byte, followed by records. A record is a tag byte,
+// its length as a uvarint and the record itself:
+//
+//	round, seed              uvarint
+//	keys                     uvarint count, then each as a string
+//	operations               uvarint count, then each:
+//	  client                 uvarint
+//	  op, flags              byte, byte: found, pending, entries
+//	  key                    uvarint index into keys, 0 for none
+//	  val, old               varint
+//	  call                   varint, from the previous operation's call
+//	  return                 varint, from the call
+//	  val                    varint, of the output
+//	  entries                uvarint count, then key index and varint val
+//	annotations              uvarint count, then each:
+//	  client, start, end     uvarint, varint, varint from the start
+//	  tag, description, details, text and background color  strings
+//
+// A string is its uvarint length and its bytes. Appending to a file
+// appends a segment, so a resumed soak keeps recording into the same file.
+const (
+	recordMagic   = "SMHIST"
+	recordVersion = 1
+	recordRound   = 1
+)
+
+const (
+	flagFound = 1 << iota
+	flagPending
+	flagEntries
+)
+
+// A HistoryWriter writes records in a binary form about a sixth of the
+// size of their JSON, each with a single Write, so an interrupted run
+// loses at most the round it was writing.
+type HistoryWriter struct {
+	w      io.Writer
+	header bool
+	buf    []byte
+	keys   map[string]uint64
+}
+
+// NewHistoryWriter returns a writer appending a segment of records to w.
+func NewHistoryWriter(w io.Writer) *HistoryWriter {
+	return &HistoryWriter{w: w}
+}
+
+// Write writes the record of a round. It fails on operations of another
+// model.
+func (hw *HistoryWriter) Write(r Record) error {
+	b := hw.buf[:0]
+	if !hw.header {
+		b = append(b, recordMagic...)
+		b = append(b, recordVersion)
+	}
+	body, err := hw.encode(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, recordRound)
+	b = binary.AppendUvarint(b, uint64(len(body)))
+	b = append(b, body...)
+	hw.buf = b
+	if _, err := hw.w.Write(b); err != nil {
+		return fmt.Errorf("workload: %v", err)
+	}
+	hw.header = true
+	return nil
+}
+
+// encode returns the body of r.
+func (hw *HistoryWriter) encode(r Record) ([]byte, error) {
+	var (
+		keys []string
+		ops  []byte
+	)
+	clear(hw.keys)
+	if hw.keys == nil {
+		hw.keys = make(map[string]uint64)
+	}
+	key := func(k string) uint64 {
+		if k == "" {
+			return 0
+		}
+		i, ok := hw.keys[k]
+		if !ok {
+			keys = append(keys, k)
+			i = uint64(len(keys))
+			hw.keys[k] = i
+		}
+		return i
+	}
+
+	ops = binary.AppendUvarint(ops, uint64(len(r.History.Operations)))
+	var prev int64
+	for i, op := range r.History.Operations {
+		in, ok := op.Input.(model.Input)
+		if !ok {
+			return nil, fmt.Errorf("workload: operation %d has input %T", i, op.Input)
+		}
+		out, ok := op.Output.(model.Output)
+		if !ok {
+			return nil, fmt.Errorf("workload: operation %d has output %T", i, op.Output)
+		}
+		var flags byte
+		if out.Found {
+			flags |= flagFound
+		}
+		if out.Pending {
+			flags |= flagPending
+		}
+		if out.Entries != nil {
+			flags |= flagEntries
+		}
+		ops = binary.AppendUvarint(ops, uint64(op.ClientId))
+		ops = append(ops, byte(in.Op), flags)
+		ops = binary.AppendUvarint(ops, key(in.Key))
+		ops = binary.AppendVarint(ops, int64(in.Val))
+		ops = binary.AppendVarint(ops, int64(in.Old))
+		ops = binary.AppendVarint(ops, op.Call-prev)
+		ops = binary.AppendVarint(ops, op.Return-op.Call)
+		ops = binary.AppendVarint(ops, int64(out.Val))
+		if out.Entries != nil {
+			ops = binary.AppendUvarint(ops, uint64(len(out.Entries)))
+			for k, v := range out.Entries {
+				ops = binary.AppendUvarint(ops, key(k))
+				ops = binary.AppendVarint(ops, int64(v))
+			}
+		}
+		prev = op.Call
+	}
+	ops = binary.AppendUvarint(ops, uint64(len(r.History.Annotations)))
+	for _, a := range r.History.Annotations {
+		ops = binary.AppendUvarint(ops, uint64(a.ClientId))
+		ops = binary.AppendVarint(ops, a.Start)
+		ops = binary.AppendVarint(ops, a.End-a.Start)
+		for _, s := range []string{a.Tag, a.Description, a.Details, a.TextColor, a.BackgroundColor} {
+			ops = appendString(ops, s)
+		}
+	}
+
+	body := binary.AppendUvarint(nil, uint64(r.Round))
+	body = binary.AppendUvarint(body, r.Seed)
+	body = binary.AppendUvarint(body, uint64(len(keys)))
+	for _, k := range keys {
+		body = appendString(body, k)
+	}
+	return append(body, ops...), nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// A HistoryReader reads the records written by HistoryWriters.
+type HistoryReader struct {
+	r *bufio.Reader
+}
+
+// NewHistoryReader returns a reader of the records of r, which may hold
+// several segments.
+func NewHistoryReader(r io.Reader) *HistoryReader {
+	return &HistoryReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record, or io.EOF after the last one.
+func (hr *HistoryReader) Next() (Record, error) {
+	for {
+		tag, err := hr.r.ReadByte()
+		if err == io.EOF {
+			return Record{}, io.EOF
+		}
+		if err != nil {
+			return Record{}, fmt.Errorf("workload: %v", err)
+		}
+		switch tag {
+		case recordMagic[0]:
+			header := make([]byte, len(recordMagic))
+			header[0] = tag
+			if _, err := io.ReadFull(hr.r, header[1:]); err != nil || string(header) != recordMagic {
+				return Record{}, errors.New("workload: not a history stream")
+			}
+			v, err := hr.r.ReadByte()
+			if err != nil {
+				return Record{}, errors.New("workload: not a history stream")
+			}
+			if v != recordVersion {
+				return Record{}, fmt.Errorf("workload: history stream version %d, want %d", v, recordVersion)
+			}
+		case recordRound:
+			n, err := binary.ReadUvarint(hr.r)
+			if err != nil {
+				return Record{}, fmt.Errorf("workload: truncated history stream: %v", err)
+			}
+			if n > maxRecord {
+				return Record{}, errCorrupt
+			}
+			body := make([]byte, n)
+			if _, err := io.ReadFull(hr.r, body); err != nil {
+				return Record{}, fmt.Errorf("workload: truncated history stream: %v", err)
+			}
+			return decodeRecord(body)
+		default:
+			return Record{}, fmt.Errorf("workload: invalid record tag %#x in history stream", tag)
+		}
+	}
+}
+
+var errCorrupt = errors.New("workload: corrupt record in history stream")
+
+// maxRecord bounds the length of a record, which a corrupt one could set
+// to anything.
+const maxRecord = 1 << 30
+
+// decoder reads the fields of a record body, keeping the first error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errCorrupt
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errCorrupt
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.b) == 0 {
+		d.err = errCorrupt
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil || n > uint64(len(d.b)) {
+		d.err = errCorrupt
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+// count reads a count of items of at least one byte each.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.err = errCorrupt
+		return 0
+	}
+	return int(n)
+}
+
+func decodeRecord(body []byte) (Record, error) {
+	d := &decoder{b: body}
+	r := Record{Round: int(d.uvarint()), Seed: d.uvarint(), History: new(History)}
+	keys := make([]string, d.count()+1)
+	for i := 1; i < len(keys); i++ {
+		keys[i] = d.string()
+	}
+	key := func() string {
+		i := d.uvarint()
+		if i >= uint64(len(keys)) {
+			d.err = errCorrupt
+			return ""
+		}
+		return keys[i]
+	}
+
+	var prev int64
+	for range d.count() {
+		client := int(d.uvarint())
+		op, flags := model.Op(d.byte()), d.byte()
+		in := model.Input{Op: op, Key: key(), Val: int(d.varint()), Old: int(d.varint())}
+		call := prev + d.varint()
+		ret := call + d.varint()
+		out := model.Output{Found: flags&flagFound != 0, Pending: flags&flagPending != 0, Val: int(d.varint())}
+		if flags&flagEntries != 0 {
+			n := d.count()
+			out.Entries = make(map[string]int, n)
+			for range n {
+				k := key()
+				out.Entries[k] = int(d.varint())
+			}
+		}
+		r.History.Operations = append(r.History.Operations, porcupine.Operation{
+			ClientId: client, Input: in, Call: call, Output: out, Return: ret,
+		})
+		prev = call
+	}
+	for range d.count() {
+		a := porcupine.Annotation{ClientId: int(d.uvarint()), Start: d.varint()}
+		a.End = a.Start + d.varint()
+		a.Tag, a.Description, a.Details, a.TextColor, a.BackgroundColor = d.string(), d.string(), d.string(), d.string(), d.string()
+		r.History.Annotations = append(r.History.Annotations, a)
+	}
+	if d.err == nil && len(d.b) > 0 {
+		d.err = errCorrupt
+	}
+	if d.err != nil {
+		return Record{}, d.err
+	}
+	return r, nil
+}
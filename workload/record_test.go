@@ -0,0 +1,97 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryStream(t *testing.T) {
+	s := Default()
+	s.Workers, s.Keys, s.Rounds = 3, 4, 20
+	s.Mix = map[string]int{"Store": 2, "Load": 2, "Range": 1, "CompareAndSwap": 1, "LoadAndDelete": 1}
+	s.Nemesis = Nemesis{SleepPercent: 10, MaxSleep: Duration(time.Microsecond)}
+	s.Pending = Pending{Percent: 10}
+
+	// Two segments in one file, as a resumed soak writes them.
+	path := filepath.Join(t.TempDir(), "rounds.hist")
+	var want []Record
+	for segment := range 2 {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hw := NewHistoryWriter(f)
+		for round := range 3 {
+			r := Record{Round: 3*segment + round, Seed: s.RoundSeed(round), History: s.Round(s.newMap(), s.RoundSeed(round), nil)}
+			if err := hw.Write(r); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, r)
+		}
+		f.Close()
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr := NewHistoryReader(bytes.NewReader(b))
+	jsonSize := 0
+	for i, w := range want {
+		got, err := hr.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		wantJSON, _ := json.Marshal(w.History)
+		gotJSON, _ := json.Marshal(got.History)
+		if got.Round != w.Round || got.Seed != w.Seed || !bytes.Equal(gotJSON, wantJSON) {
+			t.Errorf("record %d: got round %d seed %d\n%s\nwant round %d seed %d\n%s", i, got.Round, got.Seed, gotJSON, w.Round, w.Seed, wantJSON)
+		}
+		jsonSize += len(wantJSON)
+	}
+	if _, err := hr.Next(); err != io.EOF {
+		t.Errorf("Next after the last record = %v, want io.EOF", err)
+	}
+	if len(b)*4 > jsonSize {
+		t.Errorf("%d bytes of records for %d bytes of JSON", len(b), jsonSize)
+	}
+
+	// A torn last record is an error, not the end of the stream.
+	hr = NewHistoryReader(bytes.NewReader(b[:len(b)-3]))
+	for {
+		if _, err = hr.Next(); err != nil {
+			break
+		}
+	}
+	if err == io.EOF {
+		t.Error("truncated stream read to io.EOF")
+	}
+	if _, err := NewHistoryReader(bytes.NewReader([]byte("{\"version\":1}"))).Next(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Next on JSON = %v", err)
+	}
+}
+
+func TestRunRecord(t *testing.T) {
+	s := Default()
+	s.Rounds = 5
+	var buf bytes.Buffer
+	if err := s.Run(RunOptions{Record: NewHistoryWriter(&buf)}); err != nil {
+		t.Fatal(err)
+	}
+	hr := NewHistoryReader(&buf)
+	for round := range s.Rounds {
+		r, err := hr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Round != round || r.Seed != s.RoundSeed(round) || len(r.History.Operations) == 0 {
+			t.Errorf("record %d: round %d seed %d with %d operations", round, r.Round, r.Seed, len(r.History.Operations))
+		}
+	}
+}
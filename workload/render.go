@@ -0,0 +1,153 @@
+package workload
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// renderContext is the number of linearized operations Render shows
+// before those that cannot go next.
+const renderContext = 3
+
+// renderWidth is the width of the intervals Render draws.
+const renderWidth = 48
+
+// ANSI escapes of Render with color.
+const (
+	ansiGreen = "\x1b[32m"
+	ansiRed   = "\x1b[31m"
+	ansiReset = "\x1b[0m"
+)
+
+// Render writes the violation to w as text, for a terminal without a
+// browser at hand. For every key, or partition of the model, whose history
+// is not linearizable, it shows where the search for a linearization got
+// stuck: the state of the key after one of the longest partial
+// linearizations porcupine found, the last operations of it, and every
+// operation that could go next, called before any other remaining one
+// returned, with whether the model rejects it in that state or only
+// whatever follows it. Each operation is drawn as its interval, to scale
+// across the window they span. With color, ANSI escapes mark the
+// linearized operations green and the stuck ones red.
+func (v *Violation) Render(w io.Writer, color bool) error {
+	m, stuck, err := v.stuck()
+	if err != nil {
+		return err
+	}
+	r := renderer{w: w, color: color, m: m}
+	r.printf("%v\n", v)
+	for _, st := range stuck {
+		r.partition(st)
+	}
+	return r.err
+}
+
+// A stuckPartition is a partition of the history of a violation that is
+// not linearizable, and where the search for a linearization got stuck.
+type stuckPartition struct {
+	// name is "key <key>" for model.Model, and key that key.
+	name, key string
+	ops       []porcupine.Operation
+	// longest is one of the longest partial linearizations, as indices of
+	// ops, and state the state after it.
+	longest []int
+	state   any
+	// context are the last operations of longest, and next those that
+	// could go after it, called before the first of the others returned,
+	// by call.
+	context, next []int
+	// start and stop are the first call and the last return of them, or
+	// call of the pending ones.
+	start, stop int64
+}
+
+// stuck returns the model of v and its partitions that are not
+// linearizable.
+func (v *Violation) stuck() (porcupine.Model, []stuckPartition, error) {
+	return v.Result().stuck()
+}
+
+// legal reports whether m accepts op in the state st is stuck in.
+func (st *stuckPartition) legal(m porcupine.Model, op porcupine.Operation) bool {
+	ok, _ := m.Step(st.state, op.Input, op.Output)
+	return ok
+}
+
+// A renderer writes a Render, keeping the first error.
+type renderer struct {
+	w     io.Writer
+	color bool
+	m     porcupine.Model
+	err   error
+}
+
+func (r *renderer) printf(format string, args ...any) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintf(r.w, format, args...)
+	}
+}
+
+// partition renders the partition st.
+func (r *renderer) partition(st stuckPartition) {
+	r.printf("%s: %d of %d operations linearized, then the state is %s\n",
+		st.name, len(st.longest), len(st.ops), describeState(r.m, st.state))
+	if len(st.context) > 0 {
+		r.printf("  last linearized:\n")
+		for _, id := range st.context {
+			r.op(st.ops[id], st.start, st.stop, ansiGreen, "")
+		}
+	}
+	r.printf("  cannot go next:\n")
+	for _, id := range st.next {
+		why := "illegal in that state"
+		if st.legal(r.m, st.ops[id]) {
+			why = "legal, but nothing can follow it"
+		}
+		r.op(st.ops[id], st.start, st.stop, ansiRed, why)
+	}
+}
+
+// op renders op as a line with its interval within [start, stop].
+func (r *renderer) op(op porcupine.Operation, start, stop int64, color, why string) {
+	bar := []byte(strings.Repeat(" ", renderWidth))
+	if span := stop - start; span > 0 {
+		from := int((op.Call - start) * (renderWidth - 1) / span)
+		to := int((min(op.Return, stop) - start) * (renderWidth - 1) / span)
+		for i := from; i <= to; i++ {
+			bar[i] = '='
+		}
+		bar[from], bar[to] = '|', '|'
+		if op.Return > stop {
+			bar[to] = '>'
+		}
+	} else {
+		bar[0] = '|'
+	}
+	desc := describeOperation(r.m, op)
+	if why != "" {
+		desc += ": " + why
+	}
+	if r.color {
+		bar = []byte(color + string(bar) + ansiReset)
+	}
+	r.printf("    client %-3d %s %10v %10v  %s\n", op.ClientId, bar,
+		time.Duration(op.Call-start), time.Duration(op.Return-start), desc)
+}
+
+func describeState(m porcupine.Model, state any) string {
+	if m.DescribeState == nil {
+		return fmt.Sprintf("%v", state)
+	}
+	return m.DescribeState(state)
+}
+
+func describeOperation(m porcupine.Model, op porcupine.Operation) string {
+	if m.DescribeOperation == nil {
+		return fmt.Sprintf("%v -> %v", op.Input, op.Output)
+	}
+	return m.DescribeOperation(op.Input, op.Output)
+}
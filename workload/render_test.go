@@ -0,0 +1,74 @@
+package workload
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestRender(t *testing.T) {
+	// A Load that misses the Store before it, on key b; key a is fine.
+	h := &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a", Val: 1}, Call: 0, Output: model.Output{}, Return: 10},
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "b", Val: 2}, Call: 20, Output: model.Output{}, Return: 30},
+		{ClientId: 1, Input: model.Input{Op: model.Load, Key: "b"}, Call: 40, Output: model.Output{}, Return: 50},
+		{ClientId: 1, Input: model.Input{Op: model.Load, Key: "a"}, Call: 60, Output: model.Output{Found: true, Val: 1}, Return: 70},
+	}}
+	result, info := porcupine.CheckOperationsVerbose(model.Model, h.Operations, 0)
+	if result != porcupine.Illegal {
+		t.Fatalf("check = %s, want Illegal", result)
+	}
+	v := &Violation{Round: 3, Seed: 7, Info: info, History: h}
+	var b strings.Builder
+	if err := v.Render(&b, false); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"round 3 (seed 7): history is not linearizable\n",
+		"key b: 1 of 2 operations linearized, then the state is 2\n",
+		"Store(b, 2)\n",
+		"Load(b) -> not found: illegal in that state\n",
+		"|==", "20ns",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendering lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "key a") || strings.Contains(out, "\x1b[") {
+		t.Errorf("rendering shows the linearizable key or color:\n%s", out)
+	}
+	b.Reset()
+	if err := v.Render(&b, true); err != nil || !strings.Contains(b.String(), ansiRed) {
+		t.Errorf("rendering with color = %v:\n%s", err, b.String())
+	}
+}
+
+func TestRenderSharded(t *testing.T) {
+	// Every round of the forgetful map is a violation.
+	s, err := New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(2).Rounds(1).Ops(20).Keys(4, Dist{}).Shards(2).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v *Violation
+	if err := s.Run(RunOptions{}); !errors.As(err, &v) || v.Key == "" {
+		t.Fatalf("Run = %v, want a Violation of a key", err)
+	}
+	var b strings.Builder
+	if err := v.Render(&b, false); err != nil {
+		t.Fatal(err)
+	}
+	if out := b.String(); strings.Count(out, "operations linearized") != 1 || !strings.Contains(out, "key "+v.Key+":") {
+		t.Errorf("rendering of key %s:\n%s", v.Key, out)
+	}
+	v.Key = "missing"
+	if err := v.Render(&b, false); err == nil {
+		t.Error("Render of a key not in the history succeeded")
+	}
+}
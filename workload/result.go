@@ -0,0 +1,244 @@
+package workload
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// A Result is the outcome of a check of a history, with what porcupine
+// found of its linearizations, for tools such as a shrinker, a report or
+// a notification to go through instead of the visualization: the partial
+// linearizations of every partition, and of those that are not
+// linearizable the longest legal prefix and the window of operations the
+// search got stuck at.
+type Result struct {
+	Check   porcupine.CheckResult
+	Info    porcupine.LinearizationInfo
+	History *History
+	// Key is the key whose history alone was checked, see Violation.Key.
+	Key string
+	// model is the model the history was checked with, model.Model if
+	// unset.
+	model *porcupine.Model
+}
+
+// Check checks h with m, model.Model if nil, and returns the Result with
+// the linearization info. A timeout of 0 never gives up, and a check that
+// times out is porcupine.Unknown, with the partial linearizations found
+// until then.
+func Check(h *History, m *porcupine.Model, timeout time.Duration) *Result {
+	mm := model.Model
+	if m != nil {
+		mm = *m
+	}
+	check, info := porcupine.CheckOperationsVerbose(mm, h.Operations, timeout)
+	info.AddAnnotations(h.Annotations)
+	return &Result{Check: check, Info: info, History: h, model: m}
+}
+
+// Result returns the Result of the check that found v.
+func (v *Violation) Result() *Result {
+	return &Result{Check: porcupine.Illegal, Info: v.Info, History: v.History, Key: v.Key, model: v.model}
+}
+
+// A Partition is a part of a history the model checks on its own, a key
+// of model.Model, with the longest partial linearizations porcupine found
+// of it, whole ones if it is linearizable, as operations in the order
+// they take effect. The Ops of a key of model.Model are those of the key
+// and one Load-like observation of it for every Range, see model.Split.
+type Partition struct {
+	// Key is the key of model.Model, empty for another model.
+	Key            string                  `json:"key,omitempty"`
+	Ops            []porcupine.Operation   `json:"ops"`
+	Linearizations [][]porcupine.Operation `json:"linearizations"`
+	Linearizable   bool                    `json:"linearizable"`
+}
+
+// A Prefix is one of the longest partial linearizations of a partition
+// that is not linearizable, the operations in the order they take effect,
+// and the state of the model after them, as the model describes it.
+type Prefix struct {
+	Key   string                `json:"key,omitempty"`
+	Ops   []porcupine.Operation `json:"ops"`
+	State string                `json:"state"`
+}
+
+// A Window is where the search for a linearization of a partition got
+// stuck: the last operations of its Prefix, and those that could go next,
+// called before the first of the others returned, in order of their
+// calls, with whether the model accepts each in the State of the prefix;
+// one it accepts only leads to a state none of the rest can follow.
+// Start and Stop are the first call and last return of them all, a
+// pending operation ending at its call.
+type Window struct {
+	Key        string                `json:"key,omitempty"`
+	Start      int64                 `json:"start"`
+	Stop       int64                 `json:"stop"`
+	Linearized []porcupine.Operation `json:"linearized"`
+	Next       []porcupine.Operation `json:"next"`
+	Legal      []bool                `json:"legal"`
+	State      string                `json:"state"`
+}
+
+// PartialLinearizations returns every partition of the history in the
+// order the model splits it.
+func (r *Result) PartialLinearizations() ([]Partition, error) {
+	_, names, parts, partials, err := r.partitions()
+	if err != nil {
+		return nil, err
+	}
+	ps := make([]Partition, len(parts))
+	for i, part := range parts {
+		p := Partition{Ops: part}
+		if names != nil {
+			p.Key = names[i]
+		}
+		for _, ids := range partials[i] {
+			ops := make([]porcupine.Operation, len(ids))
+			for j, id := range ids {
+				ops[j] = part[id]
+			}
+			p.Linearizations = append(p.Linearizations, ops)
+			p.Linearizable = p.Linearizable || len(ids) == len(part)
+		}
+		ps[i] = p
+	}
+	return ps, nil
+}
+
+// LongestLegalPrefix returns a prefix for every partition that is not
+// linearizable, none if the history is; of a check that timed out, of
+// every partition it did not get through.
+func (r *Result) LongestLegalPrefix() ([]Prefix, error) {
+	m, stuck, err := r.stuck()
+	if err != nil {
+		return nil, err
+	}
+	ps := make([]Prefix, len(stuck))
+	for i, st := range stuck {
+		ps[i] = Prefix{Key: st.key, Ops: st.pick(st.longest), State: describeState(m, st.state)}
+	}
+	return ps, nil
+}
+
+// FailingWindow returns a window for every partition that is not
+// linearizable, none if the history is.
+func (r *Result) FailingWindow() ([]Window, error) {
+	m, stuck, err := r.stuck()
+	if err != nil {
+		return nil, err
+	}
+	ws := make([]Window, len(stuck))
+	for i, st := range stuck {
+		w := Window{Key: st.key, Start: st.start, Stop: st.stop, Linearized: st.pick(st.context), Next: st.pick(st.next), State: describeState(m, st.state)}
+		for _, op := range w.Next {
+			w.Legal = append(w.Legal, st.legal(m, op))
+		}
+		ws[i] = w
+	}
+	return ws, nil
+}
+
+// partitions returns the model of r, the partitions of its history, their
+// keys for model.Model, and the partial linearizations of each.
+func (r *Result) partitions() (m porcupine.Model, names []string, parts [][]porcupine.Operation, partials [][][]int, err error) {
+	m = model.Model
+	if r.model != nil {
+		m = *r.model
+	}
+	ops := r.History.Operations
+	switch {
+	case r.model == nil:
+		names, parts = model.Split(ops)
+		if r.Key != "" {
+			// Checked key by key, the information is of that key alone.
+			i := slices.Index(names, r.Key)
+			if i < 0 {
+				return m, nil, nil, nil, fmt.Errorf("workload: key %s is not in the history", r.Key)
+			}
+			names, parts = names[i:i+1], parts[i:i+1]
+		}
+	case m.Partition != nil:
+		parts = m.Partition(ops)
+	default:
+		parts = [][]porcupine.Operation{ops}
+	}
+	partials = r.Info.PartialLinearizations()
+	if len(partials) != len(parts) {
+		return m, nil, nil, nil, fmt.Errorf("workload: the check has %d partitions, its history %d", len(partials), len(parts))
+	}
+	return m, names, parts, partials, nil
+}
+
+// stuck returns the model of r and its partitions that are not
+// linearizable.
+func (r *Result) stuck() (porcupine.Model, []stuckPartition, error) {
+	m, names, parts, partials, err := r.partitions()
+	if err != nil {
+		return m, nil, err
+	}
+	var stuck []stuckPartition
+	for i, part := range parts {
+		st := stuckPartition{name: fmt.Sprintf("partition %d", i), ops: part}
+		if names != nil {
+			st.name, st.key = "key "+names[i], names[i]
+		}
+		for _, p := range partials[i] {
+			if len(p) > len(st.longest) {
+				st.longest = p
+			}
+		}
+		if len(st.longest) == len(part) {
+			continue
+		}
+		st.state = m.Init()
+		linearized := make([]bool, len(part))
+		for _, id := range st.longest {
+			_, st.state = m.Step(st.state, part[id].Input, part[id].Output)
+			linearized[id] = true
+		}
+		end := int64(math.MaxInt64)
+		for id, op := range part {
+			if !linearized[id] {
+				end = min(end, op.Return)
+			}
+		}
+		for id, op := range part {
+			if !linearized[id] && op.Call <= end {
+				st.next = append(st.next, id)
+			}
+		}
+		slices.SortFunc(st.next, func(a, b int) int { return cmp.Compare(part[a].Call, part[b].Call) })
+		st.context = st.longest[max(len(st.longest)-renderContext, 0):]
+		for j, id := range slices.Concat(st.context, st.next) {
+			op := part[id]
+			ret := op.Return
+			if out, ok := op.Output.(model.Output); ok && out.Pending {
+				// A pending operation returns after all others.
+				ret = op.Call
+			}
+			if j == 0 {
+				st.start, st.stop = op.Call, ret
+			}
+			st.start, st.stop = min(st.start, op.Call), max(st.stop, ret)
+		}
+		stuck = append(stuck, st)
+	}
+	return m, stuck, nil
+}
+
+// pick returns the operations of st at ids.
+func (st *stuckPartition) pick(ids []int) []porcupine.Operation {
+	ops := make([]porcupine.Operation, len(ids))
+	for i, id := range ids {
+		ops[i] = st.ops[id]
+	}
+	return ops
+}
@@ -0,0 +1,70 @@
+package workload
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestResult(t *testing.T) {
+	// The Load of a misses the Store before it, which the pending Swap
+	// cannot explain; b is linearizable.
+	h := &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a", Val: 2}, Call: 20, Output: model.Output{}, Return: 30},
+		{ClientId: 1, Input: model.Input{Op: model.Load, Key: "a"}, Call: 40, Output: model.Output{}, Return: 50},
+		{ClientId: 2, Input: model.Input{Op: model.Swap, Key: "a", Val: 3}, Call: 45, Output: model.Output{Pending: true}, Return: 1000},
+		{ClientId: 3, Input: model.Input{Op: model.Store, Key: "b", Val: 1}, Call: 0, Output: model.Output{}, Return: 10},
+	}}
+	r := Check(h, nil, 0)
+	if r.Check != porcupine.Illegal {
+		t.Fatalf("Check = %s, want Illegal", r.Check)
+	}
+	parts, err := r.PartialLinearizations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Key != "a" || parts[0].Linearizable || parts[1].Key != "b" || !parts[1].Linearizable {
+		t.Fatalf("partitions %+v, want a not linearizable and b linearizable", parts)
+	}
+	if l := parts[1].Linearizations; len(l) != 1 || len(l[0]) != 1 || l[0][0].Input.(model.Input).Key != "b" {
+		t.Errorf("linearizations of b: %v", l)
+	}
+
+	prefixes, err := r.LongestLegalPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Store(a, 2), then the pending Swap.
+	if len(prefixes) != 1 || prefixes[0].Key != "a" || len(prefixes[0].Ops) != 2 || prefixes[0].State != "3" {
+		t.Fatalf("prefixes %+v, want the Store and the Swap of a, then 3", prefixes)
+	}
+	windows, err := r.FailingWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows) != 1 {
+		t.Fatalf("%d windows, want 1", len(windows))
+	}
+	w := windows[0]
+	if len(w.Next) != 1 || w.Next[0].Input.(model.Input).Op != model.Load || w.Legal[0] || w.Start != 20 || w.Stop != 50 {
+		t.Errorf("window %+v, want the illegal Load next from 20 to 50", w)
+	}
+	if _, err := json.Marshal(windows); err != nil {
+		t.Error(err)
+	}
+
+	// A violation has the Result it was found with.
+	v := &Violation{Info: r.Info, History: h}
+	if vw, err := v.Result().FailingWindow(); err != nil || len(vw) != 1 || vw[0].State != w.State {
+		t.Errorf("Violation.Result().FailingWindow() = %+v, %v", vw, err)
+	}
+
+	h.Operations = h.Operations[3:]
+	r = Check(h, nil, 0)
+	if prefixes, err := r.LongestLegalPrefix(); r.Check != porcupine.Ok || err != nil || len(prefixes) != 0 {
+		t.Errorf("linearizable history: %s, prefixes %+v, %v", r.Check, prefixes, err)
+	}
+}
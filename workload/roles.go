@@ -0,0 +1,94 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// A Role is a group of workers running their own mix, for asymmetric
+// access patterns: a few writers among many readers stress the read path
+// of sync.Map while its dirty map keeps changing.
+//
+//	"roles": [
+//		{"name": "writer", "workers": 2, "mix": {"Store": 1}},
+//		{"name": "reader", "workers": 6, "mix": {"Load": 9, "Range": 1}},
+//		{"name": "deleter", "workers": 1, "mix": {"LoadAndDelete": 1}}
+//	]
+type Role struct {
+	Name    string         `json:"name"`
+	Workers int            `json:"workers"`
+	Mix     map[string]int `json:"mix"`
+}
+
+func (s *Spec) validateRoles() error {
+	if len(s.Roles) == 0 {
+		return nil
+	}
+	if s.Workers != 0 {
+		return errors.New("workload: workers and roles are exclusive, roles set their own workers")
+	}
+	for i, r := range s.Roles {
+		if r.Name == "" {
+			return fmt.Errorf("workload: role %d has no name", i)
+		}
+		if r.Workers < 1 {
+			return fmt.Errorf("workload: role %s: workers must be positive", r.Name)
+		}
+		if err := validateMix("role "+r.Name+": mix", r.Mix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workerMixes returns the mix of every worker, by index. The workers of
+// the roles follow each other in order.
+func (s *Spec) workerMixes() []map[string]int {
+	var mixes []map[string]int
+	if len(s.Roles) == 0 {
+		for range s.NumWorkers() {
+			mixes = append(mixes, s.Mix)
+		}
+		return mixes
+	}
+	for _, r := range s.Roles {
+		for range r.Workers {
+			mixes = append(mixes, r.Mix)
+		}
+	}
+	return mixes
+}
+
+// validateMix checks the operation names and weights of a mix, what names
+// it in errors.
+func validateMix(what string, mix map[string]int) error {
+	total := 0
+	for name, w := range mix {
+		if _, err := model.ParseOp(name); err != nil {
+			return fmt.Errorf("workload: %s: %v", what, err)
+		}
+		if w < 0 {
+			return fmt.Errorf("workload: %s: negative weight for %s", what, name)
+		}
+		total += w
+	}
+	if total == 0 {
+		return fmt.Errorf("workload: %s has no operation", what)
+	}
+	return nil
+}
+
+// mixString formats the weights of mix in the order of model.Ops.
+func mixString(mix map[string]int) string {
+	var ws []string
+	for _, op := range model.Ops() {
+		if w := mix[op.String()]; w > 0 {
+			ws = append(ws, op.String()+"="+strconv.Itoa(w))
+		}
+	}
+	return strings.Join(ws, ",")
+}
@@ -0,0 +1,49 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestRoles(t *testing.T) {
+	s, err := Load("testdata/roles.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NumWorkers() != 9 {
+		t.Errorf("NumWorkers = %d, want 9", s.NumWorkers())
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	h := s.Round(impl.New(), 1, nil)
+	allowed := func(id int) map[model.Op]bool {
+		switch {
+		case id < 2:
+			return map[model.Op]bool{model.Store: true, model.Swap: true}
+		case id < 8:
+			return map[model.Op]bool{model.Load: true, model.Range: true}
+		}
+		return map[model.Op]bool{model.LoadAndDelete: true}
+	}
+	for _, op := range h.Operations {
+		if in := op.Input.(model.Input); !allowed(op.ClientId)[in.Op] {
+			t.Fatalf("worker %d ran %s", op.ClientId, in.Op)
+		}
+	}
+	if !strings.Contains(s.String(), "roles=writer*2(Store=3,Swap=1),reader*6(Load=9,Range=1),deleter*1(LoadAndDelete=1)") {
+		t.Errorf("String = %s", &s)
+	}
+
+	for _, tc := range []struct{ spec, want string }{
+		{`{"workers": 2, "roles": [{"name": "r", "workers": 1, "mix": {"Load": 1}}]}`, "exclusive"},
+		{`{"roles": [{"workers": 1, "mix": {"Load": 1}}]}`, "no name"},
+		{`{"roles": [{"name": "r", "workers": 0, "mix": {"Load": 1}}]}`, "workers must be positive"},
+		{`{"roles": [{"name": "r", "workers": 1, "mix": {"Lod": 1}}]}`, "role r: mix"},
+	} {
+		if _, err := Parse(strings.NewReader(tc.spec)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%s) = %v, want error containing %q", tc.spec, err, tc.want)
+		}
+	}
+}
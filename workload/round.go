@@ -0,0 +1,290 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"runtime/trace"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// A History is what a round recorded: its operations and the annotations
+// of the events around them, such as the nemesis's and the phases of the
+// round, in the same time base. The Stores of a warmup are included, done
+// before the round by an extra client.
+type History struct {
+	Operations  []porcupine.Operation
+	Annotations []porcupine.Annotation
+}
+
+// Round runs one round of s against m and returns its history. Each
+// worker draws its operations from the mix and their keys from Dist, with
+// its own generator seeded from seed and the worker's index, so the same
+// seed yields the same sequence of inputs. fence, if not nil, is called
+// between each timestamp and the operation. Round panics if the operations
+// a Stream wrote cannot be read back.
+func (s *Spec) Round(m mapimpl.MapUnderTest, seed uint64, fence func()) *History {
+	h, err := s.round(context.Background(), m, seed, fence)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// round is Round with the context of the trace task the workers' regions
+// belong to, see Retrace. It returns an error instead of panicking, with
+// the history of what was read back.
+func (s *Spec) round(ctx context.Context, m mapimpl.MapUnderTest, seed uint64, fence func()) (*History, error) {
+	if fence == nil {
+		fence = func() {}
+	}
+	var (
+		keys    = s.KeyNames()
+		mixes   = s.workerMixes()
+		vals    = s.newValues()
+		h       = new(History)
+		wg      sync.WaitGroup
+		workers = s.NumWorkers()
+		// The goroutines of the operations left pending, see Pending.
+		outstanding sync.WaitGroup
+		gate        = newGate(s.Start, workers)
+		// With a barrier, time starts when the workers are released.
+		clk = recorder.NewClock(s.Clock)
+		rec = recorder.New(clk, fence)
+		// The files of the workers with a Stream.
+		streams = make([]*workerStream, workers)
+	)
+	// The warmup is a client of its own after the workers, even without
+	// one, so the pending operations are numbered after it.
+	warmup := rec.Client(workers, len(keys))
+	stores, annotations := s.warmup(m, vals)
+	for _, op := range stores {
+		warmup.Record(op)
+	}
+	for _, a := range annotations {
+		warmup.Annotate(a)
+	}
+	if !s.Start.Barrier {
+		clk.Reset()
+	}
+
+	for g := range workers {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			gate.wait()
+			defer trace.StartRegion(ctx, "worker").End()
+			trace.Logf(ctx, "worker", "%d", id)
+			c := rec.Client(id, s.Stream.capacity(s.Ops))
+			stream := s.Stream.worker(c, clk, seed)
+			streams[id] = stream
+			if d := s.Start.jitter(seed, id); d > 0 {
+				end := clk.Now()
+				c.Annotate(porcupine.Annotation{
+					Start:       end - d.Nanoseconds(),
+					End:         end,
+					Description: "start delay " + d.String(),
+				})
+			}
+			rng := rand.New(rand.NewPCG(seed, uint64(id)))
+			ops := opChooser(mixes[id])
+			key := s.Dist.chooser(rng, len(keys))
+			// The nemesis draws from its own generator, so it leaves the
+			// operations of a seed alone.
+			nemesis := s.Nemesis.worker(seed, id, clk)
+			pending := s.Pending.worker(seed, id)
+			// The operations left pending run on m itself, on goroutines
+			// of their own.
+			wm := m
+			var yields *YieldMap
+			if s.Yields.enabled() {
+				yields = s.Yields.Wrap(m, clk, seed, id, workers)
+				wm = yields
+			}
+			// The last value this worker saw under each key, the expected
+			// value of its CompareAndSwaps.
+			seen := make(map[string]int)
+			for i := range s.Ops {
+				input := model.Input{
+					Op:  ops(rng),
+					Key: keys[key()],
+					Val: id*s.Ops + i,
+				}
+				if input.Op == model.Range {
+					input.Key = ""
+				}
+				if input.Op == model.CompareAndSwap {
+					input.Old = -1
+					if v, ok := seen[input.Key]; ok {
+						input.Old = v
+					}
+				}
+				// Allocate outside of the timed part.
+				var val, old any
+				switch input.Op {
+				case model.Store, model.LoadOrStore, model.Swap:
+					val = vals.make(input.Val)
+				case model.CompareAndSwap:
+					val, old = vals.make(input.Val), vals.expected(input.Old)
+				}
+
+				if leave, d := pending.next(); leave {
+					c.Abandon(input, model.Output{Pending: true})
+					outstanding.Add(1)
+					go func() {
+						defer outstanding.Done()
+						if d > 0 {
+							time.Sleep(d)
+						}
+						execute(m, input, val, old)
+					}()
+					nemesis.perturb()
+					continue
+				}
+
+				op := c.Begin(input)
+				output := execute(wm, input, val, old)
+				op.End(output)
+				stream.flush()
+
+				switch input.Op {
+				case model.Store, model.Swap:
+					seen[input.Key] = input.Val
+				case model.LoadOrStore, model.CompareAndSwap:
+					// Found means LoadOrStore loaded, but CompareAndSwap
+					// swapped.
+					if output.Found == (input.Op == model.CompareAndSwap) {
+						seen[input.Key] = input.Val
+					} else if output.Found {
+						seen[input.Key] = output.Val
+					}
+				case model.Load, model.LoadAndDelete:
+					if output.Found {
+						seen[input.Key] = output.Val
+					}
+				}
+
+				nemesis.perturb()
+			}
+			for _, a := range nemesis.annotations {
+				c.Annotate(a)
+			}
+			if yields != nil {
+				for _, a := range yields.Annotations() {
+					c.Annotate(a)
+				}
+			}
+		}(g)
+	}
+
+	gate.ready()
+	if s.Start.Barrier {
+		clk.Reset()
+	}
+	stopNemesis := s.Nemesis.start(seed, clk, h)
+	stopGC := s.GC.start(clk, h)
+	stopLoad := s.CPULoad.start(clk, h)
+	gate.release()
+	began := clk.Now()
+	wg.Wait()
+	ran := clk.Now()
+	// Pending operations return only after every other one.
+	h.Operations, h.Annotations = rec.History()
+	// The history is missing operations if a stream fails, and may pass or
+	// fail for it, but every file is still read back and removed.
+	var streamErr error
+	for _, stream := range streams {
+		ops, err := stream.readBack()
+		if err != nil && streamErr == nil {
+			streamErr = fmt.Errorf("workload: reading back the streamed operations: %v", err)
+		}
+		h.Operations = append(h.Operations, ops...)
+	}
+	h.Annotations = append(h.Annotations, phase("workers", began, ran, fmt.Sprintf("%d workers of %d operations each", workers, s.Ops)))
+	outstanding.Wait()
+	if s.Pending.Percent > 0 {
+		h.Annotations = append(h.Annotations, phase("pending", ran, clk.Now(), "waited for the operations left pending"))
+	}
+	stopLoad()
+	stopGC()
+	stopNemesis()
+	return h, streamErr
+}
+
+// phase returns the annotation of a phase of the round, from its start to
+// its end, in a lane of its own so the events of the nemesis and the
+// operations line up with them.
+func phase(desc string, start, end int64, details string) porcupine.Annotation {
+	return porcupine.Annotation{
+		Tag:             "phase",
+		Start:           start,
+		End:             end,
+		Description:     desc,
+		Details:         details,
+		BackgroundColor: "#e6e6e6",
+	}
+}
+
+// opChooser returns a function drawing operations from rng with the
+// weights of mix.
+func opChooser(mix map[string]int) func(*rand.Rand) model.Op {
+	var (
+		ops   []model.Op
+		upto  []int
+		total int
+	)
+	for _, op := range model.Ops() {
+		if w := mix[op.String()]; w > 0 {
+			total += w
+			ops = append(ops, op)
+			upto = append(upto, total)
+		}
+	}
+	return func(rng *rand.Rand) model.Op {
+		n := rng.IntN(total)
+		i, _ := slices.BinarySearch(upto, n+1)
+		return ops[i]
+	}
+}
+
+// execute runs in on m, val and old are the values of in.Val and in.Old.
+func execute(m mapimpl.MapUnderTest, in model.Input, val, old any) model.Output {
+	var (
+		v  any
+		ok bool
+	)
+	switch in.Op {
+	case model.Load:
+		v, ok = m.Load(in.Key)
+	case model.Store:
+		m.Store(in.Key, val)
+	case model.LoadOrStore:
+		v, ok = m.LoadOrStore(in.Key, val)
+	case model.LoadAndDelete:
+		v, ok = m.LoadAndDelete(in.Key)
+	case model.Swap:
+		v, ok = m.Swap(in.Key, val)
+	case model.CompareAndSwap:
+		return model.Output{Found: m.CompareAndSwap(in.Key, old, val)}
+	case model.Range:
+		entries := make(map[string]int)
+		m.Range(func(key, value any) bool {
+			k, _ := key.(string)
+			entries[k] = valueID(value)
+			return true
+		})
+		return model.Output{Entries: entries}
+	}
+	if !ok {
+		return model.Output{}
+	}
+	return model.Output{Found: true, Val: valueID(v)}
+}
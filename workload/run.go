@@ -0,0 +1,346 @@
+package workload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// RunOptions configure Run.
+type RunOptions struct {
+	// Fence is called between each timestamp and the operation.
+	Fence func()
+	// AfterRound, if set, is called with the quiescent map and the history
+	// of each round before the history is checked. An error stops Run.
+	AfterRound func(round int, m mapimpl.MapUnderTest, history []porcupine.Operation) error
+	// Logf, if set, receives the configuration, and with Verbose the seed
+	// of every round.
+	Logf    func(format string, args ...any)
+	Verbose bool
+	// Logger, if set, receives what Logf would as slog records, with the
+	// attributes impl, and round and seed of a round: the configuration,
+	// progress and stats at Info, the seed of every round at Debug unless
+	// Verbose, and the rounds that hung at Warn. It takes the place of
+	// Logf.
+	Logger *slog.Logger
+	// Duration, if set, makes Run a soak: it runs rounds until Duration
+	// elapses, however many Rounds the spec asks for.
+	Duration time.Duration
+	// Progress, if set, is the interval at which Run logs its Stats, with
+	// the rounds per second since it started and how long it has left,
+	// and writes the Checkpoint.
+	Progress time.Duration
+	// Checkpoint, if set, is the file the state of the soak is written to,
+	// after every Progress interval and when Run returns. If the file
+	// exists, Run resumes from it: with its seed, the round after the last
+	// one it counted and what remains of Duration.
+	Checkpoint string
+	// InFlight, if set, checks the histories in the background while the
+	// next rounds run, at most InFlight of them at a time, instead of
+	// checking each round before the next one starts. A violation then
+	// stops Run once the rounds in flight are checked, and the first of
+	// their violations is reported.
+	InFlight int
+	// FirstRound, if set, skips the rounds before it, to run a batch of
+	// the rounds up to s.Rounds with the seeds they have in the whole run.
+	FirstRound int
+	// Record, if set, receives the history of every round as it was
+	// recorded, before it is validated and checked.
+	Record *HistoryWriter
+	// Stats, if set, logs the HistoryStats of every round.
+	Stats bool
+	// Summary, if set, receives the Stats of the run when Run returns.
+	Summary *Stats
+	// Sample, if set, picks a random one in Sample of the rounds by their
+	// seeds, and passes each of those that is linearizable to Sampled,
+	// which the checks in flight may call concurrently.
+	Sample  int
+	Sampled func(*Sample)
+	// Checked, if set, receives the outcome of every round once it is
+	// checked, or hung, which the checks in flight may call concurrently
+	// and out of order.
+	Checked func(Checked)
+	// Stop, if set, stops Run once it is closed, on a signal say: the round
+	// running is finished, the rounds in flight are checked and the
+	// Checkpoint is written, and Run returns ErrStopped.
+	Stop <-chan struct{}
+}
+
+// labeled runs f with the pprof label phase=name, see Run.
+func labeled(name string, f func()) {
+	pprof.Do(context.Background(), pprof.Labels("phase", name), func(context.Context) { f() })
+}
+
+// ErrStopped is the error of a Run that RunOptions.Stop stopped before
+// its end, which resumes from its checkpoint.
+var ErrStopped = errors.New("workload: stopped")
+
+// A Violation is a round whose history is not linearizable.
+type Violation struct {
+	Round int
+	Seed  uint64
+	Info  porcupine.LinearizationInfo
+	// History is the history that was checked, to save and check again.
+	History *History
+	// Key is the key whose history is not linearizable, of which Info is,
+	// if the history was checked key by key, see Checker.Shards.
+	Key string
+	// model is the model the history was checked with, model.Model if
+	// unset.
+	model *porcupine.Model
+}
+
+func (v *Violation) Error() string {
+	if v.Key != "" {
+		return fmt.Sprintf("round %d (seed %d): history of key %s is not linearizable", v.Round, v.Seed, v.Key)
+	}
+	return fmt.Sprintf("round %d (seed %d): history is not linearizable", v.Round, v.Seed)
+}
+
+// Visualize writes porcupine's HTML visualization of the violation to w.
+func (v *Violation) Visualize(w io.Writer) error {
+	m := model.Model
+	if v.model != nil {
+		m = *v.model
+	}
+	return porcupine.Visualize(m, v.Info, w)
+}
+
+// WriteHistory writes the history of the violation to w as JSON, which
+// LoadHistory and LoadRecords read back.
+func (v *Violation) WriteHistory(w io.Writer) error {
+	b, err := json.Marshal(v.History)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// FilePrefix returns the prefix of the files written about rounds on
+// impl: syncmap for sync.Map, as they have always been named, and the name
+// in lower case otherwise.
+func FilePrefix(impl string) string {
+	if impl == "sync.Map" {
+		return "syncmap"
+	}
+	return strings.ToLower(impl)
+}
+
+// ViolationName returns the name of the files of a violation in round of
+// impl, without their extension: <prefix>_violation_<round>_<time of day>.
+func ViolationName(impl string, round int) string {
+	return fmt.Sprintf("%s_violation_%d_%s", FilePrefix(impl), round, time.Now().Format("150405"))
+}
+
+// WriteFiles writes the visualization of the violation to base.html and
+// its history to base.json, which LoadHistory and -recheck read back,
+// creating the directory of base.
+func (v *Violation) WriteFiles(base string) error {
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		return fmt.Errorf("workload: %v", err)
+	}
+	for _, f := range []struct {
+		ext   string
+		write func(io.Writer) error
+	}{{".html", v.Visualize}, {".json", v.WriteHistory}} {
+		file, err := os.Create(base + f.ext)
+		if err != nil {
+			return fmt.Errorf("workload: %v", err)
+		}
+		err = f.write(file)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("workload: failed to write %s: %v", base+f.ext, err)
+		}
+	}
+	return nil
+}
+
+// progressReport describes the progress of a run at cp, elapsed after it
+// started or resumed with resumedRounds checked: its stats, the rounds
+// it checks per second since and how long it has left, until duration has
+// elapsed in a soak or the rounds are checked otherwise.
+func progressReport(cp checkpoint, resumedRounds int, elapsed time.Duration, rounds int, duration time.Duration) string {
+	str := cp.Stats.String()
+	if cp.Stats.Violations > 0 {
+		str += fmt.Sprintf(", %d violations", cp.Stats.Violations)
+	}
+	rate := float64(cp.Stats.Rounds-resumedRounds) / elapsed.Seconds()
+	str += fmt.Sprintf(", %.1f rounds/s", rate)
+	switch {
+	case duration > 0:
+		return str + fmt.Sprintf(", ETA %v", max(duration-time.Duration(cp.Stats.Elapsed), 0).Round(time.Second))
+	case rate > 0:
+		left := time.Duration(float64(max(rounds-cp.Next, 0)) / rate * float64(time.Second))
+		return str + fmt.Sprintf(", ETA %v", left.Round(time.Second))
+	}
+	return str
+}
+
+// Run validates s, then runs and checks its rounds until the first error,
+// which is a *Violation if a history is not linearizable. A history the
+// checker times out on counts as passed, and one the Watchdog gives up on
+// stops Run with a *Hung unless it skips them. If Seed is 0, Run sets it
+// to a random seed first. With opts.Duration, Run soaks: it runs rounds
+// until the duration elapses rather than s.Rounds of them.
+//
+// The rounds run with the pprof label phase=run, their recording to
+// opts.Record with phase=record and their checks with phase=check, for a
+// CPU profile to tell them apart, with go tool pprof -tagfocus.
+func (s *Spec) Run(opts RunOptions) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	cp, err := s.resume(opts.Checkpoint)
+	if err != nil {
+		return err
+	}
+	if s.Seed == 0 {
+		s.Seed = rand.Uint64()
+	}
+	log := opts.logger().With("impl", s.Impl)
+	log.Info(fmt.Sprintf("config: %s", s), "config", s.String(), "seed", s.Seed)
+	if cp.Next > 0 {
+		log.Info(fmt.Sprintf("resuming at round %d after %s", cp.Next, cp.Stats), "round", cp.Next, "stats", cp.Stats)
+	}
+	cp.Next = max(cp.Next, opts.FirstRound)
+
+	start := time.Now()
+	resumed, resumedRounds := time.Duration(cp.Stats.Elapsed), cp.Stats.Rounds
+	c := newChecker(time.Duration(s.Checker.Timeout), opts.InFlight, cp.Stats, cp.Next)
+	c.watchdog, c.log, c.shards = s.Watchdog, log, s.Checker.Shards
+	if opts.Sampled != nil {
+		c.sample, c.sampled = opts.Sample, opts.Sampled
+	}
+	c.report = opts.Checked
+	if s.NewMap == nil && opts.Checkpoint != "" {
+		// The checkpoint has the spec, for LoadCheckpoint to resume without
+		// it; a map of the caller's own has no name to be found again by.
+		if cp.Spec, err = json.Marshal(s); err != nil {
+			return fmt.Errorf("workload: %v", err)
+		}
+	}
+	cp.Duration = Duration(opts.Duration)
+	flush := func(round int) error {
+		cp.Config, cp.Seed = s.String(), s.Seed
+		cp.Stats, cp.Next = c.progress()
+		cp.Stats.Elapsed = Duration(resumed + time.Since(start))
+		if round >= 0 {
+			cp.Next = round
+		}
+		if opts.Checkpoint == "" {
+			return nil
+		}
+		return cp.write(opts.Checkpoint)
+	}
+
+	// next is the round a checkpoint resumes with, if not the first one
+	// not checked yet. After a failed round that is the round after it,
+	// so a soak can go on past a violation it has saved.
+	next := -1
+	stopped := false
+	err = func() error {
+		progress := time.Now()
+		for round := cp.Next; ; round++ {
+			select {
+			case <-opts.Stop:
+				stopped = true
+				return nil
+			default:
+			}
+			if opts.Duration > 0 {
+				if resumed+time.Since(start) >= opts.Duration {
+					return nil
+				}
+			} else if round >= s.Rounds {
+				return nil
+			}
+			if c.stopped() != nil {
+				return nil
+			}
+			if opts.Progress > 0 && time.Since(progress) >= opts.Progress {
+				progress = time.Now()
+				if err := flush(-1); err != nil {
+					return err
+				}
+				log.Info("progress: "+progressReport(cp, resumedRounds, time.Since(start), s.Rounds, opts.Duration), "round", cp.Next, "stats", cp.Stats)
+			}
+
+			seed := s.RoundSeed(round)
+			log.Log(context.Background(), opts.roundLevel(), fmt.Sprintf("Round %d: seed %d", round, seed), "round", round, "seed", seed)
+			m := s.newMap()
+			h, err := s.watchRound(c, m, round, seed, opts.Fence)
+			if err != nil {
+				next = round
+				return fmt.Errorf("round %d (seed %d): %w", round, seed, err)
+			}
+			if h == nil {
+				continue
+			}
+			if opts.Stats {
+				log.Info(fmt.Sprintf("round %d: %s", round, h.Stats()), "round", round, "seed", seed)
+			}
+			if opts.Record != nil {
+				var err error
+				labeled("record", func() { err = opts.Record.Write(Record{Round: round, Seed: seed, History: h}) })
+				if err != nil {
+					next = round
+					return fmt.Errorf("round %d (seed %d): %w", round, seed, err)
+				}
+			}
+
+			if opts.AfterRound != nil {
+				if err := opts.AfterRound(round, m, h.Operations); err != nil {
+					next = round + 1
+					return fmt.Errorf("round %d (seed %d): %w", round, seed, err)
+				}
+			}
+			if err := ValidateTimestamps(h.Operations, time.Duration(s.Checker.Epsilon)); err != nil {
+				next = round + 1
+				return fmt.Errorf("round %d (seed %d): %w", round, seed, err)
+			}
+			c.submit(round, seed, h)
+		}
+	}()
+	c.wait()
+
+	// A violation is in a round before any other error, and so is a round
+	// that hung, if it is not skipped.
+	switch stop := c.stopped().(type) {
+	case *Violation:
+		err, next = stop, stop.Round+1
+	case *Hung:
+		err, next = stop, stop.Round+1
+	}
+	if ferr := flush(next); ferr != nil && err == nil {
+		err = ferr
+	}
+	switch {
+	case stopped && err == nil:
+		err = ErrStopped
+		log.Info(fmt.Sprintf("stopped before round %d: %s", cp.Next, cp.Stats), "round", cp.Next, "stats", cp.Stats)
+	case opts.Duration > 0 || opts.Progress > 0:
+		log.Info(fmt.Sprintf("done: %s", cp.Stats), "stats", cp.Stats)
+	}
+	if opts.Summary != nil {
+		*opts.Summary = cp.Stats
+	}
+	return err
+}
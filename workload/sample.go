@@ -0,0 +1,36 @@
+package workload
+
+import (
+	"io"
+	"math/rand/v2"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// sampleSalt separates the choice of the sampled rounds from the workers'
+// generators.
+const sampleSalt = 0x73616d706c65
+
+// A Sample is a round that passed, chosen by RunOptions.Sample, to see
+// what a healthy history of the workload looks like.
+type Sample struct {
+	Round   int
+	Seed    uint64
+	Info    porcupine.LinearizationInfo
+	History *History
+}
+
+// Visualize writes porcupine's HTML visualization of the round to w, with
+// one of its linearizations.
+func (s *Sample) Visualize(w io.Writer) error {
+	return porcupine.Visualize(model.Model, s.Info, w)
+}
+
+// sampled reports whether the round of seed is one of the 1 in n a Run
+// samples. The choice is by seed, so running the same seed again samples
+// the same rounds.
+func sampled(seed uint64, n int) bool {
+	return n > 0 && rand.New(rand.NewPCG(seed^sampleSalt, 0)).IntN(n) == 0
+}
@@ -0,0 +1,47 @@
+package workload
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunSample(t *testing.T) {
+	s, err := New().Workers(2).Rounds(200).Ops(10).Shards(2).Seed(1).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu     sync.Mutex
+		rounds []int
+		page   strings.Builder
+	)
+	sample := func(sm *Sample) {
+		mu.Lock()
+		defer mu.Unlock()
+		rounds = append(rounds, sm.Round)
+		if sm.Seed != s.RoundSeed(sm.Round) || len(sm.History.Operations) != 20 {
+			t.Errorf("sample of round %d has seed %d and %d operations", sm.Round, sm.Seed, len(sm.History.Operations))
+		}
+		if page.Len() == 0 {
+			if err := sm.Visualize(&page); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	if err := s.Run(RunOptions{InFlight: 4, Sample: 10, Sampled: sample}); err != nil {
+		t.Fatal(err)
+	}
+	// About 20 of 200, chosen by their seeds however the checks finish.
+	if len(rounds) < 5 || len(rounds) > 50 {
+		t.Fatalf("%d rounds sampled, want about 20", len(rounds))
+	}
+	for _, r := range rounds {
+		if !sampled(s.RoundSeed(r), 10) {
+			t.Errorf("round %d sampled, its seed is not", r)
+		}
+	}
+	if !strings.Contains(page.String(), "<html") {
+		t.Errorf("visualization of a sample is not a page: %.200s", page.String())
+	}
+}
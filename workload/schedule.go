@@ -0,0 +1,203 @@
+package workload
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// The bounds of a Schedule, which keep the history of one small enough
+// to check on every iteration of a fuzzer.
+const (
+	MaxScheduleClients = 4
+	MaxScheduleKeys    = 4
+	MaxScheduleSteps   = 64
+)
+
+// scheduleStepBytes is the length of the encoding of a Step.
+const scheduleStepBytes = 5
+
+// A Schedule is a fixed workload decoded from bytes, for go test -fuzz to
+// mutate instead of drawing the operations of a round at random: the
+// coverage of the code running and checking it then steers the search
+// towards interleavings a uniform mix rarely reaches.
+type Schedule struct {
+	Clients int
+	Keys    int
+	Steps   []Step
+}
+
+// A Step is an operation of a Schedule, run by its client in the order of
+// the schedule after Yields calls of runtime.Gosched and a sleep of
+// Sleep.
+type Step struct {
+	Client int
+	Input  model.Input
+	Yields int
+	Sleep  time.Duration
+}
+
+// DecodeSchedule decodes any b as a Schedule: the number of clients and of
+// keys, from 1 to their maximum, from the first two bytes, then five bytes
+// for each step: its client, operation, key, value and delay. Values are
+// from 0 to 15, the low half of their byte, and the high half is the
+// expected value of a CompareAndSwap, so that values collide and
+// CompareAndSwaps succeed often. A delay below 128 is as many yields, one
+// of 128 or more sleeps a microsecond for every step above 127. Incomplete
+// steps at the end and those past MaxScheduleSteps are ignored.
+func DecodeSchedule(b []byte) *Schedule {
+	sc := &Schedule{Clients: 1, Keys: 1}
+	if len(b) > 0 {
+		sc.Clients += int(b[0]) % MaxScheduleClients
+	}
+	if len(b) > 1 {
+		sc.Keys += int(b[1]) % MaxScheduleKeys
+	}
+	keys := sc.KeyNames()
+	ops := model.Ops()
+	for b = b[min(len(b), 2):]; len(b) >= scheduleStepBytes && len(sc.Steps) < MaxScheduleSteps; b = b[scheduleStepBytes:] {
+		st := Step{
+			Client: int(b[0]) % sc.Clients,
+			Input: model.Input{
+				Op:  ops[int(b[1])%len(ops)],
+				Key: keys[int(b[2])%len(keys)],
+				Val: int(b[3] & 0x0f),
+			},
+		}
+		switch st.Input.Op {
+		case model.Range:
+			st.Input.Key = ""
+		case model.CompareAndSwap:
+			st.Input.Old = int(b[3] >> 4)
+		}
+		if d := b[4]; d < 128 {
+			st.Yields = int(d)
+		} else {
+			st.Sleep = time.Duration(d-127) * time.Microsecond
+		}
+		sc.Steps = append(sc.Steps, st)
+	}
+	return sc
+}
+
+// scheduleTick is the unit of the sleeps of the schedules of a Spec.
+const scheduleTick = time.Microsecond
+
+// Schedule returns a Schedule of a round of s with seed, for a bubble of
+// testing/synctest to run exactly the same way every time: every worker
+// draws its operations from the mix and their keys from Dist as in Round,
+// with values of ints, and sleeps from 1 to NumWorkers microseconds at
+// random before each, so that some of the operations of the workers fall
+// on the same instant and overlap. A CompareAndSwap expects the value the
+// worker last stored under the key. The bounds of DecodeSchedule do not
+// apply, nor does anything of s besides its workers, operations, keys and
+// mix: not its warmup, start, nemesis or pending operations.
+func (s *Spec) Schedule(seed uint64) *Schedule {
+	var (
+		workers = s.NumWorkers()
+		keys    = s.KeyNames()
+		mixes   = s.workerMixes()
+		sc      = &Schedule{Clients: workers, Keys: s.Keys}
+	)
+	for id := range workers {
+		rng := rand.New(rand.NewPCG(seed, uint64(id)))
+		ops := opChooser(mixes[id])
+		key := s.Dist.chooser(rng, len(keys))
+		stored := make(map[string]int)
+		for i := range s.Ops {
+			in := model.Input{Op: ops(rng), Key: keys[key()], Val: id*s.Ops + i}
+			switch in.Op {
+			case model.Range:
+				in.Key = ""
+			case model.CompareAndSwap:
+				in.Old = -1
+				if v, ok := stored[in.Key]; ok {
+					in.Old = v
+				}
+			}
+			switch in.Op {
+			case model.Store, model.LoadOrStore, model.Swap, model.CompareAndSwap:
+				stored[in.Key] = in.Val
+			}
+			sleep := time.Duration(1+rng.IntN(workers)) * scheduleTick
+			sc.Steps = append(sc.Steps, Step{Client: id, Input: in, Sleep: sleep})
+		}
+	}
+	return sc
+}
+
+// KeyNames returns the keys of sc, named as those of a Spec.
+func (sc *Schedule) KeyNames() []string {
+	s := Spec{Keys: sc.Keys}
+	return s.KeyNames()
+}
+
+// Run runs sc against m, every client in a goroutine of its own released
+// at once, and returns the history. The operations are timed by time.Now,
+// which in a bubble of testing/synctest is its fake clock: time only
+// passes there while every goroutine sleeps, so the steps of the clients
+// run in the order of the instants their sleeps end at, whatever the
+// scheduler does, and only those of one instant, which are all called and
+// return at that instant, overlap.
+func (sc *Schedule) Run(m mapimpl.MapUnderTest) *History {
+	var (
+		clk   = recorder.NewClock(recorder.ClockTime)
+		rec   = recorder.New(clk, nil)
+		start = make(chan struct{})
+		wg    sync.WaitGroup
+	)
+	for id := range sc.Clients {
+		var steps []Step
+		for _, st := range sc.Steps {
+			if st.Client == id {
+				steps = append(steps, st)
+			}
+		}
+		c := rec.Client(id, len(steps))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for _, st := range steps {
+				for range st.Yields {
+					runtime.Gosched()
+				}
+				if st.Sleep > 0 {
+					time.Sleep(st.Sleep)
+				}
+				op := c.Begin(st.Input)
+				op.End(execute(m, st.Input, st.Input.Val, st.Input.Old))
+			}
+		}()
+	}
+	clk.Reset()
+	close(start)
+	wg.Wait()
+	h := new(History)
+	h.Operations, h.Annotations = rec.History()
+	return h
+}
+
+// String returns the steps of sc, one per line, for the log of a failing
+// input.
+func (sc *Schedule) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d clients, %d keys, %d steps", sc.Clients, sc.Keys, len(sc.Steps))
+	for _, st := range sc.Steps {
+		fmt.Fprintf(&b, "\n  client %d: %s", st.Client, st.Input)
+		switch {
+		case st.Yields > 0:
+			fmt.Fprintf(&b, " after %d yields", st.Yields)
+		case st.Sleep > 0:
+			fmt.Fprintf(&b, " after %v", st.Sleep)
+		}
+	}
+	return b.String()
+}
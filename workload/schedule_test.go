@@ -0,0 +1,64 @@
+package workload
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestDecodeSchedule(t *testing.T) {
+	sc := DecodeSchedule([]byte{
+		5, 2, // 2 clients, 3 keys
+		3, 5, 4, 0x21, 7, // client 1: CompareAndSwap(k1, 2, 1) after 7 yields
+		0, 6, 1, 0x0f, 130, // client 0: Range after 3µs
+		0, 1, 2, 0xff, // incomplete
+	})
+	if sc.Clients != 2 || sc.Keys != 3 || len(sc.Steps) != 2 {
+		t.Fatalf("decoded %s, want 2 clients, 3 keys and 2 steps", sc)
+	}
+	want := []Step{
+		{Client: 1, Input: model.Input{Op: model.CompareAndSwap, Key: "k1", Val: 1, Old: 2}, Yields: 7},
+		{Client: 0, Input: model.Input{Op: model.Range, Val: 15}, Sleep: 3 * time.Microsecond},
+	}
+	for i, st := range sc.Steps {
+		if st != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i, st, want[i])
+		}
+	}
+	if sc := DecodeSchedule(nil); sc.Clients != 1 || sc.Keys != 1 || len(sc.Steps) != 0 {
+		t.Errorf("empty input decoded as %s", sc)
+	}
+	if sc := DecodeSchedule(make([]byte, 2+scheduleStepBytes*(MaxScheduleSteps+1))); len(sc.Steps) != MaxScheduleSteps {
+		t.Errorf("%d steps, want at most %d", len(sc.Steps), MaxScheduleSteps)
+	}
+}
+
+func TestScheduleRun(t *testing.T) {
+	// Every client stores its own value, then loads the key.
+	b := []byte{3, 0}
+	for c := range byte(4) {
+		b = append(b, c, 1, 0, c, 0)
+	}
+	for c := range byte(4) {
+		b = append(b, c, 0, 0, 0, 1)
+	}
+	h := DecodeSchedule(b).Run(new(sync.Map))
+	if len(h.Operations) != 8 {
+		t.Fatalf("%d operations, want 8", len(h.Operations))
+	}
+	for _, op := range h.Operations {
+		if op.Call > op.Return {
+			t.Errorf("operation %+v returns before its call", op)
+		}
+		if in := op.Input.(model.Input); in.Op == model.Load && !op.Output.(model.Output).Found {
+			t.Errorf("load of client %d after its store found nothing", op.ClientId)
+		}
+	}
+	if r := Check(h, nil, 0); r.Check != porcupine.Ok {
+		t.Errorf("check of the history of sync.Map = %s", r.Check)
+	}
+}
@@ -0,0 +1,71 @@
+package workload
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// checkSharded checks ops key by key, split by model.Split, shards keys at
+// a time, within timeout for all of them, 0 for none. The history is
+// linearizable if the history of every key is: the result is Illegal if
+// one of them is not, then Unknown if the checker timed out on one, Ok
+// otherwise. The first violation stops the check, and its key and the
+// information to visualize it, of that key's history alone, are returned
+// with it.
+//
+// porcupine checks the partitions of a model all at once, each on its own
+// goroutine, and computes the information of every one of them to
+// visualize any; with many keys, checking a few at a time and stopping
+// early bounds the work and memory in flight instead.
+func checkSharded(ops []porcupine.Operation, shards int, timeout time.Duration) (porcupine.CheckResult, porcupine.LinearizationInfo, string) {
+	keys, parts := model.Split(ops)
+	var (
+		deadline = time.Now().Add(timeout)
+		next     atomic.Int64
+		illegal  atomic.Bool
+		wg       sync.WaitGroup
+		results  = make([]porcupine.CheckResult, len(parts))
+		infos    = make([]porcupine.LinearizationInfo, len(parts))
+	)
+	for range min(shards, len(parts)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !illegal.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= len(parts) {
+					return
+				}
+				left := time.Until(deadline)
+				if timeout > 0 && left <= 0 {
+					results[i] = porcupine.Unknown
+					continue
+				}
+				if timeout == 0 {
+					left = 0
+				}
+				results[i], infos[i] = porcupine.CheckOperationsVerbose(model.Model, parts[i], left)
+				if results[i] == porcupine.Illegal {
+					illegal.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := porcupine.Ok
+	for i, r := range results {
+		switch r {
+		case porcupine.Illegal:
+			return r, infos[i], keys[i]
+		case porcupine.Unknown:
+			result = r
+		}
+	}
+	return result, porcupine.LinearizationInfo{}, ""
+}
@@ -0,0 +1,51 @@
+package workload
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestCheckSharded(t *testing.T) {
+	s := Default()
+	s.Workers, s.Keys = 4, 16
+	h := s.Round(s.newMap(), 2, nil)
+	for _, shards := range []int{1, 3, 100} {
+		if result, _, key := checkSharded(h.Operations, shards, 0); result != porcupine.Ok || key != "" {
+			t.Errorf("%d shards: %s on key %q, want Ok", shards, result, key)
+		}
+	}
+
+	// Only b is not linearizable.
+	ops := []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a", Val: 1}, Call: 0, Output: model.Output{}, Return: 1},
+		{ClientId: 1, Input: model.Input{Op: model.Load, Key: "b"}, Call: 0, Output: model.Output{Found: true, Val: 2}, Return: 1},
+		{ClientId: 0, Input: model.Input{Op: model.Load, Key: "a"}, Call: 2, Output: model.Output{Found: true, Val: 1}, Return: 3},
+	}
+	result, info, key := checkSharded(ops, 2, 0)
+	if result != porcupine.Illegal || key != "b" {
+		t.Fatalf("%s on key %q, want Illegal on b", result, key)
+	}
+	var html strings.Builder
+	if err := porcupine.Visualize(model.Model, info, &html); err != nil || strings.Contains(html.String(), "Store(a") {
+		t.Errorf("visualization of b: %v", err)
+	}
+
+	bad, err := New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(2).Rounds(1).Ops(40).Keys(4, Dist{}).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Shards(2).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v *Violation
+	if err := bad.Run(RunOptions{}); !errors.As(err, &v) || v.Key == "" || !strings.Contains(err.Error(), "key "+v.Key) {
+		t.Errorf("Run = %v, want a Violation of one key", err)
+	}
+	if _, err := New().Shards(-1).Build(); err == nil {
+		t.Error("Build accepted -1 shards")
+	}
+}
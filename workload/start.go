@@ -0,0 +1,85 @@
+package workload
+
+import (
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Start synchronizes the start of the workers of a round. Without a
+// barrier a worker starts whenever the scheduler gets to its goroutine, and
+// the first workers can be done before the last begin.
+type Start struct {
+	// Barrier holds every worker until all of them run, then releases them
+	// at once, which concentrates the operations in time and makes them
+	// overlap.
+	Barrier bool `json:"barrier,omitempty"`
+	// Jitter, if set, delays each worker by a random duration of up to
+	// Jitter after its release.
+	Jitter Duration `json:"jitter,omitempty"`
+}
+
+// startSalt separates the jitter's generators from the workers'.
+const startSalt = 0x7374617274
+
+func (st Start) validate() error {
+	if st.Jitter < 0 {
+		return errors.New("workload: start: jitter must not be negative")
+	}
+	return nil
+}
+
+// gate releases the workers of a round.
+type gate struct {
+	barrier  bool
+	arrived  sync.WaitGroup
+	released atomic.Bool
+}
+
+func newGate(st Start, workers int) *gate {
+	g := &gate{barrier: st.Barrier}
+	if g.barrier {
+		g.arrived.Add(workers)
+	} else {
+		g.released.Store(true)
+	}
+	return g
+}
+
+// wait is called by each worker before its first operation. Spinning
+// rather than blocking on a channel lets the workers that hold a P leave
+// the barrier in the same instant.
+func (g *gate) wait() {
+	if !g.barrier {
+		return
+	}
+	g.arrived.Done()
+	for !g.released.Load() {
+		runtime.Gosched()
+	}
+}
+
+// ready waits for every worker to arrive at the barrier.
+func (g *gate) ready() {
+	if g.barrier {
+		g.arrived.Wait()
+	}
+}
+
+// release lets the workers go.
+func (g *gate) release() {
+	g.released.Store(true)
+}
+
+// jitter sleeps for the start delay of worker id and returns it.
+func (st Start) jitter(seed uint64, id int) time.Duration {
+	if st.Jitter <= 0 {
+		return 0
+	}
+	d := time.Duration(rand.New(rand.NewPCG(seed^startSalt, uint64(id))).Int64N(int64(st.Jitter)) + 1)
+	time.Sleep(d)
+	return d
+}
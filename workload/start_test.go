@@ -0,0 +1,42 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+func TestStartBarrier(t *testing.T) {
+	s, err := New().Workers(16).Rounds(20).Ops(20).
+		Start(Start{Barrier: true, Jitter: Duration(20 * time.Microsecond)}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	h := s.Round(impl.New(), 3, nil)
+	if len(h.Operations) != 16*20 {
+		t.Fatalf("recorded %d operations, want %d", len(h.Operations), 16*20)
+	}
+	delays := 0
+	for _, a := range h.Annotations {
+		if strings.HasPrefix(a.Description, "start delay") {
+			delays++
+			if a.Start < 0 || a.End < a.Start {
+				t.Errorf("delay annotation %+v before the release", a)
+			}
+		}
+	}
+	if delays != 16 {
+		t.Errorf("%d start delays, want 16", delays)
+	}
+	for _, op := range h.Operations {
+		if op.Call < 0 {
+			t.Fatalf("operation %+v called before the release", op)
+		}
+	}
+	if err := s.Run(RunOptions{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,123 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// Stream writes the operations of a round to temporary files as they
+// complete, Batch at a time per worker, instead of keeping them in memory
+// until the round ends, and reads them back for checking. Each worker then
+// only buffers Batch operations, in the binary form of HistoryWriter once
+// written, so rounds of many more operations fit in memory while they
+// run; checking still needs the whole history. A worker writes between
+// its operations, outside of their timestamps, but the writes still pause
+// it and change the interleavings. If a write fails, the worker keeps its
+// operations in memory from then on and annotates the history with the
+// error.
+type Stream struct {
+	Batch int `json:"batch,omitempty"`
+	// Dir is the directory of the files, os.TempDir if empty.
+	Dir string `json:"dir,omitempty"`
+}
+
+func (st Stream) String() string {
+	str := strconv.Itoa(st.Batch)
+	if st.Dir != "" {
+		str += "(" + st.Dir + ")"
+	}
+	return str
+}
+
+func (st Stream) validate() error {
+	if st.Batch < 0 {
+		return errors.New("workload: stream: batch must not be negative")
+	}
+	return nil
+}
+
+// capacity returns the capacity of the buffer of a worker of ops
+// operations.
+func (st Stream) capacity(ops int) int {
+	if st.Batch > 0 {
+		return min(st.Batch, ops)
+	}
+	return ops
+}
+
+// workerStream writes the operations of one worker to its file.
+type workerStream struct {
+	st   Stream
+	c    *recorder.Client
+	clk  *recorder.Clock
+	seed uint64
+	f    *os.File
+	w    *HistoryWriter
+	// records is the number of records written in full.
+	records int
+}
+
+func (st Stream) worker(c *recorder.Client, clk *recorder.Clock, seed uint64) *workerStream {
+	return &workerStream{st: st, c: c, clk: clk, seed: seed}
+}
+
+// flush writes the buffered operations once there are Batch of them.
+func (w *workerStream) flush() {
+	if w.st.Batch == 0 || w.c.Len() < w.st.Batch {
+		return
+	}
+	at := w.clk.Now()
+	err := w.c.Flush(w.write)
+	if err == nil {
+		w.records++
+		return
+	}
+	w.c.Annotate(porcupine.Annotation{
+		Start:       at,
+		End:         w.clk.Now(),
+		Description: "stream failed",
+		Details:     err.Error() + ", kept the operations in memory from here on",
+	})
+	w.st.Batch = 0
+}
+
+func (w *workerStream) write(ops []porcupine.Operation) error {
+	if w.f == nil {
+		f, err := os.CreateTemp(w.st.Dir, "round-*.hist")
+		if err != nil {
+			return fmt.Errorf("workload: %v", err)
+		}
+		w.f, w.w = f, NewHistoryWriter(f)
+	}
+	return w.w.Write(Record{Seed: w.seed, History: &History{Operations: ops}})
+}
+
+// readBack returns the operations the worker wrote and removes its file.
+func (w *workerStream) readBack() ([]porcupine.Operation, error) {
+	if w.f == nil {
+		return nil, nil
+	}
+	defer os.Remove(w.f.Name())
+	defer w.f.Close()
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("workload: %v", err)
+	}
+	// A failed write may have left part of a record after the others.
+	var ops []porcupine.Operation
+	hr := NewHistoryReader(w.f)
+	for range w.records {
+		r, err := hr.Next()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", w.f.Name(), err)
+		}
+		ops = append(ops, r.History.Operations...)
+	}
+	return ops, nil
+}
@@ -0,0 +1,74 @@
+package workload
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestStream(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New().Workers(3).Ops(50).Keys(4, Dist{}).Pending(Pending{Percent: 10}).
+		Stream(Stream{Batch: 7, Dir: dir}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, missing := range []bool{false, true} {
+		if missing {
+			// The workers cannot create their files and keep everything
+			// in memory.
+			s.Stream.Dir = filepath.Join(dir, "missing")
+		}
+		h := s.Round(s.newMap(), 1, nil)
+		// Every operation either returned or is pending.
+		if len(h.Operations) != s.NumWorkers()*s.Ops {
+			t.Errorf("missing=%v: %d operations, want %d", missing, len(h.Operations), s.NumWorkers()*s.Ops)
+		}
+		if !porcupine.CheckOperations(model.Model, h.Operations) {
+			t.Errorf("missing=%v: history is not linearizable", missing)
+		}
+		failed := 0
+		for _, a := range h.Annotations {
+			if a.Description == "stream failed" {
+				failed++
+			}
+		}
+		if want := map[bool]int{false: 0, true: s.NumWorkers()}[missing]; failed != want {
+			t.Errorf("missing=%v: %d stream failures annotated, want %d", missing, failed, want)
+		}
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d files left behind", len(files))
+	}
+}
+
+// TestStreamReadBack truncates the files of a round as its workers finish,
+// which Run reports as an error of the round.
+func TestStreamReadBack(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New().Rounds(1).Workers(1).Ops(50).Stream(Stream{Batch: 7, Dir: dir}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	fence := func() {
+		if calls++; calls == 45 {
+			files, _ := filepath.Glob(filepath.Join(dir, "*.hist"))
+			for _, f := range files {
+				os.Truncate(f, 0)
+			}
+		}
+	}
+	err = s.Run(RunOptions{Fence: fence})
+	if err == nil || !strings.Contains(err.Error(), "round 0 (seed ") || !strings.Contains(err.Error(), "reading back the streamed operations") {
+		t.Errorf("Run = %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d files left behind", len(files))
+	}
+}
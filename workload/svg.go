@@ -0,0 +1,143 @@
+package workload
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// The layout of WriteSVG, in pixels.
+const (
+	svgWidth  = 1200
+	svgMargin = 16
+	svgLabel  = 80
+	svgLane   = 26
+	svgBar    = 20
+	svgHeader = 44
+)
+
+// The fills of the operations of WriteSVG.
+const (
+	svgLinearized = "#a5d6a7"
+	svgIllegal    = "#ef9a9a"
+	svgStuck      = "#ffcc80"
+	svgOther      = "#e0e0e0"
+)
+
+// WriteSVG writes a snapshot of the violation to w as a standalone SVG
+// image, to attach where the interactive visualization cannot go, such as
+// an issue or a chat. For every key, or partition of the model, whose
+// history is not linearizable, it draws the operations around where the
+// search for a linearization got stuck, those Render shows and the others
+// of the key in that window, in a lane per client: the linearized ones
+// green, those that could go next red if the model rejects them in that
+// state and orange if only whatever follows them, the others gray. As in
+// porcupine's visualization, the distinct times are evenly spaced rather
+// than to scale, so that short operations stay legible; each operation's
+// tooltip has its times.
+func (v *Violation) WriteSVG(w io.Writer) error {
+	m, stuck, err := v.stuck()
+	if err != nil {
+		return err
+	}
+	var (
+		body strings.Builder
+		y    = svgMargin + 24
+	)
+	fmt.Fprintf(&body, "<text x=\"%d\" y=\"%d\" font-size=\"16\" font-weight=\"bold\">%s</text>\n", svgMargin, svgMargin+14, html.EscapeString(v.Error()))
+	for _, st := range stuck {
+		y = svgPartition(&body, m, st, y)
+	}
+	y = svgLegend(&body, y)
+
+	_, err = fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %[1]d %[2]d\" font-family=\"sans-serif\">\n"+
+		"<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n%s</svg>\n", svgWidth, y+svgMargin, body.String())
+	return err
+}
+
+// svgPartition draws st from y down and returns the y below it.
+func svgPartition(b *strings.Builder, m porcupine.Model, st stuckPartition, y int) int {
+	fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\" font-size=\"14\">%s: %d of %d operations linearized, then the state is %s</text>\n",
+		svgMargin, y+18, html.EscapeString(st.name), len(st.longest), len(st.ops), html.EscapeString(describeState(m, st.state)))
+	y += svgHeader
+
+	// The operations of the window, and the distinct times in it.
+	fill := make(map[int]string)
+	for _, id := range st.longest {
+		fill[id] = svgLinearized
+	}
+	for _, id := range st.next {
+		fill[id] = svgStuck
+		if !st.legal(m, st.ops[id]) {
+			fill[id] = svgIllegal
+		}
+	}
+	var (
+		shown   []int
+		times   = []int64{st.start, st.stop}
+		clients []int
+	)
+	for id, op := range st.ops {
+		if op.Return < st.start || op.Call > st.stop {
+			continue
+		}
+		shown = append(shown, id)
+		times = append(times, max(op.Call, st.start), min(op.Return, st.stop))
+		clients = append(clients, op.ClientId)
+	}
+	slices.Sort(times)
+	times = slices.Compact(times)
+	slices.Sort(clients)
+	clients = slices.Compact(clients)
+	x := func(t int64) int {
+		i, _ := slices.BinarySearch(times, t)
+		span := svgWidth - 2*svgMargin - svgLabel
+		if len(times) > 1 {
+			return svgMargin + svgLabel + i*span/(len(times)-1)
+		}
+		return svgMargin + svgLabel
+	}
+
+	for i, c := range clients {
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\" font-size=\"12\" fill=\"#555\">client %d</text>\n", svgMargin, y+i*svgLane+14, c)
+	}
+	for _, id := range shown {
+		op := st.ops[id]
+		f, ok := fill[id]
+		if !ok {
+			f = svgOther
+		}
+		x0, x1 := x(max(op.Call, st.start)), x(min(op.Return, st.stop))
+		lane := slices.Index(clients, op.ClientId)
+		desc := html.EscapeString(describeOperation(m, op))
+		// A nested svg clips the description to the operation.
+		fmt.Fprintf(b, "<svg x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"><title>%s, %v to %v</title>"+
+			"<rect width=\"100%%\" height=\"100%%\" rx=\"3\" fill=\"%s\" stroke=\"#777\"/>"+
+			"<text x=\"4\" y=\"14\" font-size=\"11\">%s</text></svg>\n",
+			x0, y+lane*svgLane, max(x1-x0, 4), svgBar, desc,
+			time.Duration(op.Call-st.start), time.Duration(op.Return-st.start), f, desc)
+	}
+	return y + len(clients)*svgLane + svgMargin
+}
+
+// svgLegend draws the legend of the fills from y down and returns the y
+// below it.
+func svgLegend(b *strings.Builder, y int) int {
+	x := svgMargin
+	for _, l := range []struct{ fill, label string }{
+		{svgLinearized, "linearized"},
+		{svgIllegal, "illegal next"},
+		{svgStuck, "legal next, nothing can follow"},
+		{svgOther, "other"},
+	} {
+		fmt.Fprintf(b, "<rect x=\"%d\" y=\"%d\" width=\"14\" height=\"14\" fill=\"%s\" stroke=\"#777\"/><text x=\"%d\" y=\"%d\" font-size=\"12\">%s</text>\n",
+			x, y, l.fill, x+20, y+12, l.label)
+		x += 40 + 7*len(l.label)
+	}
+	return y + 14
+}
@@ -0,0 +1,54 @@
+package workload
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestWriteSVG(t *testing.T) {
+	// The Load misses the Store before it, which the pending Swap cannot
+	// explain, on a key to escape.
+	h := &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a&b", Val: 2}, Call: 20, Output: model.Output{}, Return: 30},
+		{ClientId: 1, Input: model.Input{Op: model.Load, Key: "a&b"}, Call: 40, Output: model.Output{}, Return: 50},
+		{ClientId: 2, Input: model.Input{Op: model.Swap, Key: "a&b", Val: 3}, Call: 45, Output: model.Output{Pending: true}, Return: 1000},
+	}}
+	result, info := porcupine.CheckOperationsVerbose(model.Model, h.Operations, 0)
+	if result != porcupine.Illegal {
+		t.Fatalf("check = %s, want Illegal", result)
+	}
+	v := &Violation{Round: 3, Seed: 7, Info: info, History: h}
+	var b strings.Builder
+	if err := v.WriteSVG(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	// Well-formed, with the key escaped.
+	d := xml.NewDecoder(strings.NewReader(out))
+	for {
+		_, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, out)
+		}
+	}
+	for _, want := range []string{
+		"round 3 (seed 7): history is not linearizable",
+		"key a&amp;b: 2 of 3 operations linearized, then the state is 3",
+		"Load(a&amp;b) -&gt; not found",
+		`fill="` + svgIllegal + `"`, `fill="` + svgLinearized + `"`, ">client 2<",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("SVG lacks %q:\n%s", want, out)
+		}
+	}
+}
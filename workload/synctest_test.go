@@ -0,0 +1,63 @@
+//go:build go1.25
+
+package workload
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+)
+
+func TestScheduleSynctest(t *testing.T) {
+	s := Default()
+	s.Workers, s.Ops, s.Keys = 4, 30, 2
+	s.Mix = map[string]int{"Load": 2, "Store": 1, "LoadOrStore": 2, "LoadAndDelete": 1, "Swap": 1, "CompareAndSwap": 1, "Range": 1}
+	sc := s.Schedule(7)
+	if len(sc.Steps) != 4*30 || sc.Clients != 4 {
+		t.Fatalf("schedule of %d steps of %d clients", len(sc.Steps), sc.Clients)
+	}
+	run := func() *History {
+		var h *History
+		synctest.Test(t, func(t *testing.T) {
+			h = sc.Run(new(sync.Map))
+		})
+		if r := Check(h, nil, 0); r.Check != porcupine.Ok {
+			t.Errorf("check = %s", r.Check)
+		}
+		return h
+	}
+
+	// Time stands still while the clients run: every operation is called
+	// and returns at the instant its sleep ended, the same in every run.
+	times := func(h *History) []int64 {
+		var ts []int64
+		for _, op := range h.Operations {
+			if op.Call != op.Return {
+				t.Fatalf("%+v took time", op)
+			}
+			ts = append(ts, op.Call)
+		}
+		return ts
+	}
+	if a, b := times(run()), times(run()); !reflect.DeepEqual(a, b) {
+		t.Errorf("times %v, then %v", a, b)
+	}
+
+	// With every step at an instant of its own, the clients take turns,
+	// and every run has the same history.
+	first := make(map[int]bool)
+	for i, st := range sc.Steps {
+		sc.Steps[i].Sleep = time.Duration(sc.Clients) * scheduleTick
+		if !first[st.Client] {
+			first[st.Client] = true
+			sc.Steps[i].Sleep = time.Duration(1+st.Client) * scheduleTick
+		}
+	}
+	if a, b := run(), run(); !reflect.DeepEqual(a.Operations, b.Operations) {
+		t.Errorf("history %v, then %v", a.Operations, b.Operations)
+	}
+}
@@ -0,0 +1,75 @@
+package workload
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// ValidateTimestamps checks that the timestamps of history can be right:
+// every operation returns no earlier than it was called, and every client
+// calls an operation no earlier than its previous one returned, in the
+// order the client recorded them. A clock that goes backwards, or one
+// read out of order, breaks either. Such timestamps misplace operations
+// in time, and porcupine then checks a history other than the one that
+// ran, which can pass a real violation as legal.
+//
+// With an epsilon, problems within epsilon are tolerated instead, and the
+// operations involved, as well as those the clock did not see take any
+// time, are widened by epsilon at both ends, in place. Wider intervals
+// only allow more linearizations, so this never turns a legal history
+// into a violation.
+func ValidateTimestamps(history []porcupine.Operation, epsilon time.Duration) error {
+	var (
+		eps      = epsilon.Nanoseconds()
+		problems []string
+		last     = make(map[int]int)
+		widen    = make(map[int]bool)
+	)
+	tolerate := func(by int64, i ...int) bool {
+		if eps == 0 || by > eps {
+			return false
+		}
+		for _, i := range i {
+			widen[i] = true
+		}
+		return true
+	}
+	for i, op := range history {
+		if d := op.Call - op.Return; d > 0 && !tolerate(d, i) {
+			problems = append(problems, fmt.Sprintf("client %d: %s returned %dns before its call", op.ClientId, describe(op), d))
+		}
+		if op.Call == op.Return && eps > 0 {
+			widen[i] = true
+		}
+		if j, ok := last[op.ClientId]; ok {
+			if d := history[j].Return - op.Call; d > 0 && !tolerate(d, i, j) {
+				problems = append(problems, fmt.Sprintf("client %d: %s called %dns before its previous operation returned", op.ClientId, describe(op), d))
+			}
+		}
+		last[op.ClientId] = i
+	}
+	if len(problems) > 0 {
+		more := ""
+		if len(problems) > 3 {
+			more = fmt.Sprintf(" and %d more", len(problems)-3)
+			problems = problems[:3]
+		}
+		return fmt.Errorf("workload: timestamps out of order: %s%s", strings.Join(problems, "; "), more)
+	}
+	for i := range widen {
+		history[i].Call -= eps
+		history[i].Return += eps
+	}
+	return nil
+}
+
+// describe is the model's description of op for the messages of
+// ValidateTimestamps.
+func describe(op porcupine.Operation) string {
+	return model.Model.DescribeOperation(op.Input, op.Output)
+}
@@ -0,0 +1,66 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestValidateTimestamps(t *testing.T) {
+	op := func(client int, call, ret int64) porcupine.Operation {
+		return porcupine.Operation{ClientId: client, Input: model.Input{Op: model.Load, Key: "k"}, Call: call, Output: model.Output{}, Return: ret}
+	}
+	tests := []struct {
+		name    string
+		history []porcupine.Operation
+		epsilon time.Duration
+		err     string
+		// want are the Call, Return pairs after widening.
+		want [][2]int64
+	}{
+		{name: "ok", history: []porcupine.Operation{op(0, 0, 5), op(1, 1, 3), op(0, 5, 9)}, want: [][2]int64{{0, 5}, {1, 3}, {5, 9}}},
+		{name: "return before call", history: []porcupine.Operation{op(0, 4, 2)}, err: "returned 2ns before its call"},
+		{name: "overlapping client", history: []porcupine.Operation{op(0, 0, 5), op(0, 3, 9)}, err: "called 2ns before its previous operation returned"},
+		{name: "tolerated", history: []porcupine.Operation{op(0, 0, 5), op(0, 3, 9), op(1, 20, 30)}, epsilon: 2, want: [][2]int64{{-2, 7}, {1, 11}, {20, 30}}},
+		{name: "beyond epsilon", history: []porcupine.Operation{op(0, 9, 2)}, epsilon: 2, err: "returned 7ns"},
+		{name: "zero width", history: []porcupine.Operation{op(0, 4, 4), op(1, 4, 6)}, epsilon: 1, want: [][2]int64{{3, 5}, {4, 6}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTimestamps(tt.history, tt.epsilon)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ValidateTimestamps = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, op := range tt.history {
+				if got := [2]int64{op.Call, op.Return}; got != tt.want[i] {
+					t.Errorf("operation %d spans %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRoundTimestamps(t *testing.T) {
+	s, err := New().Workers(4).Ops(100).Keys(3, Dist{}).Warmup(Warmup{Fill: true}).
+		Nemesis(Nemesis{GoschedPercent: 10}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	for seed := range uint64(10) {
+		if err := ValidateTimestamps(s.Round(impl.New(), seed, nil).Operations, 0); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
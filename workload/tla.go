@@ -0,0 +1,243 @@
+package workload
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// TLAConfig is the TLC configuration of a module of WriteTLA: TraceSpec
+// of MapTrace, checked for TraceNotAccepted without deadlocks, which a
+// history that is not linearizable ends in.
+const TLAConfig = `SPECIFICATION TraceSpec
+INVARIANT TraceNotAccepted
+CONSTANT Absent = Absent
+CHECK_DEADLOCK FALSE
+`
+
+var tlaModule = regexp.MustCompile(`^[A-Za-z0-9_]*[A-Za-z][A-Za-z0-9_]*$`)
+
+// WriteTLA writes h as the TLA+ module named module, for TLC to check with
+// the specification of model.Model in tla/MapSpec.tla of this repository:
+// Keys, Values and Clients of h, and Trace, its calls and returns as
+// records in order of time, calls first at equal times as porcupine
+// orders them, which MapTrace, instantiated by the module, follows, see
+// TLAConfig. A pending operation is called and never returns. A client
+// that calls its next operation before the previous one returns, as at
+// equal timestamps, continues as a new client, as in WriteKnossos.
+func WriteTLA(w io.Writer, h *History, module string) error {
+	return writeTLA(w, h, module, "", nil)
+}
+
+// WriteTLA writes the history of the violation as WriteTLA does, only
+// that of v.Key if it was checked key by key, with Linearization, the
+// states the modeled map goes through along the longest partial
+// linearization porcupine found of every key that is not linearizable:
+// the index in Trace of the call of each operation, in the order they
+// take effect, and the value of the key after it.
+func (v *Violation) WriteTLA(w io.Writer, module string) error {
+	if v.model != nil {
+		return errors.New("workload: a TLA+ trace is of model.Model, not the model the violation was checked with")
+	}
+	h := v.History
+	if v.Key != "" {
+		var err error
+		if h, err = Restrict(h, Filter{Keys: []string{v.Key}}); err != nil {
+			return err
+		}
+	}
+	_, stuck, err := v.stuck()
+	if err != nil {
+		return err
+	}
+	return writeTLA(w, h, module, v.Error(), stuck)
+}
+
+func writeTLA(w io.Writer, h *History, module, title string, stuck []stuckPartition) error {
+	if !tlaModule.MatchString(module) {
+		return fmt.Errorf("workload: %q is not the name of a TLA+ module", module)
+	}
+	type event struct {
+		op   int
+		time int64
+		ret  bool
+	}
+	var (
+		events  []event
+		keys    = make(map[string]bool)
+		values  = map[int]bool{0: true}
+		clients = make(map[int]bool)
+	)
+	for i, op := range h.Operations {
+		in, out := op.Input.(model.Input), op.Output.(model.Output)
+		if in.Op != model.Range {
+			keys[in.Key] = true
+		}
+		values[in.Val], values[in.Old], values[out.Val] = true, true, true
+		for k, v := range out.Entries {
+			keys[k], values[v] = true, true
+		}
+		events = append(events, event{i, op.Call, false})
+		if !out.Pending {
+			events = append(events, event{i, op.Return, true})
+		}
+	}
+	// Calls before returns at equal times, as porcupine orders them.
+	slices.SortStableFunc(events, func(a, b event) int {
+		if c := cmp.Compare(a.time, b.time); c != 0 {
+			return c
+		}
+		if a.ret != b.ret {
+			if a.ret {
+				return 1
+			}
+			return -1
+		}
+		return 0
+	})
+
+	var (
+		b       strings.Builder
+		process = make(map[int]int)
+		busy    = make(map[int]bool)
+		procOf  = make([]int, len(h.Operations))
+		callAt  = make([]int, len(h.Operations))
+		next    = NextClient(h)
+		start   int64
+		trace   []string
+		at      []int64
+	)
+	if len(events) > 0 {
+		start = events[0].time
+	}
+	for i, ev := range events {
+		op := h.Operations[ev.op]
+		if !ev.ret {
+			p, ok := process[op.ClientId]
+			if !ok {
+				p = op.ClientId
+			}
+			if busy[p] {
+				p = next
+				next++
+			}
+			process[op.ClientId], busy[p], procOf[ev.op], callAt[ev.op] = p, true, p, i+1
+			clients[p] = true
+			trace = append(trace, fmt.Sprintf("[type |-> \"call\", client |-> %d, input |-> %s]", p, tlaInput(op.Input.(model.Input))))
+		} else {
+			busy[procOf[ev.op]] = false
+			trace = append(trace, fmt.Sprintf("[type |-> \"return\", client |-> %d, output |-> %s]", procOf[ev.op], tlaOutput(op.Input.(model.Input), op.Output.(model.Output))))
+		}
+		at = append(at, ev.time-start)
+	}
+
+	fmt.Fprintf(&b, "---- MODULE %s ----\n", module)
+	if title != "" {
+		fmt.Fprintf(&b, "\\* %s\n", title)
+	}
+	b.WriteString("\\* Written by workload.WriteTLA, for TLC with tla/MapSpec.tla and\n\\* tla/MapTrace.tla of porcupine-syncmap and workload.TLAConfig.\n")
+	b.WriteString("EXTENDS Integers, Sequences\n\nCONSTANT Absent\nVARIABLES store, pending, result, seen, i\n\n")
+	fmt.Fprintf(&b, "Keys == %s\n", tlaSet(slices.Sorted(maps.Keys(keys)), tlaString))
+	fmt.Fprintf(&b, "Values == %s\n", tlaSet(slices.Sorted(maps.Keys(values)), strconv.Itoa))
+	fmt.Fprintf(&b, "Clients == %s\n\n", tlaSet(slices.Sorted(maps.Keys(clients)), strconv.Itoa))
+	b.WriteString("Trace == <<\n")
+	for i, e := range trace {
+		sep := ","
+		if i == len(trace)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&b, "    %s%s \\* %dns\n", e, sep, at[i])
+	}
+	b.WriteString(">>\n\n")
+
+	// The operations of a partition are copies, those of a Range one per
+	// key, found again by their client and times.
+	type opKey struct {
+		client    int
+		call, ret int64
+	}
+	index := make(map[opKey]int)
+	for i, op := range h.Operations {
+		k := opKey{op.ClientId, op.Call, op.Return}
+		if _, ok := index[k]; !ok {
+			index[k] = i
+		}
+	}
+	var lin []string
+	for _, st := range stuck {
+		key := st.key
+		state := model.Model.Init()
+		for _, id := range st.longest {
+			op := st.ops[id]
+			_, state = model.Model.Step(state, op.Input, op.Output)
+			value := model.Model.DescribeState(state)
+			if value == "missing" {
+				value = "Absent"
+			}
+			lin = append(lin, fmt.Sprintf("[key |-> %s, event |-> %d, value |-> %s]", tlaString(key), callAt[index[opKey{op.ClientId, op.Call, op.Return}]], value))
+		}
+	}
+	b.WriteString("\\* The states of the keys that are not linearizable along the longest\n\\* partial linearization porcupine found: the event of the call of each\n\\* operation, in the order they take effect, and the value after it.\n")
+	fmt.Fprintf(&b, "Linearization == <<%s>>\n\nINSTANCE MapTrace\n====\n", strings.Join(lin, ",\n    "))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tlaInput returns in as a record of MapSpec's Inputs.
+func tlaInput(in model.Input) string {
+	key, val, old := in.Key, in.Val, in.Old
+	switch in.Op {
+	case model.Load, model.LoadAndDelete:
+		val, old = 0, 0
+	case model.Store, model.LoadOrStore, model.Swap:
+		old = 0
+	case model.Range:
+		key, val, old = "", 0, 0
+	}
+	return fmt.Sprintf("[op |-> %s, key |-> %s, val |-> %d, old |-> %d]", tlaString(in.Op.String()), tlaString(key), val, old)
+}
+
+// tlaOutput returns the output of in as a record of MapSpec, whose val is
+// 0 where model.Model ignores it.
+func tlaOutput(in model.Input, out model.Output) string {
+	found, val := out.Found, out.Val
+	switch in.Op {
+	case model.Store, model.Range:
+		found = false
+	case model.CompareAndSwap:
+		val = 0
+	}
+	if !found {
+		val = 0
+	}
+	var entries []string
+	for _, k := range slices.Sorted(maps.Keys(out.Entries)) {
+		entries = append(entries, fmt.Sprintf("<<%s, %d>>", tlaString(k), out.Entries[k]))
+	}
+	if in.Op != model.Range {
+		entries = nil
+	}
+	return fmt.Sprintf("[found |-> %s, val |-> %d, entries |-> {%s}]", strings.ToUpper(strconv.FormatBool(found)), val, strings.Join(entries, ", "))
+}
+
+// tlaString quotes s as a TLA+ string.
+func tlaString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s) + `"`
+}
+
+// tlaSet returns the TLA+ set of elems.
+func tlaSet[T any](elems []T, format func(T) string) string {
+	strs := make([]string, len(elems))
+	for i, e := range elems {
+		strs[i] = format(e)
+	}
+	return "{" + strings.Join(strs, ", ") + "}"
+}
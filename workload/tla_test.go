@@ -0,0 +1,70 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestWriteTLA(t *testing.T) {
+	// That of TestWriteSVG: the Load misses the Store before it, which
+	// the pending Swap cannot explain.
+	h := &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "a&b", Val: 2}, Call: 20, Output: model.Output{}, Return: 30},
+		{ClientId: 1, Input: model.Input{Op: model.Load, Key: "a&b"}, Call: 40, Output: model.Output{}, Return: 50},
+		{ClientId: 2, Input: model.Input{Op: model.Swap, Key: "a&b", Val: 3}, Call: 45, Output: model.Output{Pending: true}, Return: 1000},
+	}}
+	result, info := porcupine.CheckOperationsVerbose(model.Model, h.Operations, 0)
+	if result != porcupine.Illegal {
+		t.Fatalf("check = %s, want Illegal", result)
+	}
+	v := &Violation{Round: 3, Seed: 7, Info: info, History: h}
+	var b strings.Builder
+	if err := v.WriteTLA(&b, "round_3"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"---- MODULE round_3 ----\n\\* round 3 (seed 7): history is not linearizable\n",
+		`Keys == {"a&b"}`, "Values == {0, 2, 3}", "Clients == {0, 1, 2}",
+		`[type |-> "call", client |-> 0, input |-> [op |-> "Store", key |-> "a&b", val |-> 2, old |-> 0]], \* 0ns`,
+		`[type |-> "return", client |-> 1, output |-> [found |-> FALSE, val |-> 0, entries |-> {}]] \* 30ns` + "\n>>",
+		`Linearization == <<[key |-> "a&b", event |-> 1, value |-> 2],` + "\n    " + `[key |-> "a&b", event |-> 4, value |-> 3]>>`,
+		"INSTANCE MapTrace\n====\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("module lacks %q:\n%s", want, out)
+		}
+	}
+	// The pending Swap never returns.
+	if n := strings.Count(out, `type |-> "return"`); n != 2 {
+		t.Errorf("%d returns, want 2:\n%s", n, out)
+	}
+
+	// A Range visits every key, and a client calling before its previous
+	// operation returns goes on as a new one.
+	h = &History{Operations: []porcupine.Operation{
+		{ClientId: 0, Input: model.Input{Op: model.Store, Key: "x", Val: 1}, Call: 0, Output: model.Output{}, Return: 10},
+		{ClientId: 0, Input: model.Input{Op: model.Range}, Call: 10, Output: model.Output{Entries: map[string]int{"x": 1, "y": 4}}, Return: 20},
+	}}
+	b.Reset()
+	if err := WriteTLA(&b, h, "ranged"); err != nil {
+		t.Fatal(err)
+	}
+	out = b.String()
+	for _, want := range []string{
+		`Keys == {"x", "y"}`, "Clients == {0, 1}", "Linearization == <<>>",
+		`[type |-> "call", client |-> 1, input |-> [op |-> "Range", key |-> "", val |-> 0, old |-> 0]]`,
+		`[type |-> "return", client |-> 1, output |-> [found |-> FALSE, val |-> 0, entries |-> {<<"x", 1>>, <<"y", 4>>}]]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("module lacks %q:\n%s", want, out)
+		}
+	}
+	if err := WriteTLA(&b, h, "round-3"); err == nil {
+		t.Error("WriteTLA accepted a module name with a dash")
+	}
+}
@@ -0,0 +1,50 @@
+package workload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime/trace"
+	"time"
+)
+
+// Retrace reruns the round of v under runtime/trace, to see the scheduling
+// of the goroutines that led to the violation. The seed only repeats the
+// operations of the round, not their interleaving, so Retrace runs it up
+// to attempts times until its history is not linearizable again. It writes
+// the trace of the last attempt to w and returns its violation, or nil if
+// no attempt reproduced one: its trace is then of a round that passed. The
+// trace has a task per attempt and a region per worker. Retrace fails if
+// the program is traced already, e.g. by go test -trace.
+func (s *Spec) Retrace(v *Violation, fence func(), attempts int, w io.Writer) (*Violation, error) {
+	var buf bytes.Buffer
+	for attempt := range max(attempts, 1) {
+		buf.Reset()
+		if err := trace.Start(&buf); err != nil {
+			return nil, fmt.Errorf("workload: %v", err)
+		}
+		ctx, task := trace.NewTask(context.Background(), "round")
+		trace.Logf(ctx, "round", "round %d (seed %d), attempt %d", v.Round, v.Seed, attempt)
+		m := s.newMap()
+		h, err := s.round(ctx, m, v.Seed, fence)
+		task.End()
+		trace.Stop()
+		if err != nil {
+			return nil, err
+		}
+
+		if ValidateTimestamps(h.Operations, time.Duration(s.Checker.Epsilon)) != nil {
+			continue
+		}
+		c := newChecker(time.Duration(s.Checker.Timeout), 0, Stats{}, 0)
+		c.shards = s.Checker.Shards
+		c.check(v.Round, v.Seed, h)
+		if again := c.failed(); again != nil {
+			_, err := w.Write(buf.Bytes())
+			return again, err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return nil, err
+}
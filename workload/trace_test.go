@@ -0,0 +1,33 @@
+package workload
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestRetrace(t *testing.T) {
+	s, err := New().Map("forgetful", func() mapimpl.MapUnderTest { return new(forgetful) }).
+		Workers(2).Rounds(20).Ops(20).Mix(map[model.Op]int{model.Store: 1, model.Load: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v *Violation
+	if err := s.Run(RunOptions{}); !errors.As(err, &v) {
+		t.Fatalf("Run = %v, want a Violation", err)
+	}
+	var buf bytes.Buffer
+	again, err := s.Retrace(v, nil, 20, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == nil || again.Round != v.Round || again.Seed != v.Seed {
+		t.Errorf("Retrace = %v, want the violation of round %d again", again, v.Round)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("go 1.")) {
+		t.Errorf("no execution trace written, got %d bytes", buf.Len())
+	}
+}
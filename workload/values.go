@@ -0,0 +1,171 @@
+package workload
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+// Value kinds of ValueKind. The model only sees the id of every value: a
+// payload is decoded back to its id and its contents are verified against
+// the id, a corrupt payload reads as the id corruptValue, which was never
+// stored and fails the check.
+const (
+	// ValueInt stores the id itself, the default without a ValueSize.
+	ValueInt = "int"
+	// ValueString stores a string of ValueSize bytes, compared by
+	// contents. The default with a ValueSize.
+	ValueString = "string"
+	// ValueBytes stores a pointer to a fresh byte slice of ValueSize
+	// bytes, compared by identity.
+	ValueBytes = "bytes"
+	// ValueStruct stores a pointer to a fresh struct holding the id and a
+	// ValueSize bytes slice, compared by identity.
+	ValueStruct = "struct"
+)
+
+// corruptValue is the id of a payload whose contents do not match its id.
+const corruptValue = -2
+
+type payload struct {
+	id   int
+	data []byte
+}
+
+func (s *Spec) valueKind() string {
+	switch {
+	case s.ValueKind != "":
+		return s.ValueKind
+	case s.ValueSize > 0:
+		return ValueString
+	}
+	return ValueInt
+}
+
+func (s *Spec) validateValues() error {
+	switch kind := s.valueKind(); {
+	case s.ValueSize < 0:
+		return fmt.Errorf("workload: value_size must not be negative")
+	case kind == ValueInt:
+		if s.ValueSize > 0 {
+			return fmt.Errorf("workload: value_size needs a payload value_kind, not int")
+		}
+		return nil
+	case kind != ValueString && kind != ValueBytes && kind != ValueStruct:
+		return fmt.Errorf("workload: unknown value_kind %q, want int, string, bytes or struct", kind)
+	case s.ValueSize < 8:
+		return fmt.Errorf("workload: value_size %d of a %s payload is below 8 bytes", s.ValueSize, kind)
+	}
+	if !stores(s.newMap(), s.newValues().make(0)) {
+		return fmt.Errorf("workload: impl %s cannot store %s values", s.Impl, s.valueKind())
+	}
+	return nil
+}
+
+// stores reports whether m accepts v as a value, a typed wrapper panics.
+func stores(m mapimpl.MapUnderTest, v any) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	m.Store("k", v)
+	return true
+}
+
+// values makes the values of a round. The pointer kinds compare by
+// identity, so CompareAndSwap must expect the very pointer that was
+// stored: those are kept by id.
+type values struct {
+	kind string
+	size int
+	objs []atomic.Value
+}
+
+func (s *Spec) newValues() *values {
+	v := &values{kind: s.valueKind(), size: s.ValueSize}
+	if v.kind == ValueBytes || v.kind == ValueStruct {
+		// The ids of the warmup follow those of the workers.
+		v.objs = make([]atomic.Value, s.NumWorkers()*s.Ops+s.Keys)
+	}
+	return v
+}
+
+// make returns a new value for id.
+func (v *values) make(id int) any {
+	if v.kind == ValueInt {
+		return id
+	}
+	b := make([]byte, v.size)
+	fill(b, id)
+	var obj any
+	switch v.kind {
+	case ValueString:
+		return string(b)
+	case ValueBytes:
+		obj = &b
+	case ValueStruct:
+		obj = &payload{id: id, data: b}
+	}
+	if id >= 0 && id < len(v.objs) {
+		v.objs[id].Store(obj)
+	}
+	return obj
+}
+
+// expected returns the value a CompareAndSwap expecting id passes: the
+// value stored for id, or one that was never stored.
+func (v *values) expected(id int) any {
+	if v.objs == nil {
+		return v.make(id)
+	}
+	if id >= 0 && id < len(v.objs) {
+		if obj := v.objs[id].Load(); obj != nil {
+			return obj
+		}
+	}
+	return v.make(-1)
+}
+
+// fill writes the contents of the payload for id to b: the id, then bytes
+// derived from it.
+func fill(b []byte, id int) {
+	binary.LittleEndian.PutUint64(b, uint64(id))
+	for i := 8; i < len(b); i++ {
+		b[i] = byte(id*31 + i)
+	}
+}
+
+// decode returns the id of payload b, or corruptValue.
+func decode(b []byte) int {
+	if len(b) < 8 {
+		return corruptValue
+	}
+	id := int(binary.LittleEndian.Uint64(b))
+	for i := 8; i < len(b); i++ {
+		if b[i] != byte(id*31+i) {
+			return corruptValue
+		}
+	}
+	return id
+}
+
+// valueID returns the id of a value of any kind.
+func valueID(v any) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case string:
+		return decode([]byte(v))
+	case *[]byte:
+		return decode(*v)
+	case *payload:
+		if id := decode(v.data); id == v.id {
+			return id
+		}
+		return corruptValue
+	}
+	return -1
+}
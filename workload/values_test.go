@@ -0,0 +1,63 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestValueKinds(t *testing.T) {
+	for _, kind := range []string{ValueString, ValueBytes, ValueStruct} {
+		s, err := New().Workers(3).Ops(300).Keys(2, Dist{}).Values(kind, 256).
+			Mix(map[model.Op]int{model.Store: 1, model.Load: 1, model.CompareAndSwap: 2, model.Swap: 1}).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		impl, _ := mapimpl.Lookup(s.Impl)
+		h := s.Round(impl.New(), 1, nil)
+		swapped := 0
+		for _, op := range h.Operations {
+			if op.Input.(model.Input).Op == model.CompareAndSwap && op.Output.(model.Output).Found {
+				swapped++
+			}
+		}
+		if swapped == 0 {
+			t.Errorf("%s: no CompareAndSwap succeeded", kind)
+		}
+		if !porcupine.CheckOperations(model.Model, h.Operations) {
+			t.Errorf("%s: history is not linearizable", kind)
+		}
+	}
+}
+
+func TestValueDigest(t *testing.T) {
+	v := &values{kind: ValueBytes, size: 16}
+	b := v.make(42).(*[]byte)
+	if id := valueID(b); id != 42 {
+		t.Fatalf("valueID = %d, want 42", id)
+	}
+	(*b)[12]++
+	if id := valueID(b); id != corruptValue {
+		t.Errorf("valueID of a corrupt payload = %d, want %d", id, corruptValue)
+	}
+	p := (&values{kind: ValueStruct, size: 8}).make(7).(*payload)
+	p.id = 8
+	if id := valueID(p); id != corruptValue {
+		t.Errorf("valueID of a mislabeled payload = %d, want %d", id, corruptValue)
+	}
+
+	for _, tc := range []struct{ spec, want string }{
+		{`{"value_size": 4}`, "below 8 bytes"},
+		{`{"value_size": 16, "value_kind": "float"}`, "unknown value_kind"},
+		{`{"value_size": 16, "value_kind": "int"}`, "needs a payload"},
+		{`{"impl": "SyncMapOf", "value_size": 16, "value_kind": "bytes"}`, "cannot store bytes"},
+	} {
+		if _, err := Parse(strings.NewReader(tc.spec)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%s) = %v, want error containing %q", tc.spec, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package workload
+
+import (
+	"errors"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// Warmup prepares the map before the recorded operations of a round, so
+// they do not all run on the cold start path of an empty map.
+type Warmup struct {
+	// Fill stores a value under every key of the key space.
+	Fill bool `json:"fill,omitempty"`
+	// Loads is the number of Loads of every key after the fill, 1 if 0.
+	// A sync.Map promotes its dirty map to the read-only one after as many
+	// misses as the dirty map has entries, so a single pass promotes them
+	// all.
+	Loads int `json:"loads,omitempty"`
+}
+
+func (w Warmup) validate() error {
+	if w.Loads < 0 {
+		return errors.New("workload: warmup: loads must not be negative")
+	}
+	return nil
+}
+
+// warmup runs the warmup on m and returns the history the model needs to
+// know the state it left: one Store per key by an extra client, the
+// workers' number, that returns before the round starts.
+func (s *Spec) warmup(m mapimpl.MapUnderTest, vals *values) ([]porcupine.Operation, []porcupine.Annotation) {
+	if !s.Warmup.Fill {
+		return nil, nil
+	}
+	var (
+		keys     = s.KeyNames()
+		client   = s.NumWorkers()
+		history  []porcupine.Operation
+		firstVal = s.NumWorkers() * s.Ops
+	)
+	// The Stores get one nanosecond each, in order, ending before the
+	// round starts at 0.
+	begin := -2 * int64(len(keys))
+	for i, k := range keys {
+		in := model.Input{Op: model.Store, Key: k, Val: firstVal + i}
+		m.Store(k, vals.make(in.Val))
+		call := begin + 2*int64(i)
+		history = append(history, porcupine.Operation{ClientId: client, Input: in, Call: call, Output: model.Output{}, Return: call + 1})
+	}
+	loads := max(s.Warmup.Loads, 1)
+	for range loads {
+		for _, k := range keys {
+			m.Load(k)
+		}
+	}
+	return history, []porcupine.Annotation{{
+		ClientId:    client,
+		Start:       begin,
+		End:         -1,
+		Description: "warmup",
+		Details:     "stored every key and loaded each of them to promote the dirty map",
+	}}
+}
@@ -0,0 +1,40 @@
+package workload
+
+import (
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestWarmup(t *testing.T) {
+	s, err := New().Workers(2).Ops(50).Keys(4, Dist{}).Values(ValueBytes, 16).
+		Warmup(Warmup{Fill: true, Loads: 2}).
+		Mix(map[model.Op]int{model.Load: 4, model.CompareAndSwap: 1}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	h := s.Round(impl.New(), 1, nil)
+	if len(h.Operations) != 2*50+4 {
+		t.Fatalf("recorded %d operations, want %d", len(h.Operations), 2*50+4)
+	}
+	var recorded []porcupine.Operation
+	for _, op := range h.Operations {
+		if op.ClientId == 2 {
+			continue
+		}
+		recorded = append(recorded, op)
+		if in, out := op.Input.(model.Input), op.Output.(model.Output); in.Op == model.Load && !out.Found {
+			t.Errorf("Load(%s) missed a warmed up key", in.Key)
+		}
+	}
+	if !porcupine.CheckOperations(model.Model, h.Operations) {
+		t.Error("history with warmup is not linearizable")
+	}
+	if porcupine.CheckOperations(model.Model, recorded) {
+		t.Error("history without the warmup stores is linearizable, the model does not need them")
+	}
+}
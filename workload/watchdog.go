@@ -0,0 +1,109 @@
+package workload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+)
+
+// Watchdog bounds the time a round may take to run, until every worker
+// and pending operation returned, and to be checked. A round past the
+// deadline is wedged, in the map under test or in the checker, and would
+// otherwise hang the whole run without a word: the watchdog logs the
+// stacks of all goroutines and gives up on the round.
+type Watchdog struct {
+	Deadline Duration `json:"deadline,omitempty"`
+	// Skip counts a hung round in Stats.Hung and goes on with the next one,
+	// instead of stopping Run with a *Hung. The goroutines of a skipped
+	// round are left where they hang.
+	Skip bool `json:"skip,omitempty"`
+}
+
+func (w Watchdog) String() string {
+	str := time.Duration(w.Deadline).String()
+	if w.Skip {
+		str += "(skip)"
+	}
+	return str
+}
+
+func (w Watchdog) validate() error {
+	if w.Deadline < 0 {
+		return errors.New("workload: watchdog: deadline must not be negative")
+	}
+	return nil
+}
+
+// A Hung is a round that did not finish running or checking within the
+// watchdog's deadline.
+type Hung struct {
+	Round int
+	Seed  uint64
+	// Stage is "round" or "check".
+	Stage string
+	After time.Duration
+	// Stacks are the stacks of all goroutines once the deadline passed.
+	Stacks []byte
+}
+
+func (h *Hung) Error() string {
+	return fmt.Sprintf("round %d (seed %d): %s hung for %v", h.Round, h.Seed, h.Stage, h.After)
+}
+
+// watch runs f, and if it has not returned by the deadline returns the
+// stacks of all goroutines, leaving f to itself.
+func (w Watchdog) watch(f func()) (stacks []byte, ok bool) {
+	if w.Deadline == 0 {
+		f()
+		return nil, true
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	timer := time.NewTimer(time.Duration(w.Deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil, true
+	case <-timer.C:
+		return dumpStacks(), false
+	}
+}
+
+// dumpStacks returns the stacks of all goroutines, as a crash prints them.
+func dumpStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// watchRound runs the round under the watchdog of c, and if it hangs
+// reports it to c and returns nil. The error is that of a round whose
+// streamed operations could not be read back.
+func (s *Spec) watchRound(c *checker, m mapimpl.MapUnderTest, round int, seed uint64, fence func()) (*History, error) {
+	var (
+		h   *History
+		err error
+	)
+	// A round left hanging must not read s once it is changed.
+	spec := *s
+	stacks, ok := c.watchdog.watch(func() {
+		labeled("run", func() { h, err = spec.round(context.Background(), m, seed, fence) })
+	})
+	if !ok {
+		c.hang(&Hung{Round: round, Seed: seed, Stage: "round", After: time.Duration(c.watchdog.Deadline), Stacks: stacks})
+		return nil, nil
+	}
+	return h, err
+}
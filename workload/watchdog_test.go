@@ -0,0 +1,64 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+// wedged is a map whose Stores block until release is closed.
+type wedged struct {
+	sync.Map
+	release chan struct{}
+}
+
+func (w *wedged) Store(key, value any) {
+	<-w.release
+	w.Map.Store(key, value)
+}
+
+func TestWatchdog(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	build := func(skip bool) Spec {
+		s, err := New().Map("wedged", func() mapimpl.MapUnderTest { return &wedged{release: release} }).
+			Workers(2).Rounds(10).Ops(10).Mix(map[model.Op]int{model.Store: 1}).
+			Watchdog(Watchdog{Deadline: Duration(20 * time.Millisecond), Skip: skip}).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	s := build(false)
+	var logs []string
+	err := s.Run(RunOptions{Logf: func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}})
+	var h *Hung
+	if !errors.As(err, &h) || h.Round != 0 || h.Stage != "round" {
+		t.Fatalf("Run = %v, want round 0 to hang", err)
+	}
+	if !strings.Contains(string(h.Stacks), "(*wedged).Store") {
+		t.Errorf("stacks lack the wedged Store:\n%s", h.Stacks)
+	}
+	if len(logs) != 2 || !strings.Contains(logs[1], "goroutines") {
+		t.Errorf("logs = %q, want the config and the stacks", logs)
+	}
+
+	s = build(true)
+	path := filepath.Join(t.TempDir(), "cp.json")
+	if err := s.Run(RunOptions{Checkpoint: path}); err != nil {
+		t.Fatal(err)
+	}
+	if cp := readCheckpoint(t, path); cp.Stats.Hung != 10 || cp.Stats.Rounds != 0 || cp.Next != 10 {
+		t.Errorf("checkpoint after skipping every round: %+v", cp)
+	}
+}
@@ -0,0 +1,361 @@
+// Package workload describes the linearizability workloads run against a
+// map implementation and executes their rounds. A workload is a Spec,
+// either built in code or loaded from a JSON file:
+//
+//	{
+//		"impl": "sync.Map",
+//		"workers": 8,
+//		"rounds": 1000,
+//		"ops": 100,
+//		"keys": 4,
+//		"value_size": 64,
+//		"mix": {"LoadOrStore": 2, "LoadAndDelete": 1},
+//		"checker": {"timeout": "10s"}
+//	}
+//
+// Omitted fields keep the values of Default. Only JSON is read, not YAML
+// or TOML.
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// A Spec is a workload: which implementation, how many workers doing how
+// many operations of which mix on which keys, and how the resulting
+// histories are checked.
+type Spec struct {
+	// Name labels the workload in logs, the file name for a loaded spec.
+	Name string `json:"name,omitempty"`
+	// Impl is the mapimpl name of the implementation under test, or just a
+	// label if NewMap is set.
+	Impl string `json:"impl"`
+	// NewMap, if set, returns the maps under test instead of the
+	// registered Impl, to check a structure outside this module.
+	NewMap func() mapimpl.MapUnderTest `json:"-"`
+	// Workers is the number of concurrent workers per round, 0 for
+	// GOMAXPROCS.
+	Workers int `json:"workers,omitempty"`
+	Rounds  int `json:"rounds"`
+	// Ops is the number of operations per worker and round.
+	Ops int `json:"ops"`
+	// Keys is the size of the key space. A single key is named "k", more
+	// are "k0", "k1", ...
+	Keys int `json:"keys"`
+	// Dist draws the key of each operation, uniform by default.
+	Dist Dist `json:"dist"`
+	// ValueSize, if not 0, stores every value as a payload of that many
+	// bytes, at least 8, instead of an int, so the map holds real
+	// allocations. ValueKind selects the payload, see ValueString.
+	ValueSize int    `json:"value_size,omitempty"`
+	ValueKind string `json:"value_kind,omitempty"`
+	// Mix weighs the operations by model.Op name, each worker draws its
+	// operations at random with these weights. Load and Range mostly hit
+	// the read-only part of a sync.Map, Store, Swap and LoadOrStore of new
+	// keys the dirty part.
+	Mix map[string]int `json:"mix"`
+	// Roles, if set, split the workers into groups with their own mixes,
+	// e.g. 2 writers and 6 readers, instead of every worker running Mix.
+	Roles []Role `json:"roles,omitempty"`
+	// Seed is the seed of the first round's operations, round r uses
+	// Seed+r, see RoundSeed. 0 leaves the choice to the harness, which
+	// picks one at random and logs it.
+	Seed uint64 `json:"seed,omitempty"`
+	// Warmup prepares the map before each round, not at all by default.
+	Warmup Warmup `json:"warmup"`
+	// Start synchronizes the start of the workers of each round.
+	Start Start `json:"start"`
+	// Nemesis perturbs the scheduler during each round, not at all by
+	// default.
+	Nemesis Nemesis `json:"nemesis"`
+	// GC puts each round under garbage collector pressure, none by
+	// default.
+	GC GC `json:"gc"`
+	// CPULoad keeps CPUs busy during each round, none by default.
+	CPULoad CPULoad `json:"cpu_load"`
+	// Pending leaves some operations outstanding, none by default.
+	Pending Pending `json:"pending"`
+	// Yields puts yield points around the operations of each round, none
+	// by default.
+	Yields Yields `json:"yields"`
+	// Watchdog gives up on rounds that hang, none by default.
+	Watchdog Watchdog `json:"watchdog"`
+	// Stream writes the operations of each round to disk as they
+	// complete, not at all by default.
+	Stream Stream `json:"stream"`
+	// Clock timestamps the operations, ClockTime if empty.
+	Clock   string  `json:"clock,omitempty"`
+	Checker Checker `json:"checker"`
+}
+
+// Checker configures how the history of each round is checked.
+type Checker struct {
+	// Timeout bounds porcupine's search per round, excess rounds count as
+	// unknown rather than failed.
+	Timeout Duration `json:"timeout"`
+	// SkipProbes disables the invariant probes run on the map after each
+	// round.
+	SkipProbes bool `json:"skip_probes,omitempty"`
+	// Epsilon tolerates timestamps out of order by up to this much, and
+	// widens the intervals of the operations involved by as much, see
+	// ValidateTimestamps. A history with timestamps out of order fails
+	// the round otherwise.
+	Epsilon Duration `json:"epsilon,omitempty"`
+	// Shards, if set, splits each history by key before checking it and
+	// checks Shards keys at a time, rather than all of them at once, up to
+	// the first key that is not linearizable, whose history alone is then
+	// visualized. Timeout bounds all the keys of a round together.
+	Shards int `json:"shards,omitempty"`
+}
+
+// Duration is a time.Duration written as a string like "5s" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default returns the workload of TestSyncMap: sync.Map, 10000 rounds of
+// 50 operations per worker on a single key, a third of them LoadAndDelete
+// and the others LoadOrStore.
+func Default() Spec {
+	return Spec{
+		Impl:    "sync.Map",
+		Rounds:  10000,
+		Ops:     50,
+		Keys:    1,
+		Mix:     map[string]int{"LoadOrStore": 2, "LoadAndDelete": 1},
+		Checker: Checker{Timeout: Duration(5 * time.Second)},
+	}
+}
+
+// Parse reads a JSON spec and validates it, omitted fields keep their
+// Default values. Unknown fields are an error, to catch typos.
+func Parse(r io.Reader) (Spec, error) {
+	s := Default()
+	s.Mix = nil
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return Spec{}, fmt.Errorf("workload: %v", err)
+	}
+	if s.Mix == nil {
+		s.Mix = Default().Mix
+	}
+	return s, s.Validate()
+}
+
+// Load reads the spec in the named file, which must be JSON.
+func Load(path string) (Spec, error) {
+	if ext := filepath.Ext(path); ext != ".json" {
+		return Spec{}, fmt.Errorf("workload: %s: unsupported format %q, only JSON specs are supported", path, ext)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Spec{}, fmt.Errorf("workload: %v", err)
+	}
+	defer f.Close()
+	s, err := Parse(f)
+	if err != nil {
+		return Spec{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	return s, nil
+}
+
+// Validate reports the first problem with s.
+func (s *Spec) Validate() error {
+	if s.NewMap == nil {
+		if _, ok := mapimpl.Lookup(s.Impl); !ok {
+			return fmt.Errorf("workload: unknown impl %q", s.Impl)
+		}
+	}
+	switch {
+	case s.Workers < 0:
+		return errors.New("workload: workers must not be negative")
+	case s.Rounds < 1 || s.Ops < 1:
+		return errors.New("workload: rounds and ops must be positive")
+	case s.Keys < 1:
+		return errors.New("workload: keys must be positive")
+	case s.Checker.Timeout < 0 || s.Checker.Epsilon < 0:
+		return errors.New("workload: checker timeout and epsilon must not be negative")
+	case s.Checker.Shards < 0:
+		return errors.New("workload: checker shards must not be negative")
+	}
+	if err := s.Dist.validate(); err != nil {
+		return err
+	}
+	if err := s.Warmup.validate(); err != nil {
+		return err
+	}
+	if err := s.Start.validate(); err != nil {
+		return err
+	}
+	if err := s.Nemesis.validate(); err != nil {
+		return err
+	}
+	if err := s.GC.validate(); err != nil {
+		return err
+	}
+	if err := s.CPULoad.validate(); err != nil {
+		return err
+	}
+	if err := s.Pending.validate(); err != nil {
+		return err
+	}
+	if err := s.Yields.validate(); err != nil {
+		return err
+	}
+	if err := s.Watchdog.validate(); err != nil {
+		return err
+	}
+	if err := s.Stream.validate(); err != nil {
+		return err
+	}
+	if err := recorder.ValidateClock(s.Clock); err != nil {
+		return err
+	}
+	// Roles replace the mix, which then need not be set.
+	if len(s.Roles) == 0 {
+		if err := validateMix("mix", s.Mix); err != nil {
+			return err
+		}
+	}
+	if err := s.validateRoles(); err != nil {
+		return err
+	}
+	if err := s.validateValues(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newMap returns a fresh map under test.
+func (s *Spec) newMap() mapimpl.MapUnderTest {
+	if s.NewMap != nil {
+		return s.NewMap()
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	return impl.New()
+}
+
+func (s *Spec) String() string {
+	str := fmt.Sprintf("impl=%s rounds=%d ops=%d workers=%d keys=%d", s.Impl, s.Rounds, s.Ops, s.NumWorkers(), s.Keys)
+	if len(s.Roles) > 0 {
+		var roles []string
+		for _, r := range s.Roles {
+			roles = append(roles, fmt.Sprintf("%s*%d(%s)", r.Name, r.Workers, mixString(r.Mix)))
+		}
+		str += " roles=" + strings.Join(roles, ",")
+	} else {
+		str += " mix=" + mixString(s.Mix)
+	}
+	if s.Keys > 1 {
+		str += " dist=" + s.Dist.String()
+	}
+	if s.ValueSize > 0 {
+		str += " value_size=" + strconv.Itoa(s.ValueSize) + " value_kind=" + s.valueKind()
+	}
+	if s.Warmup.Fill {
+		str += fmt.Sprintf(" warmup=%d", max(s.Warmup.Loads, 1))
+	}
+	if s.Start.Barrier {
+		str += " barrier"
+	}
+	if s.Start.Jitter > 0 {
+		str += " jitter=" + time.Duration(s.Start.Jitter).String()
+	}
+	if s.Nemesis.enabled() {
+		str += " nemesis=" + s.Nemesis.String()
+	}
+	if s.GC.enabled() {
+		str += " gc=" + s.GC.String()
+	}
+	if s.CPULoad.Cores > 0 {
+		str += " cpu_load=" + s.CPULoad.String()
+	}
+	if s.Pending.Percent > 0 {
+		str += " pending=" + s.Pending.String()
+	}
+	if s.Yields.enabled() {
+		str += " yields=" + s.Yields.String()
+	}
+	if s.Watchdog.Deadline > 0 {
+		str += " watchdog=" + s.Watchdog.String()
+	}
+	if s.Stream.Batch > 0 {
+		str += " stream=" + s.Stream.String()
+	}
+	if s.Clock != "" && s.Clock != ClockTime {
+		str += " clock=" + s.Clock
+	}
+	if s.Seed != 0 {
+		str += " seed=" + strconv.FormatUint(s.Seed, 10)
+	}
+	if s.Name != "" {
+		str = s.Name + ": " + str
+	}
+	return str
+}
+
+// RoundSeed returns the seed of the given round. Passing it back as Seed
+// with a single round repeats that round's operations: the interleaving of
+// the workers differs, and with it the values CompareAndSwap expects, the
+// operations, keys and values stored do not.
+func (s *Spec) RoundSeed(round int) uint64 {
+	return s.Seed + uint64(round)
+}
+
+// NumWorkers returns the number of workers per round.
+func (s *Spec) NumWorkers() int {
+	if len(s.Roles) > 0 {
+		n := 0
+		for _, r := range s.Roles {
+			n += r.Workers
+		}
+		return n
+	}
+	if s.Workers > 0 {
+		return s.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// KeyNames returns the keys of the key space.
+func (s *Spec) KeyNames() []string {
+	if s.Keys == 1 {
+		return []string{"k"}
+	}
+	keys := make([]string, s.Keys)
+	for i := range keys {
+		keys[i] = "k" + strconv.Itoa(i)
+	}
+	return keys
+}
@@ -0,0 +1,135 @@
+package workload
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/model"
+)
+
+func TestLoad(t *testing.T) {
+	s, err := Load("testdata/multikey.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "multikey" || s.Workers != 4 || s.Keys != 4 || s.ValueSize != 32 || time.Duration(s.Checker.Timeout) != 10*time.Second {
+		t.Errorf("Load = %+v", s)
+	}
+	if got := strings.Join(s.KeyNames(), ","); got != "k0,k1,k2,k3" {
+		t.Errorf("KeyNames = %s", got)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	s, err := Parse(strings.NewReader(`{"impl": "SyncMapOf", "rounds": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Default()
+	if s.Impl != "SyncMapOf" || s.Rounds != 3 || s.Ops != d.Ops || s.Keys != 1 || len(s.Mix) != len(d.Mix) || s.Checker.Timeout != d.Checker.Timeout {
+		t.Errorf("Parse = %+v", s)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct{ spec, want string }{
+		{`{"impl": "nope"}`, "unknown impl"},
+		{`{"rounds": 0}`, "must be positive"},
+		{`{"keys": 0}`, "keys must be positive"},
+		{`{"mix": {"Delete": 1}}`, "unknown operation"},
+		{`{"mix": {"LoadOrStore": 0}}`, "no operation"},
+		{`{"checker": {"timeout": 5}}`, "duration"},
+		{`{"round": 3}`, "unknown field"},
+		{`{"impl": "SyncMapOf", "value_size": 8}`, "cannot store"},
+	} {
+		if _, err := Parse(strings.NewReader(tc.spec)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%s) = %v, want error containing %q", tc.spec, err, tc.want)
+		}
+	}
+	if _, err := Load("spec.yaml"); err == nil || !strings.Contains(err.Error(), "only JSON") {
+		t.Errorf("Load(spec.yaml) = %v", err)
+	}
+}
+
+func TestRound(t *testing.T) {
+	s, err := Load("testdata/multikey.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	ops := s.Round(impl.New(), 1, nil).Operations
+	if len(ops) != s.Workers*s.Ops {
+		t.Fatalf("Round recorded %d operations, want %d", len(ops), s.Workers*s.Ops)
+	}
+	keys := make(map[string]int)
+	for _, op := range ops {
+		keys[op.Input.(model.Input).Key]++
+	}
+	if len(keys) != s.Keys {
+		t.Errorf("Round touched keys %v, want %d", keys, s.Keys)
+	}
+	if res := porcupine.CheckOperations(model.Model, ops); !res {
+		t.Error("history of a sequentially correct map is not linearizable")
+	}
+}
+
+func TestMix(t *testing.T) {
+	s := Default()
+	s.Workers, s.Ops, s.Keys = 4, 200, 3
+	s.Mix = make(map[string]int)
+	for _, op := range model.Ops() {
+		s.Mix[op.String()] = 1
+	}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	ops := s.Round(impl.New(), 7, nil).Operations
+	counts := make(map[model.Op]int)
+	for _, op := range ops {
+		counts[op.Input.(model.Input).Op]++
+	}
+	for _, op := range model.Ops() {
+		if counts[op] == 0 {
+			t.Errorf("no %s in %v", op, counts)
+		}
+	}
+	if !porcupine.CheckOperations(model.Model, ops) {
+		t.Error("history of a sequentially correct map is not linearizable")
+	}
+
+	// The same seed draws the same inputs.
+	again := s.Round(impl.New(), 7, nil).Operations
+	if inputs(ops) != inputs(again) {
+		t.Error("Round with the same seed drew different operations")
+	}
+}
+
+// inputs describes the inputs of history per worker, in order. The
+// expected value of a CompareAndSwap is left out, it depends on what the
+// worker saw.
+func inputs(history []porcupine.Operation) string {
+	per := make(map[int][]string)
+	for _, op := range history {
+		in := op.Input.(model.Input)
+		in.Old = 0
+		per[op.ClientId] = append(per[op.ClientId], fmt.Sprint(in))
+	}
+	return fmt.Sprint(per)
+}
+
+func TestRoundSeed(t *testing.T) {
+	s := Default()
+	s.Workers, s.Seed = 3, 100
+	impl, _ := mapimpl.Lookup(s.Impl)
+	failed := s.Round(impl.New(), s.RoundSeed(3), nil).Operations
+
+	// What -seed=103 -rounds=1 runs.
+	again := s
+	again.Seed, again.Rounds = s.RoundSeed(3), 1
+	if inputs(failed) != inputs(again.Round(impl.New(), again.RoundSeed(0), nil).Operations) {
+		t.Error("the seed of a round does not reproduce its operations")
+	}
+}
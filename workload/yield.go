@@ -0,0 +1,232 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+// Yields puts yield points around the operations of a round, for a
+// schedule of the workers the seed of the round fixes rather than the
+// nemesis, which yields after operations: every worker runs its
+// operations on the map through a YieldMap, which yields at random
+// before and after each, from a generator seeded by the round. With
+// Priorities, the workers yield at rates of their own, as in PCT, where
+// lower priorities run behind the higher ones. Every yield is an
+// annotation of the history, in the lane of its worker, from which
+// YieldsOf reads the YieldSchedule of a round back, for Replay to yield
+// exactly there again.
+type Yields struct {
+	// Percent is the percentage of the yield points that yield, by
+	// runtime.Gosched or, for SleepPercent of them, by a sleep of up to
+	// MaxSleep, 10µs if 0.
+	Percent      int      `json:"percent,omitempty"`
+	SleepPercent int      `json:"sleep_percent,omitempty"`
+	MaxSleep     Duration `json:"max_sleep,omitempty"`
+	// Priorities gives the workers of each round the priorities 1 to
+	// NumWorkers in a random order, and the worker of priority p yields at
+	// (NumWorkers+1-p)/NumWorkers of Percent of its points, the highest
+	// priority least.
+	Priorities bool `json:"priorities,omitempty"`
+	// Replay, if set, yields where it says instead, as a round did.
+	Replay *YieldSchedule `json:"-"`
+}
+
+// yieldSalt separates the generators of the yield points from the
+// workers'.
+const yieldSalt = 0x7969656c64
+
+// yieldDetails prefixes the details of the annotation of a yield, with the
+// index of its point.
+const yieldDetails = "yield point "
+
+func (y Yields) enabled() bool { return y.Percent > 0 || y.Replay != nil }
+
+func (y Yields) String() string {
+	if y.Replay != nil {
+		return fmt.Sprintf("replay(%d)", len(y.Replay.points))
+	}
+	s := fmt.Sprintf("%d%% sleep=%d%%(<%v)", y.Percent, y.SleepPercent, time.Duration(y.maxSleep()))
+	if y.Priorities {
+		s += " priorities"
+	}
+	return s
+}
+
+func (y Yields) maxSleep() time.Duration {
+	if y.MaxSleep == 0 {
+		return 10 * time.Microsecond
+	}
+	return time.Duration(y.MaxSleep)
+}
+
+func (y Yields) validate() error {
+	switch {
+	case y.Percent < 0 || y.Percent > 100 || y.SleepPercent < 0 || y.SleepPercent > 100:
+		return errors.New("workload: yields: percentages must be between 0 and 100")
+	case y.MaxSleep < 0:
+		return errors.New("workload: yields: max_sleep must not be negative")
+	}
+	return nil
+}
+
+// A YieldSchedule is where the workers of a round yielded: by worker and
+// yield point, a runtime.Gosched or a sleep.
+type YieldSchedule struct {
+	// points are the sleeps at the yield points, 0 for a Gosched.
+	points map[yieldPoint]time.Duration
+}
+
+type yieldPoint struct{ worker, point int }
+
+// YieldsOf returns the yield schedule of the round of h, from the
+// annotations of its yields.
+func YieldsOf(h *History) (*YieldSchedule, error) {
+	ys := &YieldSchedule{points: make(map[yieldPoint]time.Duration)}
+	for _, a := range h.Annotations {
+		if a.Tag != "" || !strings.HasPrefix(a.Details, yieldDetails) {
+			continue
+		}
+		var p int
+		if _, err := fmt.Sscanf(a.Details, yieldDetails+"%d", &p); err != nil {
+			return nil, fmt.Errorf("workload: yield of worker %d: %q: %v", a.ClientId, a.Details, err)
+		}
+		var d time.Duration
+		if s, ok := strings.CutPrefix(a.Description, "Sleep "); ok {
+			var err error
+			if d, err = time.ParseDuration(s); err != nil || d <= 0 {
+				return nil, fmt.Errorf("workload: yield of worker %d: %q is not a sleep", a.ClientId, a.Description)
+			}
+		}
+		ys.points[yieldPoint{a.ClientId, p}] = d
+	}
+	if len(ys.points) == 0 {
+		return nil, errors.New("workload: the history has no yields")
+	}
+	return ys, nil
+}
+
+// A YieldMap runs the operations of one worker on a map through yield
+// points, two for every operation, before and after it, numbered from 0
+// in the order of the operations, where it yields as its Yields say. It
+// is for one goroutine at a time.
+type YieldMap struct {
+	mapimpl.MapUnderTest
+	y           Yields
+	rng         *rand.Rand
+	percent     int
+	worker      int
+	point       int
+	clk         *recorder.Clock
+	annotations []porcupine.Annotation
+}
+
+// Wrap returns m with the yield points of worker, of workers in all, in
+// the round of seed, timing the annotations of the yields by clk.
+func (y Yields) Wrap(m mapimpl.MapUnderTest, clk *recorder.Clock, seed uint64, worker, workers int) *YieldMap {
+	ym := &YieldMap{MapUnderTest: m, y: y, rng: rand.New(rand.NewPCG(seed^yieldSalt, uint64(worker))), percent: y.Percent, worker: worker, clk: clk}
+	if y.Priorities && workers > 0 {
+		// The same permutation for every worker of the round.
+		priorities := rand.New(rand.NewPCG(seed^yieldSalt, 1<<32)).Perm(workers)
+		ym.percent = y.Percent * (workers - priorities[worker%workers]) / workers
+	}
+	return ym
+}
+
+// Annotations returns the annotations of the yields so far.
+func (ym *YieldMap) Annotations() []porcupine.Annotation { return ym.annotations }
+
+// yield is called at every yield point.
+func (ym *YieldMap) yield() {
+	p := ym.point
+	ym.point++
+	var (
+		d  time.Duration
+		ok bool
+	)
+	if ym.y.Replay != nil {
+		d, ok = ym.y.Replay.points[yieldPoint{ym.worker, p}]
+	} else if ok = ym.rng.IntN(100) < ym.percent; ok && ym.rng.IntN(100) < ym.y.SleepPercent {
+		d = time.Duration(ym.rng.Int64N(int64(ym.y.maxSleep())) + 1)
+	}
+	if !ok {
+		return
+	}
+	at, desc := ym.clk.Now(), "Gosched"
+	if d > 0 {
+		desc = "Sleep " + d.String()
+		time.Sleep(d)
+	} else {
+		runtime.Gosched()
+	}
+	ym.annotations = append(ym.annotations, porcupine.Annotation{
+		ClientId:        ym.worker,
+		Start:           at,
+		End:             ym.clk.Now(),
+		Description:     desc,
+		Details:         fmt.Sprintf("%s%d", yieldDetails, p),
+		BackgroundColor: "#d6e4f0",
+	})
+}
+
+func (ym *YieldMap) Load(key any) (any, bool) {
+	ym.yield()
+	defer ym.yield()
+	return ym.MapUnderTest.Load(key)
+}
+
+func (ym *YieldMap) Store(key, value any) {
+	ym.yield()
+	defer ym.yield()
+	ym.MapUnderTest.Store(key, value)
+}
+
+func (ym *YieldMap) LoadOrStore(key, value any) (any, bool) {
+	ym.yield()
+	defer ym.yield()
+	return ym.MapUnderTest.LoadOrStore(key, value)
+}
+
+func (ym *YieldMap) LoadAndDelete(key any) (any, bool) {
+	ym.yield()
+	defer ym.yield()
+	return ym.MapUnderTest.LoadAndDelete(key)
+}
+
+func (ym *YieldMap) Delete(key any) {
+	ym.yield()
+	defer ym.yield()
+	ym.MapUnderTest.Delete(key)
+}
+
+func (ym *YieldMap) Swap(key, value any) (any, bool) {
+	ym.yield()
+	defer ym.yield()
+	return ym.MapUnderTest.Swap(key, value)
+}
+
+func (ym *YieldMap) CompareAndSwap(key, old, new any) bool {
+	ym.yield()
+	defer ym.yield()
+	return ym.MapUnderTest.CompareAndSwap(key, old, new)
+}
+
+func (ym *YieldMap) CompareAndDelete(key, old any) bool {
+	ym.yield()
+	defer ym.yield()
+	return ym.MapUnderTest.CompareAndDelete(key, old)
+}
+
+func (ym *YieldMap) Range(f func(key, value any) bool) {
+	ym.yield()
+	defer ym.yield()
+	ym.MapUnderTest.Range(f)
+}
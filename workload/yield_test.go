@@ -0,0 +1,79 @@
+package workload
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+
+	"github.com/jmasters-git/porcupine-syncmap/mapimpl"
+	"github.com/jmasters-git/porcupine-syncmap/recorder"
+)
+
+func TestYields(t *testing.T) {
+	s := Default()
+	s.Workers, s.Ops = 3, 100
+	s.Yields = Yields{Percent: 30, SleepPercent: 10, MaxSleep: Duration(5 * time.Microsecond)}
+	impl, _ := mapimpl.Lookup(s.Impl)
+	h := s.Round(impl.New(), 5, nil)
+	if r := Check(h, nil, 0); r.Check != porcupine.Ok {
+		t.Errorf("check = %s", r.Check)
+	}
+	ys, err := YieldsOf(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sleeps int
+	for _, d := range ys.points {
+		if d > 0 {
+			sleeps++
+		}
+	}
+	// Of 600 points, 30% yield and 10% of those sleep.
+	if n := len(ys.points); n < 100 || n > 260 || sleeps == 0 || sleeps == n {
+		t.Errorf("%d yields, %d of them sleeps, of 600 points", n, sleeps)
+	}
+
+	// The seed fixes the yields, and a replay yields exactly there.
+	again, err := YieldsOf(s.Round(impl.New(), 5, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(ys.points, again.points) {
+		t.Error("the seed yielded elsewhere the second time")
+	}
+	s.Yields = Yields{Replay: ys}
+	replayed, err := YieldsOf(s.Round(impl.New(), 6, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(ys.points, replayed.points) {
+		t.Error("the replay yielded elsewhere")
+	}
+	if got := s.String(); !strings.Contains(got, " yields=replay(") {
+		t.Errorf("spec %s", got)
+	}
+
+	if _, err := YieldsOf(&History{}); err == nil {
+		t.Error("YieldsOf found yields in an empty history")
+	}
+	if _, err := Parse(strings.NewReader(`{"yields": {"percent": 101}}`)); err == nil {
+		t.Error("Parse accepted a percent of 101")
+	}
+}
+
+func TestYieldPriorities(t *testing.T) {
+	y := Yields{Percent: 100, Priorities: true}
+	clk := recorder.NewClock(recorder.ClockTime)
+	var percents []int
+	for w := range 4 {
+		percents = append(percents, y.Wrap(nil, clk, 9, w, 4).percent)
+	}
+	slices.Sort(percents)
+	if !slices.Equal(percents, []int{25, 50, 75, 100}) {
+		t.Errorf("percents %v, want one worker at each of 25, 50, 75 and 100", percents)
+	}
+}